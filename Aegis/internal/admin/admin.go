@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"gatekeeper/internal/circuit"
+	"gatekeeper/internal/config"
+	"gatekeeper/internal/router"
+)
+
+// Gateway ist die Sicht der Admin-API auf den laufenden Server
+type Gateway interface {
+	Config() *config.GatewayConfig // aktive Konfiguration (nur lesen)
+	Routes() []router.RouteInfo
+	ConfigState() config.SyncState
+	RouteStatus() []router.RouteStatus
+	Reload(force bool) config.ReloadStatus
+}
+
+type routesResponse struct {
+	GlobalMiddleware []string           `json:"global_middleware"`
+	Routes           []router.RouteInfo `json:"routes"`
+}
+
+type breakerResponse struct {
+	circuit.Status
+	Routes []string `json:"routes"` // Routen, die den Breaker nutzen (leer = nicht mehr aktiv)
+}
+
+type forceRequest struct {
+	Name  string `json:"name"`
+	State string `json:"state"` // open oder closed
+}
+
+type configResponse struct {
+	State       config.SyncState     `json:"state"`
+	RouteStatus []router.RouteStatus `json:"route_status"`
+}
+
+// Handler liefert die Admin-API am Metrik-Port (unter /admin/). Aufrufer
+// authentifizieren sich mit "Authorization: Bearer <AEGIS_ADMIN_TOKEN>".
+//
+//	GET  /admin/routes            aktive Routen mit ihrer Middleware-Kette
+//	GET  /admin/circuit-breakers  Zustand aller Breaker
+//	POST /admin/circuit-breakers  Zustand setzen ({"name": ..., "state": "open|closed"})
+//	POST /admin/reload            Konfiguration sofort laden (?force=true: auch ohne Änderung)
+//	GET  /admin/context-map       Host -> Projekt
+//	GET  /admin/config            Config-Version, Sync- und Reload-Status, Status der Routen
+func Handler(gw Gateway, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		routes := gw.Routes()
+		// Statische und globale Routen zuerst, dann nach Projekt
+		slices.SortStableFunc(routes, func(a, b router.RouteInfo) int {
+			return cmp.Compare(a.ProjectID, b.ProjectID)
+		})
+		writeJSON(w, http.StatusOK, routesResponse{
+			GlobalMiddleware: router.GlobalMiddleware(gw.Config()),
+			Routes:           routes,
+		})
+	})
+	mux.HandleFunc("GET /admin/circuit-breakers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, breakerStatuses(gw))
+	})
+	mux.HandleFunc("POST /admin/circuit-breakers", func(w http.ResponseWriter, r *http.Request) {
+		var req forceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Ungültiger JSON Body", http.StatusBadRequest)
+			return
+		}
+		state, ok := circuit.ParseState(req.State)
+		if !ok {
+			http.Error(w, "Unbekannter Zustand (open, closed)", http.StatusBadRequest)
+			return
+		}
+		err := circuit.Force(req.Name, state)
+		if errors.Is(err, circuit.ErrUnknownBreaker) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(r.Context(), "Admin-API: Circuit Breaker von Hand gesetzt", "name", req.Name, "state", req.State, "remote_addr", r.RemoteAddr)
+		for _, b := range breakerStatuses(gw) {
+			if b.Name == req.Name {
+				writeJSON(w, http.StatusOK, b)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		force := false
+		if v := r.URL.Query().Get("force"); v != "" {
+			var err error
+			if force, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "Ungültiger Wert für force", http.StatusBadRequest)
+				return
+			}
+		}
+		slog.InfoContext(r.Context(), "Admin-API: Reload angefordert", "force", force, "remote_addr", r.RemoteAddr)
+		status := gw.Reload(force)
+		code := http.StatusOK
+		if status.Result == config.ReloadFailed {
+			code = http.StatusBadGateway // Athena nicht erreichbar oder Antwort unbrauchbar
+		}
+		writeJSON(w, code, status)
+	})
+	mux.HandleFunc("GET /admin/context-map", func(w http.ResponseWriter, r *http.Request) {
+		contextMap := gw.Config().ContextMap
+		if contextMap == nil {
+			contextMap = map[string]string{}
+		}
+		writeJSON(w, http.StatusOK, contextMap)
+	})
+	mux.HandleFunc("GET /admin/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, configResponse{State: gw.ConfigState(), RouteStatus: gw.RouteStatus()})
+	})
+	return requireToken(token, mux)
+}
+
+// requireToken prüft das Bearer-Token der Admin-API
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			slog.WarnContext(r.Context(), "Ungültiger Zugriffsversuch (Admin-API)", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="aegis-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// breakerStatuses ergänzt die Breaker um die Routen, die sie aktuell nutzen
+func breakerStatuses(gw Gateway) []breakerResponse {
+	usedBy := make(map[string][]string)
+	for _, route := range gw.Routes() {
+		if route.CircuitBreaker == "" {
+			continue
+		}
+		name := route.ID
+		if name == "" {
+			name = route.Path
+		}
+		usedBy[route.CircuitBreaker] = append(usedBy[route.CircuitBreaker], name)
+	}
+
+	statuses := circuit.Statuses()
+	resp := make([]breakerResponse, 0, len(statuses))
+	for _, st := range statuses {
+		routes := usedBy[st.Name]
+		slices.Sort(routes)
+		if routes == nil {
+			routes = []string{}
+		}
+		resp = append(resp, breakerResponse{Status: st, Routes: routes})
+	}
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
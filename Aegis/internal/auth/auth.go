@@ -2,7 +2,6 @@ package auth
 
 import (
 	"context"
-	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"strings"
@@ -14,6 +13,8 @@ import (
 type CustomClaims struct {
 	UserID	string 		`json:"user_id"`
 	Roles 	[]string 	`json:"roles"`
+	Plan	string		`json:"plan,omitempty"` // Plan im Projekt (Rate-Limit-Tiers)
+	ProjectID string	`json:"project_id,omitempty"` // Projekt, für das Rollen und Plan gelten
 	jwt.RegisteredClaims
 }
 
@@ -22,9 +23,10 @@ type UserDataKey string
 const ContextKey UserDataKey = "UserData"
 
 
-func ValidateToken(tokenString string, publicKey *rsa.PublicKey) (*CustomClaims, error) {
+// ValidateToken prüft das Token mit dem Schlüssel, der zur kid im Header passt
+func ValidateToken(tokenString string, keys KeyProvider) (*CustomClaims, error) {
 
-	if publicKey == nil {
+	if keys == nil {
 		return nil, fmt.Errorf("interner fehler: öffentlicher schlüssel nicht geladen")
 	}
 
@@ -32,7 +34,8 @@ func ValidateToken(tokenString string, publicKey *rsa.PublicKey) (*CustomClaims,
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unerwarteter Signaturalgorithmus: %v, erwartet RS256", token.Header["alg"])
 		}
-		return publicKey, nil
+		kid, _ := token.Header["kid"].(string)
+		return keys.PublicKey(kid)
 	}, jwt.WithLeeway(5*time.Second))
 
 	if err != nil {
@@ -46,17 +49,24 @@ func ValidateToken(tokenString string, publicKey *rsa.PublicKey) (*CustomClaims,
 	return nil, fmt.Errorf("ungültiges oder abgelaufenes Token")
 }
 
-func AuthMiddleware(publicKey *rsa.PublicKey) func(http.Handler) http.Handler {
+func AuthMiddleware(keys KeyProvider) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			// Ohne Token genügt ein geprüftes Client-Zertifikat (ein Token hat Vorrang)
+			if authHeader == "" {
+				if _, ok := certClaims(r); ok {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 				http.Error(w, "Fehlendes oder ungültiges 'Authorization: Bearer' Header", http.StatusUnauthorized)
 				return
 			}
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-			claims, err := ValidateToken(tokenString, publicKey)
+			claims, err := ValidateToken(tokenString, keys)
 			if err != nil {
 				http.Error(w, fmt.Sprintf("Authentifizierung fehlgeschlagen: %v", err), http.StatusUnauthorized)
 				return
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Felder des Client-Zertifikats, aus denen die Identität gelesen wird
+const (
+	IdentityCN       = "cn"
+	IdentitySANDNS   = "san_dns"
+	IdentitySANURI   = "san_uri"
+	IdentitySANEmail = "san_email"
+)
+
+// ValidIdentityField meldet, ob field ein bekanntes Identitätsfeld ist (leer = cn)
+func ValidIdentityField(field string) bool {
+	switch field {
+	case "", IdentityCN, IdentitySANDNS, IdentitySANURI, IdentitySANEmail:
+		return true
+	}
+	return false
+}
+
+// CertIdentity liest die Identität aus dem Zertifikat (bei SANs der erste Eintrag)
+func CertIdentity(cert *x509.Certificate, field string) string {
+	switch field {
+	case IdentitySANDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case IdentitySANURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case IdentitySANEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// ClientCertMiddleware legt für Requests mit geprüftem Client-Zertifikat Claims in
+// den Kontext: UserID ist die Identität aus field, die Rollen sind die OUs des
+// Subjects. ACLMiddleware prüft sie wie die Claims eines JWT.
+func ClientCertMiddleware(field string) func(http.Handler) http.Handler {
+	if field == "" {
+		field = IdentityCN
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Nur vom Listener verifizierte Ketten zählen
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cert := r.TLS.VerifiedChains[0][0]
+			identity := CertIdentity(cert, field)
+			if identity == "" {
+				slog.WarnContext(r.Context(), "Client-Zertifikat ohne Identität", "field", field, "subject", cert.Subject.String())
+				http.Error(w, fmt.Sprintf("Client-Zertifikat enthält kein Feld '%s'.", field), http.StatusUnauthorized)
+				return
+			}
+
+			claims := &CustomClaims{UserID: identity, Roles: cert.Subject.OrganizationalUnit}
+			claims.Subject = identity
+			ctx := context.WithValue(r.Context(), ContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// certClaims liefert die Claims aus einem Client-Zertifikat, falls vorhanden
+func certClaims(r *http.Request) (*CustomClaims, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, false
+	}
+	claims, ok := r.Context().Value(ContextKey).(*CustomClaims)
+	return claims, ok
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKeyID = errors.New("unbekannte Key-ID")
+
+// KeyProvider liefert den öffentlichen Schlüssel zu einer Key-ID (kid)
+type KeyProvider interface {
+	PublicKey(kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeyProvider nutzt einen festen Schlüssel (PEM-Datei) und ignoriert die kid
+type StaticKeyProvider struct {
+	Key *rsa.PublicKey
+}
+
+func (p *StaticKeyProvider) PublicKey(kid string) (*rsa.PublicKey, error) {
+	if p == nil || p.Key == nil {
+		return nil, fmt.Errorf("interner fehler: öffentlicher schlüssel nicht geladen")
+	}
+	return p.Key, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKSProvider lädt die Schlüssel von Athenas /.well-known/jwks.json und cached sie.
+// Bei einer unbekannten kid wird (gedrosselt) sofort neu geladen, damit
+// Schlüsselrotationen ohne Downtime greifen.
+type JWKSProvider struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	minRefreshGap   time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	document    []byte // zuletzt geladenes JWKS (für den Config-Snapshot)
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	onChange    func()
+
+	refreshMu sync.Mutex // Verhindert parallele Fetches
+}
+
+func NewJWKSProvider(url string, refreshInterval time.Duration) *JWKSProvider {
+	if refreshInterval <= 0 {
+		refreshInterval = 5 * time.Minute
+	}
+	return &JWKSProvider{
+		url:             url,
+		client:          &http.Client{Timeout: 5 * time.Second},
+		refreshInterval: refreshInterval,
+		minRefreshGap:   10 * time.Second,
+		keys:            make(map[string]*rsa.PublicKey),
+	}
+}
+
+// PublicKey liefert den Schlüssel zur kid. Tokens ohne kid werden nur
+// akzeptiert, wenn das JWKS genau einen Schlüssel enthält.
+func (p *JWKSProvider) PublicKey(kid string) (*rsa.PublicKey, error) {
+	key, stale, err := p.lookup(kid)
+	if err == nil && !stale {
+		return key, nil
+	}
+
+	// Veraltet oder unbekannte kid: neu laden (gedrosselt)
+	if refreshErr := p.refresh(context.Background(), false); refreshErr != nil {
+		slog.Warn("JWKS konnte nicht aktualisiert werden", "url", p.url, "error", refreshErr)
+		if key != nil {
+			return key, nil // Letzter bekannter Stand bleibt gültig
+		}
+	}
+
+	key, _, err = p.lookup(kid)
+	return key, err
+}
+
+func (p *JWKSProvider) lookup(kid string) (*rsa.PublicKey, bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stale := time.Since(p.fetchedAt) > p.refreshInterval
+
+	if kid == "" {
+		if len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key, stale, nil
+			}
+		}
+		return nil, stale, fmt.Errorf("token ohne Key-ID (kid), JWKS enthält %d Schlüssel", len(p.keys))
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, stale, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+	return key, stale, nil
+}
+
+// Preload übernimmt ein gespeichertes JWKS (Config-Snapshot), solange Athena nicht
+// erreichbar ist. Die Schlüssel gelten als veraltet, jede Anfrage versucht
+// (gedrosselt) neu zu laden.
+func (p *JWKSProvider) Preload(document []byte) error {
+	keys, err := parseJWKS(document)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.document = document
+	p.fetchedAt = time.Time{}
+	p.mu.Unlock()
+	slog.Info("JWKS aus dem Config-Snapshot übernommen", "keys", len(keys))
+	return nil
+}
+
+// Document liefert das zuletzt geladene JWKS (nil = noch keins)
+func (p *JWKSProvider) Document() []byte {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.document
+}
+
+// OnChange registriert fn für geänderte JWKS-Dokumente (z.B. nach einer Rotation).
+// fn läuft im Hintergrund.
+func (p *JWKSProvider) OnChange(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = fn
+}
+
+// Refresh lädt das JWKS sofort (z.B. beim Start)
+func (p *JWKSProvider) Refresh(ctx context.Context) error {
+	return p.refresh(ctx, true)
+}
+
+func (p *JWKSProvider) refresh(ctx context.Context, force bool) error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	p.mu.RLock()
+	lastAttempt := p.lastAttempt
+	p.mu.RUnlock()
+	if !force && time.Since(lastAttempt) < p.minRefreshGap {
+		return nil
+	}
+
+	p.mu.Lock()
+	p.lastAttempt = time.Now()
+	p.mu.Unlock()
+
+	keys, document, err := p.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	changed := !bytes.Equal(document, p.document)
+	p.keys = keys
+	p.document = document
+	p.fetchedAt = time.Now()
+	onChange := p.onChange
+	p.mu.Unlock()
+
+	if changed && onChange != nil {
+		go onChange()
+	}
+
+	slog.Info("JWKS erfolgreich geladen", "url", p.url, "keys", len(keys))
+	return nil
+}
+
+func (p *JWKSProvider) fetch(ctx context.Context) (map[string]*rsa.PublicKey, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fehler beim Erstellen der JWKS-Anfrage: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fehler beim Abrufen des JWKS (%s): %w", p.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("JWKS-Endpunkt (%s) hat mit Status %d geantwortet", p.url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("fehler beim Lesen des JWKS: %w", err)
+	}
+
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w (%s)", err, p.url)
+	}
+	return keys, body, nil
+}
+
+// parseJWKS liest die RSA-Signaturschlüssel eines JWKS-Dokuments
+func parseJWKS(document []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(document, &set); err != nil {
+		return nil, fmt.Errorf("fehler beim Parsen des JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAJWK(k)
+		if err != nil {
+			slog.Warn("Ungültiger Schlüssel im JWKS übersprungen", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS enthält keine gültigen RSA-Schlüssel")
+	}
+	return keys, nil
+}
+
+func parseRSAJWK(k jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiger Modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiger Exponent: %w", err)
+	}
+	e := new(big.Int).SetBytes(eBytes)
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("ungültiger Exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(e.Int64())}, nil
+}
@@ -0,0 +1,253 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// --- Strategien ---
+type Strategy string
+
+const (
+	RoundRobin       Strategy = "round_robin"
+	LeastConnections Strategy = "least_connections"
+	RandomTwoChoices Strategy = "random_two_choices"
+)
+
+var ErrNoBackend = errors.New("kein verfügbares Upstream-Ziel")
+
+// Target ist die Konfiguration eines Upstreams (URL + Gewicht)
+type Target struct {
+	URL    string
+	Weight int
+}
+
+// Backend ist eine Upstream-Instanz. Der Zustand (aktive Verbindungen)
+// wird über die Registry zwischen Routen und Hot Reloads geteilt.
+type Backend struct {
+	URL       *url.URL
+	active    atomic.Int64
+	unhealthy atomic.Bool // Wird vom Health Checker gesetzt
+}
+
+func (b *Backend) Acquire() { b.active.Add(1) }
+func (b *Backend) Release() { b.active.Add(-1) }
+
+func (b *Backend) ActiveConnections() int64 {
+	return b.active.Load()
+}
+
+// Healthy meldet, ob das Backend in Rotation ist
+func (b *Backend) Healthy() bool {
+	return !b.unhealthy.Load()
+}
+
+func (b *Backend) SetHealthy(healthy bool) {
+	b.unhealthy.Store(!healthy)
+}
+
+var BackendRegistry = struct {
+	sync.Mutex
+	backends map[string]*Backend
+}{
+	backends: make(map[string]*Backend),
+}
+
+// GetBackend liefert das (geteilte) Backend für eine URL
+func GetBackend(rawURL string) (*Backend, error) {
+	BackendRegistry.Lock()
+	defer BackendRegistry.Unlock()
+
+	if b, ok := BackendRegistry.backends[rawURL]; ok {
+		return b, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("ungültige Upstream-URL '%s'", rawURL)
+	}
+
+	b := &Backend{URL: u}
+	BackendRegistry.backends[rawURL] = b
+	return b, nil
+}
+
+// Prune entfernt alle Backends, die keine aktive Route mehr nutzt. Sonst wüchse die
+// Registry mit jeder geänderten Upstream-URL. Laufende Requests behalten ihr Backend;
+// kommt eine URL später wieder, beginnt sie mit frischem Zustand.
+func Prune(keep []*Backend) int {
+	BackendRegistry.Lock()
+	defer BackendRegistry.Unlock()
+
+	used := make(map[*Backend]bool, len(keep))
+	for _, b := range keep {
+		used[b] = true
+	}
+	removed := 0
+	for rawURL, b := range BackendRegistry.backends {
+		if !used[b] {
+			delete(BackendRegistry.backends, rawURL)
+			removed++
+		}
+	}
+	return removed
+}
+
+type entry struct {
+	backend *Backend
+	weight  int
+	current int // Nur für Smooth Weighted Round Robin
+}
+
+// Pool wählt pro Request ein Backend gemäß der Strategie aus
+type Pool struct {
+	Strategy Strategy
+	entries  []*entry
+	mu       sync.Mutex
+}
+
+func NewPool(strategy string, targets []Target) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("mindestens ein Upstream-Ziel erforderlich")
+	}
+
+	s := Strategy(strategy)
+	switch s {
+	case "":
+		s = RoundRobin
+	case RoundRobin, LeastConnections, RandomTwoChoices:
+	default:
+		return nil, fmt.Errorf("unbekannte Load-Balancing-Strategie '%s'", strategy)
+	}
+
+	p := &Pool{Strategy: s}
+	for _, t := range targets {
+		b, err := GetBackend(t.URL)
+		if err != nil {
+			return nil, err
+		}
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		p.entries = append(p.entries, &entry{backend: b, weight: weight})
+	}
+	return p, nil
+}
+
+// Backends liefert alle Backends des Pools
+func (p *Pool) Backends() []*Backend {
+	backends := make([]*Backend, 0, len(p.entries))
+	for _, e := range p.entries {
+		backends = append(backends, e.backend)
+	}
+	return backends
+}
+
+// Next wählt das nächste Backend aus
+func (p *Pool) Next() (*Backend, error) {
+	return p.NextExcluding(nil)
+}
+
+// NextExcluding wählt ein Backend, das nicht in tried enthalten ist (für Retries).
+// Sind alle gesunden Backends bereits versucht, wird wieder aus allen gewählt.
+func (p *Pool) NextExcluding(tried []*Backend) (*Backend, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Nur gesunde Backends sind in Rotation
+	healthy := make([]*entry, 0, len(p.entries))
+	candidates := make([]*entry, 0, len(p.entries))
+	for _, e := range p.entries {
+		if !e.backend.Healthy() {
+			continue
+		}
+		healthy = append(healthy, e)
+		if !slices.Contains(tried, e.backend) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = healthy
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoBackend
+	}
+	if len(candidates) == 1 {
+		return candidates[0].backend, nil
+	}
+
+	switch p.Strategy {
+	case LeastConnections:
+		return leastConnections(candidates).backend, nil
+	case RandomTwoChoices:
+		return randomTwoChoices(candidates).backend, nil
+	default:
+		return smoothWeightedRoundRobin(candidates).backend, nil
+	}
+}
+
+// smoothWeightedRoundRobin (Nginx-Algorithmus): verteilt gemäß Gewicht ohne Bursts
+func smoothWeightedRoundRobin(candidates []*entry) *entry {
+	total := 0
+	var best *entry
+	for _, e := range candidates {
+		e.current += e.weight
+		total += e.weight
+		if best == nil || e.current > best.current {
+			best = e
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// less vergleicht die gewichtete Last (aktive Verbindungen / Gewicht)
+func less(a, b *entry) bool {
+	return a.backend.ActiveConnections()*int64(b.weight) < b.backend.ActiveConnections()*int64(a.weight)
+}
+
+func leastConnections(candidates []*entry) *entry {
+	best := candidates[0]
+	for _, e := range candidates[1:] {
+		if less(e, best) {
+			best = e
+		}
+	}
+	return best
+}
+
+// randomTwoChoices zieht zwei Kandidaten (gewichtet) und nimmt den weniger belasteten
+func randomTwoChoices(candidates []*entry) *entry {
+	a := weightedRandom(candidates, nil)
+	b := weightedRandom(candidates, a)
+	if less(b, a) {
+		return b
+	}
+	return a
+}
+
+func weightedRandom(candidates []*entry, exclude *entry) *entry {
+	total := 0
+	for _, e := range candidates {
+		if e != exclude {
+			total += e.weight
+		}
+	}
+	n := rand.IntN(total)
+	for _, e := range candidates {
+		if e == exclude {
+			continue
+		}
+		n -= e.weight
+		if n < 0 {
+			return e
+		}
+	}
+	return candidates[0]
+}
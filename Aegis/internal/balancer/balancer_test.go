@@ -0,0 +1,171 @@
+package balancer
+
+import (
+	"errors"
+	"testing"
+)
+
+// Die Registry ist global: jeder Test nutzt eigene Hosts, damit Zustand
+// (Verbindungen, Health) nicht zwischen Tests durchschlägt.
+func testPool(t *testing.T, strategy string, targets ...Target) *Pool {
+	t.Helper()
+	pool, err := NewPool(strategy, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func pick(t *testing.T, pool *Pool, tried ...*Backend) string {
+	t.Helper()
+	b, err := pool.NextExcluding(tried)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.URL.Host
+}
+
+func TestSmoothWeightedRoundRobin(t *testing.T) {
+	pool := testPool(t, "",
+		Target{URL: "http://swrr-a", Weight: 5},
+		Target{URL: "http://swrr-b", Weight: 1},
+		Target{URL: "http://swrr-c", Weight: 1},
+	)
+	// Nginx-Reihenfolge: das schwere Ziel wird verteilt statt am Stück gewählt
+	want := []string{"swrr-a", "swrr-a", "swrr-b", "swrr-a", "swrr-c", "swrr-a", "swrr-a"}
+	for round := 0; round < 2; round++ {
+		for i, host := range want {
+			if got := pick(t, pool); got != host {
+				t.Fatalf("Runde %d, Request %d: %s, erwartet %s", round, i, got, host)
+			}
+		}
+	}
+}
+
+func TestUnhealthyBackendsLeaveRotation(t *testing.T) {
+	pool := testPool(t, "round_robin",
+		Target{URL: "http://health-a"},
+		Target{URL: "http://health-b"},
+	)
+	a, b := pool.Backends()[0], pool.Backends()[1]
+
+	a.SetHealthy(false)
+	for i := 0; i < 3; i++ {
+		if got := pick(t, pool); got != "health-b" {
+			t.Fatalf("Request %d ging an %s trotz ungesundem Ziel", i, got)
+		}
+	}
+
+	b.SetHealthy(false)
+	if _, err := pool.Next(); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("Fehler = %v, erwartet ErrNoBackend", err)
+	}
+
+	a.SetHealthy(true)
+	b.SetHealthy(true)
+	if got := pick(t, pool); got == "" {
+		t.Fatal("kein Ziel nach Erholung")
+	}
+}
+
+func TestNextExcludingTried(t *testing.T) {
+	pool := testPool(t, "round_robin",
+		Target{URL: "http://retry-a", Weight: 10},
+		Target{URL: "http://retry-b", Weight: 1},
+	)
+	a, b := pool.Backends()[0], pool.Backends()[1]
+
+	if got := pick(t, pool, a); got != "retry-b" {
+		t.Fatalf("Retry ging an %s, erwartet das noch nicht versuchte Ziel", got)
+	}
+	// Alle versucht: wieder aus allen gesunden Zielen wählen statt abzubrechen
+	if _, err := pool.NextExcluding([]*Backend{a, b}); err != nil {
+		t.Fatalf("alle versucht: %v", err)
+	}
+}
+
+func TestLeastConnectionsWeighted(t *testing.T) {
+	pool := testPool(t, "least_connections",
+		Target{URL: "http://lc-a", Weight: 3},
+		Target{URL: "http://lc-b", Weight: 1},
+	)
+	a, b := pool.Backends()[0], pool.Backends()[1]
+
+	// a: 2 Verbindungen bei Gewicht 3 ist weniger Last als b: 1 bei Gewicht 1
+	a.Acquire()
+	a.Acquire()
+	b.Acquire()
+	defer func() { a.Release(); a.Release(); b.Release() }()
+	if got := pick(t, pool); got != "lc-a" {
+		t.Fatalf("gewählt %s, erwartet lc-a", got)
+	}
+
+	a.Acquire()
+	a.Acquire()
+	defer func() { a.Release(); a.Release() }()
+	if got := pick(t, pool); got != "lc-b" {
+		t.Fatalf("gewählt %s, erwartet lc-b", got)
+	}
+}
+
+func TestRandomTwoChoicesPrefersLessLoaded(t *testing.T) {
+	pool := testPool(t, "random_two_choices",
+		Target{URL: "http://p2c-a"},
+		Target{URL: "http://p2c-b"},
+	)
+	a := pool.Backends()[0]
+	a.Acquire()
+	defer a.Release()
+	// Mit zwei Kandidaten werden immer beide gezogen
+	for i := 0; i < 20; i++ {
+		if got := pick(t, pool); got != "p2c-b" {
+			t.Fatalf("Request %d ging an das belastete Ziel %s", i, got)
+		}
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		targets  []Target
+		wantErr  bool
+	}{
+		{"Default-Strategie", "", []Target{{URL: "http://new-a"}}, false},
+		{"unbekannte Strategie", "fastest", []Target{{URL: "http://new-a"}}, true},
+		{"ohne Ziele", "round_robin", nil, true},
+		{"URL ohne Host", "round_robin", []Target{{URL: "new-a"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPool(tt.strategy, tt.targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fehler = %v, erwartet Fehler: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackendsSharedAcrossPools(t *testing.T) {
+	first := testPool(t, "", Target{URL: "http://shared-a"})
+	second := testPool(t, "least_connections", Target{URL: "http://shared-a", Weight: 4})
+	if first.Backends()[0] != second.Backends()[0] {
+		t.Fatal("Routen mit gleichem Upstream teilen sich kein Backend")
+	}
+}
+
+func TestPruneRemovesUnusedBackends(t *testing.T) {
+	pool := testPool(t, "", Target{URL: "http://prune-a"}, Target{URL: "http://prune-b"})
+	a, b := pool.Backends()[0], pool.Backends()[1]
+
+	if removed := Prune([]*Backend{a}); removed < 1 {
+		t.Fatalf("%d Backends entfernt, erwartet mindestens prune-b", removed)
+	}
+	if got, _ := GetBackend("http://prune-a"); got != a {
+		t.Fatal("genutztes Backend wurde entfernt")
+	}
+	// Eine zurückkehrende URL beginnt mit frischem Zustand
+	if got, _ := GetBackend("http://prune-b"); got == b {
+		t.Fatal("ungenutztes Backend ist noch registriert")
+	}
+}
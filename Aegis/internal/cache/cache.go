@@ -1,104 +1,442 @@
 package cache
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"gatekeeper/internal/auth"
+
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	maxBodySize        = 1 << 20          // Größere Antworten werden nicht gespeichert
+	maxEntryTTL        = 24 * time.Hour   // Obergrenze für die Lebensdauer in Redis
+	validatorRetention = 5 * time.Minute  // Abgelaufene Einträge mit ETag/Last-Modified bleiben für Revalidierung
+	revalidateTimeout  = 30 * time.Second // Sperre und Timeout der Hintergrund-Revalidierung
+)
+
+// Policy beschreibt das Caching einer Route
+type Policy struct {
+	DefaultTTL time.Duration // Frische, wenn der Upstream keine angibt (cache_ttl der Route)
+	Scope      string        // Namespace der Einträge, z.B. die Projekt-ID
+	Tags       []string      // Tags für den Purge, z.B. RouteTag der Route
+}
+
+// CachedResponse ist ein gespeicherter Eintrag inkl. der Angaben zur Frische
 type CachedResponse struct {
-	Status 	int
-	Header 	http.Header
-	Body	[]byte
+	Status               int
+	Header               http.Header
+	Body                 []byte
+	Mode                 string // shared oder private
+	StoredAt             time.Time
+	InitialAge           time.Duration // Age des Upstreams beim Speichern
+	Freshness            time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	SurrogateKeys        []string // Aus dem Surrogate-Key-Header des Upstreams
+}
+
+func newEntry(status int, h http.Header, body []byte, mode string, defaultTTL time.Duration, now time.Time) *CachedResponse {
+	entry := &CachedResponse{
+		Status:        status,
+		Header:        storableHeader(h),
+		Body:          body,
+		Mode:          mode,
+		StoredAt:      now,
+		InitialAge:    ageHeader(h),
+		Freshness:     freshnessLifetime(h, defaultTTL, mode),
+		SurrogateKeys: surrogateKeys(h),
+	}
+	entry.StaleWhileRevalidate, entry.StaleIfError = staleWindows(h)
+	return entry
+}
+
+func (c *CachedResponse) age(now time.Time) time.Duration {
+	return c.InitialAge + max(now.Sub(c.StoredAt), 0)
+}
+
+func (c *CachedResponse) hasValidators() bool {
+	return c.Header.Get("ETag") != "" || c.Header.Get("Last-Modified") != ""
 }
 
-func GenerateCacheKey(r *http.Request) string {
-	if r.Method != http.MethodGet {
-		return ""
+// retention ist die Lebensdauer in Redis: Frische plus das längste Stale-Fenster
+func (c *CachedResponse) retention() time.Duration {
+	ttl := c.Freshness - c.InitialAge + max(c.StaleWhileRevalidate, c.StaleIfError)
+	if c.hasValidators() {
+		ttl = max(ttl, validatorRetention)
 	}
+	return min(ttl, maxEntryTTL)
+}
+
+// refreshed übernimmt die Header einer 304-Antwort in den Eintrag (RFC 9111 §4.3.4)
+func (c *CachedResponse) refreshed(h http.Header, defaultTTL time.Duration, now time.Time) *CachedResponse {
+	header := c.Header.Clone()
+	for name, values := range h {
+		if name != "Content-Length" {
+			header[name] = values
+		}
+	}
+	entry := newEntry(c.Status, header, c.Body, c.Mode, defaultTTL, now)
+	if len(entry.SurrogateKeys) == 0 {
+		entry.SurrogateKeys = c.SurrogateKeys
+	}
+	return entry
+}
+
+// variants beschreibt, nach welchen Request-Headern die Einträge einer URL variieren.
+// Eine Invalidierung löscht diesen Schlüssel; die Einträge der alten Generation sind
+// danach nicht mehr erreichbar und laufen über ihre TTL aus.
+type variants struct {
+	Vary []string `json:"vary"`
+	Gen  string   `json:"gen"`
+}
+
+func newGeneration() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// requestURI liefert Pfad und Query, wie der Client sie gesendet hat. r.URL.Path ist
+// bei "/*"-Routen bereits um den Präfix gekürzt.
+func requestURI(r *http.Request) string {
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
 
-	key := r.URL.Path + "?" + r.URL.RawQuery
+// primaryKey identifiziert die URL: Host, Projekt, Pfad und Query
+func primaryKey(scope string, r *http.Request) string {
 	hasher := sha256.New()
-	hasher.Write([]byte(key))
-	return hex.EncodeToString(hasher.Sum(nil))
+	fmt.Fprintf(hasher, "%s\n%s\n%s", r.Host, r.Header.Get("X-Project-ID"), requestURI(r))
+	return scopePrefix(scope) + hex.EncodeToString(hasher.Sum(nil))
 }
 
-type bodyCaptureWriter struct {
-	middleware.WrapResponseWriter
-	Body bytes.Buffer
+// variantKey identifiziert einen Eintrag über die Vary-Header und ggf. den Benutzer
+func variantKey(primary, gen string, vary []string, r *http.Request, owner string) string {
+	hasher := sha256.New()
+	for _, name := range vary {
+		fmt.Fprintf(hasher, "%s:%s\n", name, strings.Join(r.Header.Values(name), ","))
+	}
+	fmt.Fprintf(hasher, "owner:%s", owner)
+	return primary + ":" + gen + ":" + hex.EncodeToString(hasher.Sum(nil))
 }
 
-func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
-	w.Body.Write(b)
-	return w.WrapResponseWriter.Write(b)
+// userKey identifiziert den Aufrufer für private Einträge (UserID aus dem Token,
+// sonst ein Hash des Authorization-Headers)
+func userKey(r *http.Request) string {
+	if claims, ok := r.Context().Value(auth.ContextKey).(*auth.CustomClaims); ok {
+		return "user:" + claims.UserID
+	}
+	if authz := r.Header.Get("Authorization"); authz != "" {
+		sum := sha256.Sum256([]byte(authz))
+		return "auth:" + hex.EncodeToString(sum[:])
+	}
+	return ""
 }
 
-func CacheMiddleware(client *redis.Client, ttl time.Duration) func(http.Handler) http.Handler {
-	if ttl <= 0 {
-		return func(next http.Handler) http.Handler {return next}
+// CacheMiddleware cached GET-Antworten nach RFC 9111 (Shared Cache) in Redis.
+// Die Frische kommt aus Cache-Control/Expires des Upstreams, sonst aus policy.DefaultTTL.
+func CacheMiddleware(client *redis.Client, policy Policy) func(http.Handler) http.Handler {
+	if policy.DefaultTTL <= 0 {
+		return func(next http.Handler) http.Handler { return next }
 	}
 
 	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			
-			cacheKey := GenerateCacheKey(r)
-			if cacheKey == "" {
-				next.ServeHTTP(w, r)
-				return
-			}
+		return &cacheHandler{client: client, policy: policy, next: next}
+	}
+}
 
-			cacheData, err := client.Get(context.Background(), cacheKey).Bytes() 
-			
-			if err == nil { 
-				var resp CachedResponse
-				if err := json.Unmarshal(cacheData, &resp); err != nil {
-					log.Printf("[CACHE ERROR] Deserialisierung fehlgeschlagen: %v", err)
-				} else {
-					for k, v := range resp.Header {
-						for _, h := range v {
-							w.Header().Add(k, h)
-						}
-					}
-					w.WriteHeader(resp.Status)
-					w.Write(resp.Body)
-					log.Printf("[CACHE HIT] %s %s Status: %d, bedient aus Redis", r.Method, r.URL.Path, resp.Status)
-					return
-				}
-			}
+type cacheHandler struct {
+	client *redis.Client
+	policy Policy
+	next   http.Handler
+}
+
+func (c *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isSafeMethod(r.Method) {
+		c.serveUnsafe(w, r)
+		return
+	}
+	if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" || requestBypassesCache(r) {
+		w.Header().Set("X-Cache", "BYPASS")
+		cacheRequests.WithLabelValues("bypass").Inc()
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	primary := primaryKey(c.policy.Scope, r)
+	user := userKey(r)
+	meta, entryKey, entry := c.lookup(r.Context(), primary, r, user)
+
+	if entry == nil {
+		if requestOnlyIfCached(r) {
+			w.Header().Set("X-Cache", "MISS")
+			cacheRequests.WithLabelValues("miss").Inc()
+			http.Error(w, "Kein Eintrag im Cache", http.StatusGatewayTimeout)
+			return
+		}
+		c.fetchCoalesced(w, r, primary, meta, user, nil)
+		return
+	}
+
+	age := entry.age(time.Now())
+	if !requestRequiresRevalidation(r, age) || requestOnlyIfCached(r) {
+		if age < entry.Freshness {
+			serveEntry(w, r, entry, "HIT")
+			return
+		}
+		if age < entry.Freshness+entry.StaleWhileRevalidate {
+			serveEntry(w, r, entry, "STALE")
+			c.revalidateAsync(r, primary, meta, user, entry, entryKey)
+			return
+		}
+	}
+	c.fetchCoalesced(w, r, primary, meta, user, entry)
+}
+
+// serveUnsafe leitet POST, PUT, DELETE usw. durch und invalidiert bei Erfolg die
+// Einträge der URL (RFC 9111 §4.4)
+func (c *cacheHandler) serveUnsafe(w http.ResponseWriter, r *http.Request) {
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	c.next.ServeHTTP(ww, r)
+
+	if status := ww.Status(); status >= 200 && status < 400 {
+		primary := primaryKey(c.policy.Scope, r)
+		if err := c.client.Del(context.WithoutCancel(r.Context()), primary).Err(); err != nil {
+			log.Printf("[CACHE ERROR] Invalidierung fehlgeschlagen: %v", err)
+		}
+	}
+}
 
-			recorder := &bodyCaptureWriter{
-				WrapResponseWriter: middleware.NewWrapResponseWriter(w, r.ProtoMajor),
+// lookup sucht zuerst den privaten Eintrag des Benutzers, dann den geteilten
+func (c *cacheHandler) lookup(ctx context.Context, primary string, r *http.Request, user string) (*variants, string, *CachedResponse) {
+	raw, err := c.client.Get(ctx, primary).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[CACHE ERROR] Redis-Fehler beim Lesen: %v", err)
+		}
+		return nil, "", nil
+	}
+	var meta variants
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		log.Printf("[CACHE ERROR] Deserialisierung fehlgeschlagen: %v", err)
+		return nil, "", nil
+	}
+
+	var keys []string
+	if user != "" {
+		keys = append(keys, variantKey(primary, meta.Gen, meta.Vary, r, user))
+	}
+	keys = append(keys, variantKey(primary, meta.Gen, meta.Vary, r, ""))
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("[CACHE ERROR] Redis-Fehler beim Lesen: %v", err)
+		return &meta, "", nil
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var entry CachedResponse
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			log.Printf("[CACHE ERROR] Deserialisierung fehlgeschlagen: %v", err)
+			continue
+		}
+		return &meta, keys[i], &entry
+	}
+	return &meta, "", nil
+}
+
+// fetch holt die Antwort vom Upstream. Mit vorhandenem Eintrag wird bedingt revalidiert
+// und die Antwort zurückgehalten, bis feststeht, ob der Eintrag weiter gilt (304) oder
+// bei einem Fehler veraltet ausgeliefert werden darf (stale-if-error).
+// Liefert den ausgelieferten Eintrag, falls er mit anderen Requests geteilt werden darf.
+func (c *cacheHandler) fetch(w http.ResponseWriter, r *http.Request, primary string, meta *variants, user string, entry *CachedResponse) *CachedResponse {
+	req := r
+	if entry != nil {
+		req = conditionalRequest(r.Context(), r, entry)
+	}
+	cw := newCaptureWriter(w, entry != nil)
+	c.next.ServeHTTP(cw, req)
+
+	ctx := context.WithoutCancel(r.Context())
+	if entry != nil && !cw.passthrough {
+		now := time.Now()
+		switch {
+		case cw.statusCode() == http.StatusNotModified:
+			entry = entry.refreshed(cw.header, c.policy.DefaultTTL, now)
+			c.store(ctx, primary, meta, r, user, entry)
+			serveEntry(w, r, entry, "REVALIDATED")
+			return entry
+		case cw.statusCode() >= 500 && entry.age(now) < entry.Freshness+entry.StaleIfError:
+			log.Printf("[CACHE STALE] %s %s: Upstream-Fehler %d, liefere veralteten Eintrag", r.Method, r.URL.Path, cw.statusCode())
+			serveEntry(w, r, entry, "STALE")
+			return entry
+		}
+	}
+	cw.release()
+	return c.storeResponse(ctx, primary, meta, r, user, cw)
+}
+
+// revalidateAsync aktualisiert einen veralteten Eintrag im Hintergrund
+// (stale-while-revalidate). Eine Sperre in Redis verhindert parallele Revalidierungen.
+func (c *cacheHandler) revalidateAsync(r *http.Request, primary string, meta *variants, user string, entry *CachedResponse, entryKey string) {
+	ctx := context.WithoutCancel(r.Context())
+	lockKey := entryKey + ":revalidate"
+	acquired, err := c.client.SetNX(ctx, lockKey, 1, revalidateTimeout).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+	req := conditionalRequest(ctx, r, entry)
+	go func() {
+		defer cancel()
+		defer c.client.Del(context.WithoutCancel(ctx), lockKey)
+		defer func() {
+			// Der Reverse Proxy bricht fehlerhafte Antworten per panic ab
+			if rec := recover(); rec != nil {
+				log.Printf("[CACHE ERROR] Revalidierung von %s abgebrochen: %v", req.URL.Path, rec)
 			}
-			
-			next.ServeHTTP(recorder, r)
-
-			if recorder.Status() >= 200 && recorder.Status() < 300 {
-				
-				resp := CachedResponse{
-					Status: recorder.Status(),
-					Header: w.Header(),
-					Body:   recorder.Body.Bytes(), 
-				}
-
-				serializedData, err := json.Marshal(resp)
-				if err != nil {
-					log.Printf("[CACHE ERROR] Serialisierung fehlgeschlagen: %v", err)
-					return
-				}
-
-				client.Set(context.Background(), cacheKey, serializedData, ttl)
-				
-				log.Printf("[CACHE MISS] %s %s Status: %d, Downstream-Antwort für %v gespeichert.", 
-				r.Method, r.URL.Path, resp.Status, ttl)
+		}()
+
+		cw := newCaptureWriter(nil, true)
+		c.next.ServeHTTP(cw, req)
+		if cw.statusCode() == http.StatusNotModified {
+			c.store(ctx, primary, meta, req, user, entry.refreshed(cw.header, c.policy.DefaultTTL, time.Now()))
+			return
+		}
+		c.storeResponse(ctx, primary, meta, req, user, cw)
+	}()
+}
+
+// conditionalRequest ersetzt die Validatoren des Clients durch die des Eintrags
+func conditionalRequest(ctx context.Context, r *http.Request, entry *CachedResponse) *http.Request {
+	req := r.Clone(ctx)
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return req
+}
+
+// storeResponse speichert eine Upstream-Antwort und liefert den Eintrag (nil, wenn
+// die Antwort nicht gespeichert werden darf)
+func (c *cacheHandler) storeResponse(ctx context.Context, primary string, meta *variants, r *http.Request, user string, cw *captureWriter) *CachedResponse {
+	if cw.tooLarge {
+		return nil
+	}
+	mode := storeMode(r, cw.statusCode(), cw.header, user != "")
+	if mode == storeNone {
+		return nil
+	}
+	entry := newEntry(cw.statusCode(), cw.header, cw.body.Bytes(), mode, c.policy.DefaultTTL, time.Now())
+	c.store(ctx, primary, meta, r, user, entry)
+	return entry
+}
+
+func (c *cacheHandler) store(ctx context.Context, primary string, meta *variants, r *http.Request, user string, entry *CachedResponse) {
+	ttl := entry.retention()
+	if ttl <= 0 {
+		return
+	}
+
+	gen := newGeneration()
+	if meta != nil {
+		gen = meta.Gen
+	}
+	vary := varyHeaders(entry.Header)
+	owner := ""
+	if entry.Mode == storePrivate {
+		owner = user
+	}
+
+	metaData, err := json.Marshal(variants{Vary: vary, Gen: gen})
+	if err != nil {
+		log.Printf("[CACHE ERROR] Serialisierung fehlgeschlagen: %v", err)
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[CACHE ERROR] Serialisierung fehlgeschlagen: %v", err)
+		return
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, primary, metaData, maxEntryTTL)
+	pipe.Set(ctx, variantKey(primary, gen, vary, r, owner), data, ttl)
+	c.index(ctx, pipe, primary, r, entry)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[CACHE ERROR] Redis-Fehler beim Speichern: %v", err)
+		return
+	}
+	log.Printf("[CACHE STORE] %s %s Status: %d, %s, frisch für %v", r.Method, r.URL.Path, entry.Status, entry.Mode, entry.Freshness)
+}
+
+// serveEntry liefert einen Eintrag aus; passende Validatoren des Clients ergeben 304
+func serveEntry(w http.ResponseWriter, r *http.Request, entry *CachedResponse, label string) {
+	h := w.Header()
+	for name, values := range entry.Header {
+		h[name] = slices.Clone(values)
+	}
+	h.Set("Age", strconv.FormatInt(int64(entry.age(time.Now())/time.Second), 10))
+	h.Set("X-Cache", label)
+	cacheRequests.WithLabelValues(strings.ToLower(label)).Inc()
+
+	if notModified(r, entry) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body)
+}
+
+// notModified prüft If-None-Match (schwacher Vergleich) bzw. If-Modified-Since
+func notModified(r *http.Request, entry *CachedResponse) bool {
+	if entry.Status != http.StatusOK {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(entry.Header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
 			}
-		})
+		}
+		return false
 	}
-}
\ No newline at end of file
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(entry.Header.Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	fetchLockTTL       = 10 * time.Second // Sperre für den Upstream-Abruf über Replicas hinweg
+	remotePollInterval = 50 * time.Millisecond
+)
+
+// releaseLockScript gibt die Sperre nur frei, wenn sie noch dem Aufrufer gehört.
+// Dauert der Abruf länger als fetchLockTTL, hat inzwischen eventuell eine andere
+// Instanz die Sperre übernommen.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// flight ist ein laufender Upstream-Abruf, auf den gleichartige Requests dieses
+// Prozesses warten
+type flight struct {
+	done   chan struct{}
+	header http.Header     // Request-Header des Leaders (für den Vary-Vergleich)
+	entry  *CachedResponse // Ergebnis, falls es geteilt werden darf
+}
+
+// Globale Registry der laufenden Abrufe (Schlüssel: flightKey)
+var (
+	flightsMu sync.Mutex
+	flights   = make(map[string]*flight)
+)
+
+// flightKey fasst Requests zusammen, die dieselbe Antwort erwarten: gleiche URL,
+// gleiche Werte der bereits bekannten Vary-Header und derselbe Benutzer
+func flightKey(primary string, meta *variants, r *http.Request, user string) string {
+	var vary []string
+	if meta != nil {
+		vary = meta.Vary
+	}
+	return variantKey(primary, "flight", vary, r, user)
+}
+
+// varyMatches prüft, ob der Eintrag des Leaders auch für einen wartenden Request
+// gilt. Beim ersten Abruf einer URL ist das Vary der Antwort vorher nicht bekannt.
+func varyMatches(entry *CachedResponse, leader, waiter http.Header) bool {
+	for _, name := range varyHeaders(entry.Header) {
+		if !slices.Equal(leader.Values(name), waiter.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchCoalesced fasst gleichzeitige Abrufe derselben Antwort zusammen. Im Prozess
+// warten weitere Requests auf den ersten (Leader); über Replicas hinweg sorgt eine
+// kurze Sperre in Redis dafür, dass nur eine Instanz den Upstream fragt. Die übrigen
+// bekommen den gespeicherten Eintrag oder fragen den Upstream selbst, wenn die
+// Antwort nicht geteilt werden darf.
+func (c *cacheHandler) fetchCoalesced(w http.ResponseWriter, r *http.Request, primary string, meta *variants, user string, entry *CachedResponse) {
+	key := flightKey(primary, meta, r, user)
+
+	flightsMu.Lock()
+	if f, ok := flights[key]; ok {
+		flightsMu.Unlock()
+		select {
+		case <-f.done:
+		case <-r.Context().Done():
+			return
+		}
+		if f.entry != nil && varyMatches(f.entry, f.header, r.Header) {
+			coalescedWaits.WithLabelValues("local", "shared").Inc()
+			serveEntry(w, r, f.entry, "COALESCED")
+			return
+		}
+		coalescedWaits.WithLabelValues("local", "fallback").Inc()
+		c.fetch(w, r, primary, meta, user, entry)
+		return
+	}
+	f := &flight{done: make(chan struct{}), header: r.Header.Clone()}
+	flights[key] = f
+	flightsMu.Unlock()
+	defer func() {
+		flightsMu.Lock()
+		delete(flights, key)
+		flightsMu.Unlock()
+		close(f.done)
+	}()
+
+	ctx := context.WithoutCancel(r.Context())
+	lockKey := key + ":lock"
+	lockToken := newGeneration() // zufälliger Besitzer-Wert der Sperre
+	acquired, err := c.client.SetNX(ctx, lockKey, lockToken, fetchLockTTL).Result()
+	if err == nil && !acquired {
+		if shared := c.awaitRemote(r, primary, user, lockKey); shared != nil {
+			f.entry = shared
+			coalescedWaits.WithLabelValues("remote", "shared").Inc()
+			serveEntry(w, r, shared, "COALESCED")
+			return
+		}
+		coalescedWaits.WithLabelValues("remote", "fallback").Inc()
+	}
+	if acquired {
+		defer releaseLockScript.Run(ctx, c.client, []string{lockKey}, lockToken)
+	}
+	// Bei Redis-Fehlern ohne Sperre weiter (Fail-Open wie beim Rate Limiting)
+	f.entry = c.fetch(w, r, primary, meta, user, entry)
+}
+
+// awaitRemote wartet, bis die Instanz mit der Sperre einen frischen Eintrag
+// gespeichert hat. Liefert nil, wenn die Sperre ohne Eintrag freigegeben wird.
+func (c *cacheHandler) awaitRemote(r *http.Request, primary, user, lockKey string) *CachedResponse {
+	ctx := r.Context()
+	ticker := time.NewTicker(remotePollInterval)
+	defer ticker.Stop()
+
+	for deadline := time.Now().Add(fetchLockTTL); time.Now().Before(deadline); {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Sperre vor dem Eintrag prüfen: der Leader speichert, bevor er freigibt
+		locked, err := c.client.Exists(ctx, lockKey).Result()
+		if err != nil {
+			return nil
+		}
+		if _, _, entry := c.lookup(ctx, primary, r, user); entry != nil && entry.age(time.Now()) < entry.Freshness {
+			return entry
+		}
+		if locked == 0 {
+			return nil
+		}
+	}
+	return nil
+}
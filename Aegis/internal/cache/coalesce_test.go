@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// testUpstream zählt die Abrufe und hält sie fest, bis release geschlossen wird
+type testUpstream struct {
+	calls        atomic.Int32
+	release      chan struct{}
+	cacheControl string
+}
+
+func (u *testUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.calls.Add(1)
+	select {
+	case <-u.release:
+	case <-time.After(5 * time.Second):
+	}
+	w.Header().Set("Cache-Control", u.cacheControl)
+	w.Write([]byte("antwort"))
+}
+
+func testCache(t *testing.T, upstream http.Handler) (*miniredis.Miniredis, *cacheHandler) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	handler := CacheMiddleware(client, Policy{DefaultTTL: time.Minute, Scope: "p1"})(upstream)
+	return m, handler.(*cacheHandler)
+}
+
+// serveConcurrently schickt n gleiche GETs gleichzeitig und liefert die Antworten
+func serveConcurrently(t *testing.T, h http.Handler, upstream *testUpstream, n int) []*httptest.ResponseRecorder {
+	t.Helper()
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/data", nil))
+		}(recs[i])
+	}
+	waitFor(t, func() bool { return upstream.calls.Load() > 0 })
+	time.Sleep(20 * time.Millisecond) // die übrigen Requests reihen sich hinter dem Leader ein
+	close(upstream.release)
+	wg.Wait()
+	return recs
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("Bedingung nicht rechtzeitig erfüllt")
+}
+
+func TestCoalescedMissesShareOneFetch(t *testing.T) {
+	upstream := &testUpstream{release: make(chan struct{}), cacheControl: "max-age=60"}
+	_, h := testCache(t, upstream)
+
+	misses := 0
+	for _, rec := range serveConcurrently(t, h, upstream, 8) {
+		if rec.Code != http.StatusOK || rec.Body.String() != "antwort" {
+			t.Fatalf("status=%d body=%q", rec.Code, rec.Body.String())
+		}
+		// Wer nach dem Leader kommt, bekommt den Eintrag als COALESCED oder HIT
+		if rec.Header().Get("X-Cache") == "MISS" {
+			misses++
+		}
+	}
+	if calls := upstream.calls.Load(); calls != 1 || misses != 1 {
+		t.Fatalf("%d Upstream-Abrufe und %d MISS, erwartet je 1", calls, misses)
+	}
+}
+
+func TestCoalescedMissesFetchThemselvesWhenNotShareable(t *testing.T) {
+	upstream := &testUpstream{release: make(chan struct{}), cacheControl: "no-store"}
+	_, h := testCache(t, upstream)
+
+	serveConcurrently(t, h, upstream, 4)
+	if calls := upstream.calls.Load(); calls != 4 {
+		t.Fatalf("%d Upstream-Abrufe, erwartet 4 (no-store darf nicht geteilt werden)", calls)
+	}
+}
+
+func TestRemoteLockWaitsForOtherReplica(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    bool // die andere Replica speichert vor der Freigabe einen Eintrag
+		wantCache string
+		wantCalls int32
+	}{
+		{"Eintrag der anderen Replica", true, "COALESCED", 0},
+		{"Freigabe ohne Eintrag", false, "MISS", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &testUpstream{release: make(chan struct{}), cacheControl: "max-age=60"}
+			close(upstream.release)
+			m, h := testCache(t, upstream)
+
+			req := httptest.NewRequest(http.MethodGet, "/data", nil)
+			primary := primaryKey(h.policy.Scope, req)
+			lockKey := flightKey(primary, nil, req, "") + ":lock"
+			m.Set(lockKey, "andere-replica")
+
+			rec := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				h.ServeHTTP(rec, req)
+			}()
+
+			time.Sleep(2 * remotePollInterval)
+			if tt.stored {
+				entry := newEntry(http.StatusOK, header("Cache-Control", "max-age=60"), []byte("antwort"), storeShared, time.Minute, time.Now())
+				h.store(context.Background(), primary, nil, req, "", entry)
+			}
+			m.Del(lockKey)
+			<-done
+
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache || rec.Body.String() != "antwort" {
+				t.Fatalf("X-Cache=%q body=%q, erwartet %s", got, rec.Body.String(), tt.wantCache)
+			}
+			if calls := upstream.calls.Load(); calls != tt.wantCalls {
+				t.Fatalf("%d Upstream-Abrufe, erwartet %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFetchLockReleasedOnlyByOwner(t *testing.T) {
+	m, h := testCache(t, http.NotFoundHandler())
+	ctx := context.Background()
+	m.Set("lock", "andere-instanz")
+
+	if err := releaseLockScript.Run(ctx, h.client, []string{"lock"}, "eigene-instanz").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Exists("lock") {
+		t.Fatal("fremde Sperre wurde freigegeben")
+	}
+	if err := releaseLockScript.Run(ctx, h.client, []string{"lock"}, "andere-instanz").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Exists("lock") {
+		t.Fatal("eigene Sperre wurde nicht freigegeben")
+	}
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status-Codes, die ohne weitere Angaben gecacht werden dürfen (RFC 9110 §15.1)
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+	http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+	http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+	http.StatusRequestURITooLong, http.StatusNotImplemented,
+}
+
+// Speicherarten eines Eintrags
+const (
+	storeNone    = ""
+	storeShared  = "shared"  // für alle Aufrufer
+	storePrivate = "private" // nur für denselben Benutzer
+)
+
+// cacheControl enthält die Direktiven eines Cache-Control-Headers (Namen in Kleinbuchstaben)
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds liefert eine Delta-Seconds-Direktive (z.B. max-age)
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// requestBypassesCache: no-store im Request umgeht den Cache vollständig, ebenso
+// Server-Sent Events (der Stream würde sonst gepuffert und gespeichert)
+func requestBypassesCache(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	return parseCacheControl(r.Header).has("no-store")
+}
+
+// requestRequiresRevalidation: no-cache (bzw. Pragma) oder ein max-age, das der
+// Eintrag überschreitet, erzwingen eine Revalidierung beim Upstream
+func requestRequiresRevalidation(r *http.Request, age time.Duration) bool {
+	cc := parseCacheControl(r.Header)
+	if cc.has("no-cache") || r.Header.Get("Pragma") == "no-cache" {
+		return true
+	}
+	maxAge, ok := cc.seconds("max-age")
+	return ok && age >= maxAge
+}
+
+// requestOnlyIfCached: der Client will keine Anfrage an den Upstream (RFC 9111 §5.2.1.7)
+func requestOnlyIfCached(r *http.Request) bool {
+	return parseCacheControl(r.Header).has("only-if-cached")
+}
+
+// isSafeMethod: Methoden ohne Seiteneffekte invalidieren keine Einträge
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// storeMode entscheidet, ob und wie eine Antwort gespeichert werden darf (RFC 9111 §3).
+// Der Gateway-Cache ist ein Shared Cache; "private" und Antworten auf Requests mit
+// Authorization landen nur als Eintrag pro Benutzer im Cache.
+func storeMode(r *http.Request, status int, h http.Header, hasUser bool) string {
+	if !slices.Contains(cacheableStatus, status) {
+		return storeNone
+	}
+	cc := parseCacheControl(h)
+	if cc.has("no-store") || h.Get("Set-Cookie") != "" || slices.Contains(varyHeaders(h), "*") {
+		return storeNone
+	}
+
+	private := cc.has("private")
+	if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		private = true
+	}
+	if private {
+		if !hasUser {
+			return storeNone
+		}
+		return storePrivate
+	}
+	return storeShared
+}
+
+// freshnessLifetime berechnet die Frische einer Antwort für den Shared Cache.
+// Ohne explizite Angabe des Upstreams gilt das cache_ttl der Route.
+func freshnessLifetime(h http.Header, defaultTTL time.Duration, mode string) time.Duration {
+	cc := parseCacheControl(h)
+	if cc.has("no-cache") {
+		return 0 // Speichern erlaubt, aber vor jeder Nutzung revalidieren
+	}
+	if mode == storeShared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+	if expires := h.Get("Expires"); expires != "" {
+		exp, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // Ungültiges Expires bedeutet "bereits abgelaufen"
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		return max(exp.Sub(date), 0)
+	}
+	return defaultTTL
+}
+
+// staleWindows liefert stale-while-revalidate und stale-if-error (RFC 5861).
+// must-revalidate/proxy-revalidate verbieten das Ausliefern veralteter Einträge.
+func staleWindows(h http.Header) (whileRevalidate, ifError time.Duration) {
+	cc := parseCacheControl(h)
+	if cc.has("must-revalidate") || cc.has("proxy-revalidate") || cc.has("no-cache") {
+		return 0, 0
+	}
+	whileRevalidate, _ = cc.seconds("stale-while-revalidate")
+	ifError, _ = cc.seconds("stale-if-error")
+	return whileRevalidate, ifError
+}
+
+// varyHeaders liefert die kanonischen Header-Namen aus Vary
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name != "*" {
+				name = http.CanonicalHeaderKey(name)
+			}
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Hop-by-Hop- und Gateway-eigene Header werden nicht mitgespeichert
+var unstoredHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Age", "X-Cache", "Surrogate-Key",
+}
+
+// ageHeader liest den Age-Header des Upstreams
+func ageHeader(h http.Header) time.Duration {
+	n, err := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+func storableHeader(h http.Header) http.Header {
+	stored := h.Clone()
+	for _, name := range unstoredHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func header(kv ...string) http.Header {
+	h := make(http.Header)
+	for i := 0; i+1 < len(kv); i += 2 {
+		h.Add(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	const defaultTTL = 5 * time.Minute
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		mode   string
+		want   time.Duration
+	}{
+		{"ohne Angaben gilt cache_ttl", header(), storeShared, defaultTTL},
+		{"max-age", header("Cache-Control", "max-age=60"), storeShared, time.Minute},
+		{"s-maxage hat im Shared Cache Vorrang", header("Cache-Control", "max-age=60, s-maxage=120"), storeShared, 2 * time.Minute},
+		{"s-maxage gilt nicht für private Einträge", header("Cache-Control", "max-age=60, s-maxage=120"), storePrivate, time.Minute},
+		{"no-cache", header("Cache-Control", "no-cache, max-age=60"), storeShared, 0},
+		{"Direktiven ohne Beachtung der Schreibweise", header("Cache-Control", `MAX-AGE="30"`), storeShared, 30 * time.Second},
+		{"mehrere Cache-Control-Zeilen", header("Cache-Control", "public", "Cache-Control", "max-age=10"), storeShared, 10 * time.Second},
+		{"ungültiges max-age wird ignoriert", header("Cache-Control", "max-age=-1"), storeShared, defaultTTL},
+		{
+			"Expires relativ zu Date",
+			header("Date", date.Format(http.TimeFormat), "Expires", date.Add(90*time.Second).Format(http.TimeFormat)),
+			storeShared, 90 * time.Second,
+		},
+		{
+			"Expires in der Vergangenheit",
+			header("Date", date.Format(http.TimeFormat), "Expires", date.Add(-time.Hour).Format(http.TimeFormat)),
+			storeShared, 0,
+		},
+		{"ungültiges Expires ist abgelaufen", header("Expires", "0"), storeShared, 0},
+		{
+			"max-age hat Vorrang vor Expires",
+			header("Cache-Control", "max-age=60", "Date", date.Format(http.TimeFormat), "Expires", date.Add(time.Hour).Format(http.TimeFormat)),
+			storeShared, time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freshnessLifetime(tt.header, defaultTTL, tt.mode); got != tt.want {
+				t.Errorf("freshnessLifetime = %s, erwartet %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaleWindows(t *testing.T) {
+	tests := []struct {
+		name                      string
+		header                    http.Header
+		wantRevalidate, wantError time.Duration
+	}{
+		{"ohne Angaben", header(), 0, 0},
+		{"beide Fenster", header("Cache-Control", "max-age=60, stale-while-revalidate=30, stale-if-error=600"), 30 * time.Second, 10 * time.Minute},
+		{"must-revalidate verbietet veraltete Einträge", header("Cache-Control", "stale-if-error=600, must-revalidate"), 0, 0},
+		{"proxy-revalidate verbietet veraltete Einträge", header("Cache-Control", "stale-while-revalidate=30, proxy-revalidate"), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revalidate, ifError := staleWindows(tt.header)
+			if revalidate != tt.wantRevalidate || ifError != tt.wantError {
+				t.Errorf("staleWindows = %s, %s, erwartet %s, %s", revalidate, ifError, tt.wantRevalidate, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestStoreMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		request http.Header
+		status  int
+		header  http.Header
+		hasUser bool
+		want    string
+	}{
+		{"einfache Antwort", header(), http.StatusOK, header(), false, storeShared},
+		{"nicht cachebarer Status", header(), http.StatusInternalServerError, header(), false, storeNone},
+		{"404 ist cachebar", header(), http.StatusNotFound, header(), false, storeShared},
+		{"no-store", header(), http.StatusOK, header("Cache-Control", "no-store"), false, storeNone},
+		{"Set-Cookie", header(), http.StatusOK, header("Set-Cookie", "id=1"), false, storeNone},
+		{"Vary *", header(), http.StatusOK, header("Vary", "Accept, *"), false, storeNone},
+		{"private mit Benutzer", header(), http.StatusOK, header("Cache-Control", "private"), true, storePrivate},
+		{"private ohne Benutzer", header(), http.StatusOK, header("Cache-Control", "private"), false, storeNone},
+		{"Authorization macht privat", header("Authorization", "Bearer x"), http.StatusOK, header(), true, storePrivate},
+		{"Authorization mit public", header("Authorization", "Bearer x"), http.StatusOK, header("Cache-Control", "public"), true, storeShared},
+		{"Authorization mit s-maxage", header("Authorization", "Bearer x"), http.StatusOK, header("Cache-Control", "s-maxage=60"), true, storeShared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			r.Header = tt.request
+			if got := storeMode(r, tt.status, tt.header, tt.hasUser); got != tt.want {
+				t.Errorf("storeMode = %q, erwartet %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestRequiresRevalidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		request http.Header
+		age     time.Duration
+		want    bool
+	}{
+		{"ohne Angaben", header(), time.Hour, false},
+		{"no-cache", header("Cache-Control", "no-cache"), 0, true},
+		{"Pragma", header("Pragma", "no-cache"), 0, true},
+		{"max-age unterschritten", header("Cache-Control", "max-age=60"), 30 * time.Second, false},
+		{"max-age erreicht", header("Cache-Control", "max-age=60"), time.Minute, true},
+		{"max-age=0", header("Cache-Control", "max-age=0"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			r.Header = tt.request
+			if got := requestRequiresRevalidation(r, tt.age); got != tt.want {
+				t.Errorf("requestRequiresRevalidation = %v, erwartet %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []string
+	}{
+		{"ohne Vary", header(), nil},
+		{"kanonisch und sortiert", header("Vary", "accept-language, Accept"), []string{"Accept", "Accept-Language"}},
+		{"Duplikate über mehrere Zeilen", header("Vary", "Accept", "Vary", "accept, ,Origin"), []string{"Accept", "Origin"}},
+		{"Stern bleibt erhalten", header("Vary", "*"), []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := varyHeaders(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("varyHeaders = %v, erwartet %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	vary := []string{"Accept", "Accept-Language"}
+	request := func(kv ...string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header = header(kv...)
+		return r
+	}
+	base := variantKey("p", "g1", vary, request("Accept", "text/html", "Accept-Language", "de"), "")
+
+	tests := []struct {
+		name     string
+		key      string
+		wantSame bool
+	}{
+		{"gleiche Werte", variantKey("p", "g1", vary, request("Accept-Language", "de", "Accept", "text/html"), ""), true},
+		{"nicht relevanter Header", variantKey("p", "g1", vary, request("Accept", "text/html", "Accept-Language", "de", "User-Agent", "x"), ""), true},
+		{"anderer Vary-Wert", variantKey("p", "g1", vary, request("Accept", "application/json", "Accept-Language", "de"), ""), false},
+		{"fehlender Vary-Header", variantKey("p", "g1", vary, request("Accept", "text/html"), ""), false},
+		{"anderer Benutzer", variantKey("p", "g1", vary, request("Accept", "text/html", "Accept-Language", "de"), "user:1"), false},
+		{"andere Generation", variantKey("p", "g2", vary, request("Accept", "text/html", "Accept-Language", "de"), ""), false},
+		{"anderer Primary Key", variantKey("q", "g1", vary, request("Accept", "text/html", "Accept-Language", "de"), ""), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.key == base; same != tt.wantSame {
+				t.Errorf("gleicher Schlüssel = %v, erwartet %v", same, tt.wantSame)
+			}
+		})
+	}
+}
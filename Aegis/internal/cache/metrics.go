@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "cache"
+)
+
+var cacheRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "requests_total",
+		Help:      "Gesamtzahl der Cache-Anfragen, nach Ergebnis (hit/miss/stale/revalidated/coalesced/bypass).",
+	},
+	[]string{"result"},
+)
+
+var coalescedWaits = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "coalesced_waits_total",
+		Help:      "Requests, die auf einen laufenden Upstream-Abruf gewartet haben, nach Herkunft (local/remote) und Ergebnis (shared/fallback).",
+	},
+	[]string{"source", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+	prometheus.MustRegister(coalescedWaits)
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Neben den Einträgen pflegt der Cache pro Scope zwei Indizes für den Purge:
+//   cache:<scope>:tag:<tag>  Set der Primary Keys mit diesem Tag
+//   cache:<scope>:paths      Sorted Set "<pfad>\x00<primary key>" für Präfix-Suchen
+// Beide laufen wie die Einträge nach maxEntryTTL ohne Schreibzugriff aus.
+
+const pathSeparator = "\x00"
+
+// ScopeForProject liefert den Cache-Scope eines Projekts (leer = globale Routen).
+// Die Route gehört bewusst nicht dazu: ein POST auf eine andere Route mit demselben
+// Pfad invalidiert die GET-Einträge.
+func ScopeForProject(projectID string) string {
+	if projectID == "" {
+		return "global"
+	}
+	return projectID
+}
+
+// RouteTag markiert alle Einträge einer Route (ID aus Athena, sonst Pfad)
+func RouteTag(routeKey string) string {
+	return "route:" + routeKey
+}
+
+func surrogateTag(key string) string {
+	return "key:" + key
+}
+
+func scopePrefix(scope string) string {
+	return "cache:" + scope + ":"
+}
+
+func tagKey(scope, tag string) string {
+	return scopePrefix(scope) + "tag:" + tag
+}
+
+func pathsKey(scope string) string {
+	return scopePrefix(scope) + "paths"
+}
+
+// surrogateKeys liest den Surrogate-Key-Header (durch Leerzeichen getrennte Schlüssel)
+func surrogateKeys(h http.Header) []string {
+	var keys []string
+	for _, line := range h.Values("Surrogate-Key") {
+		for _, key := range strings.Fields(line) {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// index trägt einen gespeicherten Eintrag in die Purge-Indizes ein
+func (c *cacheHandler) index(ctx context.Context, pipe redis.Pipeliner, primary string, r *http.Request, entry *CachedResponse) {
+	scope := c.policy.Scope
+	path, _, _ := strings.Cut(requestURI(r), "?")
+	pipe.ZAdd(ctx, pathsKey(scope), &redis.Z{Member: path + pathSeparator + primary})
+	pipe.Expire(ctx, pathsKey(scope), maxEntryTTL)
+
+	tags := slices.Clone(c.policy.Tags)
+	for _, key := range entry.SurrogateKeys {
+		tags = append(tags, surrogateTag(key))
+	}
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagKey(scope, tag), primary)
+		pipe.Expire(ctx, tagKey(scope, tag), maxEntryTTL)
+	}
+}
+
+// PurgeRequest wählt die zu löschenden Einträge eines Projekts. Alle angegebenen
+// Kriterien werden gelöscht; Prefix "/" leert den Cache des ganzen Projekts.
+type PurgeRequest struct {
+	ProjectID string   `json:"project_id"`         // leer = globale Routen
+	RouteID   string   `json:"route_id,omitempty"` // Route-ID aus Athena (bzw. Pfad)
+	Prefix    string   `json:"prefix,omitempty"`   // Pfad-Präfix, wie vom Client angefragt
+	Tags      []string `json:"tags,omitempty"`     // Surrogate Keys des Upstreams
+}
+
+// ErrEmptyPurge wird geliefert, wenn keine Auswahl angegeben wurde
+var ErrEmptyPurge = errors.New("purge ohne route_id, prefix oder tags")
+
+// Purge löscht die ausgewählten Einträge und liefert die Anzahl der betroffenen URLs
+func Purge(ctx context.Context, client *redis.Client, req PurgeRequest) (int, error) {
+	if req.RouteID == "" && req.Prefix == "" && len(req.Tags) == 0 {
+		return 0, ErrEmptyPurge
+	}
+	scope := ScopeForProject(req.ProjectID)
+
+	var tags []string
+	if req.RouteID != "" {
+		tags = append(tags, RouteTag(req.RouteID))
+	}
+	for _, key := range req.Tags {
+		tags = append(tags, surrogateTag(key))
+	}
+
+	var primaries, indexKeys []string
+	for _, tag := range tags {
+		members, err := client.SMembers(ctx, tagKey(scope, tag)).Result()
+		if err != nil {
+			return 0, err
+		}
+		primaries = append(primaries, members...)
+		indexKeys = append(indexKeys, tagKey(scope, tag))
+	}
+
+	var pathMembers []interface{}
+	if req.Prefix != "" {
+		members, err := client.ZRangeByLex(ctx, pathsKey(scope), &redis.ZRangeBy{
+			Min: "[" + req.Prefix,
+			Max: "[" + req.Prefix + "\xff",
+		}).Result()
+		if err != nil {
+			return 0, err
+		}
+		for _, member := range members {
+			if _, primary, ok := strings.Cut(member, pathSeparator); ok {
+				primaries = append(primaries, primary)
+			}
+			pathMembers = append(pathMembers, member)
+		}
+	}
+
+	slices.Sort(primaries)
+	primaries = slices.Compact(primaries)
+
+	// Löschen des Primary Keys macht alle Varianten der URL unerreichbar
+	purged := 0
+	for batch := range slices.Chunk(primaries, 500) {
+		n, err := client.Del(ctx, batch...).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(n)
+	}
+	if len(indexKeys) > 0 {
+		if err := client.Del(ctx, indexKeys...).Err(); err != nil {
+			return purged, err
+		}
+	}
+	if len(pathMembers) > 0 {
+		if err := client.ZRem(ctx, pathsKey(scope), pathMembers...).Err(); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// PurgeHandler ist die interne Purge-API (POST, JSON PurgeRequest). Aufrufer
+// authentifizieren sich wie gegenüber Athena mit X-Internal-Secret.
+func PurgeHandler(client *redis.Client, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			http.Error(w, "Interne Authentifizierung nicht konfiguriert", http.StatusInternalServerError)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Internal-Secret")), []byte(secret)) != 1 {
+			slog.WarnContext(r.Context(), "Ungültiger interner Secret-Versuch (Cache-Purge)", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Methode nicht erlaubt", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req PurgeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Ungültiger JSON Body", http.StatusBadRequest)
+			return
+		}
+
+		purged, err := Purge(r.Context(), client, req)
+		if errors.Is(err, ErrEmptyPurge) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Cache-Purge fehlgeschlagen", "project_id", req.ProjectID, "error", err)
+			http.Error(w, "Cache-Purge fehlgeschlagen", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Cache-Purge ausgeführt",
+			"project_id", req.ProjectID, "route_id", req.RouteID, "prefix", req.Prefix, "tags", req.Tags, "purged", purged)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+	}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// captureWriter hält die Upstream-Antwort für den Cache fest. Er hat eigene Header,
+// damit nur die Header des Upstreams gespeichert werden und nicht die der äußeren
+// Middlewares (z.B. RateLimit-*).
+type captureWriter struct {
+	w           http.ResponseWriter // nil bei Revalidierung im Hintergrund
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	hold        bool // Antwort zurückhalten, bis über die Auslieferung entschieden ist
+	passthrough bool // Header sind an w gegangen, der Body wird durchgereicht
+	tooLarge    bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, hold bool) *captureWriter {
+	return &captureWriter{w: w, header: make(http.Header), hold: hold}
+}
+
+func (c *captureWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *captureWriter) statusCode() int {
+	if c.status == 0 {
+		return http.StatusOK
+	}
+	return c.status
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// Informational (z.B. 103 Early Hints) direkt weitergeben, nicht speichern
+		if !c.hold && c.w != nil {
+			c.w.WriteHeader(status)
+		}
+		return
+	}
+	c.status = status
+	if !c.hold {
+		c.startPassthrough()
+	}
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.tooLarge && c.body.Len()+len(b) > maxBodySize {
+		// Zu groß für den Cache: ab hier nur noch durchreichen
+		c.tooLarge = true
+		if !c.passthrough {
+			c.startPassthrough()
+		}
+		c.body = bytes.Buffer{}
+	}
+	if !c.tooLarge {
+		c.body.Write(b)
+	}
+	if c.passthrough && c.w != nil {
+		return c.w.Write(b)
+	}
+	return len(b), nil
+}
+
+// startPassthrough gibt Header und bisherigen Body an den Client weiter
+func (c *captureWriter) startPassthrough() {
+	c.passthrough = true
+	if c.w == nil {
+		return
+	}
+	h := c.w.Header()
+	for name, values := range c.header {
+		h[name] = values
+	}
+	h.Del("Surrogate-Key") // Nur für den Cache bestimmt
+	h.Set("X-Cache", "MISS")
+	cacheRequests.WithLabelValues("miss").Inc()
+	c.w.WriteHeader(c.statusCode())
+	if c.body.Len() > 0 {
+		c.w.Write(c.body.Bytes())
+	}
+}
+
+// release gibt eine zurückgehaltene Antwort an den Client weiter
+func (c *captureWriter) release() {
+	if !c.passthrough {
+		c.startPassthrough()
+	}
+}
+
+func (c *captureWriter) Flush() {
+	if c.passthrough && c.w != nil {
+		http.NewResponseController(c.w).Flush()
+	}
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
@@ -0,0 +1,143 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gatekeeper/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Wartezeit nach fehlgeschlagener Ausstellung (verdoppelt sich bis acmeMaxBackoff),
+// damit die Rate Limits der CA nicht ausgeschöpft werden
+const (
+	acmeBaseBackoff = time.Minute
+	acmeMaxBackoff  = time.Hour
+)
+
+type acmeRetry struct {
+	failures int
+	next     time.Time
+}
+
+// acmeIssuer bezieht Zertifikate per ACME (autocert). Konto-Schlüssel und
+// Zertifikate liegen im Cache-Verzeichnis, autocert erneuert sie selbst.
+type acmeIssuer struct {
+	manager *autocert.Manager
+
+	mu    sync.Mutex
+	ready map[string]bool
+	retry map[string]acmeRetry
+}
+
+func newACMEIssuer(c config.ACMEConfig, allowed func(string) bool) (*acmeIssuer, error) {
+	httpClient := http.DefaultClient
+	if c.CABundle != "" {
+		// Lokale CAs wie Pebble haben eigene Zertifikate für ihre API
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		ca, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("fehler beim Lesen des ACME-CA-Bundles %s: %w", c.CABundle, err)
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("ACME-CA-Bundle %s enthält kein gültiges Zertifikat", c.CABundle)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		httpClient = &http.Client{Transport: transport, Timeout: time.Minute}
+	}
+
+	a := &acmeIssuer{ready: make(map[string]bool), retry: make(map[string]acmeRetry)}
+	a.manager = &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Cache:  autocert.DirCache(c.CacheDir),
+		Email:  c.Email,
+		Client: &acme.Client{DirectoryURL: c.DirectoryURL, HTTPClient: httpClient},
+		// Nur Projekt-Hosts, sonst könnte jeder SNI-Name eine Ausstellung auslösen
+		HostPolicy: func(_ context.Context, host string) error {
+			if !allowed(host) {
+				return fmt.Errorf("host '%s' gehört zu keinem Projekt", host)
+			}
+			return nil
+		},
+	}
+	slog.Info("ACME aktiv", "directory", c.DirectoryURL, "cache", c.CacheDir)
+	return a, nil
+}
+
+// HTTPHandler beantwortet HTTP-01-Challenges und leitet alles andere auf HTTPS um
+func (s *Store) HTTPHandler() http.Handler {
+	if s.acme == nil {
+		return nil
+	}
+	return s.acme.manager.HTTPHandler(nil)
+}
+
+func (a *acmeIssuer) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := a.manager.GetCertificate(hello)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf != nil {
+		certificateExpiry.WithLabelValues(normalizeName(hello.ServerName), sourceACME).Set(float64(cert.Leaf.NotAfter.Unix()))
+	}
+	return cert, nil
+}
+
+// isReady meldet, ob für den Host bereits ein Zertifikat bezogen wurde. Handshakes
+// lösen so keine Ausstellung aus, die Wartezeit nach Fehlern bleibt gewahrt.
+func (a *acmeIssuer) isReady(host string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ready[host]
+}
+
+// ensure bezieht das Zertifikat eines neuen Hosts vorab, damit der erste Client
+// nicht auf die Ausstellung warten muss
+func (a *acmeIssuer) ensure(host string) {
+	a.mu.Lock()
+	r := a.retry[host]
+	if a.ready[host] || time.Now().Before(r.next) {
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+
+	// ECDSA-fähiger Handshake, damit autocert dasselbe Zertifikat wie für echte Clients wählt
+	_, err := a.getCertificate(&tls.ClientHelloInfo{
+		ServerName:       host,
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		SupportedCurves:  []tls.CurveID{tls.CurveP256},
+	})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		if r.failures < 7 {
+			r.failures++
+		}
+		backoff := min(acmeBaseBackoff<<(r.failures-1), acmeMaxBackoff)
+		r.next = time.Now().Add(backoff)
+		a.retry[host] = r
+		certificateLoads.WithLabelValues(sourceACME, "error").Inc()
+		slog.Warn("ACME: Zertifikat konnte nicht bezogen werden", "host", host, "retry_in", backoff.String(), "error", err)
+		return
+	}
+	delete(a.retry, host)
+	a.ready[host] = true
+	certificateLoads.WithLabelValues(sourceACME, "success").Inc()
+	slog.Info("ACME: Zertifikat bereit", "host", host)
+}
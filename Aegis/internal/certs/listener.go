@@ -0,0 +1,49 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Modi für Client-Zertifikate am Listener (GatewayConfig.ClientAuth)
+const (
+	ClientAuthOff      = ""
+	ClientAuthOptional = "optional" // Zertifikat wird geprüft, falls der Client eines schickt
+	ClientAuthRequire  = "require"  // Verbindungen ohne gültiges Zertifikat werden abgelehnt
+)
+
+// ServerConfig liefert die TLS-Konfiguration des Listeners für Client-Zertifikate.
+// Ohne ClientAuth liefert sie nil.
+func ServerConfig(mode, caPath string) (*tls.Config, error) {
+	var clientAuth tls.ClientAuthType
+	switch mode {
+	case ClientAuthOff:
+		return nil, nil
+	case ClientAuthOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unbekannter Client-Auth-Modus '%s' (optional oder require)", mode)
+	}
+
+	if caPath == "" {
+		return nil, fmt.Errorf("für Client-Zertifikate fehlt das CA-Bundle (AEGIS_TLS_CLIENT_CA_PATH)")
+	}
+	ca, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Lesen des Client-CA-Bundles %s: %w", caPath, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("Client-CA-Bundle %s enthält kein gültiges Zertifikat", caPath)
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+		ClientCAs:  pool,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package certs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "tls"
+)
+
+var certificateExpiry = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Ablaufzeitpunkt (Unix) der Listener-Zertifikate pro Name und Quelle.",
+	},
+	[]string{"name", "source"},
+)
+
+var certificateLoads = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "certificate_loads_total",
+		Help:      "Geladene bzw. ausgestellte Listener-Zertifikate nach Quelle und Ergebnis.",
+	},
+	[]string{"source", "result"},
+)
+
+var missingCertificates = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "certificate_missing_total",
+		Help:      "TLS-Handshakes ohne passendes Zertifikat (auch ohne Standard-Zertifikat).",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(certificateExpiry, certificateLoads, missingCertificates)
+}
@@ -0,0 +1,102 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// pkiIssuer lässt Zertifikate für Projekt-Hosts von der Vault-PKI-Engine ausstellen
+// (z.B. "pki/issue/aegis-hosts"). Sie liegen nur im Speicher und werden nach zwei
+// Dritteln der Laufzeit neu ausgestellt.
+type pkiIssuer struct {
+	path string
+
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate
+}
+
+func newPKIIssuer(path string) *pkiIssuer {
+	return &pkiIssuer{path: path, certs: make(map[string]*tls.Certificate)}
+}
+
+func (p *pkiIssuer) get(host string) *tls.Certificate {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.certs[host]
+}
+
+// ensure stellt ein Zertifikat aus, falls keines vorliegt oder es bald abläuft.
+// Schlägt das fehl, bleibt ein noch gültiges Zertifikat aktiv.
+func (p *pkiIssuer) ensure(host string) {
+	if cert := p.get(host); cert != nil && !needsRenewal(cert.Leaf, time.Now()) {
+		return
+	}
+	cert, err := p.issue(host)
+	if err != nil {
+		certificateLoads.WithLabelValues(sourceVaultPKI, "error").Inc()
+		slog.Warn("Vault PKI: Zertifikat konnte nicht ausgestellt werden", "host", host, "error", err)
+		return
+	}
+	certificateLoads.WithLabelValues(sourceVaultPKI, "success").Inc()
+	certificateExpiry.WithLabelValues(host, sourceVaultPKI).Set(float64(cert.Leaf.NotAfter.Unix()))
+
+	p.mu.Lock()
+	p.certs[host] = cert
+	p.mu.Unlock()
+	slog.Info("Vault PKI: Zertifikat ausgestellt", "host", host, "not_after", cert.Leaf.NotAfter)
+}
+
+// prune verwirft Zertifikate von Hosts, die nicht mehr in der Context Map stehen
+func (p *pkiIssuer) prune(hosts map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for host := range p.certs {
+		if !hosts[host] {
+			delete(p.certs, host)
+			certificateExpiry.DeleteLabelValues(host, sourceVaultPKI)
+		}
+	}
+}
+
+func (p *pkiIssuer) issue(host string) (*tls.Certificate, error) {
+	var secret *api.Secret
+	err := withVault(func(client *api.Client) (err error) {
+		secret, err = client.Logical().Write(p.path, map[string]any{"common_name": host})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Ausstellen über Vault %s: %w", p.path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("keine daten von Vault-Pfad %s erhalten", p.path)
+	}
+
+	certPEM, _ := secret.Data["certificate"].(string)
+	keyPEM, _ := secret.Data["private_key"].(string)
+	if certPEM == "" || keyPEM == "" {
+		return nil, fmt.Errorf("vault antwort enthielt kein certificate/private_key")
+	}
+	// Zwischenzertifikate mitschicken, damit Clients die Kette bilden können
+	chain := []string{certPEM}
+	if caChain, ok := secret.Data["ca_chain"].([]any); ok && len(caChain) > 0 {
+		for _, c := range caChain {
+			if s, ok := c.(string); ok {
+				chain = append(chain, s)
+			}
+		}
+	} else if issuingCA, ok := secret.Data["issuing_ca"].(string); ok {
+		chain = append(chain, issuingCA)
+	}
+
+	cert, err := tls.X509KeyPair([]byte(strings.Join(chain, "\n")), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("ungültiges Zertifikat von Vault PKI: %w", err)
+	}
+	return &cert, nil
+}
@@ -0,0 +1,421 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gatekeeper/internal/config"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/acme"
+)
+
+// Quellen eines Listener-Zertifikats (Label "source")
+const (
+	sourceDefault  = "default"
+	sourceFile     = "file"
+	sourceVaultPKI = "vault_pki"
+	sourceACME     = "acme"
+)
+
+const (
+	// maintenanceInterval: so oft werden fehlende Zertifikate ausgestellt und
+	// auslaufende erneuert
+	maintenanceInterval = time.Minute
+	// reloadDebounce fasst die Events beim Schreiben von Zertifikat und Schlüssel zusammen
+	reloadDebounce = 500 * time.Millisecond
+)
+
+// Endungen der Zertifikate im Verzeichnis; der Schlüssel liegt daneben als <name>.key
+var certExtensions = []string{".crt", ".pem"}
+
+type fileCert struct {
+	cert *tls.Certificate
+	path string
+}
+
+// Store wählt das Zertifikat des Listeners per SNI (tls.Config.GetCertificate).
+// Reihenfolge: Zertifikate aus dem Verzeichnis (exakter Name vor Wildcard), von
+// Vault PKI ausgestellte, per ACME bezogene und zuletzt das Standard-Zertifikat.
+// Vault PKI und ACME stellen nur für Hosts aus der Context Map aus.
+type Store struct {
+	certFile, keyFile string
+	dir               string
+	pki               *pkiIssuer
+	acme              *acmeIssuer
+
+	mu       sync.RWMutex
+	fallback *tls.Certificate
+	byName   map[string]fileCert // DNS-Name (auch "*.example.com") -> Zertifikat aus dem Verzeichnis
+	hosts    map[string]bool     // Projekt-Hosts aus der Context Map
+
+	watcher *fsnotify.Watcher
+	kick    chan struct{}
+	done    chan struct{}
+}
+
+// StoreEnabled meldet, ob der Listener TLS spricht
+func StoreEnabled(cfg *config.GatewayConfig) bool {
+	return (cfg.TLSCertPath != "" && cfg.TLSKeyPath != "") || cfg.TLSCertDir != "" ||
+		cfg.TLSVaultPKIPath != "" || cfg.ACME.DirectoryURL != ""
+}
+
+// NewStore lädt die Zertifikate und startet die Dateiüberwachung.
+// Ein fehlerhaftes Standard-Zertifikat bricht den Start ab, fehlerhafte Dateien
+// im Verzeichnis werden übersprungen.
+func NewStore(cfg *config.GatewayConfig) (*Store, error) {
+	s := &Store{
+		certFile: cfg.TLSCertPath,
+		keyFile:  cfg.TLSKeyPath,
+		dir:      cfg.TLSCertDir,
+		byName:   make(map[string]fileCert),
+		hosts:    make(map[string]bool),
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	if cfg.TLSVaultPKIPath != "" {
+		s.pki = newPKIIssuer(cfg.TLSVaultPKIPath)
+	}
+	if cfg.ACME.DirectoryURL != "" {
+		var err error
+		if s.acme, err = newACMEIssuer(cfg.ACME, s.allowed); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.watch(); err != nil {
+		return nil, err
+	}
+
+	go s.watchLoop()
+	return s, nil
+}
+
+// StartIssuing startet Ausstellung und Erneuerung über Vault PKI bzw. ACME. Erst
+// aufrufen, wenn der Listener läuft: die ACME-CA prüft die Hosts über ihn.
+func (s *Store) StartIssuing() {
+	go s.maintainLoop()
+}
+
+// Close beendet Dateiüberwachung und Ausstellung
+func (s *Store) Close() {
+	close(s.done)
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
+}
+
+// TLSConfig liefert die Listener-Konfiguration mit SNI-Auswahl. base (z.B. mit
+// Client-Zertifikaten) wird übernommen, falls gesetzt.
+func (s *Store) TLSConfig(base *tls.Config) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	cfg.GetCertificate = s.GetCertificate
+	if s.acme != nil {
+		// TLS-ALPN-01: die CA prüft den Host über den Listener
+		cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+		if cfg.ClientAuth != tls.NoClientCert {
+			// Die CA schickt bei der Challenge kein Client-Zertifikat
+			challengeCfg := cfg.Clone()
+			challengeCfg.ClientAuth = tls.NoClientCert
+			cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+					return challengeCfg, nil
+				}
+				return nil, nil
+			}
+		}
+	}
+	return cfg
+}
+
+// SetHosts übernimmt die Projekt-Hosts (Context Map) nach jedem Laden der Konfiguration
+func (s *Store) SetHosts(hosts []string) {
+	m := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		m[normalizeName(h)] = true
+	}
+	s.mu.Lock()
+	s.hosts = m
+	s.mu.Unlock()
+
+	if s.pki != nil {
+		s.pki.prune(m)
+	}
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// GetCertificate wählt das Zertifikat für den TLS-Handshake
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// TLS-ALPN-01-Challenges beantwortet der ACME-Client
+	if s.acme != nil && slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		return s.acme.manager.GetCertificate(hello)
+	}
+
+	name := normalizeName(hello.ServerName)
+	if cert := s.lookup(name); cert != nil {
+		return cert, nil
+	}
+	if s.pki != nil {
+		if cert := s.pki.get(name); cert != nil {
+			return cert, nil
+		}
+	}
+	if s.acme != nil && s.allowed(name) && s.acme.isReady(name) {
+		cert, err := s.acme.getCertificate(hello)
+		if err == nil {
+			return cert, nil
+		}
+		slog.Warn("ACME-Zertifikat nicht verfügbar, verwende Standard-Zertifikat", "host", name, "error", err)
+	}
+
+	s.mu.RLock()
+	fallback := s.fallback
+	s.mu.RUnlock()
+	if fallback != nil {
+		return fallback, nil
+	}
+	missingCertificates.Inc()
+	return nil, fmt.Errorf("kein Zertifikat für '%s'", name)
+}
+
+// lookup sucht im Verzeichnis: exakter Name, dann Wildcard der Elternzone
+func (s *Store) lookup(name string) *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if fc, ok := s.byName[name]; ok {
+		return fc.cert
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if fc, ok := s.byName["*"+name[i:]]; ok {
+			return fc.cert
+		}
+	}
+	return nil
+}
+
+func (s *Store) allowed(host string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts[normalizeName(host)]
+}
+
+// reload lädt Standard-Zertifikat und Verzeichnis neu. Kann eine Datei nicht
+// gelesen werden (z.B. Zertifikat schon erneuert, Schlüssel noch nicht), bleibt
+// ihr bisheriger Stand aktiv.
+func (s *Store) reload() error {
+	var fallback *tls.Certificate
+	if s.certFile != "" && s.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			certificateLoads.WithLabelValues(sourceDefault, "error").Inc()
+			s.mu.RLock()
+			fallback = s.fallback
+			s.mu.RUnlock()
+			if fallback == nil {
+				return fmt.Errorf("fehler beim Laden des Standard-Zertifikats %s: %w", s.certFile, err)
+			}
+			slog.Warn("Standard-Zertifikat konnte nicht neu geladen werden, bisheriges bleibt aktiv", "path", s.certFile, "error", err)
+		} else {
+			certificateLoads.WithLabelValues(sourceDefault, "success").Inc()
+			fallback = &cert
+		}
+	}
+
+	s.mu.RLock()
+	previous := s.byName
+	s.mu.RUnlock()
+
+	byName := make(map[string]fileCert)
+	if s.dir != "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			return fmt.Errorf("fehler beim Lesen des Zertifikatsverzeichnisses %s: %w", s.dir, err)
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || !slices.Contains(certExtensions, ext) {
+				continue
+			}
+			certPath := filepath.Join(s.dir, e.Name())
+			keyPath := strings.TrimSuffix(certPath, ext) + ".key"
+			if _, err := os.Stat(keyPath); err != nil {
+				continue // z.B. CA-Bundle ohne Schlüssel
+			}
+			cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+			if err != nil {
+				certificateLoads.WithLabelValues(sourceFile, "error").Inc()
+				slog.Warn("Zertifikat konnte nicht geladen werden", "path", certPath, "error", err)
+				for name, fc := range previous {
+					if fc.path == certPath {
+						byName[name] = fc
+					}
+				}
+				continue
+			}
+			certificateLoads.WithLabelValues(sourceFile, "success").Inc()
+			for _, name := range certNames(cert.Leaf) {
+				byName[name] = fileCert{cert: &cert, path: certPath}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.fallback = fallback
+	s.byName = byName
+	s.mu.Unlock()
+
+	certificateExpiry.DeletePartialMatch(prometheus.Labels{"source": sourceFile})
+	for name, fc := range byName {
+		certificateExpiry.WithLabelValues(name, sourceFile).Set(float64(fc.cert.Leaf.NotAfter.Unix()))
+	}
+	if fallback != nil && fallback.Leaf != nil {
+		certificateExpiry.WithLabelValues(sourceDefault, sourceDefault).Set(float64(fallback.Leaf.NotAfter.Unix()))
+	}
+	slog.Info("Listener-Zertifikate geladen", "names", len(byName), "default", fallback != nil)
+	return nil
+}
+
+// watch überwacht das Verzeichnis und den Ordner des Standard-Zertifikats
+func (s *Store) watch() error {
+	var dirs []string
+	if s.dir != "" {
+		dirs = append(dirs, s.dir)
+	}
+	if s.certFile != "" {
+		dirs = append(dirs, filepath.Dir(s.certFile), filepath.Dir(s.keyFile))
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("fehler beim Starten der Zertifikatsüberwachung: %w", err)
+	}
+	slices.Sort(dirs)
+	for _, dir := range slices.Compact(dirs) {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("fehler beim Überwachen von %s: %w", dir, err)
+		}
+	}
+	s.watcher = watcher
+	return nil
+}
+
+// watchLoop lädt die Zertifikate nach Dateiänderungen neu (Hot Reload)
+func (s *Store) watchLoop() {
+	if s.watcher == nil {
+		return
+	}
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			debounce = time.After(reloadDebounce)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("Fehler der Zertifikatsüberwachung", "error", err)
+		case <-debounce:
+			debounce = nil
+			if err := s.reload(); err != nil {
+				slog.Error("Zertifikate konnten nicht neu geladen werden", "error", err)
+			}
+		}
+	}
+}
+
+// maintainLoop stellt Zertifikate für neue Projekt-Hosts aus und erneuert auslaufende
+func (s *Store) maintainLoop() {
+	if s.pki == nil && s.acme == nil {
+		return
+	}
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.kick:
+		case <-ticker.C:
+		}
+		s.maintain()
+	}
+}
+
+func (s *Store) maintain() {
+	s.mu.RLock()
+	hosts := make([]string, 0, len(s.hosts))
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+	}
+	s.mu.RUnlock()
+	slices.Sort(hosts)
+
+	for _, host := range hosts {
+		// Zertifikate aus dem Verzeichnis haben Vorrang
+		if s.lookup(host) != nil {
+			continue
+		}
+		if s.pki != nil {
+			s.pki.ensure(host)
+		} else {
+			s.acme.ensure(host)
+		}
+	}
+}
+
+// certNames liefert die Namen, für die ein Zertifikat gilt (SANs, sonst CN)
+func certNames(leaf *x509.Certificate) []string {
+	if leaf == nil {
+		return nil
+	}
+	names := make([]string, 0, len(leaf.DNSNames))
+	for _, name := range leaf.DNSNames {
+		names = append(names, normalizeName(name))
+	}
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = append(names, normalizeName(leaf.Subject.CommonName))
+	}
+	return names
+}
+
+// needsRenewal meldet, ob weniger als ein Drittel der Laufzeit übrig ist
+func needsRenewal(leaf *x509.Certificate, now time.Time) bool {
+	if leaf == nil {
+		return true
+	}
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return leaf.NotAfter.Sub(now) < lifetime/3
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,113 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"gatekeeper/internal/config"
+)
+
+// Felder eines Vault-Secrets mit TLS-Material (PEM)
+const (
+	vaultFieldCA   = "ca"
+	vaultFieldCert = "cert"
+	vaultFieldKey  = "key"
+)
+
+// material ist das PEM-Material einer Upstream-TLS-Konfiguration
+type material struct {
+	ca, cert, key []byte
+}
+
+// loadMaterial liest das Material aus Vault und den Dateien. Dateien haben Vorrang
+// vor den Feldern des Secrets.
+func loadMaterial(c config.UpstreamTLSConfig) (material, error) {
+	var m material
+	if c.VaultPath != "" {
+		data, err := readVaultSecret(c.VaultPath)
+		if err != nil {
+			return m, err
+		}
+		m.ca = pemField(data, vaultFieldCA)
+		m.cert = pemField(data, vaultFieldCert)
+		m.key = pemField(data, vaultFieldKey)
+	}
+
+	files := []struct {
+		path string
+		dst  *[]byte
+	}{{c.CAFile, &m.ca}, {c.CertFile, &m.cert}, {c.KeyFile, &m.key}}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		pem, err := os.ReadFile(f.path)
+		if err != nil {
+			return m, fmt.Errorf("fehler beim Lesen von %s: %w", f.path, err)
+		}
+		*f.dst = pem
+	}
+	return m, nil
+}
+
+func pemField(data map[string]any, field string) []byte {
+	if s, ok := data[field].(string); ok && s != "" {
+		return []byte(s)
+	}
+	return nil
+}
+
+// ParseVersion wandelt "1.2" bzw. "1.3" in die TLS-Konstante um
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("ungültige TLS-Version '%s' (1.2 oder 1.3)", v)
+}
+
+// ClientConfig baut die TLS-Konfiguration für die Verbindungen zu den Upstreams
+// einer Route. Ohne eigene Einstellungen liefert sie nil (Standard des Transports).
+// Das Material wird bei jedem Aufruf (also bei jedem Reload) neu gelesen, erneuerte
+// Zertifikate greifen damit mit dem nächsten Reload.
+func ClientConfig(c config.UpstreamTLSConfig) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	minVersion, err := ParseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadMaterial(c)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		ServerName: c.ServerName,
+	}
+	if len(m.ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(m.ca) {
+			return nil, fmt.Errorf("CA-Bundle der Upstream-TLS-Konfiguration enthält kein gültiges Zertifikat")
+		}
+		cfg.RootCAs = pool
+	}
+	switch {
+	case len(m.cert) > 0 && len(m.key) > 0:
+		cert, err := tls.X509KeyPair(m.cert, m.key)
+		if err != nil {
+			return nil, fmt.Errorf("ungültiges Client-Zertifikat für Upstreams: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case len(m.cert) > 0 || len(m.key) > 0:
+		return nil, fmt.Errorf("Client-Zertifikat und Schlüssel müssen gemeinsam gesetzt sein")
+	}
+	return cfg, nil
+}
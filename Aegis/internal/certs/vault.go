@@ -0,0 +1,144 @@
+package certs
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// vaultCacheTTL begrenzt, wie lange ein gelesenes Secret wiederverwendet wird.
+// Ein Reload mehrerer Routen mit demselben Pfad liest Vault so nur einmal.
+const vaultCacheTTL = time.Minute
+
+type cachedSecret struct {
+	data    map[string]any
+	fetched time.Time
+}
+
+var (
+	vaultMu     sync.Mutex
+	vaultClient *api.Client
+	vaultCache  = make(map[string]cachedSecret)
+)
+
+// createVaultClient meldet Aegis per AppRole an Vault an (VAULT_ADDR,
+// AEGIS_APPROLE_ROLE_ID, AEGIS_APPROLE_SECRET_ID)
+func createVaultClient() (*api.Client, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR ist nicht gesetzt")
+	}
+	vaultConfig := api.DefaultConfig()
+	vaultConfig.Address = addr
+	client, err := api.NewClient(vaultConfig)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Erstellen des Vault-Clients: %w", err)
+	}
+
+	slog.Info("Führe Vault AppRole-Login aus...")
+	resp, err := client.Logical().Write("auth/approle/login", map[string]any{
+		"role_id":   os.Getenv("AEGIS_APPROLE_ROLE_ID"),
+		"secret_id": os.Getenv("AEGIS_APPROLE_SECRET_ID"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Vault AppRole-Login: %w", err)
+	}
+	if resp == nil || resp.Auth == nil {
+		return nil, fmt.Errorf("vault AppRole-Login gab keine Authentifizierungsdaten zurück")
+	}
+
+	client.SetToken(resp.Auth.ClientToken)
+	slog.Info("Vault AppRole-Login erfolgreich. Kurzlebiger Token gesetzt.")
+	return client, nil
+}
+
+// readVaultSecret liest ein KV-v2-Secret (mit kurzem Cache)
+func readVaultSecret(path string) (map[string]any, error) {
+	vaultMu.Lock()
+	if cached, ok := vaultCache[path]; ok && time.Since(cached.fetched) < vaultCacheTTL {
+		vaultMu.Unlock()
+		return cached.data, nil
+	}
+	vaultMu.Unlock()
+
+	var data map[string]any
+	err := withVault(func(client *api.Client) (err error) {
+		data, err = readKV2SecretData(client, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vaultMu.Lock()
+	vaultCache[path] = cachedSecret{data: data, fetched: time.Now()}
+	vaultMu.Unlock()
+	return data, nil
+}
+
+// withVault führt fn mit dem angemeldeten Client aus. Schlägt fn fehl (z.B.
+// abgelaufener Token), wird einmal neu angemeldet und fn wiederholt.
+func withVault(fn func(*api.Client) error) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		client, loginErr := currentVaultClient()
+		if loginErr != nil {
+			return loginErr
+		}
+
+		if err = fn(client); err == nil {
+			return nil
+		}
+		vaultMu.Lock()
+		if vaultClient == client {
+			vaultClient = nil
+		}
+		vaultMu.Unlock()
+	}
+	return err
+}
+
+// currentVaultClient liefert den angemeldeten Client. Der Login läuft außerhalb
+// von vaultMu, damit Cache-Treffer nicht auf Vault warten; melden sich mehrere
+// Aufrufer gleichzeitig an, gewinnt der erste.
+func currentVaultClient() (*api.Client, error) {
+	vaultMu.Lock()
+	client := vaultClient
+	vaultMu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	client, err := createVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vaultClient == nil {
+		vaultClient = client
+	}
+	return vaultClient, nil
+}
+
+func readKV2SecretData(client *api.Client, path string) (map[string]any, error) {
+	secret, err := client.Logical().Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Lesen des Secrets von Vault %s: %w", path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("keine daten gefunden unter Vault-Pfad: %s", path)
+	}
+
+	// Bei KV v2 sind die Daten in einem "data"-Feld verschachtelt
+	secretData, ok := secret.Data["data"].(map[string]any)
+	if !ok {
+		return secret.Data, nil
+	}
+	return secretData, nil
+}
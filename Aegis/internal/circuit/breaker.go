@@ -1,7 +1,11 @@
 package circuit
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,29 +14,75 @@ import (
 
 // --- Zustandskonstanten ---
 type State int
+
 const (
 	Closed State = iota
 	Open
 	HalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 const (
 	namespace = "gatekeeper"
 	subsystem = "circuitbreaker"
 )
 
+// Standardwerte, wenn in der Route nichts angegeben ist
+const (
+	DefaultWindow           = 60 * time.Second
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultMinRequests      = 10
+	DefaultHalfOpenRequests = 1
+)
+
+var (
+	// ErrOpen: der Breaker ist offen, Requests werden sofort abgelehnt
+	ErrOpen = errors.New("circuit breaker ist offen")
+	// ErrTooManyProbes: im Half-Open-Zustand sind alle Test-Requests vergeben
+	ErrTooManyProbes = errors.New("circuit breaker ist half-open, alle Test-Requests vergeben")
+)
+
 var stateGauge = prometheus.NewGaugeVec(
 	prometheus.GaugeOpts{
 		Namespace: namespace,
 		Subsystem: subsystem,
-		Name: "state",
-		Help: "Aktueller Zustand des Circuit Breakers (0=Closed, 1=Open, 2=HalfOpen).",
+		Name:      "state",
+		Help:      "Aktueller Zustand des Circuit Breakers (0=Closed, 1=Open, 2=HalfOpen).",
 	},
 	[]string{"service"},
 )
 
+var rejectedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rejected_requests_total",
+		Help:      "Vom Circuit Breaker abgelehnte Requests (state=open|half_open).",
+	},
+	[]string{"service", "state"},
+)
+
+var sharedErrors = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "shared_state_errors_total",
+		Help:      "Fehlgeschlagene Redis-Zugriffe des geteilten Breaker-Zustands (Replica nutzt dann den lokalen Zustand).",
+	},
+)
+
 func init() {
-	prometheus.MustRegister(stateGauge)
+	prometheus.MustRegister(stateGauge, rejectedRequests, sharedErrors)
 	prometheus.MustRegister(prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: "state_closed_value"},
 		func() float64 { return float64(Closed) },
@@ -47,17 +97,61 @@ func init() {
 	))
 }
 
+// Settings beschreibt, wann ein Breaker öffnet. Er öffnet, sobald im Fenster
+// FailureThreshold Fehler aufgetreten sind oder bei mindestens MinRequests Requests
+// der Fehleranteil ErrorThreshold Prozent erreicht. Ein Wert von 0 deaktiviert das
+// jeweilige Kriterium.
+type Settings struct {
+	Window           time.Duration // Länge des rollierenden Fensters
+	FailureThreshold int           // absolute Anzahl Fehler im Fenster
+	ErrorThreshold   float64       // Fehleranteil in Prozent (0-100)
+	MinRequests      int           // Mindestvolumen für ErrorThreshold
+	SlowCallDuration time.Duration // langsamere Requests zählen als Fehler (0 = aus)
+	OpenTimeout      time.Duration // Dauer bis zum Wechsel nach Half-Open
+	HalfOpenRequests int           // Anzahl Test-Requests im Half-Open-Zustand
+}
+
+// withDefaults ergänzt fehlende Werte
+func (s Settings) withDefaults() Settings {
+	if s.Window <= 0 {
+		s.Window = DefaultWindow
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = DefaultOpenTimeout
+	}
+	if s.ErrorThreshold > 0 && s.MinRequests <= 0 {
+		s.MinRequests = DefaultMinRequests
+	}
+	if s.HalfOpenRequests <= 0 {
+		s.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+	return s
+}
 
 type Breaker struct {
 	Name string
-	State State
-	FailureCount int
-	LastFailureTime time.Time
-	mu sync.Mutex
-	Timeout time.Duration
-	FailureThreshold int
-	
-	
+
+	mu       sync.Mutex
+	settings Settings
+	state    State
+	window   *rollingWindow
+	// since ist der letzte Wechsel nach Open oder Closed (bei Open/Half-Open also der
+	// Öffnungszeitpunkt). Mit geteiltem Zustand ist er die Epoche der Zähler in Redis.
+	since time.Time
+
+	// Generation wird bei jedem Zustandswechsel erhöht. Ergebnisse von Requests,
+	// die in einer früheren Generation zugelassen wurden, werden verworfen.
+	generation uint64
+	probes     int // vergebene Test-Requests im Half-Open-Zustand
+	successes  int // erfolgreiche Test-Requests im Half-Open-Zustand
+
+	// Geteilter Zustand (siehe shared.go): noch nicht übertragene Zähler und der
+	// letzte Stand aller Replicas aus Redis
+	pending        map[int64]*bucket
+	remoteTotal    int
+	remoteFailures int
+	remoteAt       time.Time
+
 	stateGauge prometheus.Gauge
 }
 
@@ -68,62 +162,239 @@ func registerBreakerMetrics(b *Breaker) {
 	log.Printf("CIRCUIT METRICS: Breaker Metriken für Service '%s' registriert.", b.Name)
 }
 
-func NewBreaker(name string, threshold int, timeout time.Duration) *Breaker {
+func NewBreaker(name string, settings Settings) *Breaker {
+	settings = settings.withDefaults()
 	b := &Breaker{
-		Name: name,
-		State: Closed,
-		FailureThreshold: threshold,
-		Timeout: timeout,
+		Name:     name,
+		settings: settings,
+		state:    Closed,
+		window:   newRollingWindow(settings.Window),
+		pending:  make(map[int64]*bucket),
 	}
-	
+
 	registerBreakerMetrics(b)
-	
+
 	return b
 }
 
-func (b *Breaker) checkState() State {
+// Settings liefert die aktuell gültigen Einstellungen
+func (b *Breaker) Settings() Settings {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	return b.settings
+}
 
-	if b.State == Open && time.Since(b.LastFailureTime) > b.Timeout {
-		b.State = HalfOpen
-		b.FailureCount = 0 
-		b.stateGauge.Set(float64(HalfOpen)) 
-		
-		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu HALF-OPEN.", b.Name)
+// State liefert den aktuellen Zustand (Open wechselt nach Ablauf des Timeouts nach Half-Open)
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+// RetryAfter liefert die Restdauer, bis der offene Breaker wieder Test-Requests zulässt
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != Open {
+		return 0
 	}
-	return b.State
+	return max(time.Until(b.since.Add(b.settings.OpenTimeout)), 0)
 }
 
-func (b *Breaker) Success() {
+// update übernimmt geänderte Einstellungen (z.B. nach einem Config-Reload).
+// Zustand und Fenster bleiben erhalten, außer die Fensterlänge ändert sich.
+func (b *Breaker) update(settings Settings) {
+	settings = settings.withDefaults()
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	
-	if b.State != Closed {
-		b.State = Closed
-		b.FailureCount = 0
-		b.stateGauge.Set(float64(Closed))
-		
+	if settings == b.settings {
+		return
+	}
+	if settings.Window != b.settings.Window {
+		b.window = newRollingWindow(settings.Window)
+		clear(b.pending)
+	}
+	b.settings = settings
+	log.Printf("CIRCUIT BREAKER: Einstellungen für Service %s aktualisiert.", b.Name)
+}
+
+// Allow prüft, ob ein Request durchgelassen wird. Die gelieferte Generation muss
+// nach dem Request an Done übergeben werden.
+func (b *Breaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	b.advance(time.Now())
+	generation, state, since := b.generation, b.state, b.since
+	switch state {
+	case Open:
+		b.mu.Unlock()
+		rejectedRequests.WithLabelValues(b.Name, Open.String()).Inc()
+		return generation, ErrOpen
+	case HalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			b.mu.Unlock()
+			rejectedRequests.WithLabelValues(b.Name, HalfOpen.String()).Inc()
+			return generation, ErrTooManyProbes
+		}
+		b.probes++
+	}
+	limit := b.settings.HalfOpenRequests
+	b.mu.Unlock()
+
+	// Mit geteiltem Zustand gilt die Anzahl der Test-Requests über alle Replicas
+	if state == HalfOpen {
+		if s := currentShared(); s != nil && !s.acquireProbe(b.Name, since, limit) {
+			b.mu.Lock()
+			if b.generation == generation && b.probes > 0 {
+				b.probes--
+			}
+			b.mu.Unlock()
+			rejectedRequests.WithLabelValues(b.Name, HalfOpen.String()).Inc()
+			return generation, ErrTooManyProbes
+		}
+	}
+	return generation, nil
+}
+
+// Done meldet das Ergebnis eines mit Allow zugelassenen Requests
+func (b *Breaker) Done(generation uint64, failed bool) {
+	s := currentShared()
+
+	b.mu.Lock()
+	now := time.Now()
+	b.advance(now)
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		b.window.record(now, failed)
+		if s != nil {
+			b.recordPending(now, failed)
+		}
+		if b.shouldTrip(now) {
+			b.setState(Open, now)
+			log.Printf("CIRCUIT BREAKER: Service %s wechselt zu OPEN (Schwellenwert im Fenster erreicht).", b.Name)
+			b.publish(s)
+			return
+		}
+	case HalfOpen:
+		if failed {
+			b.setState(Open, now)
+			log.Printf("CIRCUIT BREAKER: Service %s wechselt zu OPEN (Half-Open Test fehlgeschlagen).", b.Name)
+			b.publish(s)
+			return
+		}
+		b.successes++
+		localDone := b.successes >= b.settings.HalfOpenRequests
+		limit, since := b.settings.HalfOpenRequests, b.since
+		b.mu.Unlock()
+
+		// Mit geteiltem Zustand schließt der Breaker erst, wenn die Test-Requests
+		// aller Replicas zusammen erfolgreich waren
+		if s != nil {
+			if done, ok := s.probeSucceeded(b.Name, since, limit); ok {
+				localDone = done
+			}
+		}
+		if !localDone {
+			return
+		}
+		b.mu.Lock()
+		if b.generation != generation || b.state != HalfOpen {
+			b.mu.Unlock()
+			return
+		}
+		b.setState(Closed, time.Now())
 		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu CLOSED (Erfolgreich zurückgesetzt).", b.Name)
+		b.publish(s)
+		return
 	}
+	b.mu.Unlock()
 }
 
-func (b *Breaker) Fail() {
+// Release gibt einen mit Allow zugelassenen Request ohne Ergebnis zurück, z.B.
+// wenn der Client abgebrochen hat. Ein Test-Request im Half-Open-Zustand wird
+// wieder frei, ohne als Erfolg oder Fehler zu zählen.
+func (b *Breaker) Release(generation uint64) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	
-	if b.State != Open {
-		b.FailureCount++
-		b.LastFailureTime = time.Now()
+	b.advance(time.Now())
+	if generation != b.generation || b.state != HalfOpen || b.probes == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.probes--
+	since := b.since
+	b.mu.Unlock()
+
+	if s := currentShared(); s != nil {
+		s.releaseProbe(b.Name, since)
+	}
+}
 
-		if b.FailureCount >= b.FailureThreshold || b.State == HalfOpen {
-			b.State = Open
-			b.FailureCount = 0
-			b.stateGauge.Set(float64(Open))
+// publish gibt den Mutex frei und verteilt den neuen Zustand an die anderen
+// Replicas (im Hintergrund, damit Redis den Request nicht aufhält)
+func (b *Breaker) publish(s *sharedState) {
+	rec := b.record()
+	b.mu.Unlock()
+	if s != nil {
+		go s.publish(rec)
+	}
+}
+
+// shouldTrip wertet das Fenster aus (nur im Closed-Zustand)
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	total, failures := b.counts(now)
+	s := b.settings
+	if s.FailureThreshold > 0 && failures >= s.FailureThreshold {
+		return true
+	}
+	if s.ErrorThreshold > 0 && total >= s.MinRequests {
+		return float64(failures)*100 >= s.ErrorThreshold*float64(total)
+	}
+	return false
+}
 
-			log.Printf("CIRCUIT BREAKER: Service %s wechselt zu OPEN (Schwellenwert erreicht oder Half-Open Test fehlgeschlagen).", b.Name)
+// counts liefert die Zähler des Fensters: mit aktuellem Stand aus Redis die aller
+// Replicas (plus noch nicht übertragene), sonst nur die lokalen
+func (b *Breaker) counts(now time.Time) (total, failures int) {
+	if b.remoteAt.IsZero() || now.Sub(b.remoteAt) > sharedStaleAfter {
+		return b.window.counts(now)
+	}
+	total, failures = b.remoteTotal, b.remoteFailures
+	for start, p := range b.pending {
+		if now.Sub(time.UnixMilli(start)) < b.settings.Window {
+			total += p.total
+			failures += p.failures
 		}
 	}
+	return total, failures
+}
+
+// advance wechselt nach Ablauf des Timeouts von Open nach Half-Open
+func (b *Breaker) advance(now time.Time) {
+	if b.state == Open && now.Sub(b.since) >= b.settings.OpenTimeout {
+		b.setState(HalfOpen, now)
+		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu HALF-OPEN.", b.Name)
+	}
+}
+
+// setState wechselt den Zustand und setzt alle Zähler zurück. Open und Closed
+// beginnen eine neue Epoche (since), Half-Open behält die des Öffnens.
+func (b *Breaker) setState(state State, at time.Time) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	b.window.reset()
+	clear(b.pending)
+	b.remoteTotal, b.remoteFailures, b.remoteAt = 0, 0, time.Time{}
+	if state != HalfOpen {
+		b.since = time.UnixMilli(at.UnixMilli()) // Millisekunden wie in Redis
+	}
+	b.stateGauge.Set(float64(state))
 }
 
 var BreakerRegistry = struct {
@@ -133,15 +404,79 @@ var BreakerRegistry = struct {
 	breakers: make(map[string]*Breaker),
 }
 
-func GetBreaker(name string, threshold int, timeout time.Duration) *Breaker {
+// GetBreaker liefert den Breaker mit diesem Namen. Ein vorhandener Breaker behält
+// seinen Zustand über Config-Reloads, übernimmt aber die neuen Einstellungen.
+func GetBreaker(name string, settings Settings) *Breaker {
 	BreakerRegistry.Lock()
 	defer BreakerRegistry.Unlock()
 
 	if b, ok := BreakerRegistry.breakers[name]; ok {
+		b.update(settings)
 		return b
 	}
 
-	b := NewBreaker(name, threshold, timeout)
+	b := NewBreaker(name, settings)
 	BreakerRegistry.breakers[name] = b
 	return b
 }
+
+// ErrUnknownBreaker: unter dem Namen ist kein Breaker registriert
+var ErrUnknownBreaker = errors.New("unbekannter circuit breaker")
+
+// Status beschreibt einen Breaker für die Admin-API
+type Status struct {
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since,omitzero"`        // letzter Wechsel nach Open bzw. Closed
+	RetryAfter string    `json:"retry_after,omitempty"` // Restdauer bis Half-Open
+	Requests   int       `json:"window_requests"`
+	Failures   int       `json:"window_failures"`
+}
+
+// Statuses liefert den Zustand aller Breaker, sortiert nach Namen
+func Statuses() []Status {
+	breakers := allBreakers()
+	statuses := make([]Status, 0, len(breakers))
+	for _, b := range breakers {
+		b.mu.Lock()
+		now := time.Now()
+		b.advance(now)
+		total, failures := b.counts(now)
+		st := Status{Name: b.Name, State: b.state.String(), Since: b.since, Requests: total, Failures: failures}
+		if b.state == Open {
+			st.RetryAfter = max(b.since.Add(b.settings.OpenTimeout).Sub(now), 0).Round(time.Second).String()
+		}
+		b.mu.Unlock()
+		statuses = append(statuses, st)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+// Force setzt den Zustand eines Breakers von Hand: Open wirkt wie ein erreichter
+// Schwellenwert (nach OpenTimeout folgt Half-Open), Closed setzt ihn zurück.
+// Mit geteiltem Zustand übernehmen die anderen Replicas den Wechsel.
+func Force(name string, state State) error {
+	if state != Open && state != Closed {
+		return fmt.Errorf("zustand %s kann nicht erzwungen werden (open, closed)", state)
+	}
+	b := lookupBreaker(name)
+	if b == nil {
+		return ErrUnknownBreaker
+	}
+	b.mu.Lock()
+	b.setState(state, time.Now())
+	log.Printf("CIRCUIT BREAKER: Service %s wechselt zu %s (von Hand gesetzt).", b.Name, strings.ToUpper(state.String()))
+	b.publish(currentShared())
+	return nil
+}
+
+// ParseState liest einen Zustand im Format von State.String
+func ParseState(s string) (State, bool) {
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		if s == state.String() {
+			return state, true
+		}
+	}
+	return Closed, false
+}
@@ -0,0 +1,161 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Schritte eines Zustandstests: allow vergibt den nächsten Request (Index in der
+// Reihenfolge der allow-Schritte), done/release beenden ihn, timeout lässt die
+// OpenTimeout ablaufen
+type step struct {
+	do      string
+	req     int
+	failed  bool
+	wantErr error
+}
+
+func allow(wantErr error) step       { return step{do: "allow", wantErr: wantErr} }
+func done(req int, failed bool) step { return step{do: "done", req: req, failed: failed} }
+func release(req int) step           { return step{do: "release", req: req} }
+func timeout() step                  { return step{do: "timeout"} }
+
+// trip öffnet einen Breaker mit FailureThreshold 1 (Request 0)
+var trip = []step{allow(nil), done(0, true)}
+
+func steps(groups ...[]step) []step {
+	var all []step
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		steps    []step
+		want     State
+	}{
+		{
+			name:     "bleibt unter dem Schwellenwert geschlossen",
+			settings: Settings{FailureThreshold: 3},
+			steps:    []step{allow(nil), done(0, true), allow(nil), done(1, true), allow(nil), done(2, false)},
+			want:     Closed,
+		},
+		{
+			name:     "öffnet beim Schwellenwert und lehnt ab",
+			settings: Settings{FailureThreshold: 2},
+			steps:    []step{allow(nil), done(0, true), allow(nil), done(1, true), allow(ErrOpen)},
+			want:     Open,
+		},
+		{
+			name:     "Fehleranteil erst ab MinRequests",
+			settings: Settings{ErrorThreshold: 50, MinRequests: 4},
+			steps:    []step{allow(nil), done(0, true), allow(nil), done(1, true), allow(nil), done(2, false)},
+			want:     Closed,
+		},
+		{
+			name:     "Fehleranteil erreicht",
+			settings: Settings{ErrorThreshold: 50, MinRequests: 4},
+			steps:    []step{allow(nil), done(0, true), allow(nil), done(1, false), allow(nil), done(2, false), allow(nil), done(3, true)},
+			want:     Open,
+		},
+		{
+			name:     "wechselt nach OpenTimeout zu Half-Open",
+			settings: Settings{FailureThreshold: 1},
+			steps:    steps(trip, []step{timeout()}),
+			want:     HalfOpen,
+		},
+		{
+			name:     "Half-Open vergibt nur die erlaubten Test-Requests",
+			settings: Settings{FailureThreshold: 1, HalfOpenRequests: 1},
+			steps:    steps(trip, []step{timeout(), allow(nil), allow(ErrTooManyProbes)}),
+			want:     HalfOpen,
+		},
+		{
+			name:     "erfolgreicher Test schließt",
+			settings: Settings{FailureThreshold: 1},
+			steps:    steps(trip, []step{timeout(), allow(nil), done(1, false), allow(nil)}),
+			want:     Closed,
+		},
+		{
+			name:     "fehlgeschlagener Test öffnet erneut",
+			settings: Settings{FailureThreshold: 1},
+			steps:    steps(trip, []step{timeout(), allow(nil), done(1, true), allow(ErrOpen)}),
+			want:     Open,
+		},
+		{
+			name:     "schließt erst nach allen Test-Requests",
+			settings: Settings{FailureThreshold: 1, HalfOpenRequests: 2},
+			steps:    steps(trip, []step{timeout(), allow(nil), allow(nil), done(1, false)}),
+			want:     HalfOpen,
+		},
+		{
+			name:     "alle Test-Requests erfolgreich",
+			settings: Settings{FailureThreshold: 1, HalfOpenRequests: 2},
+			steps:    steps(trip, []step{timeout(), allow(nil), allow(nil), done(1, false), done(2, false)}),
+			want:     Closed,
+		},
+		{
+			name:     "Release gibt den Test-Request ohne Ergebnis frei",
+			settings: Settings{FailureThreshold: 1, HalfOpenRequests: 1},
+			steps:    steps(trip, []step{timeout(), allow(nil), release(1), allow(nil)}),
+			want:     HalfOpen,
+		},
+		{
+			name:     "Release im Closed-Zustand zählt nicht",
+			settings: Settings{FailureThreshold: 1},
+			steps:    []step{allow(nil), release(0)},
+			want:     Closed,
+		},
+		{
+			name:     "Ergebnis aus früherer Generation wird verworfen",
+			settings: Settings{FailureThreshold: 1},
+			steps:    []step{allow(nil), allow(nil), done(1, true), timeout(), done(0, false)},
+			want:     HalfOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker("test:"+tt.name, tt.settings)
+			var generations []uint64
+			for i, s := range tt.steps {
+				switch s.do {
+				case "allow":
+					generation, err := b.Allow()
+					if !errors.Is(err, s.wantErr) {
+						t.Fatalf("Schritt %d: Allow = %v, erwartet %v", i, err, s.wantErr)
+					}
+					generations = append(generations, generation)
+				case "done":
+					b.Done(generations[s.req], s.failed)
+				case "release":
+					b.Release(generations[s.req])
+				case "timeout":
+					b.mu.Lock()
+					b.since = b.since.Add(-b.settings.OpenTimeout)
+					b.mu.Unlock()
+				}
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("State = %s, erwartet %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerWindowExpires(t *testing.T) {
+	b := NewBreaker("test:window", Settings{FailureThreshold: 2, Window: 50 * time.Millisecond})
+	generation, _ := b.Allow()
+	b.Done(generation, true)
+	time.Sleep(60 * time.Millisecond)
+	generation, _ = b.Allow()
+	b.Done(generation, true)
+	if got := b.State(); got != Closed {
+		t.Errorf("State = %s, erwartet %s (erster Fehler liegt außerhalb des Fensters)", got, Closed)
+	}
+}
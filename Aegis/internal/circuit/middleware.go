@@ -1,30 +1,71 @@
 package circuit
 
 import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
+
+	"gatekeeper/internal/grpc"
 )
 
 type responseWriterInterceptor struct {
 	http.ResponseWriter
 	statusCode int
+	start      time.Time
+	elapsed    time.Duration // Zeit bis zu den Response-Headern
 }
 
 func newResponseWriterInterceptor(w http.ResponseWriter) *responseWriterInterceptor {
 	return &responseWriterInterceptor{
 		ResponseWriter: w,
-		statusCode:	http.StatusOK,
+		statusCode:     http.StatusOK,
+		start:          time.Now(),
 	}
 }
 
 func (rwi *responseWriterInterceptor) WriteHeader(statusCode int) {
-	rwi.statusCode = statusCode
+	if rwi.elapsed == 0 && statusCode >= 200 {
+		rwi.statusCode = statusCode
+		rwi.elapsed = time.Since(rwi.start)
+	}
 	rwi.ResponseWriter.WriteHeader(statusCode)
 }
 
 func (rwi *responseWriterInterceptor) Write(b []byte) (int, error) {
+	if rwi.elapsed == 0 {
+		rwi.elapsed = time.Since(rwi.start)
+	}
 	return rwi.ResponseWriter.Write(b)
 }
 
+// Hijack zählt ein Protokoll-Upgrade als Antwort; die Dauer der übernommenen
+// Verbindung (WebSocket) ist kein langsamer Aufruf
+func (rwi *responseWriterInterceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rwi.ResponseWriter).Hijack()
+	if err == nil && rwi.elapsed == 0 {
+		rwi.statusCode = http.StatusSwitchingProtocols
+		rwi.elapsed = time.Since(rwi.start)
+	}
+	return conn, brw, err
+}
+
+func (rwi *responseWriterInterceptor) Unwrap() http.ResponseWriter {
+	return rwi.ResponseWriter
+}
+
+// responseTime liefert die Zeit bis zu den Response-Headern (bzw. bis zum Ende,
+// wenn nichts geschrieben wurde). Lange Streams zählen damit nicht als langsam.
+func (rwi *responseWriterInterceptor) responseTime() time.Duration {
+	if rwi.elapsed == 0 {
+		return time.Since(rwi.start)
+	}
+	return rwi.elapsed
+}
+
 func CircuitBreakerMiddleware(breaker *Breaker) func(http.Handler) http.Handler {
 	if breaker == nil {
 		return func(next http.Handler) http.Handler { return next }
@@ -33,28 +74,50 @@ func CircuitBreakerMiddleware(breaker *Breaker) func(http.Handler) http.Handler
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			state := breaker.checkState()
-
-			if state == Open {
-				http.Error(w, "Service nicht verfügbar (Circuit Breaker OPEN)", http.StatusServiceUnavailable)
+			generation, err := breaker.Allow()
+			if err != nil {
+				if errors.Is(err, ErrOpen) {
+					if wait := breaker.RetryAfter(); wait > 0 {
+						w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					}
+					http.Error(w, "Service nicht verfügbar (Circuit Breaker OPEN)", http.StatusServiceUnavailable)
+					return
+				}
+				http.Error(w, "Service nicht verfügbar (Circuit Breaker HALF-OPEN)", http.StatusServiceUnavailable)
 				return
 			}
 
-			// Half-Open Test: Wir erlauben den ersten Request. Bei Fehlschlag schaltet der Breaker auf Open.
-			if state == HalfOpen {
-				// Hier könnte man zusätzlich eine Logik implementieren, um nur EINEN Test-Request
-				// durchzulassen, aber die aktuelle Logik im Breaker.Fail() fängt dies ab.
-			}
-
 			rwi := newResponseWriterInterceptor(w)
+			completed := false
+			defer func() {
+				// Panics und abgebrochene Handler zählen als Fehler, sonst bliebe ein
+				// Half-Open-Test für immer vergeben. Hat der Client abgebrochen (der
+				// Proxy bricht dann mit http.ErrAbortHandler ab), wird er nur freigegeben.
+				if completed {
+					return
+				}
+				if r.Context().Err() != nil {
+					breaker.Release(generation)
+					return
+				}
+				breaker.Done(generation, true)
+			}()
 			next.ServeHTTP(rwi, r)
+			completed = true
 
-			// Nur 5xx Fehler lösen den Breaker aus
-			if rwi.statusCode >= 500 {
-				breaker.Fail()
-			} else {
-				breaker.Success()
+			// 5xx Fehler und langsame Antworten lösen den Breaker aus
+			slow := breaker.Settings().SlowCallDuration
+			failed := rwi.statusCode >= 500 || (slow > 0 && rwi.responseTime() >= slow)
+			// gRPC meldet Fehler mit HTTP 200 und grpc-status in Header oder Trailer
+			if grpc.Status(rwi.Header()) == grpc.CodeUnavailable {
+				failed = true
+			}
+			if r.Context().Err() != nil {
+				// Client hat abgebrochen: weder Fehler noch Erfolg des Upstreams
+				breaker.Release(generation)
+				return
 			}
+			breaker.Done(generation, failed)
 		})
 	}
 }
@@ -0,0 +1,417 @@
+package circuit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Geteilter Zustand über alle Aegis-Replicas (optional, AEGIS_CIRCUIT_SHARED_STATE).
+// Pro Breaker liegen in Redis:
+//   circuit:<name>:state                    letzter Wechsel nach Open/Closed (sharedRecord)
+//   circuit:<name>:<since>:<bucket>         Hash mit Requests (t) und Fehlern (f) pro Bucket
+//   circuit:<name>:<since>:probes|successes Test-Requests im Half-Open-Zustand
+// Zustandswechsel werden zusätzlich über Pub/Sub verteilt, damit alle Replicas
+// gleichzeitig öffnen und schließen. Ist Redis nicht erreichbar, arbeitet jede
+// Replica mit ihrem lokalen Zustand weiter.
+
+const (
+	sharedSyncInterval = 500 * time.Millisecond
+	sharedStaleAfter   = 3 * sharedSyncInterval // danach zählen wieder nur lokale Werte
+	sharedOpTimeout    = 200 * time.Millisecond // Redis-Zugriffe im Request-Pfad
+	sharedStateTTL     = 24 * time.Hour
+	sharedEventChannel = "circuit:events"
+)
+
+// sharedRecord ist ein Zustandswechsel (Redis-Key und Pub/Sub-Nachricht)
+type sharedRecord struct {
+	Name   string `json:"name"`
+	State  string `json:"state"` // "open" oder "closed"
+	Since  int64  `json:"since"` // Unix-Millisekunden
+	Origin string `json:"origin"`
+}
+
+type sharedState struct {
+	client   *redis.Client
+	instance string
+	cancel   context.CancelFunc
+	failing  atomic.Bool
+}
+
+var (
+	sharedMu sync.RWMutex
+	shared   *sharedState
+)
+
+// EnableSharedState teilt den Zustand aller Breaker über Redis. Ein erneuter Aufruf
+// (z.B. nach einem Wechsel der Redis-Adresse) ersetzt den bisherigen Client.
+func EnableSharedState(client *redis.Client, instanceID string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &sharedState{client: client, instance: instanceID, cancel: cancel}
+
+	sharedMu.Lock()
+	if shared != nil {
+		shared.cancel()
+	}
+	shared = s
+	sharedMu.Unlock()
+
+	go s.subscribe(ctx)
+	go s.syncLoop(ctx)
+	log.Printf("CIRCUIT BREAKER: Geteilter Zustand über Redis aktiv (Instanz %s).", instanceID)
+}
+
+// DisableSharedState beendet Synchronisation und Pub/Sub
+func DisableSharedState() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared != nil {
+		shared.cancel()
+		shared = nil
+	}
+}
+
+func currentShared() *sharedState {
+	sharedMu.RLock()
+	defer sharedMu.RUnlock()
+	return shared
+}
+
+func sharedPrefix(name string) string {
+	return "circuit:" + name + ":"
+}
+
+func sharedStateKey(name string) string {
+	return sharedPrefix(name) + "state"
+}
+
+func sharedEpochKey(name string, since time.Time, suffix string) string {
+	return fmt.Sprintf("%s%d:%s", sharedPrefix(name), since.UnixMilli(), suffix)
+}
+
+// observe protokolliert nur Wechsel zwischen erreichbar und nicht erreichbar
+func (s *sharedState) observe(err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		sharedErrors.Inc()
+		if !s.failing.Swap(true) {
+			log.Printf("CIRCUIT BREAKER: Redis nicht erreichbar, Breaker arbeiten mit lokalem Zustand: %v", err)
+		}
+		return
+	}
+	if s.failing.Swap(false) {
+		log.Printf("CIRCUIT BREAKER: Redis wieder erreichbar, Breaker-Zustand wird wieder geteilt.")
+	}
+}
+
+// publish speichert einen Zustandswechsel und verteilt ihn an die anderen Replicas
+func (s *sharedState) publish(rec sharedRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedOpTimeout)
+	defer cancel()
+	pipe := s.client.Pipeline()
+	pipe.Set(ctx, sharedStateKey(rec.Name), data, sharedStateTTL)
+	pipe.Publish(ctx, sharedEventChannel, data)
+	_, err = pipe.Exec(ctx)
+	s.observe(err)
+}
+
+// acquireProbe vergibt einen Test-Request über alle Replicas. Bei Redis-Fehlern
+// entscheidet allein die lokale Zählung.
+func (s *sharedState) acquireProbe(name string, since time.Time, limit int) bool {
+	n, err := s.incrEpoch(name, since, "probes")
+	if err != nil {
+		return true
+	}
+	if n > int64(limit) {
+		// Abgelehnte Versuche zurückgeben: sonst bliebe ein per Release
+		// freigegebener Test-Request belegt, bis der Zähler ausläuft
+		s.releaseProbe(name, since)
+		return false
+	}
+	return true
+}
+
+// releaseProbeScript verringert den Zähler nur, solange der Key existiert (ein
+// DECR auf einen abgelaufenen Key legte ihn ohne TTL neu an)
+var releaseProbeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+  return redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+// releaseProbe gibt einen vergebenen Test-Request zurück. Bei Redis-Fehlern
+// läuft der Zähler mit dem Key aus.
+func (s *sharedState) releaseProbe(name string, since time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedOpTimeout)
+	defer cancel()
+	err := releaseProbeScript.Run(ctx, s.client, []string{sharedEpochKey(name, since, "probes")}).Err()
+	s.observe(err)
+}
+
+// probeSucceeded zählt einen erfolgreichen Test-Request. done meldet, ob alle Test-
+// Requests erfolgreich waren; ok ist false, wenn Redis nicht erreichbar war.
+func (s *sharedState) probeSucceeded(name string, since time.Time, limit int) (done, ok bool) {
+	n, err := s.incrEpoch(name, since, "successes")
+	if err != nil {
+		return false, false
+	}
+	return n >= int64(limit), true
+}
+
+func (s *sharedState) incrEpoch(name string, since time.Time, suffix string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedOpTimeout)
+	defer cancel()
+	key := sharedEpochKey(name, since, suffix)
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// Läuft aus, damit abgebrochene Test-Requests den Breaker nicht blockieren
+	pipe.Expire(ctx, key, time.Minute)
+	_, err := pipe.Exec(ctx)
+	s.observe(err)
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// subscribe übernimmt Zustandswechsel anderer Replicas
+func (s *sharedState) subscribe(ctx context.Context) {
+	pubsub := s.client.Subscribe(ctx, sharedEventChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var rec sharedRecord
+			if err := json.Unmarshal([]byte(msg.Payload), &rec); err != nil || rec.Origin == s.instance {
+				continue
+			}
+			if b := lookupBreaker(rec.Name); b != nil {
+				b.mu.Lock()
+				b.applyRemote(rec)
+				b.mu.Unlock()
+			}
+		}
+	}
+}
+
+// syncLoop gleicht regelmäßig Zähler und Zustand aller Breaker mit Redis ab. Das holt
+// auch verpasste Pub/Sub-Nachrichten und lokale Wechsel während eines Ausfalls nach.
+func (s *sharedState) syncLoop(ctx context.Context) {
+	ticker := time.NewTicker(sharedSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, b := range allBreakers() {
+			s.sync(ctx, b)
+		}
+	}
+}
+
+func (s *sharedState) sync(ctx context.Context, b *Breaker) {
+	ctx, cancel := context.WithTimeout(ctx, sharedOpTimeout)
+	defer cancel()
+
+	// Lokale Zähler übernehmen, ohne den Mutex während des Redis-Zugriffs zu halten
+	b.mu.Lock()
+	now := time.Now()
+	b.advance(now)
+	generation, since, settings := b.generation, b.since, b.settings
+	pending := b.pending
+	b.pending = make(map[int64]*bucket)
+	b.mu.Unlock()
+
+	bucketSize := max(settings.Window/windowBuckets, time.Millisecond)
+	pipe := s.client.Pipeline()
+	for start, p := range pending {
+		key := sharedEpochKey(b.Name, since, strconv.FormatInt(start, 10))
+		pipe.HIncrBy(ctx, key, "t", int64(p.total))
+		pipe.HIncrBy(ctx, key, "f", int64(p.failures))
+		pipe.PExpire(ctx, key, settings.Window+bucketSize)
+	}
+	var buckets []*redis.SliceCmd
+	for start := now.Truncate(bucketSize); now.Sub(start) < settings.Window; start = start.Add(-bucketSize) {
+		key := sharedEpochKey(b.Name, since, strconv.FormatInt(start.UnixMilli(), 10))
+		buckets = append(buckets, pipe.HMGet(ctx, key, "t", "f"))
+	}
+	stateCmd := pipe.Get(ctx, sharedStateKey(b.Name))
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		s.observe(err)
+		b.restorePending(generation, pending)
+		return
+	}
+	s.observe(nil)
+
+	total, failures := 0, 0
+	for _, cmd := range buckets {
+		values := cmd.Val()
+		if len(values) == 2 {
+			total += parseCount(values[0])
+			failures += parseCount(values[1])
+		}
+	}
+	var remote *sharedRecord
+	if data, err := stateCmd.Bytes(); err == nil {
+		var rec sharedRecord
+		if json.Unmarshal(data, &rec) == nil {
+			remote = &rec
+		}
+	}
+
+	b.mu.Lock()
+	if remote != nil && b.applyRemote(*remote) {
+		b.mu.Unlock()
+		return
+	}
+	if b.generation != generation {
+		b.mu.Unlock()
+		return
+	}
+	// Eigener Wechsel, der Redis (noch) nicht erreicht hat
+	if !b.since.IsZero() && (remote == nil || newerTransition(b.since.UnixMilli(), b.state, remote.Since, remote.stateValue())) {
+		b.publish(s)
+		return
+	}
+	b.remoteTotal, b.remoteFailures, b.remoteAt = total, failures, now
+	if b.state == Closed && b.shouldTrip(time.Now()) {
+		b.setState(Open, time.Now())
+		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu OPEN (Schwellenwert über alle Replicas erreicht).", b.Name)
+		b.publish(s)
+		return
+	}
+	b.mu.Unlock()
+}
+
+func parseCount(v interface{}) int {
+	str, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(str)
+	return n
+}
+
+// recordPending merkt einen Request für die nächste Übertragung vor (Mutex gehalten)
+func (b *Breaker) recordPending(now time.Time, failed bool) {
+	start := now.Truncate(max(b.settings.Window/windowBuckets, time.Millisecond)).UnixMilli()
+	p, ok := b.pending[start]
+	if !ok {
+		p = &bucket{}
+		b.pending[start] = p
+	}
+	p.total++
+	if failed {
+		p.failures++
+	}
+}
+
+// restorePending übernimmt nicht übertragene Zähler wieder, solange sie noch im
+// Fenster liegen und der Breaker den Zustand nicht gewechselt hat
+func (b *Breaker) restorePending(generation uint64, pending map[int64]*bucket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.generation != generation {
+		return
+	}
+	now := time.Now()
+	for start, p := range pending {
+		if now.Sub(time.UnixMilli(start)) >= b.settings.Window {
+			continue
+		}
+		if cur, ok := b.pending[start]; ok {
+			cur.total += p.total
+			cur.failures += p.failures
+		} else {
+			b.pending[start] = p
+		}
+	}
+}
+
+// record beschreibt den aktuellen Zustand für Redis (Mutex gehalten)
+func (b *Breaker) record() sharedRecord {
+	state := Closed
+	if b.state != Closed {
+		state = Open // Half-Open ergibt sich auf jeder Replica aus dem Öffnungszeitpunkt
+	}
+	rec := sharedRecord{Name: b.Name, State: state.String(), Since: b.since.UnixMilli()}
+	if s := currentShared(); s != nil {
+		rec.Origin = s.instance
+	}
+	return rec
+}
+
+// newerTransition meldet, ob ein Wechsel (since, state) neuer ist als ein anderer.
+// Bei gleichem Zeitpunkt gewinnt Open, damit sich gleichzeitige Wechsel einigen.
+func newerTransition(since int64, state State, otherSince int64, otherState State) bool {
+	if since != otherSince {
+		return since > otherSince
+	}
+	return state != Closed && otherState == Closed
+}
+
+func (rec sharedRecord) stateValue() State {
+	if rec.State == Open.String() {
+		return Open
+	}
+	return Closed
+}
+
+// applyRemote übernimmt einen neueren Zustandswechsel einer anderen Replica
+// (Mutex gehalten). Liefert true, wenn sich der Zustand geändert hat.
+func (b *Breaker) applyRemote(rec sharedRecord) bool {
+	if !newerTransition(rec.Since, rec.stateValue(), b.since.UnixMilli(), b.state) {
+		return false
+	}
+	at := time.UnixMilli(rec.Since)
+	switch rec.State {
+	case Open.String():
+		b.setState(Open, at)
+		b.advance(time.Now())
+		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu OPEN (Zustand von Instanz %s übernommen).", b.Name, rec.Origin)
+	case Closed.String():
+		b.setState(Closed, at)
+		log.Printf("CIRCUIT BREAKER: Service %s wechselt zu CLOSED (Zustand von Instanz %s übernommen).", b.Name, rec.Origin)
+	default:
+		return false
+	}
+	return true
+}
+
+func lookupBreaker(name string) *Breaker {
+	BreakerRegistry.Lock()
+	defer BreakerRegistry.Unlock()
+	return BreakerRegistry.breakers[name]
+}
+
+func allBreakers() []*Breaker {
+	BreakerRegistry.Lock()
+	defer BreakerRegistry.Unlock()
+	breakers := make([]*Breaker, 0, len(BreakerRegistry.breakers))
+	for _, b := range BreakerRegistry.breakers {
+		breakers = append(breakers, b)
+	}
+	return breakers
+}
@@ -0,0 +1,177 @@
+package circuit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// testReplicas liefert zwei Replicas, die sich ein miniredis teilen. Die
+// Hintergrund-Goroutinen (syncLoop, subscribe) starten die Tests bei Bedarf selbst.
+func testReplicas(t *testing.T) (*miniredis.Miniredis, *sharedState, *sharedState) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return m, &sharedState{client: client, instance: "a"}, &sharedState{client: client, instance: "b"}
+}
+
+func recordFailure(b *Breaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.window.record(now, true)
+	b.recordPending(now, true)
+}
+
+func TestSharedCountsTripAllReplicas(t *testing.T) {
+	m, a, b := testReplicas(t)
+	ctx := context.Background()
+	settings := Settings{FailureThreshold: 2}
+	onA, onB := NewBreaker("shared:counts", settings), NewBreaker("shared:counts", settings)
+
+	// Je ein Fehler pro Replica: erst zusammen ist der Schwellenwert erreicht
+	recordFailure(onA)
+	a.sync(ctx, onA)
+	if got := onA.State(); got != Closed {
+		t.Fatalf("Replica a nach eigenem Fehler: %s, erwartet %s", got, Closed)
+	}
+	recordFailure(onB)
+	b.sync(ctx, onB)
+	if got := onB.State(); got != Open {
+		t.Fatalf("Replica b mit Fehlern beider Replicas: %s, erwartet %s", got, Open)
+	}
+
+	// Der Wechsel wird im Hintergrund gespeichert; a übernimmt ihn beim nächsten Sync
+	waitFor(t, func() bool { return m.Exists(sharedStateKey("shared:counts")) })
+	a.sync(ctx, onA)
+	if got := onA.State(); got != Open {
+		t.Fatalf("Replica a nach Sync: %s, erwartet %s", got, Open)
+	}
+}
+
+func TestSharedProbesAcrossReplicas(t *testing.T) {
+	_, a, b := testReplicas(t)
+	since := time.UnixMilli(time.Now().UnixMilli())
+
+	if !a.acquireProbe("shared:probes", since, 1) {
+		t.Fatal("erster Test-Request abgelehnt")
+	}
+	if b.acquireProbe("shared:probes", since, 1) {
+		t.Fatal("zweiter Test-Request auf anderer Replica zugelassen")
+	}
+	// Der abgelehnte Versuch von b belegt nichts, a gibt seinen abgebrochenen frei
+	a.releaseProbe("shared:probes", since)
+	if !b.acquireProbe("shared:probes", since, 1) {
+		t.Fatal("freigegebener Test-Request wurde nicht neu vergeben")
+	}
+
+	if done, ok := a.probeSucceeded("shared:probes", since, 2); !ok || done {
+		t.Fatalf("erster Erfolg: done=%v ok=%v, erwartet done=false", done, ok)
+	}
+	if done, ok := b.probeSucceeded("shared:probes", since, 2); !ok || !done {
+		t.Fatalf("zweiter Erfolg: done=%v ok=%v, erwartet done=true", done, ok)
+	}
+}
+
+func TestReleaseProbeDoesNotRecreateExpiredKey(t *testing.T) {
+	m, a, _ := testReplicas(t)
+	since := time.UnixMilli(time.Now().UnixMilli())
+
+	a.acquireProbe("shared:expired", since, 1)
+	m.FastForward(2 * time.Minute)
+	a.releaseProbe("shared:expired", since)
+	if m.Exists(sharedEpochKey("shared:expired", since, "probes")) {
+		t.Fatal("abgelaufener Zähler wurde ohne TTL neu angelegt")
+	}
+}
+
+func TestSharedEventsApplyRemoteTransitions(t *testing.T) {
+	m, a, b := testReplicas(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	breaker := GetBreaker("shared:events", Settings{FailureThreshold: 1})
+	t.Cleanup(func() {
+		BreakerRegistry.Lock()
+		delete(BreakerRegistry.breakers, breaker.Name)
+		BreakerRegistry.Unlock()
+	})
+	go b.subscribe(ctx)
+	waitFor(t, func() bool { return len(m.PubSubChannels("")) > 0 })
+
+	// Eigene Nachrichten ignoriert die Replica
+	b.publish(sharedRecord{Name: breaker.Name, State: Open.String(), Since: time.Now().UnixMilli(), Origin: "b"})
+	time.Sleep(20 * time.Millisecond)
+	if got := breaker.State(); got != Closed {
+		t.Fatalf("nach eigener Nachricht: %s, erwartet %s", got, Closed)
+	}
+
+	a.publish(sharedRecord{Name: breaker.Name, State: Open.String(), Since: time.Now().UnixMilli(), Origin: "a"})
+	waitFor(t, func() bool { return breaker.State() == Open })
+
+	// Ein älterer Wechsel überschreibt den aktuellen Zustand nicht
+	a.publish(sharedRecord{Name: breaker.Name, State: Closed.String(), Since: time.Now().Add(-time.Hour).UnixMilli(), Origin: "a"})
+	time.Sleep(20 * time.Millisecond)
+	if got := breaker.State(); got != Open {
+		t.Fatalf("nach älterem Wechsel: %s, erwartet %s", got, Open)
+	}
+}
+
+func TestSharedStateFallsBackToLocal(t *testing.T) {
+	m, a, _ := testReplicas(t)
+	breaker := NewBreaker("shared:fallback", Settings{FailureThreshold: 2})
+	recordFailure(breaker)
+	m.Close()
+
+	since := time.UnixMilli(time.Now().UnixMilli())
+	if !a.acquireProbe("shared:fallback", since, 1) {
+		t.Fatal("ohne Redis entscheidet die lokale Zählung über Test-Requests")
+	}
+	a.sync(context.Background(), breaker)
+	if !a.failing.Load() {
+		t.Fatal("Redis-Ausfall wurde nicht erkannt")
+	}
+	// Nicht übertragene Zähler bleiben für den nächsten Sync erhalten
+	breaker.mu.Lock()
+	pending := len(breaker.pending)
+	breaker.mu.Unlock()
+	if pending != 1 {
+		t.Fatalf("%d offene Buckets, erwartet 1", pending)
+	}
+}
+
+func TestNewerTransition(t *testing.T) {
+	tests := []struct {
+		name              string
+		since, otherSince int64
+		state, otherState State
+		want              bool
+	}{
+		{"späterer Wechsel", 2, 1, Closed, Open, true},
+		{"früherer Wechsel", 1, 2, Open, Closed, false},
+		{"gleichzeitig gewinnt Open", 1, 1, Open, Closed, true},
+		{"gleichzeitig verliert Closed", 1, 1, Closed, Open, false},
+		{"gleicher Zustand", 1, 1, Open, Open, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newerTransition(tt.since, tt.state, tt.otherSince, tt.otherState); got != tt.want {
+				t.Fatalf("newerTransition = %v, erwartet %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("Bedingung nicht rechtzeitig erfüllt")
+}
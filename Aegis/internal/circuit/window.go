@@ -0,0 +1,61 @@
+package circuit
+
+import "time"
+
+// windowBuckets ist die Auflösung des rollierenden Fensters
+const windowBuckets = 10
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int // inkl. langsamer Requests
+}
+
+// rollingWindow zählt Requests und Fehler der letzten Fensterdauer in Buckets.
+// Nicht threadsicher, wird nur unter dem Mutex des Breakers benutzt.
+type rollingWindow struct {
+	size    time.Duration
+	buckets [windowBuckets]bucket
+}
+
+func newRollingWindow(size time.Duration) *rollingWindow {
+	return &rollingWindow{size: size}
+}
+
+func (w *rollingWindow) bucketSize() time.Duration {
+	return max(w.size/windowBuckets, time.Millisecond)
+}
+
+// current liefert den Bucket für now und setzt ihn zurück, falls er veraltet ist
+func (w *rollingWindow) current(now time.Time) *bucket {
+	size := w.bucketSize()
+	start := now.Truncate(size)
+	b := &w.buckets[(start.UnixNano()/int64(size))%windowBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+func (w *rollingWindow) record(now time.Time, failed bool) {
+	b := w.current(now)
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+// counts summiert alle Buckets, die noch im Fenster liegen
+func (w *rollingWindow) counts(now time.Time) (total, failures int) {
+	for _, b := range w.buckets {
+		if now.Sub(b.start) < w.size {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *rollingWindow) reset() {
+	w.buckets = [windowBuckets]bucket{}
+}
@@ -0,0 +1,188 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type contextKey struct{}
+
+// resolved ist das Ergebnis für einen Request
+type resolved struct {
+	client string
+	hops   []string // Client und die vertrauenswürdigen Proxies dahinter (ohne direkten Peer)
+}
+
+// Header mit der Proxy-Kette (AEGIS_CLIENT_IP_HEADER)
+const (
+	HeaderXForwardedFor = "x-forwarded-for" // Standard: ALB, nginx, HAProxy hängen hier an
+	HeaderForwarded     = "forwarded"       // RFC 7239
+)
+
+// Resolver ermittelt die Client-IP. Der konfigurierte Header (X-Forwarded-For
+// oder Forwarded) wird nur ausgewertet, wenn der direkte Peer ein
+// vertrauenswürdiger Proxy ist, und von rechts gelesen: der erste Eintrag
+// außerhalb der Proxy-Netze ist der Client. Der jeweils andere Header wird
+// ignoriert, da die Proxies ihn unverändert vom Client durchreichen.
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewResolver erwartet CIDRs oder einzelne Adressen der vertrauenswürdigen
+// Proxies (z.B. "10.0.0.0/8", "192.168.1.10") und den Header, den diese Proxies
+// setzen (leer = X-Forwarded-For). Ohne Proxies zählt nur der Peer.
+func NewResolver(proxies []string, header string) (*Resolver, error) {
+	r := &Resolver{header: strings.ToLower(strings.TrimSpace(header))}
+	switch r.header {
+	case "":
+		r.header = HeaderXForwardedFor
+	case HeaderXForwardedFor, HeaderForwarded:
+	default:
+		return nil, fmt.Errorf("unbekannter Header für die Client-IP: '%s' (x-forwarded-for, forwarded)", header)
+	}
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("ungültige Proxy-Adresse '%s': %w", p, err)
+			}
+			addr = addr.Unmap()
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("ungültiges Proxy-Netz '%s': %w", p, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// Enabled meldet, ob vertrauenswürdige Proxies konfiguriert sind
+func (r *Resolver) Enabled() bool {
+	return r != nil && len(r.trusted) > 0
+}
+
+// Trusted meldet, ob die Adresse zu einem vertrauenswürdigen Proxy gehört
+func (r *Resolver) Trusted(addr netip.Addr) bool {
+	if r == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware ermittelt die Client-IP einmal pro Request und legt sie im Context ab
+func Middleware(r *Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			res := r.resolve(req)
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), contextKey{}, res)))
+		})
+	}
+}
+
+// FromRequest liefert die ermittelte Client-IP (ohne Middleware: die Adresse des Peers)
+func FromRequest(req *http.Request) string {
+	if res, ok := req.Context().Value(contextKey{}).(resolved); ok {
+		return res.client
+	}
+	return peerHost(req.RemoteAddr)
+}
+
+// ForwardedFor liefert die Kette für X-Forwarded-For an den Upstream: Client und
+// vertrauenswürdige Proxies, ohne vom Client gefälschte Einträge davor. Den
+// direkten Peer hängt der Reverse Proxy selbst an.
+func ForwardedFor(req *http.Request) []string {
+	if res, ok := req.Context().Value(contextKey{}).(resolved); ok {
+		return res.hops
+	}
+	return nil
+}
+
+func (r *Resolver) resolve(req *http.Request) resolved {
+	peer := peerHost(req.RemoteAddr)
+	peerAddr, err := netip.ParseAddr(peer)
+	if err != nil || !r.Trusted(peerAddr) {
+		return resolved{client: peer}
+	}
+
+	chain := forwardedChain(req.Header, r.header)
+	res := resolved{client: peerAddr.Unmap().String()}
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := parseNode(chain[i])
+		if !ok {
+			break // Unbekanntes Format: der letzte gültige Eintrag gilt
+		}
+		res.client = addr.String()
+		res.hops = append([]string{res.client}, res.hops...)
+		if !r.Trusted(addr) {
+			break
+		}
+	}
+	return res
+}
+
+// forwardedChain liefert die Einträge des Headers von links nach rechts
+func forwardedChain(h http.Header, header string) []string {
+	var chain []string
+	if header == HeaderForwarded {
+		for _, line := range h.Values("Forwarded") {
+			for _, element := range strings.Split(line, ",") {
+				node := ""
+				for _, pair := range strings.Split(element, ";") {
+					key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						node = strings.Trim(value, `"`)
+					}
+				}
+				chain = append(chain, node)
+			}
+		}
+		return chain
+	}
+	for _, line := range h.Values("X-Forwarded-For") {
+		for _, node := range strings.Split(line, ",") {
+			chain = append(chain, strings.TrimSpace(node))
+		}
+	}
+	return chain
+}
+
+// parseNode liest eine Adresse mit oder ohne Port ("1.2.3.4:80", "[2001:db8::1]:80")
+func parseNode(node string) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// peerHost entfernt den Port, sonst hätte jede Verbindung ihren eigenen Zähler
+func peerHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
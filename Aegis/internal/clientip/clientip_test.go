@@ -0,0 +1,159 @@
+package clientip
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		header     string
+		remoteAddr string
+		xff        []string
+		forwarded  []string
+		wantClient string
+		wantHops   []string
+	}{
+		{
+			name:       "ohne Proxies zählt der Peer",
+			remoteAddr: "203.0.113.7:51000",
+			xff:        []string{"198.51.100.1"},
+			wantClient: "203.0.113.7",
+		},
+		{
+			name:       "Peer ist kein vertrauenswürdiger Proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.7:51000",
+			xff:        []string{"198.51.100.1"},
+			wantClient: "203.0.113.7",
+		},
+		{
+			name:       "erster Eintrag von rechts außerhalb der Proxy-Netze",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"6.6.6.6, 198.51.100.1, 10.0.0.5"},
+			wantClient: "198.51.100.1",
+			wantHops:   []string{"198.51.100.1", "10.0.0.5"},
+		},
+		{
+			name:       "mehrere X-Forwarded-For-Zeilen",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"198.51.100.1", "10.0.0.5"},
+			wantClient: "198.51.100.1",
+			wantHops:   []string{"198.51.100.1", "10.0.0.5"},
+		},
+		{
+			name:       "ungültiger Eintrag beendet die Kette",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"198.51.100.1, unknown, 10.0.0.5"},
+			wantClient: "10.0.0.5",
+			wantHops:   []string{"10.0.0.5"},
+		},
+		{
+			name:       "nur Proxies in der Kette",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"10.0.0.9, 10.0.0.5"},
+			wantClient: "10.0.0.9",
+			wantHops:   []string{"10.0.0.9", "10.0.0.5"},
+		},
+		{
+			name:       "ohne Header bleibt der Proxy der Client",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			wantClient: "10.0.0.2",
+		},
+		{
+			name:       "IPv4-mapped Peer und Einträge mit Port",
+			proxies:    []string{"10.0.0.2"},
+			remoteAddr: "[::ffff:10.0.0.2]:443",
+			xff:        []string{"198.51.100.1:8080"},
+			wantClient: "198.51.100.1",
+			wantHops:   []string{"198.51.100.1"},
+		},
+		{
+			name:       "IPv6 mit Klammern",
+			proxies:    []string{"2001:db8::/32"},
+			remoteAddr: "[2001:db8::1]:443",
+			xff:        []string{"[2001:db9::7]"},
+			wantClient: "2001:db9::7",
+			wantHops:   []string{"2001:db9::7"},
+		},
+		{
+			name:       "Forwarded wird bei X-Forwarded-For ignoriert",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"198.51.100.1"},
+			forwarded:  []string{"for=6.6.6.6"},
+			wantClient: "198.51.100.1",
+			wantHops:   []string{"198.51.100.1"},
+		},
+		{
+			name:       "Forwarded als konfigurierter Header",
+			proxies:    []string{"10.0.0.0/8"},
+			header:     HeaderForwarded,
+			remoteAddr: "10.0.0.2:443",
+			xff:        []string{"6.6.6.6"},
+			forwarded:  []string{`for=198.51.100.1;proto=https, for="[2001:db8::5]:4711"`, "for=10.0.0.5"},
+			wantClient: "2001:db8::5",
+			wantHops:   []string{"2001:db8::5", "10.0.0.5"},
+		},
+		{
+			name:       "Forwarded ohne for-Parameter beendet die Kette",
+			proxies:    []string{"10.0.0.0/8"},
+			header:     HeaderForwarded,
+			remoteAddr: "10.0.0.2:443",
+			forwarded:  []string{"for=198.51.100.1, proto=https"},
+			wantClient: "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewResolver(tt.proxies, tt.header)
+			if err != nil {
+				t.Fatalf("NewResolver: %v", err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			for _, v := range tt.forwarded {
+				req.Header.Add("Forwarded", v)
+			}
+
+			got := r.resolve(req)
+			if got.client != tt.wantClient {
+				t.Errorf("client = %q, erwartet %q", got.client, tt.wantClient)
+			}
+			if !slices.Equal(got.hops, tt.wantHops) {
+				t.Errorf("hops = %v, erwartet %v", got.hops, tt.wantHops)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		header  string
+	}{
+		{"ungültiges Netz", []string{"10.0.0.0/33"}, ""},
+		{"ungültige Adresse", []string{"proxy.local"}, ""},
+		{"unbekannter Header", nil, "x-real-ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewResolver(tt.proxies, tt.header); err == nil {
+				t.Error("Fehler erwartet")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// Modi für das PROXY-Protokoll am Listener (AEGIS_PROXY_PROTOCOL)
+const (
+	ProxyProtocolOff      = ""
+	ProxyProtocolOptional = "optional" // Header von vertrauenswürdigen Proxies auswerten, falls gesendet
+	ProxyProtocolRequire  = "require"  // vertrauenswürdige Proxies müssen den Header senden
+)
+
+// proxyHeaderTimeout begrenzt das Warten auf den PROXY-Header einer neuen Verbindung
+const proxyHeaderTimeout = 5 * time.Second
+
+// ProxyProtocolListener wertet den PROXY-Header (v1 und v2, z.B. von HAProxy oder
+// einem AWS NLB) aus: RemoteAddr der Verbindung ist danach die Adresse des Clients.
+// Nur vertrauenswürdige Proxies dürfen den Header senden, bei allen anderen wird er
+// verworfen und die Adresse der Verbindung bleibt maßgeblich.
+func ProxyProtocolListener(ln net.Listener, r *Resolver, mode string) (net.Listener, error) {
+	trustedPolicy := proxyproto.USE
+	switch mode {
+	case ProxyProtocolOptional:
+	case ProxyProtocolRequire:
+		trustedPolicy = proxyproto.REQUIRE
+	default:
+		return nil, fmt.Errorf("unbekannter Modus für das PROXY-Protokoll: '%s' (optional, require)", mode)
+	}
+	if !r.Enabled() {
+		return nil, fmt.Errorf("das PROXY-Protokoll erfordert vertrauenswürdige Proxies (AEGIS_TRUSTED_PROXIES)")
+	}
+
+	return &proxyproto.Listener{
+		Listener: ln,
+		Policy: func(upstream net.Addr) (proxyproto.Policy, error) {
+			addrPort, err := netip.ParseAddrPort(upstream.String())
+			if err != nil || !r.Trusted(addrPort.Addr()) {
+				return proxyproto.IGNORE, nil
+			}
+			return trustedPolicy, nil
+		},
+		ReadHeaderTimeout: proxyHeaderTimeout,
+	}, nil
+}
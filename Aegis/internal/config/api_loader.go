@@ -2,12 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,44 +20,83 @@ type athenaProjectRoute struct {
 	TargetURL string `json:"target_url"`
 	CacheTTL  string `json:"cache_ttl"`
 
+	Upstreams []struct {
+		URL    string `json:"url"`
+		Weight int    `json:"weight"`
+	} `json:"upstreams"`
+	LoadBalancing string `json:"load_balancing"`
+
+	Methods  []string `json:"methods"`
+	Priority int      `json:"priority"`
+
 	RequiredRoles []string `json:"required_roles"`
 
 	RateLimit struct {
-		Limit  int    `json:"limit"`
-		Window string `json:"window"`
+		Limit     int    `json:"limit"`
+		Window    string `json:"window"`
+		Algorithm string `json:"algorithm"`
+		Burst     int    `json:"burst"`
 	} `json:"rate_limit"`
 
-	CircuitBreaker struct {
-		FailureThreshold int    `json:"failure_threshold"`
-		OpenTimeout      string `json:"open_timeout"`
-	} `json:"circuit_breaker"`
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+
+	HealthCheck HealthCheckConfig `json:"health_check"`
+	Retry       RetryConfig       `json:"retry"`
+	Transform   TransformConfig   `json:"transform"`
+	Streaming   StreamingConfig   `json:"streaming"`
+	Protocol    string            `json:"protocol"`
+	UpstreamTLS UpstreamTLSConfig `json:"upstream_tls"`
+	IPPolicy    IPPolicyConfig    `json:"ip_policy"`
+
+	RateLimitTiers  []RateLimitTier `json:"rate_limit_tiers"`
+	ProjectIPPolicy IPPolicyConfig  `json:"project_ip_policy"`
 }
 
 // fetchFromAthena ist eine wiederverwendbare Helferfunktion
 func fetchFromAthena(apiURL, apiSecret string) ([]byte, error) {
+	data, _, err := fetchFromAthenaIfChanged(apiURL, apiSecret, "")
+	return data, err
+}
+
+// fetchFromAthenaIfChanged sendet die bekannte Version als If-None-Match.
+// Liefert ErrConfigNotModified, wenn Athena mit 304 antwortet.
+func fetchFromAthenaIfChanged(apiURL, apiSecret, knownVersion string) ([]byte, string, error) {
 	if apiURL == "" {
-		return nil, fmt.Errorf("API URL ist leer")
+		return nil, "", fmt.Errorf("API URL ist leer")
 	}
 	client := &http.Client{Timeout: 10 * time.Second}
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("fehler beim Erstellen der Anfrage: %w", err)
+		return nil, "", fmt.Errorf("fehler beim Erstellen der Anfrage: %w", err)
 	}
 	req.Header.Set("X-Internal-Secret", apiSecret)
+	if knownVersion != "" {
+		req.Header.Set("If-None-Match", `"`+knownVersion+`"`)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fehler beim Abrufen von Athena (%s): %w", apiURL, err)
+		return nil, "", fmt.Errorf("fehler beim Abrufen von Athena (%s): %w", apiURL, err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, knownVersion, ErrConfigNotModified
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("athena API (%s) hat mit Status %d geantwortet", apiURL, resp.StatusCode)
+		return nil, "", fmt.Errorf("athena API (%s) hat mit Status %d geantwortet", apiURL, resp.StatusCode)
 	}
-	return io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	return data, resp.Header.Get("X-Config-Version"), err
 }
 
 // LoadConfigFromAPI (Lädt jetzt Routen UND die Context-Map)
 func LoadConfigFromAPI(routesAPIURL, contextMapAPIURL, apiSecret string) (*GatewayConfig, error) {
-	slog.Debug("Lade Gateway-Konfiguration von Athena API...", "routes_url", routesAPIURL)
+	return LoadConfigFromAPIIfChanged(routesAPIURL, contextMapAPIURL, apiSecret, "")
+}
+
+// LoadConfigFromAPIIfChanged lädt die Konfiguration nur, wenn sich die Version
+// gegenüber knownVersion geändert hat (sonst ErrConfigNotModified)
+func LoadConfigFromAPIIfChanged(routesAPIURL, contextMapAPIURL, apiSecret, knownVersion string) (*GatewayConfig, error) {
+	slog.Debug("Lade Gateway-Konfiguration von Athena API...", "routes_url", routesAPIURL, "known_version", knownVersion)
 
 	cfg := loadLocalConfig() // Lädt Ports, Redis, AdminHost etc. aus Env-Vars
 
@@ -63,11 +104,15 @@ func LoadConfigFromAPI(routesAPIURL, contextMapAPIURL, apiSecret string) (*Gatew
 		return nil, fmt.Errorf("ATHENA_CONFIG_URL oder ATHENA_INTERNAL_SECRET ist nicht gesetzt")
 	}
 
-	// 1. Routen laden
-	routesData, err := fetchFromAthena(routesAPIURL, apiSecret)
+	// 1. Routen laden (die Version umfasst auch die Context Map)
+	routesData, version, err := fetchFromAthenaIfChanged(routesAPIURL, apiSecret, knownVersion)
+	if errors.Is(err, ErrConfigNotModified) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("fehler beim Laden der Routen: %w", err)
 	}
+	cfg.Version = version
 
 	var athenaRoutes []*athenaProjectRoute
 	if err := json.Unmarshal(routesData, &athenaRoutes); err != nil {
@@ -77,42 +122,70 @@ func LoadConfigFromAPI(routesAPIURL, contextMapAPIURL, apiSecret string) (*Gatew
 	cfg.Routes = make([]RouteConfig, 0, len(athenaRoutes))
 	for _, ar := range athenaRoutes {
 		aegisRoute := RouteConfig{
+			ID:            ar.ID,
+			ProjectID:     ar.ProjectID,
 			Path:          ar.Path,
+			Methods:       ar.Methods,
+			Priority:      ar.Priority,
 			TargetURL:     ar.TargetURL,
+			LoadBalancing: ar.LoadBalancing,
 			RequiredRoles: ar.RequiredRoles,
 			CacheTTL:      ar.CacheTTL,
 			RateLimit: RateLimitConfig{
-				Limit:  uint32(ar.RateLimit.Limit),
-				Window: ar.RateLimit.Window,
-			},
-			CircuitBreaker: CircuitBreakerConfig{
-				FailureThreshold: ar.CircuitBreaker.FailureThreshold,
-				OpenTimeout:      ar.CircuitBreaker.OpenTimeout,
+				Limit:     uint32(ar.RateLimit.Limit),
+				Window:    ar.RateLimit.Window,
+				Algorithm: ar.RateLimit.Algorithm,
+				Burst:     uint32(ar.RateLimit.Burst),
+				Tiers:     ar.RateLimitTiers,
 			},
+			CircuitBreaker: ar.CircuitBreaker,
+			HealthCheck: ar.HealthCheck,
+			Retry:       ar.Retry,
+			Transform:   ar.Transform,
+			Streaming:   ar.Streaming,
+			Protocol:    ar.Protocol,
+			UpstreamTLS: ar.UpstreamTLS,
+			IPPolicy:    ar.IPPolicy,
+			ProjectIPPolicy: ar.ProjectIPPolicy,
+		}
+		for _, u := range ar.Upstreams {
+			aegisRoute.Upstreams = append(aegisRoute.Upstreams, UpstreamConfig{URL: u.URL, Weight: u.Weight})
 		}
 		cfg.Routes = append(cfg.Routes, aegisRoute)
 	}
-	slog.Info("Gateway-Routen erfolgreich von Athena API geladen", slog.Int("routes_loaded", len(cfg.Routes)))
+	slog.Info("Gateway-Routen erfolgreich von Athena API geladen", slog.Int("routes_loaded", len(cfg.Routes)), slog.String("version", cfg.Version))
 
-	// 2. Context Map laden
-	if contextMapAPIURL != "" {
-		slog.Debug("Lade Context Map von Athena...", "url", contextMapAPIURL)
-		mapData, err := fetchFromAthena(contextMapAPIURL, apiSecret)
-		if err != nil {
-			slog.Warn("Fehler beim Laden der Context Map (wird übersprungen)", "error", err)
-		} else {
-			var contextMap map[string]string
-			if err := json.Unmarshal(mapData, &contextMap); err != nil {
-				slog.Warn("Fehler beim Parsen der Context Map", "error", err)
-			} else {
-				cfg.ContextMap = contextMap
-				slog.Info("Context Map erfolgreich geladen", slog.Int("hosts", len(cfg.ContextMap)))
-			}
+	// 2. Context Map laden. Projekt-Routen sind nur über ihre Hosts erreichbar:
+	// ohne Context Map wären sie alle 404, daher gilt ein Fehler hier als
+	// fehlgeschlagener Sync (der bisherige Stand bleibt aktiv)
+	if contextMapAPIURL == "" {
+		if cfg.HasProjectRoutes() {
+			return nil, ErrContextMapURLMissing
 		}
-	} else {
 		slog.Warn("ATHENA_CONTEXT_MAP_URL nicht gesetzt. Domain-Routing ist deaktiviert.")
 		cfg.ContextMap = make(map[string]string) // Initialisiere leere Map
+		return cfg, nil
+	}
+	slog.Debug("Lade Context Map von Athena...", "url", contextMapAPIURL)
+	mapData, mapVersion, err := fetchFromAthenaIfChanged(contextMapAPIURL, apiSecret, "")
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Laden der Context Map: %w", err)
+	}
+	// Athena versioniert Routen und Context Map gemeinsam. Weicht die Version ab,
+	// hat sich die Konfiguration zwischen beiden Abrufen geändert: der nächste Sync
+	// lädt dann einen konsistenten Stand
+	if mapVersion != version {
+		return nil, fmt.Errorf("versionen von Routen (%q) und Context Map (%q) weichen ab", version, mapVersion)
 	}
+	var contextMap map[string]string
+	if err := json.Unmarshal(mapData, &contextMap); err != nil {
+		return nil, fmt.Errorf("fehler beim Parsen der Context Map: %w", err)
+	}
+	if contextMap == nil {
+		contextMap = make(map[string]string)
+	}
+	cfg.ContextMap = contextMap
+	slog.Info("Context Map erfolgreich geladen", slog.Int("hosts", len(cfg.ContextMap)))
 
 	return cfg, nil
 }
@@ -148,12 +221,60 @@ func loadLocalConfig() *GatewayConfig {
 		cfg.RedisAddr = "localhost:6379"
 	}
 
+	if shared := os.Getenv("AEGIS_CIRCUIT_SHARED_STATE"); shared != "" {
+		enabled, err := strconv.ParseBool(shared)
+		if err != nil {
+			slog.Warn("Ungültiger AEGIS_CIRCUIT_SHARED_STATE, Breaker-Zustand bleibt lokal", "value", shared, "error", err)
+		}
+		cfg.SharedCircuitState = enabled
+	}
+
+	// JWKS: explizit per Env, sonst (ohne PEM-Pfad) abgeleitet aus der Athena-Service-URL
 	cfg.JwtPublicKeyPath = os.Getenv("JWT_PUBLIC_KEY_PATH")
-	if cfg.JwtPublicKeyPath == "" {
+	cfg.JwksURL = os.Getenv("ATHENA_JWKS_URL")
+	if cfg.JwksURL == "" && cfg.JwtPublicKeyPath == "" {
+		if athenaURL := os.Getenv("ATHENA_SERVICE_URL"); athenaURL != "" {
+			cfg.JwksURL = strings.TrimSuffix(athenaURL, "/") + "/.well-known/jwks.json"
+		}
+	}
+
+	if cfg.JwtPublicKeyPath == "" && cfg.JwksURL == "" {
 		slog.Warn("JWT_PUBLIC_KEY_PATH ist nicht gesetzt. JWT-Validierung wird fehlschlagen.")
 		cfg.JwtPublicKeyPath = "configs/public.pem"
 	}
 
+	// TLS am Listener, optional mit Client-Zertifikaten (mTLS für Maschinen-Clients)
+	cfg.TLSCertPath = os.Getenv("AEGIS_TLS_CERT_PATH")
+	cfg.TLSKeyPath = os.Getenv("AEGIS_TLS_KEY_PATH")
+	cfg.TLSCertDir = os.Getenv("AEGIS_TLS_CERT_DIR")
+	cfg.TLSVaultPKIPath = os.Getenv("AEGIS_TLS_VAULT_PKI_PATH")
+	cfg.ACME = ACMEConfig{
+		DirectoryURL: os.Getenv("AEGIS_ACME_DIRECTORY_URL"),
+		Email:        os.Getenv("AEGIS_ACME_EMAIL"),
+		CacheDir:     os.Getenv("AEGIS_ACME_CACHE_DIR"),
+		CABundle:     os.Getenv("AEGIS_ACME_CA_BUNDLE"),
+	}
+	if cfg.ACME.DirectoryURL != "" && cfg.ACME.CacheDir == "" {
+		cfg.ACME.CacheDir = "configs/acme"
+	}
+	if httpPort := os.Getenv("AEGIS_ACME_HTTP_PORT"); httpPort != "" {
+		if cfg.ACME.HTTPPort, err = strconv.Atoi(httpPort); err != nil {
+			slog.Warn("Ungültiger AEGIS_ACME_HTTP_PORT, HTTP-01 ist deaktiviert", "value", httpPort, "error", err)
+			cfg.ACME.HTTPPort = 0
+		}
+	}
+	cfg.ClientAuth = os.Getenv("AEGIS_TLS_CLIENT_AUTH")
+	cfg.ClientCAPath = os.Getenv("AEGIS_TLS_CLIENT_CA_PATH")
+	cfg.ClientIdentity = os.Getenv("AEGIS_TLS_CLIENT_IDENTITY")
+
+	// Client-IP hinter Load Balancern (z.B. "10.0.0.0/8,192.168.1.10")
+	if proxies := os.Getenv("AEGIS_TRUSTED_PROXIES"); proxies != "" {
+		cfg.TrustedProxies = strings.Split(proxies, ",")
+	}
+	cfg.ProxyProtocol = os.Getenv("AEGIS_PROXY_PROTOCOL")
+	cfg.ClientIPHeader = os.Getenv("AEGIS_CLIENT_IP_HEADER")
+	cfg.GeoIPDBPath = os.Getenv("AEGIS_GEOIP_DB_PATH")
+
 	cfg.Cors = CorsConfig{
 		AllowedOrigins: []string{"http://localhost:8082"},
 	}
@@ -161,6 +282,8 @@ func loadLocalConfig() *GatewayConfig {
 	// NEUE ENV-VARIABLEN
 	cfg.ContextMapURL = os.Getenv("ATHENA_CONTEXT_MAP_URL")
 	cfg.AdminHost = os.Getenv("AEGIS_ADMIN_HOST") // z.B. "athena.deine-firma.de"
+	cfg.AdminToken = os.Getenv("AEGIS_ADMIN_TOKEN")
+	cfg.SnapshotPath = SnapshotPathFromEnv()
 
 	return cfg
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadConfigFromAPIContextMap(t *testing.T) {
+	const projectRoutes = `[{"id":"r1","project_id":"p1","path":"/api","target_url":"http://upstream"}]`
+	const globalRoutes = `[{"id":"r1","path":"/api","target_url":"http://upstream"}]`
+
+	tests := []struct {
+		name       string
+		routes     string
+		mapStatus  int
+		mapBody    string
+		mapVersion string
+		noMapURL   bool
+		wantErr    error
+		wantHosts  int
+	}{
+		{
+			name:       "Routen und Context Map mit gleicher Version",
+			routes:     projectRoutes,
+			mapStatus:  http.StatusOK,
+			mapBody:    `{"kunde.example":"p1"}`,
+			mapVersion: "v1",
+			wantHosts:  1,
+		},
+		{
+			name:      "Context Map nicht abrufbar",
+			routes:    projectRoutes,
+			mapStatus: http.StatusInternalServerError,
+			wantErr:   errAny,
+		},
+		{
+			name:       "Context Map nicht parsebar",
+			routes:     projectRoutes,
+			mapStatus:  http.StatusOK,
+			mapBody:    `["kunde.example"]`,
+			mapVersion: "v1",
+			wantErr:    errAny,
+		},
+		{
+			name:       "Konfiguration zwischen den Abrufen geändert",
+			routes:     projectRoutes,
+			mapStatus:  http.StatusOK,
+			mapBody:    `{"kunde.example":"p1"}`,
+			mapVersion: "v2",
+			wantErr:    errAny,
+		},
+		{
+			name:     "Projekt-Routen ohne Context-Map-URL",
+			routes:   projectRoutes,
+			noMapURL: true,
+			wantErr:  ErrContextMapURLMissing,
+		},
+		{
+			name:     "nur globale Routen ohne Context-Map-URL",
+			routes:   globalRoutes,
+			noMapURL: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Config-Version", "v1")
+				w.Write([]byte(tt.routes))
+			})
+			mux.HandleFunc("/context-map", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Config-Version", tt.mapVersion)
+				w.WriteHeader(tt.mapStatus)
+				w.Write([]byte(tt.mapBody))
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			mapURL := srv.URL + "/context-map"
+			if tt.noMapURL {
+				mapURL = ""
+			}
+			cfg, err := LoadConfigFromAPIIfChanged(srv.URL+"/routes", mapURL, "secret", "")
+			switch {
+			case tt.wantErr == errAny && err == nil:
+				t.Fatal("Fehler erwartet, Konfiguration wurde geladen")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("Fehler = %v, erwartet %v", err, tt.wantErr)
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unerwarteter Fehler: %v", err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(cfg.ContextMap) != tt.wantHosts {
+				t.Errorf("Hosts in der Context Map = %d, erwartet %d", len(cfg.ContextMap), tt.wantHosts)
+			}
+			if cfg.Version != "v1" {
+				t.Errorf("Version = %q, erwartet v1", cfg.Version)
+			}
+		})
+	}
+}
+
+// errAny steht in den Tabellen für "irgendein Fehler"
+var errAny = errors.New("beliebiger Fehler")
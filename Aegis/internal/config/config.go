@@ -1,21 +1,162 @@
 package config
 
+import "net"
+
 type RateLimitConfig struct {
-	Limit  uint32 `yaml:"limit" json:"limit"`
-	Window string `yaml:"window" json:"window"`
+	Limit     uint32 `yaml:"limit" json:"limit"`
+	Window    string `yaml:"window" json:"window"`
+	Algorithm string `yaml:"algorithm,omitempty" json:"algorithm,omitempty"` // token_bucket (Default) oder sliding_window
+	Burst     uint32 `yaml:"burst,omitempty" json:"burst,omitempty"`         // Nur token_bucket, Default = Limit
+
+	Tiers []RateLimitTier `yaml:"tiers,omitempty" json:"tiers,omitempty"` // Tiers des Projekts (Reihenfolge = Priorität)
+}
+
+// RateLimitTier ist ein benanntes Limit, das per Rolle oder Plan aus dem JWT gewählt wird.
+// Das Tier "anonymous" gilt für Aufrufer ohne gültiges Token.
+type RateLimitTier struct {
+	Name   string   `yaml:"name" json:"name"`
+	Limit  uint32   `yaml:"limit" json:"limit"`
+	Window string   `yaml:"window" json:"window"`
+	Burst  uint32   `yaml:"burst,omitempty" json:"burst,omitempty"`
+	Roles  []string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	Plans  []string `yaml:"plans,omitempty" json:"plans,omitempty"`
 }
 
+// CircuitBreakerConfig beschreibt einen Breaker mit rollierendem Zeitfenster. Er
+// öffnet bei FailureThreshold Fehlern im Fenster oder, ab MinRequests Requests,
+// bei einem Fehleranteil von ErrorThreshold Prozent. Langsame Antworten
+// (SlowCallDuration) zählen als Fehler.
 type CircuitBreakerConfig struct {
 	FailureThreshold int    `yaml:"failure_threshold" json:"failure_threshold"`
 	OpenTimeout      string `yaml:"open_timeout" json:"open_timeout"`
+	Window           string `yaml:"window,omitempty" json:"window,omitempty"`                         // Standard 60s
+	ErrorThreshold   int    `yaml:"error_threshold,omitempty" json:"error_threshold,omitempty"`       // Prozent, 0 = aus
+	MinRequests      int    `yaml:"min_requests,omitempty" json:"min_requests,omitempty"`             // Standard 10
+	SlowCallDuration string `yaml:"slow_call_duration,omitempty" json:"slow_call_duration,omitempty"` // Leer = aus
+	HalfOpenRequests int    `yaml:"half_open_requests,omitempty" json:"half_open_requests,omitempty"` // Standard 1
+	Key              string `yaml:"key,omitempty" json:"key,omitempty"`                               // "route" (Standard) oder "upstream"
+}
+
+// Enabled meldet, ob für die Route ein Breaker aktiv ist
+func (c CircuitBreakerConfig) Enabled() bool {
+	return c.FailureThreshold > 0 || c.ErrorThreshold > 0
+}
+
+// RetryConfig: fehlgeschlagene Upstream-Requests werden wiederholt, wenn die Methode
+// idempotent ist oder der Client einen Idempotency-Key mitschickt
+type RetryConfig struct {
+	MaxAttempts         int      `yaml:"max_attempts" json:"max_attempts"`                                         // inkl. erstem Versuch, <= 1 = aus
+	StatusCodes         []int    `yaml:"status_codes,omitempty" json:"status_codes,omitempty"`                     // Standard 502, 503, 504
+	RetryOn             []string `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`                             // connect_failure, reset, timeout
+	BackoffBase         string   `yaml:"backoff_base,omitempty" json:"backoff_base,omitempty"`                     // Standard 25ms
+	BackoffMax          string   `yaml:"backoff_max,omitempty" json:"backoff_max,omitempty"`                       // Standard 250ms
+	BudgetPercent       int      `yaml:"budget_percent,omitempty" json:"budget_percent,omitempty"`                 // Retries in Prozent der Requests, Standard 20
+	MinRetriesPerSecond int      `yaml:"min_retries_per_second,omitempty" json:"min_retries_per_second,omitempty"` // Standard 3
+}
+
+// HeaderRulesConfig ändert Header in der Reihenfolge Remove, Set, Add
+type HeaderRulesConfig struct {
+	Add    map[string]string `yaml:"add,omitempty" json:"add,omitempty"`
+	Set    map[string]string `yaml:"set,omitempty" json:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty" json:"remove,omitempty"`
+}
+
+// PathRewriteConfig schreibt den vollständigen Pfad des Clients per Regex um
+// (z.B. "^/api/v1/users/(.*)$" -> "/internal/users/$1"). Ist sie gesetzt, entfällt
+// das automatische Entfernen des Routen-Präfixes.
+type PathRewriteConfig struct {
+	Pattern     string `yaml:"pattern" json:"pattern"`
+	Replacement string `yaml:"replacement" json:"replacement"`
+}
+
+// TransformConfig beschreibt die Umschreibungen einer Route zwischen Client und Upstream
+type TransformConfig struct {
+	PathRewrite     *PathRewriteConfig `yaml:"path_rewrite,omitempty" json:"path_rewrite,omitempty"`
+	RequestHeaders  HeaderRulesConfig  `yaml:"request_headers,omitempty" json:"request_headers,omitempty"`
+	ResponseHeaders HeaderRulesConfig  `yaml:"response_headers,omitempty" json:"response_headers,omitempty"`
+	Query           map[string]string  `yaml:"query,omitempty" json:"query,omitempty"` // Query-Parameter, die gesetzt werden
+}
+
+// StreamingConfig macht eine Route zur Streaming-Route (WebSocket, Server-Sent Events).
+// Für sie gelten die Read-/WriteTimeouts des Servers nicht und der Cache ist aus.
+type StreamingConfig struct {
+	Enabled          bool   `yaml:"enabled" json:"enabled"`
+	IdleTimeout      string `yaml:"idle_timeout,omitempty" json:"idle_timeout,omitempty"`           // Standard 5m, "0s" = aus
+	MaxDuration      string `yaml:"max_duration,omitempty" json:"max_duration,omitempty"`           // Leer = unbegrenzt
+	MaxConnections   int    `yaml:"max_connections,omitempty" json:"max_connections,omitempty"`     // Pro Instanz, 0 = unbegrenzt
+	TokenQueryParam  string `yaml:"token_query_param,omitempty" json:"token_query_param,omitempty"` // z.B. "access_token"
+	TokenSubprotocol bool   `yaml:"token_subprotocol,omitempty" json:"token_subprotocol,omitempty"` // JWT als Subprotocol "bearer.<jwt>"
+}
+
+// UpstreamTLSConfig beschreibt TLS zu den Upstreams einer Route (z.B. mTLS mit
+// Client-Zertifikat). Das Material kommt aus Dateien oder aus einem Vault-Secret
+// (KV v2, Felder ca, cert, key); gesetzte Dateien haben Vorrang.
+type UpstreamTLSConfig struct {
+	CAFile     string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`         // PEM-Bundle, leer = System-CAs
+	CertFile   string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`     // Client-Zertifikat (PEM)
+	KeyFile    string `yaml:"key_file,omitempty" json:"key_file,omitempty"`       // Schlüssel zum Client-Zertifikat (PEM)
+	VaultPath  string `yaml:"vault_path,omitempty" json:"vault_path,omitempty"`   // z.B. "secret/data/aegis/tls/payments"
+	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"` // SNI/Hostname-Prüfung, leer = Host der URL
+	MinVersion string `yaml:"min_version,omitempty" json:"min_version,omitempty"` // "1.2" (Standard) oder "1.3"
+}
+
+// Enabled meldet, ob für die Route eigene TLS-Einstellungen gelten
+func (c UpstreamTLSConfig) Enabled() bool {
+	return c != UpstreamTLSConfig{}
+}
+
+// IPPolicyConfig beschreibt den Zugriff auf Netzwerkebene (vor Rate Limit und Auth).
+// Deny-Einträge haben Vorrang; sind Allow-Einträge gesetzt, muss die Client-IP in
+// einem der Netze oder Länder (ISO 3166, über AEGIS_GEOIP_DB_PATH) liegen.
+type IPPolicyConfig struct {
+	AllowCIDRs     []string `yaml:"allow_cidrs,omitempty" json:"allow_cidrs,omitempty"` // CIDRs oder einzelne IPs
+	DenyCIDRs      []string `yaml:"deny_cidrs,omitempty" json:"deny_cidrs,omitempty"`
+	AllowCountries []string `yaml:"allow_countries,omitempty" json:"allow_countries,omitempty"` // z.B. "DE"
+	DenyCountries  []string `yaml:"deny_countries,omitempty" json:"deny_countries,omitempty"`
 }
+
+// Enabled meldet, ob mindestens eine Regel gesetzt ist
+func (c IPPolicyConfig) Enabled() bool {
+	return len(c.AllowCIDRs) > 0 || len(c.DenyCIDRs) > 0 || len(c.AllowCountries) > 0 || len(c.DenyCountries) > 0
+}
+
+// HealthCheckConfig steuert das aktive Health Checking der Upstreams einer Route
+type HealthCheckConfig struct {
+	Path               string `yaml:"path,omitempty" json:"path,omitempty"`
+	Interval           string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout            string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	HealthyThreshold   int    `yaml:"healthy_threshold,omitempty" json:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold,omitempty" json:"unhealthy_threshold,omitempty"`
+}
+
+// UpstreamConfig beschreibt eine Backend-Instanz einer Route
+type UpstreamConfig struct {
+	URL    string `yaml:"url" json:"url"`
+	Weight int    `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
 type RouteConfig struct {
+	ID             string               `yaml:"id,omitempty" json:"id,omitempty"` // Routen-ID in Athena (für Status-Meldungen)
+	ProjectID      string               `yaml:"project_id,omitempty" json:"project_id,omitempty"` // Leer = globale Route (alle Hosts)
 	Path           string               `yaml:"path" json:"path"`
+	Methods        []string             `yaml:"methods,omitempty" json:"methods,omitempty"` // Leer = alle Methoden
+	Priority       int                  `yaml:"priority,omitempty" json:"priority,omitempty"` // Höher gewinnt bei gleichem Pfad
 	TargetURL      string               `yaml:"target_url" json:"target_url"`
+	Upstreams      []UpstreamConfig     `yaml:"upstreams,omitempty" json:"upstreams,omitempty"`
+	LoadBalancing  string               `yaml:"load_balancing,omitempty" json:"load_balancing,omitempty"`
 	RequiredRoles  []string             `yaml:"required_roles,omitempty" json:"required_roles,omitempty"`
 	RateLimit      RateLimitConfig      `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
 	CacheTTL       string               `yaml:"cache_ttl,omitempty" json:"cache_ttl,omitempty"`
 	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker,omitempty" json:"circuit_breaker,omitempty"`
+	HealthCheck    HealthCheckConfig    `yaml:"health_check,omitempty" json:"health_check,omitempty"`
+	Retry          RetryConfig          `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Transform      TransformConfig      `yaml:"transform,omitempty" json:"transform,omitempty"`
+	Streaming      StreamingConfig      `yaml:"streaming,omitempty" json:"streaming,omitempty"`
+	Protocol       string               `yaml:"protocol,omitempty" json:"protocol,omitempty"` // http (Standard), grpc oder h2c
+	UpstreamTLS    UpstreamTLSConfig    `yaml:"upstream_tls,omitempty" json:"upstream_tls,omitempty"`
+	IPPolicy       IPPolicyConfig       `yaml:"ip_policy,omitempty" json:"ip_policy,omitempty"`
+	// Richtlinie des Projekts, gilt zusätzlich zu IPPolicy (von Athena pro Route mitgeliefert)
+	ProjectIPPolicy IPPolicyConfig `yaml:"project_ip_policy,omitempty" json:"project_ip_policy,omitempty"`
 
 	ProxyTimeout string `yaml:"proxy_timeout,omitempty" json:"proxy_timeout,omitempty"`
 
@@ -23,24 +164,97 @@ type RouteConfig struct {
 	WebhookSignatureHeader string `yaml:"webhook_signature_header,omitempty" json:"webhook_signature_header,omitempty"`
 }
 
+// Targets liefert alle Upstreams der Route. Ohne explizite Liste
+// wird TargetURL als einziges Ziel mit Gewicht 1 verwendet.
+func (r RouteConfig) Targets() []UpstreamConfig {
+	if len(r.Upstreams) == 0 {
+		return []UpstreamConfig{{URL: r.TargetURL, Weight: 1}}
+	}
+	return r.Upstreams
+}
+
 type CorsConfig struct {
 	AllowedOrigins []string `yaml:"allowed_origins,omitempty" json:"allowed_origins,omitempty"`
 }
 
+// ACMEConfig beschreibt die automatische Ausstellung von Zertifikaten für Projekt-Hosts
+// (z.B. Let's Encrypt, lokal Pebble). Challenges laufen per TLS-ALPN-01 am Listener
+// und optional per HTTP-01 auf HTTPPort.
+type ACMEConfig struct {
+	DirectoryURL string `yaml:"directory_url,omitempty" json:"directory_url,omitempty"` // Leer = ACME aus
+	Email        string `yaml:"email,omitempty" json:"email,omitempty"`
+	CacheDir     string `yaml:"cache_dir,omitempty" json:"cache_dir,omitempty"` // Konto-Schlüssel und Zertifikate
+	CABundle     string `yaml:"ca_bundle,omitempty" json:"ca_bundle,omitempty"` // Zusätzliche CAs des ACME-Servers (Pebble)
+	HTTPPort     int    `yaml:"http_port,omitempty" json:"http_port,omitempty"` // 0 = kein HTTP-01
+}
+
 type GatewayConfig struct {
 	Routes []RouteConfig `yaml:"routes" json:"routes"` // Wichtig: JSON-Tag
 	Port   int           `yaml:"port" json:"port"`
 
+	Version      string `yaml:"-" json:"version,omitempty"` // Config-Version von Athena (ETag)
+	SnapshotPath string `yaml:"-" json:"-"`                 // Last-known-good Snapshot (AEGIS_CONFIG_SNAPSHOT_PATH)
+
 	ContextMap    map[string]string `json:"context_map"` // Map[Host] -> ProjectID
 	ContextMapURL string            `json:"-"`           // Wird aus Env geladen, nicht API
 	AdminHost     string            `json:"-"`           // z.B. athena.deine-firma.de
+	AdminToken    string            `json:"-"`           // Bearer-Token der Admin-API am Metrik-Port (leer = aus)
 
 	JwtPublicKeyPath string `yaml:"jwt_public_key_path" json:"jwt_public_key_path"`
+	JwksURL          string `yaml:"jwks_url,omitempty" json:"-"` // Athenas /.well-known/jwks.json (hat Vorrang vor der PEM-Datei)
+	JWKS             []byte `yaml:"-" json:"-"`                  // Letztes JWKS-Dokument, nur beim Start aus dem Snapshot gesetzt
 
 	Cors        CorsConfig `yaml:"cors,omitempty" json:"cors,omitempty"`
 	MetricsPort int        `yaml:"metrics_port,omitempty" json:"metrics_port,omitempty"`
 	RedisAddr   string     `yaml:"redis_addr" json:"redis_addr"`
 
+	// Circuit-Breaker-Zustand über Redis mit allen Replicas teilen (AEGIS_CIRCUIT_SHARED_STATE)
+	SharedCircuitState bool `yaml:"shared_circuit_state,omitempty" json:"-"`
+
 	TLSCertPath string `yaml:"tls_cert_path,omitempty" json:"tls_cert_path,omitempty"`
 	TLSKeyPath  string `yaml:"tls_key_path,omitempty" json:"tls_key_path,omitempty"`
-}
\ No newline at end of file
+
+	// Zertifikate pro Host (SNI) aus einem Verzeichnis, Vault PKI oder per ACME.
+	// TLSCertPath/TLSKeyPath bleibt das Zertifikat für Hosts ohne eigenes.
+	TLSCertDir      string     `yaml:"tls_cert_dir,omitempty" json:"-"`
+	TLSVaultPKIPath string     `yaml:"tls_vault_pki_path,omitempty" json:"-"` // z.B. "pki/issue/aegis-hosts"
+	ACME            ACMEConfig `yaml:"acme,omitempty" json:"-"`
+
+	// Client-Zertifikate am Listener (nur mit TLS): "" (aus), "optional" oder "require".
+	// Die Identität aus dem Zertifikat steht danach wie ein JWT für die ACL bereit.
+	ClientAuth     string `yaml:"client_auth,omitempty" json:"-"`
+	ClientCAPath   string `yaml:"client_ca_path,omitempty" json:"-"`   // PEM-Bundle der zugelassenen Client-CAs
+	ClientIdentity string `yaml:"client_identity,omitempty" json:"-"` // cn (Standard), san_dns, san_uri oder san_email
+
+	// Vertrauenswürdige Proxies (CIDRs/IPs) vor Aegis: nur ihre X-Forwarded-For-,
+	// Forwarded- und PROXY-Angaben zählen für die Client-IP
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty" json:"-"`
+	ProxyProtocol  string   `yaml:"proxy_protocol,omitempty" json:"-"` // "" (aus), "optional" oder "require"
+	ClientIPHeader string   `yaml:"client_ip_header,omitempty" json:"-"` // x-forwarded-for (Standard) oder forwarded
+
+	// MaxMind-Datenbank (z.B. GeoLite2-Country) für Länder in IP-Richtlinien
+	GeoIPDBPath string `yaml:"geoip_db_path,omitempty" json:"-"`
+}
+
+// HasProjectRoutes meldet, ob Routen nur über die Hosts eines Projekts erreichbar sind
+func (c *GatewayConfig) HasProjectRoutes() bool {
+	for _, route := range c.Routes {
+		if route.ProjectID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ProjectIDForHost liefert das Projekt eines Kunden-Hosts (Port wird ignoriert).
+// Der Admin-Host gehört nie zu einem Projekt.
+func (c *GatewayConfig) ProjectIDForHost(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if c.AdminHost != "" && host == c.AdminHost {
+		return "", false
+	}
+	projectID, ok := c.ContextMap[host]
+	return projectID, ok
+}
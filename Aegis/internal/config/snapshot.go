@@ -0,0 +1,156 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	SourceAthena   = "athena"
+	SourceSnapshot = "snapshot"
+
+	defaultSnapshotPath = "configs/config-snapshot.json"
+)
+
+var ErrSnapshotCorrupt = errors.New("config-snapshot ist beschädigt (checksumme stimmt nicht)")
+
+// snapshotData enthält nur die von Athena stammenden Teile der Konfiguration.
+// Ports, Redis etc. kommen beim Start weiterhin aus den Env-Vars.
+type snapshotData struct {
+	Version    string            `json:"version,omitempty"`
+	Routes     []RouteConfig     `json:"routes"`
+	ContextMap map[string]string `json:"context_map"`
+	// Zuletzt geladenes JWKS von Athena: Tokens bleiben im Degraded Mode prüfbar
+	JWKS json.RawMessage `json:"jwks,omitempty"`
+}
+
+type snapshotFile struct {
+	Checksum string          `json:"checksum"` // sha256 über Config (hex)
+	SavedAt  time.Time       `json:"saved_at"`
+	Config   json.RawMessage `json:"config"`
+}
+
+// SyncState beschreibt, woher die aktive Konfiguration stammt (für /health)
+type SyncState struct {
+	Source          string        `json:"source"`
+	Version         string        `json:"version,omitempty"`
+	Degraded        bool          `json:"degraded"`
+	LastSync        time.Time     `json:"last_sync,omitzero"`
+	LastError       string        `json:"last_error,omitempty"`
+	SnapshotSavedAt time.Time     `json:"snapshot_saved_at,omitzero"`
+	LastReload      *ReloadStatus `json:"last_reload,omitempty"`
+}
+
+// Ergebnisse eines Reload-Versuchs
+const (
+	ReloadApplied   = "applied"
+	ReloadUnchanged = "unchanged"
+	ReloadFailed    = "failed"
+)
+
+// ReloadStatus beschreibt den letzten Versuch, die Konfiguration von Athena zu laden
+type ReloadStatus struct {
+	At      time.Time `json:"at"`
+	Trigger string    `json:"trigger"` // watch, poll oder admin
+	Result  string    `json:"result"`
+	Version string    `json:"version,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// SnapshotPathFromEnv liefert den Pfad des Config-Snapshots (AEGIS_CONFIG_SNAPSHOT_PATH)
+func SnapshotPathFromEnv() string {
+	if path := os.Getenv("AEGIS_CONFIG_SNAPSHOT_PATH"); path != "" {
+		return path
+	}
+	return defaultSnapshotPath
+}
+
+// SaveSnapshot speichert den zuletzt erfolgreich angewendeten Stand und das
+// JWKS-Dokument (leer = PEM-Modus oder noch nicht geladen), atomar über eine
+// temporäre Datei, nur für den Prozess-User lesbar
+func SaveSnapshot(path string, cfg *GatewayConfig, jwks []byte) error {
+	data, err := json.Marshal(snapshotData{
+		Version:    cfg.Version,
+		Routes:     cfg.Routes,
+		ContextMap: cfg.ContextMap,
+		JWKS:       jwks,
+	})
+	if err != nil {
+		return fmt.Errorf("fehler beim Serialisieren des Config-Snapshots: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	file, err := json.Marshal(snapshotFile{
+		Checksum: hex.EncodeToString(sum[:]),
+		SavedAt:  time.Now().UTC(),
+		Config:   data,
+	})
+	if err != nil {
+		return fmt.Errorf("fehler beim Serialisieren des Config-Snapshots: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("fehler beim Anlegen des Snapshot-Verzeichnisses: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-snapshot-*")
+	if err != nil {
+		return fmt.Errorf("fehler beim Schreiben des Config-Snapshots: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op nach erfolgreichem Rename
+
+	if _, err := tmp.Write(file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("fehler beim Schreiben des Config-Snapshots: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("fehler beim Schreiben des Config-Snapshots: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("fehler beim Schreiben des Config-Snapshots: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("fehler beim Ersetzen des Config-Snapshots %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadSnapshot lädt den letzten bekannten Stand und prüft die Checksumme.
+// Lokale Einstellungen werden wie beim normalen Start aus den Env-Vars gelesen.
+func LoadSnapshot(path string) (*GatewayConfig, time.Time, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("fehler beim Lesen des Config-Snapshots %s: %w", path, err)
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, time.Time{}, fmt.Errorf("fehler beim Parsen des Config-Snapshots %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(file.Config)
+	if hex.EncodeToString(sum[:]) != file.Checksum {
+		return nil, time.Time{}, ErrSnapshotCorrupt
+	}
+
+	var data snapshotData
+	if err := json.Unmarshal(file.Config, &data); err != nil {
+		return nil, time.Time{}, fmt.Errorf("fehler beim Parsen des Config-Snapshots %s: %w", path, err)
+	}
+
+	cfg := loadLocalConfig()
+	cfg.Version = data.Version
+	cfg.Routes = data.Routes
+	cfg.ContextMap = data.ContextMap
+	cfg.JWKS = data.JWKS
+	if cfg.ContextMap == nil {
+		cfg.ContextMap = make(map[string]string)
+	}
+	return cfg, file.SavedAt, nil
+}
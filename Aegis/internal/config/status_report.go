@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// RouteStatusEntry ist der Apply-Status einer Route, wie Athena ihn erwartet
+type RouteStatusEntry struct {
+	RouteID string `json:"route_id"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// RouteStatusReport wird nach jedem Reload an Athena gemeldet
+type RouteStatusReport struct {
+	InstanceID    string             `json:"instance_id"`
+	ConfigVersion string             `json:"config_version"`
+	Routes        []RouteStatusEntry `json:"routes"`
+}
+
+// StatusURLFromConfigURL leitet den Status-Endpunkt aus ATHENA_CONFIG_URL ab
+// (.../internal/v1/routes/config -> .../internal/v1/routes/status)
+func StatusURLFromConfigURL(configURL string) string {
+	if !strings.HasSuffix(configURL, "/routes/config") {
+		return ""
+	}
+	return strings.TrimSuffix(configURL, "/routes/config") + "/routes/status"
+}
+
+// InstanceID identifiziert diese Aegis-Instanz gegenüber Athena (AEGIS_INSTANCE_ID, sonst Hostname)
+func InstanceID() string {
+	if id := os.Getenv("AEGIS_INSTANCE_ID"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "aegis"
+}
+
+// ReportRouteStatus meldet den Apply-Status aller Routen an Athena
+func ReportRouteStatus(ctx context.Context, statusURL, apiSecret string, report RouteStatusReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("fehler beim Serialisieren des Routen-Status: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, statusURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fehler beim Erstellen der Status-Anfrage: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Secret", apiSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fehler beim Melden des Routen-Status an Athena (%s): %w", statusURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("athena Status-API (%s) hat mit Status %d geantwortet", statusURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrConfigNotModified = errors.New("konfiguration unverändert")
+	ErrWatchNotSupported = errors.New("athena unterstützt keinen Config-Watch")
+	// Projekt-Routen ohne Context Map wären auf keinem Host erreichbar
+	ErrContextMapURLMissing = errors.New("ATHENA_CONTEXT_MAP_URL ist nicht gesetzt, Projekt-Routen wären nicht erreichbar")
+)
+
+// WatchURLFromConfigURL leitet den Watch-Endpunkt aus ATHENA_CONFIG_URL ab
+// (.../internal/v1/routes/config -> .../internal/v1/config/watch)
+func WatchURLFromConfigURL(configURL string) string {
+	if !strings.HasSuffix(configURL, "/routes/config") {
+		return ""
+	}
+	return strings.TrimSuffix(configURL, "/routes/config") + "/config/watch"
+}
+
+var watchClient = &http.Client{} // Timeout pro Anfrage über den Context
+
+// WatchConfigVersion wartet per Long-Poll bei Athena, bis sich die Konfiguration
+// gegenüber knownVersion ändert. Ohne Änderung innerhalb von timeout wird
+// knownVersion zurückgegeben.
+func WatchConfigVersion(ctx context.Context, watchURL, apiSecret, knownVersion string, timeout time.Duration) (string, error) {
+	u, err := url.Parse(watchURL)
+	if err != nil {
+		return "", fmt.Errorf("ungültige Watch-URL %s: %w", watchURL, err)
+	}
+	q := u.Query()
+	q.Set("version", knownVersion)
+	q.Set("timeout", timeout.String())
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout+10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("fehler beim Erstellen der Watch-Anfrage: %w", err)
+	}
+	req.Header.Set("X-Internal-Secret", apiSecret)
+
+	resp, err := watchClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fehler beim Watch auf Athena (%s): %w", watchURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return knownVersion, nil
+	case http.StatusOK:
+		var body struct {
+			Version string `json:"version"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("fehler beim Parsen der Watch-Antwort: %w", err)
+		}
+		return body.Version, nil
+	case http.StatusNotFound:
+		return "", ErrWatchNotSupported
+	default:
+		return "", fmt.Errorf("athena Watch (%s) hat mit Status %d geantwortet", watchURL, resp.StatusCode)
+	}
+}
@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxMessageSize begrenzt die übernommene Fehlermeldung in grpc-message
+const maxMessageSize = 1024
+
+// ErrorMiddleware wandelt Fehlerantworten auf gRPC-Requests, die nicht vom Upstream
+// kommen (Auth, ACL, Rate Limit, Circuit Breaker, Proxy-Fehler usw.), in gRPC-Status
+// um. gRPC-Clients werten nur grpc-status aus, nicht den HTTP-Status oder den Body.
+func ErrorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGRPCRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// errorWriter reicht gRPC-Antworten unverändert durch und hält alle anderen
+// Fehlerantworten zurück, um sie als Trailers-Only-Antwort zu senden
+type errorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int // HTTP-Status der umzuwandelnden Antwort (0 = durchreichen)
+	msg         bytes.Buffer
+}
+
+func (e *errorWriter) WriteHeader(status int) {
+	if e.wroteHeader || (status >= 100 && status < 200) {
+		if !e.wroteHeader {
+			e.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+	e.wroteHeader = true
+	if status < http.StatusBadRequest || IsGRPC(e.Header().Get("Content-Type")) {
+		e.ResponseWriter.WriteHeader(status)
+		return
+	}
+	e.status = status
+}
+
+func (e *errorWriter) Write(b []byte) (int, error) {
+	if !e.wroteHeader {
+		e.WriteHeader(http.StatusOK)
+	}
+	if e.status == 0 {
+		return e.ResponseWriter.Write(b)
+	}
+	if room := maxMessageSize - e.msg.Len(); room > 0 {
+		e.msg.Write(b[:min(len(b), room)])
+	}
+	return len(b), nil
+}
+
+// finish schreibt eine zurückgehaltene Fehlerantwort als gRPC-Status
+func (e *errorWriter) finish() {
+	if e.status == 0 {
+		return
+	}
+	msg := strings.TrimSpace(e.msg.String())
+	if msg == "" {
+		msg = http.StatusText(e.status)
+	}
+	h := e.Header()
+	h.Del("Content-Length")
+	h.Del("X-Content-Type-Options")
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(CodeForHTTPStatus(e.status)))
+	h.Set("Grpc-Message", encodeMessage(msg))
+	e.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+func (e *errorWriter) Flush() {
+	if e.wroteHeader && e.status == 0 {
+		http.NewResponseController(e.ResponseWriter).Flush()
+	}
+}
+
+func (e *errorWriter) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
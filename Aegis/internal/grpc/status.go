@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC-Statuscodes (https://grpc.github.io/grpc/core/md_doc_statuscodes.html)
+const (
+	CodeUnknown           = 2
+	CodeInvalidArgument   = 3
+	CodeDeadlineExceeded  = 4
+	CodePermissionDenied  = 7
+	CodeResourceExhausted = 8
+	CodeUnimplemented     = 12
+	CodeInternal          = 13
+	CodeUnavailable       = 14
+	CodeUnauthenticated   = 16
+)
+
+// IsGRPC prüft, ob ein Content-Type gRPC ist (application/grpc, application/grpc+proto
+// usw., aber nicht gRPC-Web)
+func IsGRPC(contentType string) bool {
+	rest, ok := strings.CutPrefix(contentType, "application/grpc")
+	return ok && (rest == "" || rest[0] == '+' || rest[0] == ';')
+}
+
+// IsGRPCRequest prüft, ob ein Request ein gRPC-Aufruf ist
+func IsGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && IsGRPC(r.Header.Get("Content-Type"))
+}
+
+// CodeForHTTPStatus ordnet einer HTTP-Fehlerantwort des Gateways einen gRPC-Status zu
+func CodeForHTTPStatus(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidArgument
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return CodeUnimplemented
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return CodeDeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+// Status liefert den gRPC-Status einer Antwort aus Headern oder Trailern
+// (-1, wenn keiner gesetzt ist)
+func Status(h http.Header) int {
+	value := h.Get("Grpc-Status")
+	if value == "" {
+		// Nicht angekündigte Trailer setzt der Reverse Proxy mit http.TrailerPrefix
+		if values := h[http.TrailerPrefix+"Grpc-Status"]; len(values) > 0 {
+			value = values[0]
+		}
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return code
+}
+
+// encodeMessage kodiert grpc-message nach der gRPC-Spezifikation (Percent-Encoding
+// aller Bytes außerhalb von 0x20-0x7E sowie '%')
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,294 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/certs"
+	"gatekeeper/internal/config"
+)
+
+// Defaults, falls die Route nur einen Pfad angibt
+const (
+	DefaultInterval           = 10 * time.Second
+	DefaultTimeout            = 2 * time.Second
+	DefaultHealthyThreshold   = 2
+	DefaultUnhealthyThreshold = 3
+)
+
+// Config ist die geparste Health-Check-Konfiguration eines Backends
+type Config struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	TLS                config.UpstreamTLSConfig // TLS der Route, damit auch mTLS-Upstreams geprüft werden
+}
+
+// ParseConfig wandelt die Routen-Konfiguration um. Ohne Pfad ist das
+// Health Checking deaktiviert (ok == false).
+func ParseConfig(hc config.HealthCheckConfig) (cfg Config, ok bool, err error) {
+	if hc.Path == "" {
+		return Config{}, false, nil
+	}
+
+	cfg = Config{
+		Path:               hc.Path,
+		Interval:           DefaultInterval,
+		Timeout:            DefaultTimeout,
+		HealthyThreshold:   DefaultHealthyThreshold,
+		UnhealthyThreshold: DefaultUnhealthyThreshold,
+	}
+	if hc.Interval != "" && hc.Interval != "0s" {
+		if cfg.Interval, err = time.ParseDuration(hc.Interval); err != nil || cfg.Interval <= 0 {
+			return Config{}, false, fmt.Errorf("ungültiges HealthCheck.Interval '%s'", hc.Interval)
+		}
+	}
+	if hc.Timeout != "" && hc.Timeout != "0s" {
+		if cfg.Timeout, err = time.ParseDuration(hc.Timeout); err != nil || cfg.Timeout <= 0 {
+			return Config{}, false, fmt.Errorf("ungültiges HealthCheck.Timeout '%s'", hc.Timeout)
+		}
+	}
+	if hc.HealthyThreshold > 0 {
+		cfg.HealthyThreshold = hc.HealthyThreshold
+	}
+	if hc.UnhealthyThreshold > 0 {
+		cfg.UnhealthyThreshold = hc.UnhealthyThreshold
+	}
+	return cfg, true, nil
+}
+
+// TargetsFromRoutes sammelt alle zu prüfenden Backends aus der Gateway-Konfiguration.
+// Nutzen mehrere Routen dasselbe Backend, gewinnt die erste Konfiguration.
+func TargetsFromRoutes(routes []config.RouteConfig) map[*balancer.Backend]Config {
+	targets := make(map[*balancer.Backend]Config)
+	for _, route := range routes {
+		cfg, ok, err := ParseConfig(route.HealthCheck)
+		if err != nil {
+			slog.Warn("Health Check: Konfiguration ungültig, Route wird nicht geprüft", "path", route.Path, "error", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		cfg.TLS = route.UpstreamTLS
+		for _, t := range route.Targets() {
+			backend, err := balancer.GetBackend(t.URL)
+			if err != nil {
+				continue
+			}
+			if existing, dup := targets[backend]; dup && existing != cfg {
+				slog.Warn("Health Check: Backend wird von mehreren Routen unterschiedlich geprüft, erste Konfiguration gilt", "upstream", t.URL, "path", route.Path)
+				continue
+			}
+			targets[backend] = cfg
+		}
+	}
+	return targets
+}
+
+// TargetStatus ist der Health-Zustand eines Backends (für /health)
+type TargetStatus struct {
+	Upstream  string    `json:"upstream"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type probe struct {
+	backend *balancer.Backend
+	cfg     Config
+	cancel  context.CancelFunc
+	client  *http.Client
+
+	mu        sync.Mutex
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError string
+}
+
+// Checker prüft Backends im Hintergrund und nimmt sie aus der Rotation
+type Checker struct {
+	mu     sync.Mutex
+	probes map[*balancer.Backend]*probe
+	client *http.Client
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		probes: make(map[*balancer.Backend]*probe),
+		client: &http.Client{
+			// Redirects nicht folgen: ein 3xx gilt bereits als gesund
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// clientFor liefert den Client einer Probe: Backends mit eigener TLS-Konfiguration
+// bekommen einen eigenen Transport, alle anderen teilen sich den Standard-Client
+func (c *Checker) clientFor(backend *balancer.Backend, cfg Config) *http.Client {
+	if !cfg.TLS.Enabled() {
+		return c.client
+	}
+	tlsConfig, err := certs.ClientConfig(cfg.TLS)
+	if err != nil {
+		// Die Route wurde beim Laden bereits abgelehnt; die Probe schlägt dann fehl
+		slog.Warn("Health Check: TLS-Konfiguration ungültig", "upstream", backend.URL.String(), "error", err)
+		return c.client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, CheckRedirect: c.client.CheckRedirect}
+}
+
+// Sync gleicht die laufenden Probes mit der neuen Konfiguration ab (Hot Reload)
+func (c *Checker) Sync(targets map[*balancer.Backend]Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for backend, p := range c.probes {
+		cfg, keep := targets[backend]
+		if keep && cfg == p.cfg {
+			continue
+		}
+		p.cancel()
+		if p.client != c.client {
+			p.client.CloseIdleConnections()
+		}
+		delete(c.probes, backend)
+		if !keep {
+			// Nicht mehr geprüfte Backends kommen wieder in Rotation
+			backend.SetHealthy(true)
+			healthyGauge.DeleteLabelValues(backend.URL.String())
+			slog.Info("Health Check: Prüfung beendet", "upstream", backend.URL.String())
+		}
+	}
+
+	for backend, cfg := range targets {
+		if _, running := c.probes[backend]; running {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &probe{backend: backend, cfg: cfg, cancel: cancel, client: c.clientFor(backend, cfg)}
+		c.probes[backend] = p
+		healthyGauge.WithLabelValues(backend.URL.String()).Set(boolToFloat(backend.Healthy()))
+		go c.run(ctx, p)
+		slog.Info("Health Check: Prüfung gestartet", "upstream", backend.URL.String(), "path", cfg.Path, "interval", cfg.Interval.String())
+	}
+}
+
+// Stop beendet alle Probes (Graceful Shutdown)
+func (c *Checker) Stop() {
+	c.Sync(nil)
+}
+
+// Status liefert den Zustand aller geprüften Backends, sortiert nach URL
+func (c *Checker) Status() []TargetStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]TargetStatus, 0, len(c.probes))
+	for backend, p := range c.probes {
+		p.mu.Lock()
+		statuses = append(statuses, TargetStatus{
+			Upstream:  backend.URL.String(),
+			Healthy:   backend.Healthy(),
+			LastCheck: p.lastCheck,
+			LastError: p.lastError,
+		})
+		p.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Upstream < statuses[j].Upstream })
+	return statuses
+}
+
+func (c *Checker) run(ctx context.Context, p *probe) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.check(ctx, p)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context, p *probe) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	u := *p.backend.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + p.cfg.Path
+	u.RawQuery = ""
+
+	var checkErr error
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		checkErr = err
+	} else {
+		req.Header.Set("User-Agent", "aegis-health-check")
+		resp, err := p.client.Do(req)
+		if err != nil {
+			checkErr = err
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				checkErr = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+	}
+
+	// Abgebrochene Probes (Reload/Shutdown) nicht werten
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	upstream := p.backend.URL.String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastCheck = time.Now()
+
+	if checkErr != nil {
+		checksTotal.WithLabelValues(upstream, "failure").Inc()
+		p.lastError = checkErr.Error()
+		p.successes = 0
+		p.failures++
+		if p.backend.Healthy() && p.failures >= p.cfg.UnhealthyThreshold {
+			p.backend.SetHealthy(false)
+			healthyGauge.WithLabelValues(upstream).Set(0)
+			slog.Warn("Health Check: Upstream UNHEALTHY, aus Rotation entfernt", "upstream", upstream, "failures", p.failures, "error", checkErr)
+		}
+		return
+	}
+
+	checksTotal.WithLabelValues(upstream, "success").Inc()
+	p.lastError = ""
+	p.failures = 0
+	p.successes++
+	if !p.backend.Healthy() && p.successes >= p.cfg.HealthyThreshold {
+		p.backend.SetHealthy(true)
+		healthyGauge.WithLabelValues(upstream).Set(1)
+		slog.Info("Health Check: Upstream wieder HEALTHY, zurück in Rotation", "upstream", upstream, "successes", p.successes)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
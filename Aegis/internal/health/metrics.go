@@ -0,0 +1,35 @@
+package health
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "upstream"
+)
+
+var healthyGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "healthy",
+		Help:      "Aktueller Health-Status eines Upstream-Ziels (1=healthy, 0=unhealthy).",
+	},
+	[]string{"upstream"},
+)
+
+var checksTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "health_checks_total",
+		Help:      "Gesamtzahl der aktiven Health Checks, nach Ergebnis (success/failure).",
+	},
+	[]string{"upstream", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(healthyGauge)
+	prometheus.MustRegister(checksTotal)
+}
@@ -0,0 +1,138 @@
+package ipfilter
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// reloadDebounce fasst die Events beim Ersetzen der Datei (z.B. durch geoipupdate) zusammen
+const reloadDebounce = time.Second
+
+// countryRecord ist der Teil eines Country- oder City-Eintrags, den Aegis braucht
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// GeoDB löst Client-IPs über eine Datenbank im MaxMind-Format (GeoLite2/GeoIP2
+// Country oder City) in Länder auf. Die Datei liegt im Speicher und wird nach
+// Änderungen neu geladen; bis dahin bleibt der alte Stand aktiv.
+type GeoDB struct {
+	path    string
+	reader  atomic.Pointer[maxminddb.Reader]
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// OpenGeoDB lädt die Datenbank und startet die Dateiüberwachung
+func OpenGeoDB(path string) (*GeoDB, error) {
+	g := &GeoDB{path: path, done: make(chan struct{})}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Starten der GeoIP-Überwachung: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("fehler beim Überwachen von %s: %w", filepath.Dir(path), err)
+	}
+	g.watcher = watcher
+
+	go g.watchLoop()
+	return g, nil
+}
+
+// Close beendet die Dateiüberwachung
+func (g *GeoDB) Close() {
+	if g == nil {
+		return
+	}
+	close(g.done)
+	g.watcher.Close()
+}
+
+// Country liefert den ISO-3166-Code der Adresse ("" = unbekannt oder keine Datenbank).
+// Ohne Land im Eintrag (z.B. Anycast) gilt das Land der Registrierung.
+func (g *GeoDB) Country(addr netip.Addr) string {
+	if g == nil || !addr.IsValid() {
+		return ""
+	}
+	var record countryRecord
+	if err := g.reader.Load().Lookup(net.IP(addr.AsSlice()), &record); err != nil {
+		slog.Debug("GeoIP-Lookup fehlgeschlagen", "ip", addr.String(), "error", err)
+		return ""
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode
+	}
+	return record.RegisteredCountry.ISOCode
+}
+
+// load liest die Datei vollständig ein. Laufende Lookups behalten den alten
+// Reader, daher kein mmap (der müsste geschlossen werden).
+func (g *GeoDB) load() error {
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		return fmt.Errorf("GeoIP-Datenbank konnte nicht gelesen werden: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("ungültige GeoIP-Datenbank '%s': %w", g.path, err)
+	}
+	if !strings.Contains(reader.Metadata.DatabaseType, "Country") && !strings.Contains(reader.Metadata.DatabaseType, "City") {
+		slog.Warn("GeoIP-Datenbank enthält eventuell keine Länder", "path", g.path, "type", reader.Metadata.DatabaseType)
+	}
+	g.reader.Store(reader)
+
+	geoDBBuild.Set(float64(reader.Metadata.BuildEpoch))
+	slog.Info("GeoIP-Datenbank geladen", "path", g.path, "type", reader.Metadata.DatabaseType,
+		"build", time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC())
+	return nil
+}
+
+// watchLoop lädt die Datenbank nach Änderungen an der Datei neu (Hot Reload)
+func (g *GeoDB) watchLoop() {
+	name := filepath.Base(g.path)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-g.done:
+			return
+		case event, ok := <-g.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != name || (event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write)) {
+				continue
+			}
+			debounce = time.After(reloadDebounce)
+		case err, ok := <-g.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("Fehler der GeoIP-Überwachung", "error", err)
+		case <-debounce:
+			debounce = nil
+			if err := g.load(); err != nil {
+				slog.Error("GeoIP-Datenbank konnte nicht neu geladen werden, alter Stand bleibt aktiv", "error", err)
+			}
+		}
+	}
+}
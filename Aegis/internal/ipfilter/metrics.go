@@ -0,0 +1,33 @@
+package ipfilter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "ipfilter"
+)
+
+var blockedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "blocked_requests_total",
+		Help:      "Von IP-Richtlinien blockierte Requests pro Projekt, Route, Geltungsbereich und Regel.",
+	},
+	[]string{"project", "route", "scope", "rule"},
+)
+
+var geoDBBuild = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "geoip_build_timestamp_seconds",
+		Help:      "Erstellungszeitpunkt (Unix) der geladenen GeoIP-Datenbank.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(blockedRequests, geoDBBuild)
+}
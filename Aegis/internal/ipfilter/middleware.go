@@ -0,0 +1,82 @@
+package ipfilter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"gatekeeper/internal/clientip"
+)
+
+// Route identifiziert die geschützte Route in Metriken und Audit-Log
+type Route struct {
+	Project string // Projekt-ID, "global" für Routen ohne Projekt
+	ID      string // Routen-ID aus Athena, sonst der Pfad
+}
+
+type blockedResponse struct {
+	Error   string `json:"error"`
+	BlockID string `json:"block_id"` // steht auch im Audit-Log
+}
+
+// Middleware weist Requests ab, deren Client-IP (siehe clientip) gegen eine der
+// Richtlinien verstößt: 403 mit einer Block-ID, die im Audit-Log wiederkehrt.
+// Ohne GeoIP-Datenbank ist das Land unbekannt.
+func Middleware(geo *GeoDB, route Route, policies ...*Policy) func(http.Handler) http.Handler {
+	needsCountry := false
+	for _, p := range policies {
+		needsCountry = needsCountry || p.NeedsCountry()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientip.FromRequest(r)
+			addr, err := netip.ParseAddr(ip)
+			if err == nil {
+				addr = addr.Unmap()
+			}
+			country := ""
+			if needsCountry {
+				country = geo.Country(addr)
+			}
+
+			for _, p := range policies {
+				rule := p.check(addr, country)
+				if rule == "" {
+					continue
+				}
+				blockedRequests.WithLabelValues(route.Project, route.ID, p.scope, rule).Inc()
+
+				blockID := newBlockID()
+				slog.WarnContext(r.Context(), "Request durch IP-Richtlinie blockiert",
+					slog.String("event", "IP_POLICY_BLOCK"),
+					slog.String("block_id", blockID),
+					slog.String("project_id", route.Project),
+					slog.String("route_id", route.ID),
+					slog.String("scope", p.scope),
+					slog.String("rule", rule),
+					slog.String("client_ip", ip),
+					slog.String("country", country),
+					slog.String("method", r.Method),
+					slog.String("host", r.Host),
+					slog.String("path", r.URL.Path),
+				)
+
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(blockedResponse{Error: "Zugriff verweigert (IP-Richtlinie)", BlockID: blockID})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newBlockID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Geltungsbereich einer Richtlinie (Label "scope" und Audit-Log)
+const (
+	ScopeProject = "project"
+	ScopeRoute   = "route"
+)
+
+// ruleNotAllowed: Allow-Einträge vorhanden, aber keiner passt
+const ruleNotAllowed = "not_allowed"
+
+// Rules sind die Einträge einer Richtlinie (Aufbau wie config.IPPolicyConfig)
+type Rules struct {
+	AllowCIDRs     []string
+	DenyCIDRs      []string
+	AllowCountries []string
+	DenyCountries  []string
+}
+
+type prefixRule struct {
+	prefix netip.Prefix
+	name   string // z.B. "deny_cidr:10.0.0.0/8"
+}
+
+// Policy ist eine geprüfte Richtlinie. Deny-Einträge haben Vorrang; mit
+// Allow-Einträgen muss die Adresse in einem der Netze oder Länder liegen.
+type Policy struct {
+	scope          string
+	allowNets      []prefixRule
+	denyNets       []prefixRule
+	allowCountries map[string]bool
+	denyCountries  map[string]bool
+}
+
+// NewPolicy prüft die Einträge: CIDRs oder einzelne IPs, Länder als
+// zweistelliger ISO-3166-Code (Groß-/Kleinschreibung egal)
+func NewPolicy(scope string, r Rules) (*Policy, error) {
+	p := &Policy{scope: scope}
+	var err error
+	if p.allowNets, err = parseNets("allow_cidr", r.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if p.denyNets, err = parseNets("deny_cidr", r.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	if p.allowCountries, err = parseCountries(r.AllowCountries); err != nil {
+		return nil, err
+	}
+	if p.denyCountries, err = parseCountries(r.DenyCountries); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NeedsCountry meldet, ob die Richtlinie Länder prüft
+func (p *Policy) NeedsCountry() bool {
+	return len(p.allowCountries) > 0 || len(p.denyCountries) > 0
+}
+
+// check liefert die Regel, die den Client blockiert ("" = erlaubt). Eine
+// ungültige Adresse passt zu keinem Eintrag, ein leeres Land zu keinem Land.
+func (p *Policy) check(addr netip.Addr, country string) string {
+	for _, r := range p.denyNets {
+		if addr.IsValid() && r.prefix.Contains(addr) {
+			return r.name
+		}
+	}
+	if country != "" && p.denyCountries[country] {
+		return "deny_country:" + country
+	}
+	if len(p.allowNets) == 0 && len(p.allowCountries) == 0 {
+		return ""
+	}
+	for _, r := range p.allowNets {
+		if addr.IsValid() && r.prefix.Contains(addr) {
+			return ""
+		}
+	}
+	if country != "" && p.allowCountries[country] {
+		return ""
+	}
+	return ruleNotAllowed
+}
+
+func parseNets(kind string, values []string) ([]prefixRule, error) {
+	rules := make([]prefixRule, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		var prefix netip.Prefix
+		if strings.Contains(v, "/") {
+			parsed, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("ungültiges Netz '%s': %w", v, err)
+			}
+			if parsed.Addr().Is4In6() {
+				parsed = netip.PrefixFrom(parsed.Addr().Unmap(), parsed.Bits()-96)
+			}
+			prefix = parsed.Masked()
+		} else {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("ungültige Adresse '%s': %w", v, err)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		rules = append(rules, prefixRule{prefix: prefix, name: kind + ":" + prefix.String()})
+	}
+	return rules, nil
+}
+
+func parseCountries(values []string) (map[string]bool, error) {
+	countries := make(map[string]bool, len(values))
+	for _, v := range values {
+		code := strings.ToUpper(strings.TrimSpace(v))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("ungültiger Ländercode '%s' (ISO 3166, z.B. DE)", v)
+		}
+		countries[code] = true
+	}
+	return countries, nil
+}
@@ -0,0 +1,142 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		ip      string // leer = unbekannte Adresse
+		country string
+		want    string
+	}{
+		{
+			name: "ohne Regeln ist alles erlaubt",
+			ip:   "198.51.100.1",
+			want: "",
+		},
+		{
+			name:  "Deny-Netz",
+			rules: Rules{DenyCIDRs: []string{"198.51.100.0/24"}},
+			ip:    "198.51.100.1",
+			want:  "deny_cidr:198.51.100.0/24",
+		},
+		{
+			name:  "einzelne Deny-Adresse",
+			rules: Rules{DenyCIDRs: []string{"198.51.100.1"}},
+			ip:    "198.51.100.1",
+			want:  "deny_cidr:198.51.100.1/32",
+		},
+		{
+			name:  "Deny-Netz wird normalisiert",
+			rules: Rules{DenyCIDRs: []string{" 10.1.2.3/8 "}},
+			ip:    "10.200.0.1",
+			want:  "deny_cidr:10.0.0.0/8",
+		},
+		{
+			name:  "IPv4-mapped Deny-Netz gilt für IPv4",
+			rules: Rules{DenyCIDRs: []string{"::ffff:192.0.2.0/120"}},
+			ip:    "192.0.2.9",
+			want:  "deny_cidr:192.0.2.0/24",
+		},
+		{
+			name:    "Deny-Land",
+			rules:   Rules{DenyCountries: []string{"ru"}},
+			ip:      "198.51.100.1",
+			country: "RU",
+			want:    "deny_country:RU",
+		},
+		{
+			name:    "Deny hat Vorrang vor Allow",
+			rules:   Rules{AllowCIDRs: []string{"198.51.100.0/24"}, DenyCountries: []string{"RU"}},
+			ip:      "198.51.100.1",
+			country: "RU",
+			want:    "deny_country:RU",
+		},
+		{
+			name:  "Allow-Netz passt",
+			rules: Rules{AllowCIDRs: []string{"198.51.100.0/24"}},
+			ip:    "198.51.100.1",
+			want:  "",
+		},
+		{
+			name:  "Allow-Netz passt nicht",
+			rules: Rules{AllowCIDRs: []string{"198.51.100.0/24"}},
+			ip:    "203.0.113.1",
+			want:  ruleNotAllowed,
+		},
+		{
+			name:    "Allow-Land reicht bei Allow-Netz und -Land",
+			rules:   Rules{AllowCIDRs: []string{"198.51.100.0/24"}, AllowCountries: []string{"DE"}},
+			ip:      "203.0.113.1",
+			country: "DE",
+			want:    "",
+		},
+		{
+			name:  "unbekanntes Land erfüllt keine Allow-Länder",
+			rules: Rules{AllowCountries: []string{"DE"}},
+			ip:    "203.0.113.1",
+			want:  ruleNotAllowed,
+		},
+		{
+			name:  "unbekanntes Land trifft keine Deny-Länder",
+			rules: Rules{DenyCountries: []string{"DE"}},
+			ip:    "203.0.113.1",
+			want:  "",
+		},
+		{
+			name:  "ungültige Adresse mit Allow-Regeln",
+			rules: Rules{AllowCIDRs: []string{"0.0.0.0/0"}},
+			want:  ruleNotAllowed,
+		},
+		{
+			name:  "ungültige Adresse trifft keine Deny-Netze",
+			rules: Rules{DenyCIDRs: []string{"0.0.0.0/0"}},
+			want:  "",
+		},
+		{
+			name:  "IPv6",
+			rules: Rules{AllowCIDRs: []string{"2001:db8::/32"}},
+			ip:    "2001:db8::1",
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(ScopeRoute, tt.rules)
+			if err != nil {
+				t.Fatalf("NewPolicy: %v", err)
+			}
+			var addr netip.Addr
+			if tt.ip != "" {
+				addr = netip.MustParseAddr(tt.ip)
+			}
+			if got := p.check(addr, tt.country); got != tt.want {
+				t.Errorf("check = %q, erwartet %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicyRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+	}{
+		{"ungültiges Netz", Rules{AllowCIDRs: []string{"10.0.0.0/40"}}},
+		{"ungültige Adresse", Rules{DenyCIDRs: []string{"10.0.0"}}},
+		{"Ländercode zu lang", Rules{AllowCountries: []string{"DEU"}}},
+		{"Ländercode mit Ziffer", Rules{DenyCountries: []string{"D1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPolicy(ScopeProject, tt.rules); err == nil {
+				t.Error("Fehler erwartet")
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"bufio"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"gatekeeper/internal/clientip"
 )
 
 // loggingResponseWriter (Kopie aus Aegis/internal/server/middleware.go)
@@ -25,6 +29,20 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
 	return lrw.ResponseWriter.Write(b)
 }
 
+// Hijack übergibt die Verbindung bei Protokoll-Upgrades (WebSocket)
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(lrw.ResponseWriter).Hijack()
+	if err == nil && lrw.status == 0 {
+		lrw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap erlaubt http.ResponseController den Zugriff auf Flush und Deadlines
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 // RequestLogger (Kopie aus Aegis/internal/server/middleware.go, Paketname geändert)
 func RequestLogger(next http.Handler) http.Handler {
  	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +57,7 @@ func RequestLogger(next http.Handler) http.Handler {
  			slog.String("method", r.Method),
  			slog.String("path", r.URL.Path),
  			slog.String("remote_addr", r.RemoteAddr),
+ 			slog.String("client_ip", clientip.FromRequest(r)),
  			slog.Int("status", lw.status),
  			slog.Duration("duration", duration),
  		}
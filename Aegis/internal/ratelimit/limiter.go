@@ -2,36 +2,121 @@ package ratelimit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"gatekeeper/internal/auth"
+	"gatekeeper/internal/clientip"
 
 	"github.com/go-redis/redis/v8"
 )
 
-// RateLimitMiddleware wendet ein Rate Limit auf eingehende Anfragen an.
-func RateLimitMiddleware(client *redis.Client, limit int, window time.Duration) func(http.Handler) http.Handler {
-	if limit <= 0 {
+// Algorithmen für das Rate Limiting pro Route
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// Policy beschreibt das Rate Limit einer Route
+type Policy struct {
+	Algorithm string        // token_bucket (Default) oder sliding_window
+	Limit     int           // Requests pro Window
+	Window    time.Duration
+	Burst     int           // Nur token_bucket: Bucket-Kapazität (Default = Limit)
+	Scope     string        // Namespace der Zähler, z.B. "<projekt>:<route>"
+	Tiers     []Tier        // Optionale Tiers pro Rolle/Plan (Reihenfolge = Priorität)
+	ProjectID string        // Projekt der Route: Tiers gelten nur für Tokens dieses Projekts
+}
+
+// Result ist das Ergebnis einer Rate-Limit-Prüfung
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Bis das Limit wieder voll verfügbar ist
+	RetryAfter time.Duration // Nur bei Ablehnung
+}
+
+// ValidAlgorithm prüft den Namen des Algorithmus (leer = Default)
+func ValidAlgorithm(algorithm string) bool {
+	return algorithm == "" || algorithm == AlgorithmTokenBucket || algorithm == AlgorithmSlidingWindow
+}
+
+// Allow prüft und verbucht einen Request für den Schlüssel (atomar per Lua in Redis)
+func (p Policy) Allow(ctx context.Context, client *redis.Client, subject string) (Result, error) {
+	key := "ratelimit:" + p.Scope + ":" + subject
+	windowMs := max(p.Window.Milliseconds(), 1)
+
+	var raw interface{}
+	var err error
+	limit := p.Limit
+	switch p.Algorithm {
+	case AlgorithmSlidingWindow:
+		raw, err = slidingWindowScript.Run(ctx, client, []string{key}, windowMs, p.Limit, requestMember()).Result()
+	default:
+		burst := p.Burst
+		if burst <= 0 {
+			burst = p.Limit
+		}
+		limit = burst
+		rate := float64(p.Limit) / float64(windowMs) // Tokens pro ms
+		raw, err = tokenBucketScript.Run(ctx, client, []string{key}, strconv.FormatFloat(rate, 'f', -1, 64), burst).Result()
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok || len(values) != 4 {
+		return Result{}, fmt.Errorf("unerwartete Antwort des Rate-Limit-Skripts: %v", raw)
+	}
+	nums := make([]int64, len(values))
+	for i, v := range values {
+		if nums[i], ok = v.(int64); !ok {
+			return Result{}, fmt.Errorf("unerwartete Antwort des Rate-Limit-Skripts: %v", raw)
+		}
+	}
+
+	return Result{
+		Allowed:    nums[0] == 1,
+		Limit:      limit,
+		Remaining:  int(max(nums[1], 0)),
+		Reset:      time.Duration(nums[2]) * time.Millisecond,
+		RetryAfter: time.Duration(nums[3]) * time.Millisecond,
+	}, nil
+}
+
+// RateLimitMiddleware wendet das Rate Limit der Route auf eingehende Anfragen an.
+// keys dient zur Identifikation des Aufrufers für die Tier-Auswahl (optional).
+func RateLimitMiddleware(client *redis.Client, policy Policy, keys auth.KeyProvider) func(http.Handler) http.Handler {
+	if policy.Limit <= 0 {
 		return func(next http.Handler) http.Handler { return next }
 	}
+	if len(policy.Tiers) == 0 {
+		keys = nil // Ohne Tiers ist keine Token-Prüfung nötig
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			key := getRateLimitKey(r)
+			claims := callerClaims(r, keys)
+			key := getRateLimitKey(r, claims)
 			if key == "" {
 				// Wenn kein Key bestimmt werden kann (z.B. keine IP), lassen wir den Request durch
 				// und zählen ihn als 'allowed' (da das Limit in diesem Fall nicht gilt)
-				rateLimitRequests.WithLabelValues("allowed").Inc() // NEU: Metrik für erlaubte Anfragen
+				rateLimitRequests.WithLabelValues("allowed").Inc()
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			currentCount, err := client.Incr(context.Background(), key).Result()
+			tierPolicy, tier := policy.forCaller(claims)
+			result, err := tierPolicy.Allow(r.Context(), client, tier+":"+key)
 			if err != nil {
 				log.Printf("Redis-Fehler beim Rate Limiting: %v", err)
 				// Fail-Open: Bei Redis-Fehler erlauben wir den Request, um Verfügbarkeit zu gewährleisten.
@@ -39,39 +124,49 @@ func RateLimitMiddleware(client *redis.Client, limit int, window time.Duration)
 				return
 			}
 
-			if currentCount == 1 {
-				// Setze das Verfallsdatum nur beim ersten Zählerstand
-				client.Expire(context.Background(), key, window)
-			}
-
-			if int(currentCount) > limit {
-				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", window.Seconds()))
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
 				http.Error(w, "Rate Limit überschritten", http.StatusTooManyRequests) // HTTP 429
-				rateLimitRequests.WithLabelValues("denied").Inc() // NEU: Metrik für abgelehnte Anfragen
+				rateLimitRequests.WithLabelValues("denied").Inc()
 				return
 			}
-			
-			rateLimitRequests.WithLabelValues("allowed").Inc() // NEU: Metrik für erlaubte Anfragen
+
+			rateLimitRequests.WithLabelValues("allowed").Inc()
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// setRateLimitHeaders setzt die RateLimit-Header (IETF draft-ietf-httpapi-ratelimit-headers)
+func setRateLimitHeaders(w http.ResponseWriter, result Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// requestMember erzeugt ein eindeutiges Member für das Sliding Log
+func requestMember() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + hex.EncodeToString(buf)
+}
+
 // getRateLimitKey extrahiert den eindeutigen Schlüssel für das Rate Limiting (UserID oder IP).
-func getRateLimitKey(r *http.Request) string {
+func getRateLimitKey(r *http.Request, claims *auth.CustomClaims) string {
 	// 1. Versuche, den Schlüssel über den authentifizierten Benutzer zu erhalten
-	if claims, ok := r.Context().Value(auth.ContextKey).(*auth.CustomClaims); ok {
+	if claims != nil {
 		return "user:" + claims.UserID
 	}
 
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		parts := strings.Split(ip, ",")
-		return "ip:" + strings.TrimSpace(parts[0])
+	// 2. Sonst die Client-IP (X-Forwarded-For nur von vertrauenswürdigen Proxies)
+	if ip := clientip.FromRequest(r); ip != "" {
+		return "ip:" + ip
 	}
-    
-    if r.RemoteAddr != "" {
-        return "ip:" + r.RemoteAddr
-    }
 
-	return "" 
+	return ""
 }
@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// testRedis startet ein miniredis mit eingefrorener Uhr. Die Skripte lesen die
+// Zeit per TIME aus Redis, advance stellt sie vor (und lässt TTLs ablaufen).
+func testRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client, func(time.Duration)) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		m.SetTime(now)
+		m.FastForward(d)
+	}
+	return m, client, advance
+}
+
+// limitStep ist ein Request nach advance, mit dem erwarteten Ergebnis
+type limitStep struct {
+	advance       time.Duration
+	wantAllowed   bool
+	wantRemaining int
+	wantRetry     time.Duration
+}
+
+func runLimitSteps(t *testing.T, policy Policy, steps []limitStep) {
+	t.Helper()
+	_, client, advance := testRedis(t)
+	for i, step := range steps {
+		advance(step.advance)
+		res, err := policy.Allow(context.Background(), client, "ip:203.0.113.7")
+		if err != nil {
+			t.Fatalf("Schritt %d: %v", i, err)
+		}
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining || res.RetryAfter != step.wantRetry {
+			t.Fatalf("Schritt %d: allowed=%v remaining=%d retry=%v, erwartet allowed=%v remaining=%d retry=%v",
+				i, res.Allowed, res.Remaining, res.RetryAfter, step.wantAllowed, step.wantRemaining, step.wantRetry)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// 10 Requests pro 10s = 1 Token pro Sekunde, Bucket fasst 3
+	policy := Policy{Limit: 10, Window: 10 * time.Second, Burst: 3, Scope: "p1:r1"}
+
+	tests := []struct {
+		name  string
+		steps []limitStep
+	}{
+		{
+			name: "Burst bis zur Kapazität, dann Ablehnung",
+			steps: []limitStep{
+				{0, true, 2, 0},
+				{0, true, 1, 0},
+				{0, true, 0, 0},
+				{0, false, 0, time.Second},
+			},
+		},
+		{
+			name: "Nachfüllen pro Zeit",
+			steps: []limitStep{
+				{0, true, 2, 0},
+				{0, true, 1, 0},
+				{0, true, 0, 0},
+				{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+				{500 * time.Millisecond, true, 0, 0},
+				{0, false, 0, time.Second},
+			},
+		},
+		{
+			name: "Nachfüllen nie über die Kapazität",
+			steps: []limitStep{
+				{0, true, 2, 0},
+				{time.Hour, true, 2, 0},
+				{0, true, 1, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runLimitSteps(t, policy, tt.steps)
+		})
+	}
+}
+
+func TestTokenBucketBurstDefaultsToLimit(t *testing.T) {
+	_, client, _ := testRedis(t)
+	policy := Policy{Limit: 5, Window: time.Minute, Scope: "p1:r1"}
+	res, err := policy.Allow(context.Background(), client, "ip:203.0.113.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Limit != 5 || res.Remaining != 4 {
+		t.Fatalf("limit=%d remaining=%d, erwartet 5 und 4", res.Limit, res.Remaining)
+	}
+	// Leerer Bucket: 1 Token auf 12s
+	if res.Reset != 12*time.Second {
+		t.Fatalf("reset=%v, erwartet 12s", res.Reset)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	policy := Policy{Algorithm: AlgorithmSlidingWindow, Limit: 2, Window: time.Second, Scope: "p1:r1"}
+
+	tests := []struct {
+		name  string
+		steps []limitStep
+	}{
+		{
+			name: "Limit im Fenster, Retry-After bis der älteste Eintrag herausfällt",
+			steps: []limitStep{
+				{0, true, 1, 0},
+				{400 * time.Millisecond, true, 0, 0},
+				{200 * time.Millisecond, false, 0, 400 * time.Millisecond},
+			},
+		},
+		{
+			name: "Fenstergrenze",
+			steps: []limitStep{
+				{0, true, 1, 0},
+				{400 * time.Millisecond, true, 0, 0},
+				{599 * time.Millisecond, false, 0, time.Millisecond},
+				// Genau ein Fenster nach dem ersten Request fällt dieser heraus
+				{time.Millisecond, true, 0, 0},
+				{0, false, 0, 400 * time.Millisecond},
+			},
+		},
+		{
+			name: "abgelehnte Requests zählen nicht",
+			steps: []limitStep{
+				{0, true, 1, 0},
+				{0, true, 0, 0},
+				{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+				{500 * time.Millisecond, true, 1, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runLimitSteps(t, policy, tt.steps)
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	m, client, _ := testRedis(t)
+	policy := Policy{Limit: 1, Window: time.Minute, Scope: "p1:r1"}
+	handler := RateLimitMiddleware(client, policy, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "203.0.113.7:51000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request(); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("erster Request: status=%d remaining=%q", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+	rec := request()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("zweiter Request: status=%d, erwartet 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After=%q, erwartet 60", got)
+	}
+
+	// Fail-Open: ohne Redis werden Requests durchgelassen
+	m.Close()
+	if rec := request(); rec.Code != http.StatusOK {
+		t.Fatalf("ohne Redis: status=%d, erwartet 200", rec.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gatekeeper/internal/auth"
+)
+
+// AnonymousTier gilt für Aufrufer ohne gültiges Token (Zähler pro IP)
+const AnonymousTier = "anonymous"
+
+// defaultTier ist das Limit der Route selbst (kein passendes Tier)
+const defaultTier = "default"
+
+// Tier ist ein benanntes Limit, das per Rolle oder Plan des Aufrufers gewählt wird
+type Tier struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Burst  int
+	Roles  []string
+	Plans  []string
+}
+
+func (t Tier) matches(claims *auth.CustomClaims) bool {
+	if claims.Plan != "" && slices.Contains(t.Plans, claims.Plan) {
+		return true
+	}
+	for _, role := range claims.Roles {
+		if slices.Contains(t.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// forCaller wählt das Limit für den Aufrufer: das erste passende Tier (Reihenfolge
+// aus Athena), für Anonyme das Tier "anonymous", sonst das Limit der Route.
+// Rollen und Plan gelten nur im Projekt des Tokens: ein Token eines anderen
+// Projekts bekommt das Limit der Route. Der Tier-Name wird Teil des
+// Redis-Schlüssels, damit Tiers getrennt zählen.
+func (p Policy) forCaller(claims *auth.CustomClaims) (Policy, string) {
+	if claims != nil && claims.ProjectID != p.ProjectID {
+		return p, defaultTier
+	}
+	for _, t := range p.Tiers {
+		if claims == nil && t.Name != AnonymousTier {
+			continue
+		}
+		if claims != nil && (t.Name == AnonymousTier || !t.matches(claims)) {
+			continue
+		}
+		tiered := p
+		tiered.Limit, tiered.Window, tiered.Burst = t.Limit, t.Window, t.Burst
+		return tiered, t.Name
+	}
+	return p, defaultTier
+}
+
+// callerClaims liefert die Claims des Aufrufers. Das Rate Limit läuft vor der
+// Auth-Middleware, daher wird ein vorhandenes Bearer Token hier selbst geprüft.
+// Ungültige Tokens zählen als anonym (die Auth-Middleware lehnt sie ggf. ab).
+func callerClaims(r *http.Request, keys auth.KeyProvider) *auth.CustomClaims {
+	if claims, ok := r.Context().Value(auth.ContextKey).(*auth.CustomClaims); ok {
+		return claims
+	}
+	authHeader := r.Header.Get("Authorization")
+	if keys == nil || !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil
+	}
+	claims, err := auth.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "), keys)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"gatekeeper/internal/auth"
+)
+
+func TestPolicyForCaller(t *testing.T) {
+	policy := Policy{
+		Limit:     10,
+		Window:    time.Minute,
+		ProjectID: "p1",
+		Tiers: []Tier{
+			{Name: "gold", Limit: 1000, Window: time.Minute, Plans: []string{"gold"}},
+			{Name: "staff", Limit: 500, Window: time.Minute, Roles: []string{"admin", "support"}},
+			{Name: AnonymousTier, Limit: 2, Window: time.Minute},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		claims    *auth.CustomClaims
+		wantTier  string
+		wantLimit int
+	}{
+		{"anonym", nil, AnonymousTier, 2},
+		{"Plan", &auth.CustomClaims{ProjectID: "p1", Plan: "gold"}, "gold", 1000},
+		{"Rolle", &auth.CustomClaims{ProjectID: "p1", Roles: []string{"user", "support"}}, "staff", 500},
+		{"Reihenfolge bestimmt die Priorität", &auth.CustomClaims{ProjectID: "p1", Plan: "gold", Roles: []string{"admin"}}, "gold", 1000},
+		{"kein passendes Tier", &auth.CustomClaims{ProjectID: "p1", Plan: "free"}, defaultTier, 10},
+		{"angemeldete Aufrufer nie anonym", &auth.CustomClaims{ProjectID: "p1"}, defaultTier, 10},
+		{"Token eines anderen Projekts", &auth.CustomClaims{ProjectID: "p2", Plan: "gold"}, defaultTier, 10},
+		{"Token ohne Projekt", &auth.CustomClaims{Plan: "gold"}, defaultTier, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, tier := policy.forCaller(tt.claims)
+			if tier != tt.wantTier || got.Limit != tt.wantLimit {
+				t.Errorf("forCaller = %s (Limit %d), erwartet %s (Limit %d)", tier, got.Limit, tt.wantTier, tt.wantLimit)
+			}
+		})
+	}
+}
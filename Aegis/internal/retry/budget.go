@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Standardwerte des Retry-Budgets
+const (
+	DefaultBudgetPercent       = 20
+	DefaultMinRetriesPerSecond = 3
+)
+
+// Budget begrenzt Retries auf einen Anteil der Requests, damit sie einen Ausfall
+// nicht verstärken. Jeder Request zahlt percent/100 Token ein, jeder Retry kostet
+// einen. Zusätzlich kommen minPerSecond Token pro Sekunde hinzu, damit auch Routen
+// mit wenig Verkehr wiederholen können. Das Budget gilt pro Route und Instanz.
+type Budget struct {
+	mu           sync.Mutex
+	ratio        float64
+	minPerSecond float64
+	tokens       float64
+	max          float64
+	last         time.Time
+}
+
+func NewBudget(percent, minPerSecond int) *Budget {
+	ratio := float64(percent) / 100
+	return &Budget{
+		ratio:        ratio,
+		minPerSecond: float64(minPerSecond),
+		tokens:       float64(minPerSecond),
+		// Höchstens so viele Token ansparen, wie in zehn Sekunden minimal zufließen
+		max:  max(10*float64(minPerSecond), 1),
+		last: time.Now(),
+	}
+}
+
+// Deposit wird für jeden ursprünglichen Request aufgerufen
+func (b *Budget) Deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+}
+
+// Withdraw reserviert einen Retry; false, wenn das Budget erschöpft ist
+func (b *Budget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		budgetExhausted.Inc()
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *Budget) refill() {
+	now := time.Now()
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.minPerSecond, b.max)
+	b.last = now
+}
@@ -0,0 +1,65 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func withdrawAll(b *Budget) int {
+	n := 0
+	for b.Withdraw() {
+		n++
+	}
+	return n
+}
+
+func TestBudget(t *testing.T) {
+	// 20 % der Requests, ohne Mindestrate (min 0 ergibt ein Budget von höchstens 1)
+	b := NewBudget(20, 0)
+	if b.Withdraw() {
+		t.Fatal("leeres Budget erlaubt einen Retry")
+	}
+	for i := 0; i < 4; i++ {
+		b.Deposit()
+	}
+	if b.Withdraw() {
+		t.Fatal("4 Requests bei 20 % ergeben noch keinen Retry")
+	}
+	b.Deposit()
+	if !b.Withdraw() {
+		t.Fatal("5 Requests bei 20 % ergeben einen Retry")
+	}
+}
+
+func TestBudgetMinPerSecond(t *testing.T) {
+	b := NewBudget(20, 3)
+	if n := withdrawAll(b); n != 3 {
+		t.Fatalf("Startguthaben %d, erwartet 3", n)
+	}
+
+	// Eine Sekunde später sind drei Retries nachgeflossen
+	b.mu.Lock()
+	b.last = b.last.Add(-time.Second)
+	b.mu.Unlock()
+	if n := withdrawAll(b); n != 3 {
+		t.Fatalf("nach einer Sekunde %d Retries, erwartet 3", n)
+	}
+
+	// Nach langer Ruhe höchstens zehn Sekunden Mindestrate
+	b.mu.Lock()
+	b.last = b.last.Add(-time.Hour)
+	b.mu.Unlock()
+	if n := withdrawAll(b); n != 30 {
+		t.Fatalf("nach einer Stunde %d Retries, erwartet 30", n)
+	}
+}
+
+func TestNilBudgetIsUnlimited(t *testing.T) {
+	var b *Budget
+	b.Deposit()
+	for i := 0; i < 100; i++ {
+		if !b.Withdraw() {
+			t.Fatal("ohne Budget wurde ein Retry abgelehnt")
+		}
+	}
+}
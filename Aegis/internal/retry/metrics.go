@@ -0,0 +1,33 @@
+package retry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "retry"
+)
+
+var retryAttempts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "attempts_total",
+		Help:      "Wiederholte Upstream-Requests nach Grund (status, connect_failure, reset, timeout).",
+	},
+	[]string{"reason"},
+)
+
+var budgetExhausted = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "budget_exhausted_total",
+		Help:      "Retries, die wegen eines erschöpften Retry-Budgets nicht ausgeführt wurden.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(retryAttempts, budgetExhausted)
+}
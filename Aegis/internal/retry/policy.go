@@ -0,0 +1,114 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// Fehlerklassen, die wiederholt werden können (RetryConfig.RetryOn)
+const (
+	ConnectFailure = "connect_failure" // Verbindungsaufbau fehlgeschlagen, Request nie angekommen
+	Reset          = "reset"           // Verbindung vor der Antwort abgebrochen
+	Timeout        = "timeout"         // Keine Antwort innerhalb des ProxyTimeout
+)
+
+// Standardwerte, wenn in der Route nichts angegeben ist
+var (
+	DefaultStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	DefaultRetryOn     = []string{ConnectFailure, Reset}
+)
+
+const (
+	DefaultBackoffBase = 25 * time.Millisecond
+	DefaultBackoffMax  = 250 * time.Millisecond
+	// IdempotencyKeyHeader erlaubt Retries auch für POST/PATCH
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// ValidErrorClass prüft einen Eintrag aus RetryOn
+func ValidErrorClass(class string) bool {
+	return class == ConnectFailure || class == Reset || class == Timeout
+}
+
+// Policy beschreibt, wann ein Request an den Upstream wiederholt wird
+type Policy struct {
+	MaxAttempts int      // inkl. des ersten Versuchs
+	StatusCodes []int    // Antworten, die wiederholt werden
+	RetryOn     []string // Fehlerklassen, die wiederholt werden
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Budget      *Budget // begrenzt Retries im Verhältnis zu den Requests (nil = unbegrenzt)
+}
+
+// Eligible prüft, ob ein Request überhaupt wiederholt werden darf: nur idempotente
+// Methoden oder Requests mit Idempotency-Key, keine Protokoll-Upgrades
+func (p *Policy) Eligible(r *http.Request) bool {
+	if p == nil || p.MaxAttempts <= 1 || r.Header.Get("Upgrade") != "" {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// RetryStatus prüft, ob eine Antwort mit diesem Status wiederholt wird
+func (p *Policy) RetryStatus(status int) bool {
+	return slices.Contains(p.StatusCodes, status)
+}
+
+// RetryError liefert die Fehlerklasse eines Proxy-Fehlers, wenn er wiederholt wird
+func (p *Policy) RetryError(ctx context.Context, err error) (string, bool) {
+	if ctx.Err() != nil {
+		return "", false // Client hat abgebrochen
+	}
+	class := Classify(err)
+	return class, class != "" && slices.Contains(p.RetryOn, class)
+}
+
+// Classify ordnet einen Transportfehler einer Fehlerklasse zu (leer = unbekannt)
+func Classify(err error) string {
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial", errors.Is(err, syscall.ECONNREFUSED):
+		return ConnectFailure
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Reset
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout
+	}
+	return ""
+}
+
+// Backoff liefert die Wartezeit vor dem n-ten Retry (ab 1): exponentiell mit
+// vollem Jitter, begrenzt auf BackoffMax
+func (p *Policy) Backoff(retry int) time.Duration {
+	ceiling := p.BackoffMax
+	if shift := retry - 1; shift < 30 {
+		ceiling = min(p.BackoffBase<<shift, p.BackoffMax)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// Attempt reserviert einen Retry aus dem Budget und zählt ihn mit seinem Grund
+func (p *Policy) Attempt(reason string) bool {
+	if !p.Budget.Withdraw() {
+		return false
+	}
+	retryAttempts.WithLabelValues(reason).Inc()
+	return true
+}
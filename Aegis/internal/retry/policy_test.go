@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEligible(t *testing.T) {
+	policy := &Policy{MaxAttempts: 3}
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{"GET", http.MethodGet, nil, true},
+		{"PUT ist idempotent", http.MethodPut, nil, true},
+		{"POST ohne Idempotency-Key", http.MethodPost, nil, false},
+		{"POST mit Idempotency-Key", http.MethodPost, map[string]string{IdempotencyKeyHeader: "k1"}, true},
+		{"PATCH mit Idempotency-Key", http.MethodPatch, map[string]string{IdempotencyKeyHeader: "k1"}, true},
+		{"Protokoll-Upgrade", http.MethodGet, map[string]string{"Upgrade": "websocket"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := policy.Eligible(r); got != tt.want {
+				t.Fatalf("Eligible = %v, erwartet %v", got, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if (&Policy{MaxAttempts: 1}).Eligible(r) || (*Policy)(nil).Eligible(r) {
+		t.Fatal("ohne weitere Versuche darf nichts wiederholt werden")
+	}
+}
+
+// timeoutError erfüllt net.Error wie ein abgelaufener ResponseHeaderTimeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout awaiting response headers" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Dial fehlgeschlagen", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, ConnectFailure},
+		{"Verbindung abgelehnt", fmt.Errorf("proxy: %w", syscall.ECONNREFUSED), ConnectFailure},
+		{"Verbindung zurückgesetzt", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, Reset},
+		{"Upstream schließt vor der Antwort", io.EOF, Reset},
+		{"Timeout", timeoutError{}, Timeout},
+		{"Dial-Timeout zählt als Verbindungsfehler", &net.OpError{Op: "dial", Err: timeoutError{}}, ConnectFailure},
+		{"unbekannt", errors.New("tls: bad certificate"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Fatalf("Classify = %q, erwartet %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	policy := &Policy{RetryOn: DefaultRetryOn}
+	if _, ok := policy.RetryError(context.Background(), timeoutError{}); ok {
+		t.Fatal("Timeout wird ohne explizite Freigabe wiederholt")
+	}
+	if class, ok := policy.RetryError(context.Background(), io.EOF); !ok || class != Reset {
+		t.Fatalf("RetryError = %q, %v, erwartet reset", class, ok)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := policy.RetryError(ctx, io.EOF); ok {
+		t.Fatal("Abbruch durch den Client wird wiederholt")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &Policy{BackoffBase: 10 * time.Millisecond, BackoffMax: 35 * time.Millisecond}
+	ceilings := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 35 * time.Millisecond, 35 * time.Millisecond}
+	for i, ceiling := range ceilings {
+		for n := 0; n < 200; n++ {
+			if d := policy.Backoff(i + 1); d < 0 || d > ceiling {
+				t.Fatalf("Retry %d: Backoff %v außerhalb von [0, %v]", i+1, d, ceiling)
+			}
+		}
+	}
+	if d := policy.Backoff(64); d > policy.BackoffMax {
+		t.Fatalf("großer Retry-Zähler: Backoff %v über BackoffMax", d)
+	}
+	if d := (&Policy{}).Backoff(1); d != 0 {
+		t.Fatalf("ohne Backoff: %v", d)
+	}
+}
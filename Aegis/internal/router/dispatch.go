@@ -0,0 +1,175 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"gatekeeper/internal/config"
+)
+
+// Erlaubte Methoden für dynamische Routen
+var knownMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Präzedenz der dynamischen Routen:
+//  1. exakter Pfad vor Präfix ("/*"), längster Präfix gewinnt (übernimmt chi)
+//  2. bei gleichem Pfad: höhere Priority, dann explizite Methoden vor "alle Methoden"
+//  3. passt keine Route des Pfads zur Methode, geht der Request an den nächstkürzeren Präfix
+//
+// Gleicher Pfad + gleiche Priority + überlappende Methoden im selben Projekt ist ein
+// Konflikt (siehe PrepareRoutes).
+
+// normalizeMethods liefert die Methoden in Großbuchstaben (leer = alle)
+func normalizeMethods(methods []string) []string {
+	normalized := make([]string, 0, len(methods))
+	for _, m := range methods {
+		normalized = append(normalized, strings.ToUpper(strings.TrimSpace(m)))
+	}
+	return normalized
+}
+
+// methodsOverlap prüft, ob zwei Routen mindestens eine Methode gemeinsam bedienen.
+// Eine Route ohne Methoden ist nur mit einer anderen ohne Methoden deckungsgleich,
+// gegenüber expliziten Methoden ist sie der Fallback.
+func methodsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == 0 && len(b) == 0
+	}
+	for _, m := range normalizeMethods(a) {
+		if slices.Contains(normalizeMethods(b), m) {
+			return true
+		}
+	}
+	return false
+}
+
+// routesConflict: beide Routen würden denselben Request gleichrangig beanspruchen
+func routesConflict(a, b config.RouteConfig) bool {
+	return a.ProjectID == b.ProjectID && a.Path == b.Path && a.Priority == b.Priority && methodsOverlap(a.Methods, b.Methods)
+}
+
+type methodEntry struct {
+	methods []string // leer = alle
+	handler http.Handler
+}
+
+func (e methodEntry) allows(method string) bool {
+	if len(e.methods) == 0 || slices.Contains(e.methods, method) {
+		return true
+	}
+	// HEAD wird von GET-Routen mitbedient
+	return method == http.MethodHead && slices.Contains(e.methods, http.MethodGet)
+}
+
+// methodDispatcher verteilt die Requests eines Pfads anhand der HTTP-Methode
+type methodDispatcher struct {
+	entries  []methodEntry // nach Präzedenz sortiert
+	fallback http.Handler  // Dispatcher des nächstkürzeren Präfix (optional)
+}
+
+func (d *methodDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, e := range d.entries {
+		if e.allows(r.Method) {
+			e.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	if d.fallback != nil {
+		d.fallback.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Allow", strings.Join(d.allowed(), ", "))
+	http.Error(w, "Methode nicht erlaubt", http.StatusMethodNotAllowed)
+}
+
+func (d *methodDispatcher) allowed() []string {
+	var allowed []string
+	for _, e := range d.entries {
+		for _, m := range e.methods {
+			if !slices.Contains(allowed, m) {
+				allowed = append(allowed, m)
+			}
+		}
+	}
+	return allowed
+}
+
+// routeGroup fasst alle Routen mit identischem Pfad-Muster zusammen.
+// chi kennt pro Muster nur einen Handler, daher wird pro Gruppe ein Dispatcher registriert.
+type routeGroup struct {
+	path   string
+	routes []PreparedRoute
+}
+
+// groupRoutes gruppiert nach Pfad und sortiert die Routen jeder Gruppe nach Präzedenz.
+// Die Reihenfolge der Gruppen ist unabhängig von der Reihenfolge aus Athena.
+// Präfix-Gruppen kommen zuerst: chi.Mount belegt auch den exakten Präfix-Pfad
+// ("/a/*" auch "/a"), eine exakte Route dort muss danach registriert werden.
+func groupRoutes(routes []PreparedRoute) []*routeGroup {
+	byPath := make(map[string]*routeGroup)
+	var groups []*routeGroup
+	for _, route := range routes {
+		g, ok := byPath[route.Path]
+		if !ok {
+			g = &routeGroup{path: route.Path}
+			byPath[route.Path] = g
+			groups = append(groups, g)
+		}
+		g.routes = append(g.routes, route)
+	}
+
+	for _, g := range groups {
+		sort.SliceStable(g.routes, func(i, j int) bool {
+			a, b := g.routes[i], g.routes[j]
+			if a.Priority != b.Priority {
+				return a.Priority > b.Priority
+			}
+			return len(a.Methods) > 0 && len(b.Methods) == 0
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		_, iPrefix := mountPrefixOf(groups[i].path)
+		_, jPrefix := mountPrefixOf(groups[j].path)
+		if iPrefix != jPrefix {
+			return iPrefix
+		}
+		return groups[i].path < groups[j].path
+	})
+	return groups
+}
+
+// mountPrefixOf liefert den Präfix einer "/*"-Route (ok=false bei exakten Pfaden)
+func mountPrefixOf(path string) (string, bool) {
+	if !strings.HasSuffix(path, "/*") {
+		return "", false
+	}
+	return strings.TrimSuffix(path, "/*"), true
+}
+
+// parentPrefixGroup sucht den längsten Präfix, unter den der Pfad der Gruppe fällt
+func parentPrefixGroup(g *routeGroup, groups []*routeGroup) *routeGroup {
+	own := g.path
+	if prefix, ok := mountPrefixOf(g.path); ok {
+		own = prefix
+	}
+
+	var parent *routeGroup
+	parentLen := -1
+	for _, candidate := range groups {
+		if candidate == g {
+			continue
+		}
+		prefix, ok := mountPrefixOf(candidate.path)
+		if !ok || len(prefix) <= parentLen {
+			continue
+		}
+		if own == prefix || strings.HasPrefix(own, prefix+"/") {
+			parent, parentLen = candidate, len(prefix)
+		}
+	}
+	return parent
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"gatekeeper/internal/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// namedUpstream antwortet mit seinem Namen, damit der Test sieht, welche Route gewann
+func namedUpstream(t *testing.T, name string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestDynamicRoutePrecedence(t *testing.T) {
+	route := func(id, path string, priority int, methods ...string) config.RouteConfig {
+		return config.RouteConfig{ID: id, Path: path, Priority: priority, Methods: methods, TargetURL: namedUpstream(t, id)}
+	}
+	routes := []config.RouteConfig{
+		route("api-alle", "/shop/*", 0),
+		route("api-get", "/shop/*", 0, "GET"),
+		route("api-post", "/shop/*", 10, "post"),
+		route("users-exakt", "/shop/users", 0, "GET"),
+		route("users-put", "/shop/users/*", 0, "PUT"),
+		route("other-get", "/other", 0, "GET"),
+		route("v2-get", "/v2/*", 0, "GET"),
+		route("v2-alle", "/v2/*", 5),
+	}
+
+	tests := []struct {
+		method, path string
+		want         string // Upstream oder Status
+	}{
+		{"GET", "/shop/x", "api-get"},          // explizite Methode vor "alle Methoden"
+		{"POST", "/shop/x", "api-post"},        // Methoden werden normalisiert
+		{"DELETE", "/shop/x", "api-alle"},      // Rest geht an die Route ohne Methoden
+		{"GET", "/shop/users", "users-exakt"},  // exakter Pfad vor Präfix
+		{"HEAD", "/shop/users", "users-exakt"}, // GET bedient HEAD mit
+		{"POST", "/shop/users", "api-post"},    // Methode passt nicht: nächstkürzerer Präfix
+		{"PUT", "/shop/users/1", "users-put"},  // längster Präfix gewinnt
+		{"GET", "/shop/users/1", "api-get"},    // ... und fällt bei fremder Methode zurück
+		{"GET", "/v2/x", "v2-alle"},            // höhere Priority vor expliziter Methode
+		{"DELETE", "/other", "405"},            // ohne Präfix: Method Not Allowed
+		{"GET", "/unbekannt", "404"},
+	}
+
+	// Die Reihenfolge aus Athena darf das Ergebnis nicht beeinflussen
+	reversed := slices.Clone(routes)
+	slices.Reverse(reversed)
+	for _, order := range [][]config.RouteConfig{routes, reversed} {
+		prepared, _ := PrepareRoutes(order, nil)
+		if len(prepared) != len(routes) {
+			t.Fatalf("%d von %d Routen gültig", len(prepared), len(routes))
+		}
+		r := chi.NewRouter()
+		registerDynamicRoutes(&Dependencies{Config: &config.GatewayConfig{}}, r, prepared)
+
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			got := rec.Header().Get("X-Upstream")
+			if got == "" {
+				got = http.StatusText(rec.Code)
+				switch rec.Code {
+				case http.StatusMethodNotAllowed:
+					got = "405"
+					if allow := rec.Header().Get("Allow"); allow != "GET" {
+						t.Errorf("%s %s: Allow=%q, erwartet GET", tt.method, tt.path, allow)
+					}
+				case http.StatusNotFound:
+					got = "404"
+				}
+			}
+			if got != tt.want {
+				t.Errorf("%s %s: %s, erwartet %s", tt.method, tt.path, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRoutesConflict(t *testing.T) {
+	base := config.RouteConfig{ProjectID: "p1", Path: "/shop/*", Priority: 0, Methods: []string{"GET", "POST"}}
+	tests := []struct {
+		name  string
+		other config.RouteConfig
+		want  bool
+	}{
+		{"überlappende Methoden", config.RouteConfig{ProjectID: "p1", Path: "/shop/*", Methods: []string{"post"}}, true},
+		{"disjunkte Methoden", config.RouteConfig{ProjectID: "p1", Path: "/shop/*", Methods: []string{"PUT"}}, false},
+		{"ohne Methoden ist Fallback", config.RouteConfig{ProjectID: "p1", Path: "/shop/*"}, false},
+		{"andere Priority", config.RouteConfig{ProjectID: "p1", Path: "/shop/*", Priority: 1, Methods: []string{"GET"}}, false},
+		{"anderes Projekt", config.RouteConfig{ProjectID: "p2", Path: "/shop/*", Methods: []string{"GET"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := routesConflict(base, tt.other); got != tt.want {
+				t.Fatalf("routesConflict = %v, erwartet %v", got, tt.want)
+			}
+		})
+	}
+	if !routesConflict(config.RouteConfig{Path: "/a"}, config.RouteConfig{Path: "/a"}) {
+		t.Fatal("zwei Routen ohne Methoden auf gleichem Pfad müssen kollidieren")
+	}
+}
@@ -2,33 +2,68 @@ package router
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"gatekeeper/internal/auth"
 	"gatekeeper/internal/cache"
 	"gatekeeper/internal/circuit"
 	"gatekeeper/internal/config"
+	"gatekeeper/internal/health"
+	"gatekeeper/internal/ipfilter"
 	"gatekeeper/internal/ratelimit"
 	"gatekeeper/internal/security"
+	"gatekeeper/internal/stream"
+	"gatekeeper/internal/transform"
 )
 
+type healthResponse struct {
+	Status    string                `json:"status"`
+	Redis     string                `json:"redis"`
+	Upstreams []health.TargetStatus `json:"upstreams"`
+	Config    *config.SyncState     `json:"config,omitempty"`
+}
+
 // healthCheckHandler (aus server.go/setupRoutes extrahiert)
+// Liefert den Gateway-Status inkl. Health der aktiv geprüften Upstreams.
 func healthCheckHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{Status: "OK", Redis: "OK", Upstreams: []health.TargetStatus{}}
+		code := http.StatusOK
+
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 		_, err := deps.RedisClient.Ping(ctx).Result()
 		if err != nil {
 			log.Printf("HEALTH CHECK FEHLER: Redis nicht erreichbar: %v", err)
-			http.Error(w, "Gatekeeper Status: NOK (Redis nicht erreichbar)", http.StatusServiceUnavailable)
-			return
+			resp.Status = "NOK"
+			resp.Redis = "NOK (nicht erreichbar)"
+			code = http.StatusServiceUnavailable
+		}
+
+		if deps.HealthChecker != nil {
+			resp.Upstreams = deps.HealthChecker.Status()
 		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Gatekeeper Status: OK"))
+
+		// Start aus dem Snapshot ohne Athena: Traffic läuft, aber Änderungen kommen nicht an
+		if deps.ConfigState != nil {
+			state := deps.ConfigState()
+			resp.Config = &state
+			if state.Degraded && code == http.StatusOK {
+				resp.Status = "DEGRADED"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
 	}
 }
 
@@ -45,16 +80,21 @@ func blockStandaloneOtp(stripPrefix string, next http.Handler) http.Handler {
 	})
 }
 
+var errMissingAthenaURL = errors.New("ATHENA_SERVICE_URL ist in der .env-Datei nicht gesetzt")
+
 // buildStaticAuthProxy (aus server.go/setupRoutes extrahiert)
 func buildStaticAuthProxy() (http.Handler, error) {
 	athenaURL := os.Getenv("ATHENA_SERVICE_URL")
 	if athenaURL == "" {
-		log.Fatal("FATAL: ATHENA_SERVICE_URL ist in der .env-Datei nicht gesetzt!")
+		return nil, errMissingAthenaURL
 	}
 
     
     // (Verwendet NewReverseProxy aus proxy.go)
-	proxy := NewReverseProxy(athenaURL, nil, 0) // Kein Breaker oder Timeout für Auth-Proxy
+	proxy, err := NewReverseProxy(athenaURL, 0) // Kein Breaker oder Timeout für Auth-Proxy
+	if err != nil {
+		return nil, err
+	}
 
 	baseProxyHandler := http.StripPrefix("/api", proxy)
 
@@ -65,9 +105,12 @@ func buildStaticAuthProxy() (http.Handler, error) {
 func buildStaticAuthenticatedProxy(deps *Dependencies, stripPrefix string) (http.Handler, error) {
 	athenaURL := os.Getenv("ATHENA_SERVICE_URL")
 	if athenaURL == "" {
-		log.Fatal("FATAL: ATHENA_SERVICE_URL ist in der .env-Datei nicht gesetzt!")
+		return nil, errMissingAthenaURL
+	}
+	proxy, err := NewReverseProxy(athenaURL, 0)
+	if err != nil {
+		return nil, err
 	}
-	proxy := NewReverseProxy(athenaURL, nil, 0)
 	
 	// 1. Zuerst den Proxy in StripPrefix einpacken
 	handler := http.StripPrefix(stripPrefix, proxy)
@@ -77,63 +120,150 @@ func buildStaticAuthenticatedProxy(deps *Dependencies, stripPrefix string) (http
 	handler = security.ClaimAndCleaningMiddleware(handler)
 	
 	// 3. Auth-Middleware (validiert Token, füllt Kontext für ClaimAndCleaningMiddleware)
-	handler = auth.AuthMiddleware(deps.PublicKey)(handler)
+	handler = auth.AuthMiddleware(deps.Keys)(handler)
 	
 	return handler, nil
 }
 
 // buildRouteHandler (NEU: Extrahiert aus der Schleife in server.go/setupRoutes)
-// Erstellt die Middleware-Kette für eine einzelne dynamische Route
-func buildRouteHandler(deps *Dependencies, route config.RouteConfig) http.Handler {
+// Erstellt die Middleware-Kette für eine einzelne dynamische Route und liefert
+// deren Namen von außen nach innen (für die Admin-API).
+// Die Einstellungen hat PrepareRoutes bereits geparst und geprüft.
+func buildRouteHandler(deps *Dependencies, route config.RouteConfig, settings routeSettings) (http.Handler, []string) {
 	// 1. Reverse Proxy erstellen (Ziel)
 	var breaker *circuit.Breaker
-	var timeoutDuration time.Duration = 0
-
-	if route.ProxyTimeout != "" {
-		var err error
-		timeoutDuration, err = time.ParseDuration(route.ProxyTimeout)
-		if err != nil {
-			log.Fatalf("Ungültiges ProxyTimeout '%s' für Pfad %s: %v", route.ProxyTimeout, route.Path, err)
-		}
+	if route.CircuitBreaker.Enabled() {
+		breaker = circuit.GetBreaker(breakerName(route), settings.breaker)
 	}
-	cbCfg := route.CircuitBreaker
-	if cbCfg.FailureThreshold > 0 {
-		cbTimeout, err := time.ParseDuration(cbCfg.OpenTimeout)
-		if err != nil {
-			log.Fatalf("Ungültiges CircuitBreaker.OpenTimeout '%s' für Pfad %s: %v", cbCfg.OpenTimeout, route.Path, err)
-		}
-		serviceName := route.TargetURL
-		breaker = circuit.GetBreaker(serviceName, cbCfg.FailureThreshold, cbTimeout)
+	// (Verwendet NewBalancedReverseProxy aus proxy.go)
+	handler := NewBalancedReverseProxy(settings.pool, settings.proxyTimeout, settings.retry, upstreamHTTP2(route), settings.tls)
+	chain := []string{mwProxy}
+	if settings.retry != nil {
+		chain = []string{mwProxy, mwRetry}
 	}
-	// (Verwendet NewReverseProxy aus proxy.go)
-	handler := NewReverseProxy(route.TargetURL, breaker, timeoutDuration)
 
 	// 2. Routen-spezifische Middlewares anwenden (von innen nach außen)
+	// Header-/Query-Regeln direkt am Proxy: der Cache speichert die umgeschriebenen Header
+	handler = transform.Middleware(settings.transform)(handler)
+	if !settings.transform.Empty() {
+		chain = append(chain, mwTransform)
+	}
 	if breaker != nil {
 		handler = circuit.CircuitBreakerMiddleware(breaker)(handler)
+		chain = append(chain, mwCircuitBreaker)
 	}
 	if route.WebhookSecret != "" {
 		if route.WebhookSignatureHeader == "" {
 			route.WebhookSignatureHeader = "X-Hub-Signature-256"
 		}
 		handler = security.WebhookSignatureMiddleware(route.WebhookSecret, route.WebhookSignatureHeader)(handler)
+		chain = append(chain, mwWebhook)
+	}
+	// Der Cache liegt innerhalb von Auth/ACL, damit Treffer die Prüfungen nicht umgehen.
+	// Streams würde er puffern, daher ist er auf Streaming-Routen aus.
+	if settings.cacheTTL > 0 && settings.stream != nil {
+		slog.Warn("CacheTTL wird auf Streaming-Route ignoriert", "path", route.Path)
+	} else if settings.cacheTTL > 0 {
+		handler = cache.CacheMiddleware(deps.RedisClient, cache.Policy{
+			DefaultTTL: settings.cacheTTL,
+			Scope:      cache.ScopeForProject(route.ProjectID),
+			Tags:       []string{cache.RouteTag(routeIdentifier(route))},
+		})(handler)
+		chain = append(chain, mwCache)
+	}
+	// Das Verbindungslimit zählt nur Streams, die Auth und Rate Limit passiert haben
+	if settings.stream != nil {
+		handler = stream.Middleware(rateLimitScope(route), *settings.stream)(handler)
+		chain = append(chain, mwStream)
 	}
 	if len(route.RequiredRoles) > 0 {
 		handler = security.ClaimAndCleaningMiddleware(handler)
 		handler = auth.ACLMiddleware(route.RequiredRoles)(handler)
-		handler = auth.AuthMiddleware(deps.PublicKey)(handler)
+		handler = auth.AuthMiddleware(deps.Keys)(handler)
+		chain = append(chain, mwClaimCleaning, mwACL, mwAuth)
 	}
 	if route.RateLimit.Limit > 0 {
-		window, err := time.ParseDuration(route.RateLimit.Window)
-		if err != nil { log.Fatalf("Ungültiges RateLimit.Window '%s' für Pfad %s: %v", route.RateLimit.Window, route.Path, err) }
-		limitInt := int(route.RateLimit.Limit)
-		handler = ratelimit.RateLimitMiddleware(deps.RedisClient, limitInt, window)(handler)
+		handler = ratelimit.RateLimitMiddleware(deps.RedisClient, ratelimit.Policy{
+			Algorithm: route.RateLimit.Algorithm,
+			Limit:     int(route.RateLimit.Limit),
+			Window:    settings.rateLimitWindow,
+			Burst:     int(route.RateLimit.Burst),
+			Scope:     rateLimitScope(route),
+			Tiers:     settings.rateLimitTiers,
+			ProjectID: route.ProjectID,
+		}, deps.Keys)(handler)
+		chain = append(chain, mwRateLimit)
 	}
-	if route.CacheTTL != "" && route.CacheTTL != "0" && route.CacheTTL != "0s" {
-		ttl, err := time.ParseDuration(route.CacheTTL)
-		if err != nil { log.Fatalf("Ungültiges CacheTTL '%s' für Pfad %s: %v", route.CacheTTL, route.Path, err) }
-		handler = cache.CacheMiddleware(deps.RedisClient, ttl)(handler)
+	// Außen: das Token aus Query/Subprotocol muss vor Rate Limit und Auth im Header stehen
+	if settings.stream != nil && settings.stream.ExtractsToken() {
+		handler = stream.TokenMiddleware(*settings.stream)(handler)
+		chain = append(chain, mwStreamToken)
 	}
-	
-	return handler
-}
\ No newline at end of file
+	// Ganz außen: blockierte Netze erreichen weder Rate Limit und Auth noch Stream-Limits
+	if len(settings.ipPolicies) > 0 {
+		for _, p := range settings.ipPolicies {
+			if p.NeedsCountry() && deps.GeoIP == nil {
+				slog.Warn("IP-Richtlinie prüft Länder, aber AEGIS_GEOIP_DB_PATH ist nicht gesetzt", "path", route.Path, "route_id", route.ID)
+			}
+		}
+		project := route.ProjectID
+		if project == "" {
+			project = "global"
+		}
+		handler = ipfilter.Middleware(deps.GeoIP, ipfilter.Route{Project: project, ID: routeIdentifier(route)}, settings.ipPolicies...)(handler)
+		chain = append(chain, mwIPFilter)
+	}
+
+	slices.Reverse(chain)
+	return handler, chain
+}
+
+// rateLimitScope trennt die Zähler pro Projekt und Route
+func rateLimitScope(route config.RouteConfig) string {
+	project := route.ProjectID
+	if project == "" {
+		project = "global"
+	}
+	return project + ":" + routeIdentifier(route)
+}
+
+// Upstream-Protokolle einer Route (RouteConfig.Protocol)
+const (
+	protocolHTTP = "http"
+	protocolGRPC = "grpc" // HTTP/2 mit gRPC-Semantik: Pfad bleibt unverändert (/paket.Service/Methode)
+	protocolH2C  = "h2c"  // HTTP/2 ohne TLS (prior knowledge) für http://-Upstreams
+)
+
+// upstreamHTTP2 meldet, ob der Proxy nur HTTP/2 mit den Upstreams spricht
+func upstreamHTTP2(route config.RouteConfig) bool {
+	return route.Protocol == protocolGRPC || route.Protocol == protocolH2C
+}
+
+// Schlüssel des Circuit Breakers einer Route (CircuitBreakerConfig.Key)
+const (
+	breakerKeyRoute    = "route"
+	breakerKeyUpstream = "upstream"
+)
+
+// breakerName liefert den Registry-Namen des Breakers. Standard ist ein Breaker pro
+// Route; mit Key "upstream" teilen sich alle Routen mit denselben Upstreams einen
+// Breaker (die zuletzt geladene Route bestimmt dann die Einstellungen).
+func breakerName(route config.RouteConfig) string {
+	if route.CircuitBreaker.Key == breakerKeyUpstream {
+		urls := make([]string, 0, len(route.Targets()))
+		for _, t := range route.Targets() {
+			urls = append(urls, t.URL)
+		}
+		slices.Sort(urls)
+		return breakerKeyUpstream + ":" + strings.Join(urls, ",")
+	}
+	return breakerKeyRoute + ":" + rateLimitScope(route)
+}
+
+// routeIdentifier identifiziert eine Route: ID aus Athena, sonst der Pfad
+func routeIdentifier(route config.RouteConfig) string {
+	if route.ID != "" {
+		return route.ID
+	}
+	return route.Path
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"net/http"
+
+	"gatekeeper/internal/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// hostRoutingMiddleware leitet Requests auf Kunden-Hosts an den Sub-Router des
+// Projekts, dem der Host laut ContextMap gehört. Projekt-Routen sind damit nur
+// auf den eigenen Hosts erreichbar; alles andere (statische Routen, /health,
+// globale Routen ohne Projekt) läuft weiter über den Haupt-Router.
+func hostRoutingMiddleware(cfg *config.GatewayConfig, projects map[string]*chi.Mux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			projectID, ok := cfg.ProjectIDForHost(r.Host)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			projectRouter, ok := projects[projectID]
+			if !ok || !projectRouter.Match(chi.NewRouteContext(), r.Method, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			projectRouter.ServeHTTP(w, r)
+		})
+	}
+}
+
+// splitRoutesByProject trennt globale Routen (ohne Projekt) von Projekt-Routen
+func splitRoutesByProject(routes []PreparedRoute) ([]PreparedRoute, map[string][]PreparedRoute) {
+	var global []PreparedRoute
+	byProject := make(map[string][]PreparedRoute)
+	for _, route := range routes {
+		if route.ProjectID == "" {
+			global = append(global, route)
+			continue
+		}
+		byProject[route.ProjectID] = append(byProject[route.ProjectID], route)
+	}
+	return global, byProject
+}
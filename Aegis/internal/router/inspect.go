@@ -0,0 +1,131 @@
+package router
+
+import (
+	"slices"
+	"strings"
+
+	"gatekeeper/internal/config"
+)
+
+// Namen der Middlewares in der Admin-API
+const (
+	mwClientIP       = "client_ip"
+	mwContext        = "context"
+	mwTracing        = "tracing"
+	mwGRPCErrors     = "grpc_errors"
+	mwCORS           = "cors"
+	mwSecurity       = "security_headers"
+	mwPayloadSize    = "payload_size"
+	mwClientCert     = "client_cert"
+	mwRequestLogger  = "request_logger"
+	mwHostRouting    = "host_routing"
+	mwMethodDispatch = "method_dispatch"
+	mwStripPrefix    = "strip_prefix"
+	mwPathRewrite    = "path_rewrite"
+	mwOtpBlock       = "otp_block"
+	mwIPFilter       = "ip_filter"
+	mwStreamToken    = "stream_token"
+	mwRateLimit      = "rate_limit"
+	mwAuth           = "auth"
+	mwACL            = "acl"
+	mwClaimCleaning  = "claim_cleaning"
+	mwStream         = "stream"
+	mwCache          = "cache"
+	mwWebhook        = "webhook_signature"
+	mwCircuitBreaker = "circuit_breaker"
+	mwTransform      = "transform"
+	mwRetry          = "retry"
+	mwProxy          = "proxy"
+)
+
+// RouteInfo beschreibt eine aktive Route, wie sie registriert wurde
+type RouteInfo struct {
+	ID             string   `json:"route_id,omitempty"`
+	ProjectID      string   `json:"project_id,omitempty"`
+	Hosts          []string `json:"hosts,omitempty"` // Hosts des Projekts laut Context Map (leer = alle)
+	Path           string   `json:"path"`
+	Methods        []string `json:"methods"`
+	Priority       int      `json:"priority"`
+	Protocol       string   `json:"protocol,omitempty"`
+	Targets        []string `json:"targets"`
+	Static         bool     `json:"static,omitempty"` // fest eingebaute Athena-Routen
+	CircuitBreaker string   `json:"circuit_breaker,omitempty"`
+	// Middlewares der Route von außen nach innen (nach den globalen)
+	Middleware []string `json:"middleware"`
+}
+
+// GlobalMiddleware liefert die Middlewares, die vor jeder Route laufen (von außen nach innen)
+func GlobalMiddleware(cfg *config.GatewayConfig) []string {
+	chain := []string{mwClientIP, mwContext, mwTracing, mwGRPCErrors, mwCORS, mwSecurity, mwPayloadSize}
+	if cfg.ClientAuth != "" {
+		chain = append(chain, mwClientCert)
+	}
+	return append(chain, mwRequestLogger, mwHostRouting)
+}
+
+// dynamicRouteInfo beschreibt eine registrierte Projekt- oder globale Route
+func dynamicRouteInfo(cfg *config.GatewayConfig, route config.RouteConfig, chain []string) RouteInfo {
+	info := RouteInfo{
+		ID:        route.ID,
+		ProjectID: route.ProjectID,
+		Path:      route.Path,
+		Methods:   normalizeMethods(route.Methods),
+		Priority:  route.Priority,
+		Protocol:  route.Protocol,
+	}
+	if len(info.Methods) == 0 {
+		info.Methods = []string{"*"}
+	}
+	for _, t := range route.Targets() {
+		info.Targets = append(info.Targets, t.URL)
+	}
+	if route.ProjectID != "" {
+		for host, projectID := range cfg.ContextMap {
+			if projectID == route.ProjectID {
+				info.Hosts = append(info.Hosts, host)
+			}
+		}
+		slices.Sort(info.Hosts)
+	}
+	if route.CircuitBreaker.Enabled() {
+		info.CircuitBreaker = breakerName(route)
+	}
+
+	info.Middleware = append([]string{mwMethodDispatch}, routePathMiddleware(route)...)
+	info.Middleware = append(info.Middleware, chain...)
+	return info
+}
+
+// routePathMiddleware spiegelt rewriteRoutePath (von außen nach innen)
+func routePathMiddleware(route config.RouteConfig) []string {
+	mountPrefix, mounted := mountPrefixOf(route.Path)
+	isAuth := mounted && strings.HasSuffix(mountPrefix, "/auth")
+	if pr := route.Transform.PathRewrite; pr != nil && pr.Pattern != "" {
+		if isAuth {
+			return []string{mwPathRewrite, mwOtpBlock}
+		}
+		return []string{mwPathRewrite}
+	}
+	if route.Protocol == protocolGRPC || !mounted || mountPrefix == "" {
+		return nil
+	}
+	if isAuth {
+		return []string{mwOtpBlock, mwStripPrefix}
+	}
+	return []string{mwStripPrefix}
+}
+
+// staticRouteInfo beschreibt die fest eingebauten Athena-Routen
+func staticRouteInfo(path, target string, authenticated bool) RouteInfo {
+	chain := []string{mwOtpBlock, mwStripPrefix, mwProxy}
+	if authenticated {
+		chain = []string{mwAuth, mwClaimCleaning, mwStripPrefix, mwProxy}
+	}
+	return RouteInfo{
+		Path:       path,
+		Methods:    []string{"*"},
+		Targets:    []string{target},
+		Static:     true,
+		Middleware: chain,
+	}
+}
@@ -1,28 +1,45 @@
 package router
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"strings" // WICHTIGER IMPORT
 	"time"
 
-	"gatekeeper/internal/circuit"
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/clientip"
+	"gatekeeper/internal/retry"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/propagation"
 )
 
-// NewReverseProxy (aus server.go verschoben)
-func NewReverseProxy(targetURL string, breaker *circuit.Breaker, timeout time.Duration) http.Handler {
-	target, err := url.Parse(targetURL)
+type backendContextKey struct{}
+
+// NewReverseProxy (aus server.go verschoben) - Proxy auf genau ein Ziel
+func NewReverseProxy(targetURL string, timeout time.Duration) (http.Handler, error) {
+	pool, err := balancer.NewPool(string(balancer.RoundRobin), []balancer.Target{{URL: targetURL, Weight: 1}})
 	if err != nil {
-		log.Fatalf("Ungültige Ziel-URL: %s", err)
+		return nil, fmt.Errorf("ungültige Ziel-URL: %w", err)
 	}
+	return NewBalancedReverseProxy(pool, timeout, nil, false, nil), nil
+}
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
+// NewBalancedReverseProxy verteilt Requests über die Backends des Pools. Mit einer
+// Retry-Policy werden fehlgeschlagene Versuche auf einem anderen Backend wiederholt.
+// Mit http2 spricht der Proxy nur HTTP/2 mit den Upstreams (h2 über TLS, h2c ohne),
+// wie es gRPC verlangt; Trailer werden dabei durchgereicht. tlsConfig ersetzt die
+// TLS-Einstellungen für https://-Upstreams (eigene CAs, Client-Zertifikat, SNI).
+func NewBalancedReverseProxy(pool *balancer.Pool, timeout time.Duration, policy *retry.Policy, http2 bool, tlsConfig *tls.Config) http.Handler {
+	proxy := &httputil.ReverseProxy{}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 
@@ -30,6 +47,15 @@ func NewReverseProxy(targetURL string, breaker *circuit.Breaker, timeout time.Du
 		transport.ResponseHeaderTimeout = timeout
 		transport.DialContext = (&net.Dialer{ Timeout: 5 * time.Second }).DialContext
 	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	if http2 {
+		// Ohne HTTP1 nutzt der Transport für http://-Ziele h2c mit prior knowledge
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
 
 	proxy.Transport = otelhttp.NewTransport(transport,
 		otelhttp.WithClientTrace(nil),
@@ -38,24 +64,95 @@ func NewReverseProxy(targetURL string, breaker *circuit.Breaker, timeout time.Du
 
 	// Director MUSS angepasst werden, um StripPrefix korrekt zu unterstützen
 	proxy.Director = func(req *http.Request) {
+		target := req.Context().Value(backendContextKey{}).(*balancer.Backend).URL
 		req.URL.Scheme = target.Scheme
 		req.URL.Host = target.Host
 		req.Host = target.Host // Wichtig für Host-Header-Routing
+
+		// Nur die geprüfte Kette weitergeben, der Reverse Proxy hängt den Peer an.
+		// Beide Header fallen weg: der nicht konfigurierte kommt ungeprüft vom Client.
+		req.Header.Del("Forwarded")
+		req.Header.Del("X-Forwarded-For")
+		if hops := clientip.ForwardedFor(req); len(hops) > 0 {
+			req.Header.Set("X-Forwarded-For", strings.Join(hops, ", "))
+		}
+		req.Header.Set("X-Real-IP", clientip.FromRequest(req))
 		
 		// Diese Zeile ist entscheidend für http.StripPrefix
 		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
 	}
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		target := r.Context().Value(backendContextKey{}).(*balancer.Backend).URL
+		if aw, ok := w.(*attemptWriter); ok && aw.retryError(r, err) {
+			log.Printf("Proxy-Fehler zu %s: %v (wird wiederholt)", target.Host, err)
+			return
+		}
 		log.Printf("Proxy-Fehler zu %s: %v", target.Host, err)
-		if breaker != nil { breaker.Fail() }
 		if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
 			http.Error(w, "Downstream Service Timeout.", http.StatusGatewayTimeout) // 504
 			return
 		}
 		http.Error(w, "Downstream Service nicht erreichbar.", http.StatusServiceUnavailable) // 503
 	}
-	return proxy
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !policy.Eligible(r) {
+			serveAttempt(proxy, pool, w, r, nil)
+			return
+		}
+
+		policy.Budget.Deposit()
+		body, ok := bufferBody(r)
+		if !ok {
+			serveAttempt(proxy, pool, w, r, nil)
+			return
+		}
+
+		var tried []*balancer.Backend
+		for attempt := 1; ; attempt++ {
+			if body != nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			aw := newAttemptWriter(w, policy, attempt >= policy.MaxAttempts)
+			backend := serveAttempt(proxy, pool, aw, r, tried)
+			if backend == nil || aw.retry == "" {
+				return
+			}
+			tried = append(tried, backend)
+
+			wait := policy.Backoff(attempt)
+			slog.DebugContext(r.Context(), "Upstream-Request wird wiederholt",
+				"backend", backend.URL.Host, "reason", aw.retry, "attempt", attempt, "backoff", wait)
+			select {
+			case <-time.After(wait):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// serveAttempt schickt den Request an ein Backend, das nicht in tried enthalten ist
+// (falls möglich), und liefert das gewählte Backend
+func serveAttempt(proxy *httputil.ReverseProxy, pool *balancer.Pool, w http.ResponseWriter, r *http.Request, tried []*balancer.Backend) *balancer.Backend {
+	backend, err := pool.NextExcluding(tried)
+	if err != nil {
+		log.Printf("Load Balancer: %v", err)
+		if aw, ok := w.(*attemptWriter); ok {
+			aw.final = true // Ohne Backend gibt es nichts zu wiederholen
+		}
+		http.Error(w, "Downstream Service nicht erreichbar.", http.StatusServiceUnavailable) // 503
+		return nil
+	}
+
+	// Aktive Verbindungen zählen (für least_connections / random_two_choices)
+	backend.Acquire()
+	defer backend.Release()
+
+	ctx := context.WithValue(r.Context(), backendContextKey{}, backend)
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+	return backend
 }
 
 // singleJoiningSlash (Hilfsfunktion für den Proxy Director)
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"gatekeeper/internal/retry"
+)
+
+// maxRetryBodySize begrenzt den gepufferten Request-Body; größere Requests werden
+// nicht wiederholt
+const maxRetryBodySize = 1 << 20
+
+// attemptWriter hält die Antwort eines Versuchs zurück, bis feststeht, ob sie an
+// den Client geht oder der Request wiederholt wird. Er hat eigene Header, damit die
+// Header eines verworfenen Versuchs nicht in der endgültigen Antwort landen.
+type attemptWriter struct {
+	w       http.ResponseWriter
+	header  http.Header
+	policy  *retry.Policy
+	final   bool // letzter erlaubter Versuch: alles durchreichen
+	started bool // Header sind an w gegangen
+	retry   string
+}
+
+func newAttemptWriter(w http.ResponseWriter, policy *retry.Policy, final bool) *attemptWriter {
+	return &attemptWriter{w: w, header: make(http.Header), policy: policy, final: final}
+}
+
+// Header liefert nach Beginn der Antwort die Header von w, damit später gesetzte
+// Trailer (z.B. grpc-status) beim Client ankommen
+func (a *attemptWriter) Header() http.Header {
+	if a.started {
+		return a.w.Header()
+	}
+	return a.header
+}
+
+func (a *attemptWriter) WriteHeader(status int) {
+	if a.started || a.retry != "" {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// Informational (z.B. 103 Early Hints) direkt weitergeben
+		a.copyHeader()
+		a.w.WriteHeader(status)
+		for name := range a.header {
+			a.w.Header().Del(name)
+		}
+		return
+	}
+	if !a.final && a.policy.RetryStatus(status) && a.policy.Attempt("status") {
+		a.retry = strconv.Itoa(status)
+		return
+	}
+	a.started = true
+	a.copyHeader()
+	a.w.WriteHeader(status)
+}
+
+func (a *attemptWriter) Write(b []byte) (int, error) {
+	if !a.started && a.retry == "" {
+		a.WriteHeader(http.StatusOK)
+	}
+	if a.retry != "" {
+		return len(b), nil // Antwort des verworfenen Versuchs
+	}
+	return a.w.Write(b)
+}
+
+// retryError entscheidet im ErrorHandler des Proxys, ob ein Transportfehler
+// wiederholt wird. Dann schreibt der ErrorHandler keine Antwort.
+func (a *attemptWriter) retryError(r *http.Request, err error) bool {
+	if a.final || a.started {
+		return false
+	}
+	class, ok := a.policy.RetryError(r.Context(), err)
+	if !ok || !a.policy.Attempt(class) {
+		return false
+	}
+	a.retry = class
+	return true
+}
+
+func (a *attemptWriter) copyHeader() {
+	h := a.w.Header()
+	for name, values := range a.header {
+		h[name] = values
+	}
+}
+
+func (a *attemptWriter) Flush() {
+	if a.started {
+		http.NewResponseController(a.w).Flush()
+	}
+}
+
+func (a *attemptWriter) Unwrap() http.ResponseWriter {
+	return a.w
+}
+
+// bufferBody liest den Request-Body für Wiederholungen ein. Liefert false, wenn er
+// zu groß ist; der Body bleibt dann für einen einzelnen Versuch lesbar.
+func bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > maxRetryBodySize {
+		return nil, false
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxRetryBodySize+1))
+	if err != nil || len(buf) > maxRetryBodySize {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return nil, false
+	}
+	r.Body.Close()
+	return buf, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,163 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/retry"
+)
+
+// recordingUpstream antwortet mit status und merkt sich die empfangenen Bodies
+type recordingUpstream struct {
+	mu     sync.Mutex
+	status int
+	bodies []string
+}
+
+func (u *recordingUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	u.mu.Lock()
+	u.bodies = append(u.bodies, string(body))
+	u.mu.Unlock()
+	w.Header().Set("X-Versuch", http.StatusText(u.status))
+	w.WriteHeader(u.status)
+	w.Write([]byte(http.StatusText(u.status)))
+}
+
+func (u *recordingUpstream) calls() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.bodies)
+}
+
+func startUpstream(t *testing.T, status int) (*recordingUpstream, string) {
+	t.Helper()
+	u := &recordingUpstream{status: status}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+	return u, srv.URL
+}
+
+func testRetryProxy(t *testing.T, budget *retry.Budget, urls ...string) http.Handler {
+	t.Helper()
+	targets := make([]balancer.Target, len(urls))
+	for i, u := range urls {
+		targets[i] = balancer.Target{URL: u}
+	}
+	pool, err := balancer.NewPool("round_robin", targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &retry.Policy{
+		MaxAttempts: 3,
+		StatusCodes: retry.DefaultStatusCodes,
+		RetryOn:     retry.DefaultRetryOn,
+		Budget:      budget,
+	}
+	return NewBalancedReverseProxy(pool, 0, policy, false, nil)
+}
+
+func TestRetryOnStatusUsesOtherBackend(t *testing.T) {
+	failing, failingURL := startUpstream(t, http.StatusServiceUnavailable)
+	ok, okURL := startUpstream(t, http.StatusOK)
+	proxy := testRetryProxy(t, nil, failingURL, okURL)
+
+	for i := 0; i < 4; i++ {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+			t.Fatalf("Request %d: status=%d body=%q", i, rec.Code, rec.Body.String())
+		}
+		// Header des verworfenen Versuchs dürfen nicht durchschlagen
+		if got := rec.Header().Values("X-Versuch"); len(got) != 1 || got[0] != "OK" {
+			t.Fatalf("Request %d: X-Versuch=%q", i, got)
+		}
+	}
+	if ok.calls() != 4 || failing.calls() == 0 {
+		t.Fatalf("%d Aufrufe am gesunden, %d am fehlerhaften Ziel", ok.calls(), failing.calls())
+	}
+}
+
+func TestRetryStopsAfterMaxAttempts(t *testing.T) {
+	failing, failingURL := startUpstream(t, http.StatusBadGateway)
+	proxy := testRetryProxy(t, nil, failingURL)
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status=%d, erwartet die Antwort des letzten Versuchs", rec.Code)
+	}
+	if n := failing.calls(); n != 3 {
+		t.Fatalf("%d Versuche, erwartet 3", n)
+	}
+}
+
+func TestRetryOnConnectFailure(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	ok, okURL := startUpstream(t, http.StatusOK)
+	proxy := testRetryProxy(t, nil, closed.URL, okURL)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: status=%d, erwartet Wiederholung am erreichbaren Ziel", i, rec.Code)
+		}
+	}
+	if n := ok.calls(); n != 2 {
+		t.Fatalf("%d Aufrufe am erreichbaren Ziel, erwartet 2", n)
+	}
+}
+
+func TestRetryNonIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		wantCalls int
+	}{
+		{"POST ohne Idempotency-Key", "", 1},
+		{"POST mit Idempotency-Key", "k1", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing, failingURL := startUpstream(t, http.StatusServiceUnavailable)
+			proxy := testRetryProxy(t, nil, failingURL)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+			if tt.key != "" {
+				req.Header.Set(retry.IdempotencyKeyHeader, tt.key)
+			}
+			proxy.ServeHTTP(httptest.NewRecorder(), req)
+			if n := failing.calls(); n != tt.wantCalls {
+				t.Fatalf("%d Versuche, erwartet %d", n, tt.wantCalls)
+			}
+			// Jeder Versuch bekommt den vollständigen Body
+			for i, body := range failing.bodies {
+				if body != "payload" {
+					t.Fatalf("Versuch %d: Body %q", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	failing, failingURL := startUpstream(t, http.StatusServiceUnavailable)
+	// 20 % ohne Mindestrate: ein einzelner Request verdient noch keinen Retry
+	proxy := testRetryProxy(t, retry.NewBudget(20, 0), failingURL)
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status=%d, erwartet die ursprüngliche Antwort", rec.Code)
+	}
+	if n := failing.calls(); n != 1 {
+		t.Fatalf("%d Versuche trotz erschöpftem Budget", n)
+	}
+}
@@ -1,15 +1,22 @@
 package router
 
 import (
-	"crypto/rsa"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
 	"strings"
 
+	"gatekeeper/internal/auth"
+	"gatekeeper/internal/clientip"
 	"gatekeeper/internal/config"
+	"gatekeeper/internal/grpc"
+	"gatekeeper/internal/health"
+	"gatekeeper/internal/ipfilter"
 	"gatekeeper/internal/middleware"
 	"gatekeeper/internal/security"
+	"gatekeeper/internal/transform"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redis/v8"
@@ -19,21 +26,34 @@ import (
 // Dependencies bündelt die Abhängigkeiten für den Router
 type Dependencies struct {
 	Config      *config.GatewayConfig
-	PublicKey   *rsa.PublicKey
+	Keys        auth.KeyProvider
 	RedisClient *redis.Client
+	HealthChecker *health.Checker
+	ConfigState   func() config.SyncState // Herkunft der aktiven Konfiguration (Athena/Snapshot)
+	ClientIP      *clientip.Resolver      // Client-IP hinter vertrauenswürdigen Proxies
+	GeoIP         *ipfilter.GeoDB         // Länder für IP-Richtlinien (nil = keine Datenbank)
+	Routes        []PreparedRoute         // dynamische Routen, geprüft von PrepareRoutes
 }
 
-// SetupRouter erstellt und konfiguriert den gesamten Chi-Router
-func SetupRouter(deps *Dependencies) *chi.Mux {
+// SetupRouter erstellt und konfiguriert den gesamten Chi-Router. Zusätzlich
+// liefert er die registrierten Routen mit ihren Middlewares (für die Admin-API).
+// Fehler betreffen nur die statischen Athena-Routen (ATHENA_SERVICE_URL).
+func SetupRouter(deps *Dependencies) (*chi.Mux, []RouteInfo, error) {
 	log.Println("Registriere Routen...")
 	newRouter := chi.NewRouter()
+	var infos []RouteInfo
 
 	// 1. Globale Middlewares
+	// Client-IP zuerst: Logger, Rate Limit und Proxy nutzen sie
+	newRouter.Use(clientip.Middleware(deps.ClientIP))
 	newRouter.Use(middleware.ContextInjectorMiddleware(deps.Config))
 	
 	newRouter.Use(func(next http.Handler) http.Handler {
 		return otelhttp.NewHandler(next, "HTTP Request")
 	})
+	// Fehler des Gateways als gRPC-Status an gRPC-Clients (vor dem Logger, der so
+	// den ursprünglichen HTTP-Status protokolliert)
+	newRouter.Use(grpc.ErrorMiddleware)
 	corsCfg := security.DefaultCORSConfig
 	if len(deps.Config.Cors.AllowedOrigins) > 0 {
 		corsCfg.AllowedOrigins = deps.Config.Cors.AllowedOrigins
@@ -42,40 +62,52 @@ func SetupRouter(deps *Dependencies) *chi.Mux {
 	newRouter.Use(security.CORSMiddleware(corsCfg))
 	newRouter.Use(security.SecurityHeadersMiddleware)
 	newRouter.Use(security.PayloadSizeMiddleware)
+	// Identität aus dem Client-Zertifikat (mTLS am Listener) für ACL und Rate Limit
+	if deps.Config.ClientAuth != "" {
+		newRouter.Use(auth.ClientCertMiddleware(deps.Config.ClientIdentity))
+	}
 	newRouter.Use(middleware.RequestLogger)
 
+	// Projekt-Routen laufen in eigenen Sub-Routern pro Projekt (befüllt in Schritt 4)
+	projectRouters := make(map[string]*chi.Mux)
+	newRouter.Use(hostRoutingMiddleware(deps.Config, projectRouters))
+
 	// 2. Statische Routen
 	
 	// /api/auth (unauthentifiziert)
 	authHandler, err := buildStaticAuthProxy()
 	if err != nil {
-		log.Fatalf("FATAL: /api/auth proxy-Fehler: %v", err)
+		return nil, nil, fmt.Errorf("/api/auth proxy-Fehler: %w", err)
 	}
 	newRouter.Mount("/api/auth", authHandler)
+	infos = append(infos, staticRouteInfo("/api/auth/*", os.Getenv("ATHENA_SERVICE_URL"), false))
 	log.Println("Registriere statische Auth-Routen (/api/auth/*)")
 
 	// /api/projects (authentifiziert)
 	projectsHandler, err := buildStaticAuthenticatedProxy(deps, "/api")
 	if err != nil {
-		log.Fatalf("FATAL: /api/projects proxy-Fehler: %v", err)
+		return nil, nil, fmt.Errorf("/api/projects proxy-Fehler: %w", err)
 	}
 	newRouter.Mount("/api/projects", projectsHandler)
+	infos = append(infos, staticRouteInfo("/api/projects/*", os.Getenv("ATHENA_SERVICE_URL"), true))
 	log.Println("Registriere statische Admin-Routen (/api/projects/*)")
 
 	// /api/users (authentifiziert)
 	usersHandler, err := buildStaticAuthenticatedProxy(deps, "/api")
 	if err != nil {
-		log.Fatalf("FATAL: /api/users proxy-Fehler: %v", err)
+		return nil, nil, fmt.Errorf("/api/users proxy-Fehler: %w", err)
 	}
 	newRouter.Mount("/api/users", usersHandler)
+	infos = append(infos, staticRouteInfo("/api/users/*", os.Getenv("ATHENA_SERVICE_URL"), true))
 	log.Println("Registriere statische Admin-Routen (/api/users/*)")
 
 	// NEU: /api/admin (authentifiziert)
 	adminHandler, err := buildStaticAuthenticatedProxy(deps, "/api")
 	if err != nil {
-		log.Fatalf("FATAL: /api/admin proxy-Fehler: %v", err)
+		return nil, nil, fmt.Errorf("/api/admin proxy-Fehler: %w", err)
 	}
 	newRouter.Mount("/api/admin", adminHandler)
+	infos = append(infos, staticRouteInfo("/api/admin/*", os.Getenv("ATHENA_SERVICE_URL"), true))
 	log.Println("Registriere statische Admin-Routen (/api/admin/*)")
 
 
@@ -84,53 +116,132 @@ func SetupRouter(deps *Dependencies) *chi.Mux {
 
 	// 4. Dynamische Projekt-Routen
 	log.Println("Registriere dynamische Projekt-Routen von Athena...")
-	for _, route := range deps.Config.Routes {
+	var routes []PreparedRoute
+	for _, route := range deps.Routes {
 		// WICHTIG: /api/admin hier auch überspringen
-		if strings.HasPrefix(route.Path, "/api/auth/") ||
-			strings.HasPrefix(route.Path, "/api/projects/") ||
-			strings.HasPrefix(route.Path, "/api/users/") ||
-			strings.HasPrefix(route.Path, "/api/admin/") {
+		if isReservedPath(route.Path) {
 			log.Printf("Überspringe dynamische Route (wird statisch verwaltet): %s", route.Path)
 			continue
 		}
-		
-		handler := buildRouteHandler(deps, route)
-
-		// 5. Pfad-Präfix DYNAMISCH entfernen
-		registrationPath := route.Path
-		if strings.HasSuffix(registrationPath, "/*") {
-			mountPrefix := strings.TrimSuffix(registrationPath, "/*")
-			
-			lastSlashIndex := strings.LastIndex(mountPrefix, "/")
-			var stripPrefix string
-			if lastSlashIndex > 0 {
-				stripPrefix = mountPrefix[:lastSlashIndex]
-			} else if mountPrefix != "" {
-				stripPrefix = mountPrefix
-			} else {
-				stripPrefix = ""
-			}
+		routes = append(routes, route)
+	}
 
-			strippedHandler := handler
-			if stripPrefix != "" {
-				strippedHandler = http.StripPrefix(stripPrefix, handler)
-			}
-			
-			if strings.HasSuffix(mountPrefix, "/auth") {
-				strippedHandler = blockStandaloneOtp(stripPrefix, strippedHandler)
+	// Globale Routen (ohne Projekt) gelten auf allen Hosts, Projekt-Routen nur
+	// auf den Hosts, die laut ContextMap zum Projekt gehören
+	global, byProject := splitRoutesByProject(routes)
+	infos = append(infos, registerDynamicRoutes(deps, newRouter, global)...)
+	for projectID, projectRoutes := range byProject {
+		projectRouter := chi.NewRouter()
+		infos = append(infos, registerDynamicRoutes(deps, projectRouter, projectRoutes)...)
+		projectRouters[projectID] = projectRouter
+	}
+
+	log.Println("Routen erfolgreich (neu) geladen.")
+	return newRouter, infos, nil
+}
+
+// registerDynamicRoutes registriert Routen in einem (Sub-)Router.
+// Routen mit gleichem Pfad teilen sich einen Dispatcher (Methode + Priority).
+func registerDynamicRoutes(deps *Dependencies, r *chi.Mux, routes []PreparedRoute) []RouteInfo {
+	groups := groupRoutes(routes)
+	dispatchers := make(map[*routeGroup]*methodDispatcher, len(groups))
+	chains := make(map[*PreparedRoute][]string, len(routes))
+	for _, g := range groups {
+		d := &methodDispatcher{}
+		for i, route := range g.routes {
+			handler, chain := buildRouteHandler(deps, route.RouteConfig, route.settings)
+			d.entries = append(d.entries, methodEntry{
+				methods: normalizeMethods(route.Methods),
+				handler: rewriteRoutePath(route, handler),
+			})
+			chains[&g.routes[i]] = chain
+		}
+		dispatchers[g] = d
+	}
+
+	var infos []RouteInfo
+	for _, g := range groups {
+		d := dispatchers[g]
+		if parent := parentPrefixGroup(g, groups); parent != nil {
+			d.fallback = dispatchers[parent]
+		}
+		if len(d.entries) == 0 {
+			continue
+		}
+		if err := tryRegisterHandler(r, g.routes[0].RouteConfig, d); err != nil {
+			slog.Error("Route übersprungen", "path", g.path, "error", err)
+			continue
+		}
+		for i, route := range g.routes {
+			chain, ok := chains[&g.routes[i]]
+			if !ok {
+				continue // Handler konnte nicht gebaut werden
 			}
+			log.Printf("Route registriert: %s %s -> %s (priority %d, project %q)", methodsLabel(route.Methods), route.Path, route.TargetURL, route.Priority, route.ProjectID)
+			infos = append(infos, dynamicRouteInfo(deps.Config, route.RouteConfig, chain))
+		}
+	}
+	return infos
+}
 
-			newRouter.Mount(mountPrefix, strippedHandler)
-			log.Printf("Route registriert (Mount): %s/* -> %s (Stripping '%s', ...)",
-				mountPrefix, route.TargetURL, stripPrefix)
+// registerRoute hängt eine dynamische Route in den Router ein.
+// Routen auf "/*" werden gemountet, alle anderen exakt registriert.
+func registerRoute(r *chi.Mux, route config.RouteConfig, handler http.Handler) {
+	if mountPrefix, ok := mountPrefixOf(route.Path); ok {
+		r.Mount(mountPrefix, handler)
+		return
+	}
+	r.Handle(route.Path, handler)
+}
 
-		} else {
-			newRouter.Handle(registrationPath, handler)
-			log.Printf("Route registriert (Handle Exact): %s -> %s",
-				registrationPath, route.TargetURL)
+// rewriteRoutePath wendet die Pfad-Regel der Route an. Ohne Regel wird wie bisher
+// das Routen-Präfix entfernt.
+func rewriteRoutePath(route PreparedRoute, handler http.Handler) http.Handler {
+	// Von PrepareRoutes kompiliert
+	if rewrite := route.settings.transform.PathRewrite; rewrite != nil {
+		if mountPrefix, ok := mountPrefixOf(route.Path); ok && strings.HasSuffix(mountPrefix, "/auth") {
+			// Auch mit eigener Regel darf der Standalone-OTP-Endpunkt nicht erreichbar sein
+			handler = blockStandaloneOtp(mountPrefix, handler)
 		}
+		return transform.RewritePathMiddleware(rewrite)(handler)
+	}
+	if route.Protocol == protocolGRPC {
+		return handler // gRPC-Pfade (/paket.Service/Methode) gehen unverändert an den Upstream
 	}
+	return stripRoutePrefix(route.RouteConfig, handler)
+}
 
-	log.Println("Routen erfolgreich (neu) geladen.")
-	return newRouter
-}
\ No newline at end of file
+// stripRoutePrefix entfernt bei "/*"-Routen den Pfad-Präfix DYNAMISCH vor dem Proxy
+func stripRoutePrefix(route config.RouteConfig, handler http.Handler) http.Handler {
+	mountPrefix, ok := mountPrefixOf(route.Path)
+	if !ok {
+		return handler
+	}
+
+	lastSlashIndex := strings.LastIndex(mountPrefix, "/")
+	var stripPrefix string
+	if lastSlashIndex > 0 {
+		stripPrefix = mountPrefix[:lastSlashIndex]
+	} else if mountPrefix != "" {
+		stripPrefix = mountPrefix
+	} else {
+		stripPrefix = ""
+	}
+
+	strippedHandler := handler
+	if stripPrefix != "" {
+		strippedHandler = http.StripPrefix(stripPrefix, handler)
+	}
+
+	if strings.HasSuffix(mountPrefix, "/auth") {
+		strippedHandler = blockStandaloneOtp(stripPrefix, strippedHandler)
+	}
+	return strippedHandler
+}
+
+func methodsLabel(methods []string) string {
+	if len(methods) == 0 {
+		return "*"
+	}
+	return strings.Join(normalizeMethods(methods), ",")
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"gatekeeper/internal/auth"
+	"gatekeeper/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestStreamSlotTakenAfterAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.CustomClaims{
+		Roles:            []string{"user"},
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	route := config.RouteConfig{
+		ID:            "stream-slot",
+		Path:          "/events",
+		TargetURL:     upstream.URL,
+		RequiredRoles: []string{"user"},
+		Streaming:     config.StreamingConfig{Enabled: true, MaxConnections: 1, TokenQueryParam: "access_token"},
+	}
+	settings, err := parseRouteSettings(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, chain := buildRouteHandler(&Dependencies{Keys: &auth.StaticKeyProvider{Key: &key.PublicKey}}, route, settings)
+	want := []string{mwStreamToken, mwAuth, mwACL, mwClaimCleaning, mwStream, mwProxy}
+	if !slices.Equal(chain, want) {
+		t.Fatalf("Middleware %v, erwartet %v", chain, want)
+	}
+
+	serve := func(target string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	// Der einzige Platz ist mit einem angemeldeten Stream belegt
+	go serve("/events?access_token=" + token)
+	<-entered
+
+	if code := serve("/events"); code != http.StatusUnauthorized {
+		t.Fatalf("ohne Token: %d, erwartet 401 statt des Verbindungslimits", code)
+	}
+	if code := serve("/events?access_token=" + token); code != http.StatusServiceUnavailable {
+		t.Fatalf("angemeldet über dem Limit: %d, erwartet 503", code)
+	}
+}
@@ -0,0 +1,465 @@
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/cache"
+	"gatekeeper/internal/certs"
+	"gatekeeper/internal/circuit"
+	"gatekeeper/internal/config"
+	"gatekeeper/internal/health"
+	"gatekeeper/internal/ipfilter"
+	"gatekeeper/internal/ratelimit"
+	"gatekeeper/internal/retry"
+	"gatekeeper/internal/stream"
+	"gatekeeper/internal/transform"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Apply-Status einer Route nach einem (Hot) Reload
+const (
+	RouteStatusApplied  = "applied"
+	RouteStatusRejected = "rejected"
+)
+
+// Statisch verwaltete Pfade (Athena-Proxy), dynamische Routen dürfen sie nicht überschreiben
+var reservedPrefixes = []string{"/api/auth/", "/api/projects/", "/api/users/", "/api/admin/"}
+
+// RouteStatus beschreibt, ob eine Route aus Athena aktiv ist
+type RouteStatus struct {
+	ID     string `json:"route_id,omitempty"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Bei Ablehnung läuft die zuletzt gültige Version der Route weiter
+	ServingPrevious bool `json:"serving_previous,omitempty"`
+}
+
+// routeSettings sind die geparsten Einstellungen einer Route
+type routeSettings struct {
+	proxyTimeout    time.Duration
+	breaker         circuit.Settings
+	rateLimitWindow time.Duration
+	rateLimitTiers  []ratelimit.Tier
+	cacheTTL        time.Duration
+	pool            *balancer.Pool
+	retry           *retry.Policy
+	transform       *transform.Rules
+	stream          *stream.Settings   // nil = keine Streaming-Route
+	tls             *tls.Config        // nil = Standard-TLS des Transports
+	ipPolicies      []*ipfilter.Policy // Projekt vor Route, leer = keine Prüfung
+}
+
+func parseRouteSettings(route config.RouteConfig) (routeSettings, error) {
+	var s routeSettings
+	var err error
+
+	if route.ProxyTimeout != "" {
+		if s.proxyTimeout, err = time.ParseDuration(route.ProxyTimeout); err != nil || s.proxyTimeout < 0 {
+			return s, fmt.Errorf("ungültiges ProxyTimeout '%s'", route.ProxyTimeout)
+		}
+	}
+	if route.CircuitBreaker.Enabled() {
+		if s.breaker, err = parseBreakerSettings(route.CircuitBreaker); err != nil {
+			return s, err
+		}
+	}
+	if route.RateLimit.Limit > 0 {
+		if s.rateLimitWindow, err = time.ParseDuration(route.RateLimit.Window); err != nil || s.rateLimitWindow <= 0 {
+			return s, fmt.Errorf("ungültiges RateLimit.Window '%s'", route.RateLimit.Window)
+		}
+		if !ratelimit.ValidAlgorithm(route.RateLimit.Algorithm) {
+			return s, fmt.Errorf("unbekannter RateLimit.Algorithm '%s'", route.RateLimit.Algorithm)
+		}
+		for _, t := range route.RateLimit.Tiers {
+			window, err := time.ParseDuration(t.Window)
+			if err != nil || window <= 0 || t.Limit == 0 {
+				return s, fmt.Errorf("ungültiges Rate-Limit-Tier '%s' (limit %d, window '%s')", t.Name, t.Limit, t.Window)
+			}
+			s.rateLimitTiers = append(s.rateLimitTiers, ratelimit.Tier{
+				Name: t.Name, Limit: int(t.Limit), Window: window, Burst: int(t.Burst),
+				Roles: t.Roles, Plans: t.Plans,
+			})
+		}
+	}
+	if route.CacheTTL != "" && route.CacheTTL != "0" && route.CacheTTL != "0s" {
+		if s.cacheTTL, err = time.ParseDuration(route.CacheTTL); err != nil || s.cacheTTL < 0 {
+			return s, fmt.Errorf("ungültiges CacheTTL '%s'", route.CacheTTL)
+		}
+	}
+
+	targets := make([]balancer.Target, 0, len(route.Targets()))
+	for _, t := range route.Targets() {
+		targets = append(targets, balancer.Target{URL: t.URL, Weight: t.Weight})
+	}
+	if s.pool, err = balancer.NewPool(route.LoadBalancing, targets); err != nil {
+		return s, fmt.Errorf("ungültige Upstream-Konfiguration: %w", err)
+	}
+
+	if _, _, err := health.ParseConfig(route.HealthCheck); err != nil {
+		return s, err
+	}
+	if route.Retry.MaxAttempts > 1 {
+		if s.retry, err = parseRetryPolicy(route.Retry); err != nil {
+			return s, err
+		}
+	}
+	if s.transform, err = parseTransformRules(route.Transform); err != nil {
+		return s, err
+	}
+	if !slices.Contains([]string{"", protocolHTTP, protocolGRPC, protocolH2C}, route.Protocol) {
+		return s, fmt.Errorf("unbekanntes Protocol '%s' (http, grpc oder h2c)", route.Protocol)
+	}
+	if route.Streaming.Enabled {
+		if s.stream, err = parseStreamSettings(route.Streaming); err != nil {
+			return s, err
+		}
+	}
+	if s.tls, err = certs.ClientConfig(route.UpstreamTLS); err != nil {
+		return s, fmt.Errorf("ungültige UpstreamTLS-Konfiguration: %w", err)
+	}
+	for _, p := range []struct {
+		scope  string
+		policy config.IPPolicyConfig
+	}{
+		{ipfilter.ScopeProject, route.ProjectIPPolicy},
+		{ipfilter.ScopeRoute, route.IPPolicy},
+	} {
+		if !p.policy.Enabled() {
+			continue
+		}
+		policy, err := ipfilter.NewPolicy(p.scope, ipfilter.Rules(p.policy))
+		if err != nil {
+			return s, fmt.Errorf("ungültige IP-Richtlinie (%s): %w", p.scope, err)
+		}
+		s.ipPolicies = append(s.ipPolicies, policy)
+	}
+	return s, nil
+}
+
+// parseStreamSettings prüft die Streaming-Konfiguration. Ohne IdleTimeout gilt
+// stream.DefaultIdleTimeout, "0s" schaltet ihn ab.
+func parseStreamSettings(c config.StreamingConfig) (*stream.Settings, error) {
+	s := &stream.Settings{
+		IdleTimeout:      stream.DefaultIdleTimeout,
+		MaxConnections:   c.MaxConnections,
+		TokenQueryParam:  c.TokenQueryParam,
+		TokenSubprotocol: c.TokenSubprotocol,
+	}
+	if c.IdleTimeout != "" {
+		idle, err := time.ParseDuration(c.IdleTimeout)
+		if err != nil || idle < 0 {
+			return nil, fmt.Errorf("ungültiges Streaming.IdleTimeout '%s'", c.IdleTimeout)
+		}
+		s.IdleTimeout = idle
+	}
+	if c.MaxDuration != "" {
+		maxDuration, err := time.ParseDuration(c.MaxDuration)
+		if err != nil || maxDuration < 0 {
+			return nil, fmt.Errorf("ungültiges Streaming.MaxDuration '%s'", c.MaxDuration)
+		}
+		s.MaxDuration = maxDuration
+	}
+	if c.MaxConnections < 0 {
+		return nil, fmt.Errorf("ungültiges Streaming.MaxConnections %d", c.MaxConnections)
+	}
+	return s, nil
+}
+
+// parseTransformRules kompiliert die Pfad-Regel und prüft die Header-Regeln
+func parseTransformRules(c config.TransformConfig) (*transform.Rules, error) {
+	rules := &transform.Rules{
+		RequestHeaders:  transform.HeaderRules(c.RequestHeaders),
+		ResponseHeaders: transform.HeaderRules(c.ResponseHeaders),
+		Query:           c.Query,
+	}
+	if c.PathRewrite != nil && c.PathRewrite.Pattern != "" {
+		rewrite, err := transform.NewPathRewrite(c.PathRewrite.Pattern, c.PathRewrite.Replacement)
+		if err != nil {
+			return nil, err
+		}
+		rules.PathRewrite = rewrite
+	}
+	for name := range c.Query {
+		if name == "" {
+			return nil, fmt.Errorf("leerer Name eines Query-Parameters in Transform.Query")
+		}
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// parseRetryPolicy prüft die Retry-Konfiguration und ergänzt Standardwerte
+func parseRetryPolicy(c config.RetryConfig) (*retry.Policy, error) {
+	p := &retry.Policy{
+		MaxAttempts: c.MaxAttempts,
+		StatusCodes: c.StatusCodes,
+		RetryOn:     c.RetryOn,
+		BackoffBase: retry.DefaultBackoffBase,
+		BackoffMax:  retry.DefaultBackoffMax,
+	}
+	if len(p.StatusCodes) == 0 {
+		p.StatusCodes = retry.DefaultStatusCodes
+	}
+	for _, code := range p.StatusCodes {
+		if code < 400 || code > 599 {
+			return nil, fmt.Errorf("ungültiger Retry-Statuscode %d (nur 4xx/5xx)", code)
+		}
+	}
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = retry.DefaultRetryOn
+	}
+	for _, class := range p.RetryOn {
+		if !retry.ValidErrorClass(class) {
+			return nil, fmt.Errorf("unbekannte Retry-Fehlerklasse '%s'", class)
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"BackoffBase", c.BackoffBase, &p.BackoffBase},
+		{"BackoffMax", c.BackoffMax, &p.BackoffMax},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("ungültiges Retry.%s '%s'", d.name, d.value)
+		}
+		*d.dest = parsed
+	}
+	if p.BackoffMax < p.BackoffBase {
+		return nil, fmt.Errorf("retry.BackoffMax (%s) ist kleiner als Retry.BackoffBase (%s)", p.BackoffMax, p.BackoffBase)
+	}
+
+	percent, minPerSecond := c.BudgetPercent, c.MinRetriesPerSecond
+	if percent < 0 || percent > 100 || minPerSecond < 0 {
+		return nil, fmt.Errorf("ungültiges Retry-Budget (%d%%, %d/s)", percent, minPerSecond)
+	}
+	if percent == 0 {
+		percent = retry.DefaultBudgetPercent
+	}
+	if minPerSecond == 0 {
+		minPerSecond = retry.DefaultMinRetriesPerSecond
+	}
+	p.Budget = retry.NewBudget(percent, minPerSecond)
+	return p, nil
+}
+
+// parseBreakerSettings prüft die Breaker-Konfiguration. Leere Dauern (oder 0s)
+// nehmen die Standardwerte aus dem circuit-Paket.
+func parseBreakerSettings(c config.CircuitBreakerConfig) (circuit.Settings, error) {
+	s := circuit.Settings{
+		FailureThreshold: c.FailureThreshold,
+		ErrorThreshold:   float64(c.ErrorThreshold),
+		MinRequests:      c.MinRequests,
+		HalfOpenRequests: c.HalfOpenRequests,
+	}
+	durations := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"OpenTimeout", c.OpenTimeout, &s.OpenTimeout},
+		{"Window", c.Window, &s.Window},
+		{"SlowCallDuration", c.SlowCallDuration, &s.SlowCallDuration},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return s, fmt.Errorf("ungültiges CircuitBreaker.%s '%s'", d.name, d.value)
+		}
+		*d.dest = parsed
+	}
+	if c.FailureThreshold < 0 || c.MinRequests < 0 || c.HalfOpenRequests < 0 {
+		return s, fmt.Errorf("negative Werte in der CircuitBreaker-Konfiguration sind nicht erlaubt")
+	}
+	if c.ErrorThreshold < 0 || c.ErrorThreshold > 100 {
+		return s, fmt.Errorf("ungültiges CircuitBreaker.ErrorThreshold %d (0-100 Prozent)", c.ErrorThreshold)
+	}
+	if !slices.Contains([]string{"", breakerKeyRoute, breakerKeyUpstream}, c.Key) {
+		return s, fmt.Errorf("unbekannter CircuitBreaker.Key '%s' (route oder upstream)", c.Key)
+	}
+	return s, nil
+}
+
+// PreparedRoute ist eine von PrepareRoutes geprüfte Route mit ihren geparsten
+// Einstellungen. SetupRouter baut den Handler daraus, ohne sie erneut zu parsen.
+type PreparedRoute struct {
+	config.RouteConfig
+	settings routeSettings
+}
+
+// RouteConfigs liefert die Konfigurationen der vorbereiteten Routen
+func RouteConfigs(routes []PreparedRoute) []config.RouteConfig {
+	configs := make([]config.RouteConfig, 0, len(routes))
+	for _, route := range routes {
+		configs = append(configs, route.RouteConfig)
+	}
+	return configs
+}
+
+// Backends liefert die Upstream-Backends der vorbereiteten Routen
+func Backends(routes []PreparedRoute) []*balancer.Backend {
+	var backends []*balancer.Backend
+	for _, route := range routes {
+		backends = append(backends, route.settings.pool.Backends()...)
+	}
+	return backends
+}
+
+// ValidateRoute prüft eine Route vollständig, ohne sie zu registrieren
+func ValidateRoute(route config.RouteConfig) error {
+	_, err := validateRoute(route)
+	return err
+}
+
+func validateRoute(route config.RouteConfig) (routeSettings, error) {
+	if route.Path == "" || !strings.HasPrefix(route.Path, "/") {
+		return routeSettings{}, fmt.Errorf("ungültiger Pfad '%s' (muss mit / beginnen)", route.Path)
+	}
+	if isReservedPath(route.Path) {
+		return routeSettings{}, fmt.Errorf("pfad '%s' ist reserviert (wird statisch verwaltet)", route.Path)
+	}
+	for _, m := range normalizeMethods(route.Methods) {
+		if !slices.Contains(knownMethods, m) {
+			return routeSettings{}, fmt.Errorf("ungültige HTTP-Methode '%s'", m)
+		}
+	}
+	return parseRouteSettings(route)
+}
+
+func isReservedPath(path string) bool {
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrepareRoutes validiert die neue Routenliste vor dem Reload. Ungültige Routen
+// werden abgelehnt; existierte vorher eine gültige Version derselben Route
+// (gleiche ID bzw. gleicher Pfad und Methoden), läuft diese weiter (Quarantäne).
+func PrepareRoutes(routes, previous []config.RouteConfig) ([]PreparedRoute, []RouteStatus) {
+	previousByKey := make(map[string]config.RouteConfig, len(previous))
+	for _, route := range previous {
+		previousByKey[routeKey(route)] = route
+	}
+
+	// Probe-Router pro Projekt (wie in SetupRouter): erkennen ungültige Muster, bei
+	// denen chi panict. Routen mit gleichem Pfad teilen sich einen Dispatcher und
+	// werden nur einmal registriert; sie dürfen sich innerhalb eines Projekts nicht
+	// gleichrangig überschneiden (Methoden + Priority).
+	probes := make(map[string]*chi.Mux)
+	byPath := make(map[string][]config.RouteConfig, len(routes))
+	register := func(route config.RouteConfig) error {
+		key := route.ProjectID + " " + route.Path
+		for _, other := range byPath[key] {
+			if routesConflict(route, other) {
+				return fmt.Errorf("konflikt mit Route '%s' auf '%s' (gleiche Priority %d, überlappende Methoden %s)",
+					other.ID, route.Path, route.Priority, methodsLabel(other.Methods))
+			}
+		}
+		if len(byPath[key]) == 0 {
+			probe, ok := probes[route.ProjectID]
+			if !ok {
+				probe = chi.NewRouter()
+				probes[route.ProjectID] = probe
+			}
+			if err := tryRegister(probe, route); err != nil {
+				return err
+			}
+		}
+		byPath[key] = append(byPath[key], route)
+		return nil
+	}
+
+	effective := make([]PreparedRoute, 0, len(routes))
+	statuses := make([]RouteStatus, 0, len(routes))
+	for _, route := range routes {
+		status := RouteStatus{ID: route.ID, Path: route.Path, Status: RouteStatusApplied}
+
+		settings, err := validateRoute(route)
+		if err == nil {
+			err = register(route)
+		}
+		if err == nil {
+			effective = append(effective, PreparedRoute{RouteConfig: route, settings: settings})
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Status = RouteStatusRejected
+		status.Reason = err.Error()
+		if prev, ok := previousByKey[routeKey(route)]; ok && !reflect.DeepEqual(prev, route) {
+			if settings, err := parseRouteSettings(prev); err == nil && register(prev) == nil {
+				effective = append(effective, PreparedRoute{RouteConfig: prev, settings: settings})
+				status.ServingPrevious = true
+			}
+		}
+		slog.Warn("Route abgelehnt", "route_id", route.ID, "path", route.Path, "reason", status.Reason, "serving_previous", status.ServingPrevious)
+		statuses = append(statuses, status)
+	}
+	return effective, statuses
+}
+
+func routeKey(route config.RouteConfig) string {
+	if route.ID != "" {
+		return "id:" + route.ID
+	}
+	return "path:" + methodsLabel(route.Methods) + " " + route.Path
+}
+
+// StaleCacheRoutes liefert die Purge-Auswahl der Routen mit Cache, die beim Reload
+// geändert oder entfernt wurden: ihre Einträge stammen von der alten Definition
+func StaleCacheRoutes(previous, current []config.RouteConfig) []cache.PurgeRequest {
+	currentByKey := make(map[string]config.RouteConfig, len(current))
+	for _, route := range current {
+		currentByKey[routeKey(route)] = route
+	}
+	var purges []cache.PurgeRequest
+	for _, prev := range previous {
+		if prev.CacheTTL == "" {
+			continue
+		}
+		if cur, ok := currentByKey[routeKey(prev)]; ok && reflect.DeepEqual(prev, cur) {
+			continue
+		}
+		purges = append(purges, cache.PurgeRequest{ProjectID: prev.ProjectID, RouteID: routeIdentifier(prev)})
+	}
+	return purges
+}
+
+// tryRegister registriert die Route probeweise (Probe-Router)
+func tryRegister(r *chi.Mux, route config.RouteConfig) error {
+	return tryRegisterHandler(r, route, http.NotFoundHandler())
+}
+
+// tryRegisterHandler fängt Panics von chi (Pfad-Konflikte, ungültige Muster) ab
+func tryRegisterHandler(r *chi.Mux, route config.RouteConfig, handler http.Handler) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("pfad '%s' kann nicht registriert werden: %v", route.Path, rec)
+		}
+	}()
+	registerRoute(r, route, handler)
+	return nil
+}
@@ -49,4 +49,8 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
  	}
  
  	return lrw.ResponseWriter.Write(b)
-}
\ No newline at end of file
+}
+
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
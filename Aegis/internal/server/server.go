@@ -3,9 +3,11 @@ package server
 import (
 	"context"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,9 +15,20 @@ import (
 	"sync"
 	"syscall"
 	"time"
-
+	"unicode/utf8"
+
+	"gatekeeper/internal/admin"
+	"gatekeeper/internal/auth"
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/cache"
+	"gatekeeper/internal/certs"
+	"gatekeeper/internal/clientip"
+	"gatekeeper/internal/circuit"
 	"gatekeeper/internal/config"
+	"gatekeeper/internal/health"
+	"gatekeeper/internal/ipfilter"
 	"gatekeeper/internal/router"
+	"gatekeeper/internal/stream"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redis/v8"
@@ -26,20 +39,38 @@ import (
 // Dependencies bündelt alle statischen Abhängigkeiten für den Server
 type Dependencies struct {
 	Config           *config.GatewayConfig
-	PublicKey        *rsa.PublicKey
+	Keys             auth.KeyProvider
 	TracerShutdown   func(context.Context) error
 	AthenaAPIURL     string
 	AthenaAPISecret  string
 	AthenaContextMapURL string
+	AthenaWatchURL   string // Long-Poll-Endpunkt für Config-Änderungen (leer = Polling)
+	InitialState     config.SyncState // Herkunft der Startkonfiguration (Athena oder Snapshot)
+	AthenaStatusURL  string           // Rückmeldung des Apply-Status pro Route (leer = deaktiviert)
+	InstanceID       string
 }
 
 // Server-Struktur hält den Zustand
 type Server struct {
 	httpServer  *http.Server
 	chiRouter   *chi.Mux
+	routes      []router.RouteInfo // registrierte Routen des aktiven Routers (Admin-API)
 	deps        *Dependencies
 	redisClient *redis.Client
 	routerMutex sync.RWMutex
+	healthChecker *health.Checker
+	certStore     *certs.Store // Listener-Zertifikate pro Host (nil = kein TLS)
+	clientIP      *clientip.Resolver
+	geoIP         *ipfilter.GeoDB // nil = keine GeoIP-Datenbank
+
+	syncMu        sync.Mutex // Serialisiert Config-Syncs (Watch/Polling und Admin-API)
+	stateMu       sync.RWMutex // nur nach routerMutex sperren, nie umgekehrt
+	syncState     config.SyncState
+	routeStatus   []router.RouteStatus
+	statusVersion string     // Config-Version, zu der routeStatus gehört
+	reportPending bool       // Apply-Status muss (erneut) an Athena gemeldet werden
+	lastReport    time.Time  // letzte erfolgreiche Meldung (Heartbeat)
+	reportMu      sync.Mutex // Serialisiert Meldungen, damit keine ältere eine neuere überholt
 }
 
 // NewRedisClient
@@ -68,17 +99,79 @@ func NewServer(deps *Dependencies) *Server {
 	s := &Server{
 		deps:        deps,
 		redisClient: redisClient,
+		healthChecker: health.NewChecker(),
+		syncState:   deps.InitialState,
+	}
+
+	// Breaker-Zustand mit den anderen Replicas teilen
+	if deps.Config.SharedCircuitState {
+		circuit.EnableSharedState(redisClient, deps.InstanceID)
+	}
+
+	// Zertifikate pro Projekt-Host (SNI)
+	if certs.StoreEnabled(deps.Config) {
+		store, err := certs.NewStore(deps.Config)
+		if err != nil {
+			log.Fatalf("FATAL: Listener-Zertifikate konnten nicht geladen werden: %v", err)
+		}
+		store.SetHosts(listenerHosts(deps.Config))
+		s.certStore = store
+	}
+
+	// Client-IP nur aus Headern vertrauenswürdiger Proxies
+	resolver, err := clientip.NewResolver(deps.Config.TrustedProxies, deps.Config.ClientIPHeader)
+	if err != nil {
+		log.Fatalf("FATAL: AEGIS_TRUSTED_PROXIES/AEGIS_CLIENT_IP_HEADER: %v", err)
+	}
+	s.clientIP = resolver
+
+	// Länder für IP-Richtlinien (wird bei Änderungen an der Datei neu geladen)
+	if deps.Config.GeoIPDBPath != "" {
+		geo, err := ipfilter.OpenGeoDB(deps.Config.GeoIPDBPath)
+		if err != nil {
+			log.Fatalf("FATAL: AEGIS_GEOIP_DB_PATH: %v", err)
+		}
+		s.geoIP = geo
 	}
 
+	// Ungültige Routen aussortieren statt den Start abzubrechen
+	prepared, statuses := router.PrepareRoutes(deps.Config.Routes, nil)
+	deps.Config.Routes = router.RouteConfigs(prepared)
+	s.setRouteStatus(deps.Config.Version, statuses)
+
 	// Router-Abhängigkeiten vorbereiten
 	routerDeps := &router.Dependencies{
 		Config:      deps.Config,
-		PublicKey:   deps.PublicKey,
+		Keys:        deps.Keys,
 		RedisClient: redisClient,
+		HealthChecker: s.healthChecker,
+		ConfigState: s.ConfigState,
+		ClientIP:    s.clientIP,
+		GeoIP:       s.geoIP,
+		Routes:      prepared,
 	}
 
 	// Den ersten Router aufsetzen
-	s.chiRouter = router.SetupRouter(routerDeps)
+	chiRouter, routes, err := router.SetupRouter(routerDeps)
+	if err != nil {
+		log.Fatalf("FATAL: Router konnte nicht erstellt werden: %v", err)
+	}
+	s.chiRouter, s.routes = chiRouter, routes
+	s.pruneBackends(prepared)
+
+	// Aktive Health Checks für alle Upstreams starten
+	s.healthChecker.Sync(health.TargetsFromRoutes(deps.Config.Routes))
+
+	// Nach einer Schlüsselrotation den Snapshot mit dem neuen JWKS sichern
+	if p, ok := deps.Keys.(*auth.JWKSProvider); ok {
+		p.OnChange(func() { s.saveSnapshot(s.Config()) })
+	}
+
+	// Nur frisch von Athena geladene Konfigurationen als Snapshot sichern
+	if !s.syncState.Degraded {
+		s.saveSnapshot(deps.Config)
+		go s.reportRouteStatus()
+	}
 
 	return s
 }
@@ -89,7 +182,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	router := s.chiRouter
 	s.routerMutex.RUnlock()
 
-	router.ServeHTTP(w, r)
+	// Streaming-Routen heben Read-/WriteTimeout über den Controller der Verbindung auf
+	router.ServeHTTP(w, stream.WithConnController(w, r))
 }
 
 // Start (Bündelt die Start-Logik aus main.go)
@@ -97,30 +191,77 @@ func (s *Server) Start() {
 	// Metrik-Server starten
 	go s.startMetricsServer(s.deps.Config.MetricsPort)
 
-	// Config Poller starten
-	go s.startConfigPoller(s.deps.AthenaAPIURL, s.deps.AthenaAPISecret)
+	// Config Sync starten (Watch auf Athena, Fallback Polling)
+	go s.startConfigSync(s.deps.AthenaAPIURL, s.deps.AthenaAPISecret, s.deps.AthenaWatchURL)
 
 	// Haupt-Server starten
 	portStr := strconv.Itoa(s.deps.Config.Port)
 	addr := fmt.Sprintf(":%s", portStr)
 
+	// HTTP/2 auch ohne TLS (h2c mit prior knowledge), damit gRPC-Clients den
+	// Gateway in Entwicklungsumgebungen ohne Zertifikat erreichen
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	// Read-/WriteTimeout gelten für jede Verbindung. Streaming-Routen (WebSocket, SSE,
+	// gRPC) heben sie in stream.Middleware auf; dafür hinterlegt ServeHTTP den
+	// ResponseController der Verbindung, damit kein Writer dazwischen Unwrap braucht.
 	s.httpServer = &http.Server{
 		Addr:         addr,
 		Handler:      s, // Leitet an s.ServeHTTP -> chiRouter weiter
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		Protocols:    protocols,
 	}
 
 	cfg := s.deps.Config
-	if cfg.TLSCertPath != "" && cfg.TLSKeyPath != "" {
+	tlsEnabled := s.certStore != nil
+
+	// Client-Zertifikate (mTLS) für Maschinen-Clients
+	if cfg.ClientAuth != certs.ClientAuthOff {
+		if !tlsEnabled {
+			log.Fatalf("FATAL: AEGIS_TLS_CLIENT_AUTH=%s erfordert Listener-Zertifikate (AEGIS_TLS_CERT_PATH, AEGIS_TLS_CERT_DIR, Vault PKI oder ACME)", cfg.ClientAuth)
+		}
+		if !auth.ValidIdentityField(cfg.ClientIdentity) {
+			log.Fatalf("FATAL: Unbekanntes Identitätsfeld AEGIS_TLS_CLIENT_IDENTITY=%s (cn, san_dns, san_uri, san_email)", cfg.ClientIdentity)
+		}
+		tlsConfig, err := certs.ServerConfig(cfg.ClientAuth, cfg.ClientCAPath)
+		if err != nil {
+			log.Fatalf("FATAL: Client-Zertifikate am Listener: %v", err)
+		}
+		s.httpServer.TLSConfig = tlsConfig
+		slog.Info("Client-Zertifikate am Listener aktiv", "mode", cfg.ClientAuth, "identity", cfg.ClientIdentity)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Fehler beim Starten des Servers auf %s: %v", addr, err)
+	}
+	// PROXY-Protokoll (HAProxy, NLB): Client-Adresse aus dem Verbindungs-Header
+	if cfg.ProxyProtocol != clientip.ProxyProtocolOff {
+		if ln, err = clientip.ProxyProtocolListener(ln, s.clientIP, cfg.ProxyProtocol); err != nil {
+			log.Fatalf("FATAL: AEGIS_PROXY_PROTOCOL=%s: %v", cfg.ProxyProtocol, err)
+		}
+		slog.Info("PROXY-Protokoll am Listener aktiv", "mode", cfg.ProxyProtocol)
+	}
+
+	if tlsEnabled {
+		// Zertifikat per SNI aus dem Store statt eines einzelnen Paars
+		s.httpServer.TLSConfig = s.certStore.TLSConfig(s.httpServer.TLSConfig)
+		if cfg.ACME.HTTPPort > 0 {
+			go s.startACMEChallengeServer(cfg.ACME.HTTPPort, s.certStore.HTTPHandler())
+		}
+		s.certStore.StartIssuing()
 		fmt.Printf("Gatekeeper gestartet auf https://localhost%s (TLS/HTTPS)\n", addr)
-		if err := s.httpServer.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath); err != nil && err != http.ErrServerClosed {
+		if err := s.httpServer.ServeTLS(ln, "", ""); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Fehler beim Starten des HTTPS-Servers: %v", err)
 		}
 	} else {
 		fmt.Printf("Gatekeeper gestartet auf http://localhost%s (Kein TLS. Nur für Entwicklung!)\n", addr)
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Fehler beim Starten des HTTP-Servers: %v", err)
 		}
 	}
@@ -137,6 +278,13 @@ func (s *Server) WaitForShutdown() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	s.healthChecker.Stop()
+	circuit.DisableSharedState()
+	if s.certStore != nil {
+		s.certStore.Close()
+	}
+	s.geoIP.Close()
+
 	if s.deps.TracerShutdown != nil {
 		if err := s.deps.TracerShutdown(ctx); err != nil {
 			log.Printf("Fehler beim Shutdown des Tracer Providers: %v", err)
@@ -152,11 +300,48 @@ func (s *Server) WaitForShutdown() {
 	log.Println("Server erfolgreich heruntergefahren.")
 }
 
+// startACMEChallengeServer beantwortet HTTP-01-Challenges auf Port 80 (bzw. port)
+// und leitet alle anderen Requests auf HTTPS um
+func (s *Server) startACMEChallengeServer(port int, handler http.Handler) {
+	addr := fmt.Sprintf(":%d", port)
+	slog.Info("ACME HTTP-01-Challenges aktiv", "addr", addr)
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Fehler beim Starten des ACME-Challenge-Servers auf %s: %v", addr, err)
+	}
+}
+
+// listenerHosts sind die Hosts, für die der Listener Zertifikate braucht
+func listenerHosts(cfg *config.GatewayConfig) []string {
+	hosts := make([]string, 0, len(cfg.ContextMap)+1)
+	for host := range cfg.ContextMap {
+		hosts = append(hosts, host)
+	}
+	if cfg.AdminHost != "" {
+		hosts = append(hosts, cfg.AdminHost)
+	}
+	return hosts
+}
+
 // startMetricsServer (aus main.go verschoben)
 func (s *Server) startMetricsServer(port int) {
 	addr := fmt.Sprintf(":%d", port)
     metricsMux := http.NewServeMux()
     metricsMux.Handle("/metrics", promhttp.Handler())
+    // Interne Cache-Purge-API (Athena bei Routen-Änderungen, Deploy-Skripte)
+    metricsMux.Handle("/internal/cache/purge", cache.PurgeHandler(s.redisClient, s.deps.AthenaAPISecret))
+    // Admin-API für Betreiber (Routen, Breaker, Reload), nur mit Token
+    if token := s.deps.Config.AdminToken; token != "" {
+        metricsMux.Handle("/admin/", admin.Handler(s, token))
+        slog.Info("Admin-API aktiv", "addr", addr+"/admin/")
+    } else {
+        slog.Info("Admin-API deaktiviert (AEGIS_ADMIN_TOKEN nicht gesetzt)")
+    }
     fmt.Printf("Prometheus Metriken gestartet auf http://localhost%s/metrics\n", addr)
     
     err := http.ListenAndServe(addr, metricsMux)
@@ -165,7 +350,7 @@ func (s *Server) startMetricsServer(port int) {
     }
 }
 
-// loadPublicKey (Hier dupliziert für den Poller)
+// loadPublicKey (Hier dupliziert für den Config Sync)
 func loadPublicKey(filePath string) (*rsa.PublicKey, error) {
 	keyData, err := os.ReadFile(filePath)
 	if err != nil {
@@ -179,66 +364,183 @@ func loadPublicKey(filePath string) (*rsa.PublicKey, error) {
 }
 
 
-// startConfigPoller (aus main.go verschoben, jetzt mit Key-Reloading)
-func (s *Server) startConfigPoller(apiURL, apiSecret string) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-	log.Printf("Config Poller gestartet. Prüfe Athena API alle 30s auf %s", apiURL)
-
-	contextMapURL := s.deps.AthenaContextMapURL
-
-	for range ticker.C {
-		slog.Debug("Config Poller: Prüfe Athena API auf Änderungen...")
+const (
+	configPollInterval = 30 * time.Second // Fallback ohne Watch
+	configWatchTimeout = 30 * time.Second
+	// Apply-Status auch ohne Änderung regelmäßig melden: Athena verwirft Berichte
+	// nach 15 Minuten (Instanz beendet)
+	routeStatusHeartbeat = 5 * time.Minute
+	maxStatusReasonLen   = 1024
+)
 
-		cfg, err := config.LoadConfigFromAPI(apiURL, contextMapURL, apiSecret)
-		if err != nil {
-			slog.Warn("Config Poller: FEHLER beim Abrufen der Konfig von Athena", "error", err)
-			continue
-		}
+// startConfigSync hält die Konfiguration aktuell. Bevorzugt per Long-Poll auf
+// Athenas Watch-Endpunkt (Änderungen greifen sofort), sonst per Polling.
+// Neu geladen wird nur, wenn sich die Config-Version geändert hat.
+func (s *Server) startConfigSync(apiURL, apiSecret, watchURL string) {
+	if watchURL == "" {
+		log.Printf("Config Sync gestartet (Polling). Prüfe Athena API alle %s auf %s", configPollInterval, apiURL)
+	} else {
+		log.Printf("Config Sync gestartet (Watch). Warte auf Änderungen über %s", watchURL)
+	}
 
-		currentKeyPath := s.GetPublicKeyPath()
-		newKeyPath := cfg.JwtPublicKeyPath
-		
-		var newPubKey *rsa.PublicKey
-		
-		if currentKeyPath != newKeyPath {
-			slog.Info("Config Poller: Pfad des Public Key hat sich geändert. Lade neu.", "old", currentKeyPath, "new", newKeyPath)
-			newPubKey, err = loadPublicKey(newKeyPath)
-			if err != nil {
-				slog.Warn("Config Poller: FEHLER: Neuer Public Key konnte nicht geladen werden. Reload übersprungen.", "path", newKeyPath, "error", err)
+	backoff := time.Second
+	for {
+		trigger := "watch"
+		if watchURL == "" {
+			trigger = "poll"
+			time.Sleep(configPollInterval)
+		} else {
+			known := s.ConfigVersion()
+			newVersion, err := config.WatchConfigVersion(context.Background(), watchURL, apiSecret, known, configWatchTimeout)
+			switch {
+			case errors.Is(err, config.ErrWatchNotSupported):
+				slog.Warn("Config Sync: Athena bietet keinen Watch-Endpunkt an, wechsle auf Polling", "url", watchURL)
+				watchURL = ""
 				continue
+			case err != nil:
+				slog.Warn("Config Sync: Watch fehlgeschlagen, prüfe per Abruf", "error", err, "retry_in", backoff.String())
+				s.markSyncError(err)
+				time.Sleep(backoff)
+				backoff = min(backoff*2, configPollInterval)
+			default:
+				backoff = time.Second
+				if newVersion == known {
+					s.markSynced() // Athena erreichbar, Stand aktuell
+					continue // Timeout ohne Änderung
+				}
+				slog.Info("Config Sync: Neue Config-Version gemeldet", "old", known, "new", newVersion)
 			}
-		} else {
-			newPubKey = nil
 		}
 
-		if err := s.ReloadConfig(cfg, newPubKey); err != nil {
-			slog.Warn("Config Poller: Fehler beim Hot Reload", "error", err)
-		} else {
-			slog.Info("Config Poller: Hot Reload erfolgreich abgeschlossen.")
+		s.syncConfig(apiURL, apiSecret, trigger, false)
+	}
+}
+
+// Reload lädt die Konfiguration sofort von Athena (Admin-API). Mit force wird
+// auch bei unveränderter Version neu aufgebaut.
+func (s *Server) Reload(force bool) config.ReloadStatus {
+	return s.syncConfig(s.deps.AthenaAPIURL, s.deps.AthenaAPISecret, "admin", force)
+}
+
+// syncConfig lädt die Konfiguration, falls geändert, und führt den Hot Reload aus
+func (s *Server) syncConfig(apiURL, apiSecret, trigger string, force bool) config.ReloadStatus {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	slog.Debug("Config Sync: Prüfe Athena API auf Änderungen...", "trigger", trigger)
+
+	known := s.ConfigVersion()
+	status := config.ReloadStatus{At: time.Now(), Trigger: trigger, Version: known}
+	if force {
+		known = "" // ohne If-None-Match liefert Athena immer die volle Konfiguration
+	}
+
+	cfg, err := config.LoadConfigFromAPIIfChanged(apiURL, s.deps.AthenaContextMapURL, apiSecret, known)
+	if errors.Is(err, config.ErrConfigNotModified) {
+		slog.Debug("Config Sync: Konfiguration unverändert, kein Reload")
+		s.markSynced()
+		status.Result = config.ReloadUnchanged
+		return s.setLastReload(status)
+	}
+	if err != nil {
+		slog.Warn("Config Sync: FEHLER beim Abrufen der Konfig von Athena", "error", err)
+		s.markSyncError(err)
+		status.Result, status.Error = config.ReloadFailed, err.Error()
+		return s.setLastReload(status)
+	}
+
+	currentKeyPath := s.GetPublicKeyPath()
+	newKeyPath := cfg.JwtPublicKeyPath
+
+	var newPubKey *rsa.PublicKey
+
+	// Im JWKS-Modus rotiert der JWKSProvider selbst, die PEM-Datei ist irrelevant
+	if cfg.JwksURL == "" && currentKeyPath != newKeyPath {
+		slog.Info("Config Sync: Pfad des Public Key hat sich geändert. Lade neu.", "old", currentKeyPath, "new", newKeyPath)
+		newPubKey, err = loadPublicKey(newKeyPath)
+		if err != nil {
+			slog.Warn("Config Sync: FEHLER: Neuer Public Key konnte nicht geladen werden. Reload übersprungen.", "path", newKeyPath, "error", err)
+			status.Result, status.Error = config.ReloadFailed, err.Error()
+			return s.setLastReload(status)
 		}
 	}
+
+	if err := s.ReloadConfig(cfg, newPubKey); err != nil {
+		slog.Warn("Config Sync: Fehler beim Hot Reload", "error", err)
+		status.Result, status.Error = config.ReloadFailed, err.Error()
+		return s.setLastReload(status)
+	}
+	slog.Info("Config Sync: Hot Reload erfolgreich abgeschlossen.", "version", cfg.Version)
+	status.Result, status.Version = config.ReloadApplied, cfg.Version
+	return s.setLastReload(status)
+}
+
+// setLastReload merkt sich das Ergebnis des letzten Reload-Versuchs
+func (s *Server) setLastReload(status config.ReloadStatus) config.ReloadStatus {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.syncState.LastReload = &status
+	return status
 }
 
 // ReloadConfig (Aktualisiert, um den Router neu zu erstellen)
 func (s *Server) ReloadConfig(newCfg *config.GatewayConfig, newPubKey *rsa.PublicKey) error {
 	slog.Debug("Hot Reload wird ausgelöst...")
-	
-	// 1. Abhängigkeiten aktualisieren
-	s.routerMutex.Lock()
-	s.deps.Config = newCfg
+
+	s.routerMutex.RLock()
+	previousRoutes := s.deps.Config.Routes
+	keys := s.deps.Keys
+	redisClient := s.redisClient
+	s.routerMutex.RUnlock()
+
+	// 0. Routen validieren: ungültige werden abgelehnt, ihre letzte gültige Version läuft weiter
+	prepared, statuses := router.PrepareRoutes(newCfg.Routes, previousRoutes)
+	newCfg.Routes = router.RouteConfigs(prepared)
+
+	// 1. Abhängigkeiten für den neuen Router vorbereiten. Der aktive Stand bleibt
+	// unverändert, bis der Router steht.
 	if newPubKey != nil {
-		s.deps.PublicKey = newPubKey
+		keys = &auth.StaticKeyProvider{Key: newPubKey}
+	}
+	oldRedisClient := redisClient
+	if newCfg.RedisAddr != oldRedisClient.Options().Addr {
+		slog.Info("Redis-Adresse hat sich geändert", "old", oldRedisClient.Options().Addr, "new", newCfg.RedisAddr)
+		redisClient = NewRedisClient(newCfg.RedisAddr)
 	}
-	
-	// Prüfen, ob Redis sich geändert hat
-	if newCfg.RedisAddr != s.redisClient.Options().Addr {
-		slog.Info("Redis-Adresse hat sich geändert", "old", s.redisClient.Options().Addr, "new", newCfg.RedisAddr)
-		
-		oldRedisClient := s.redisClient
-		s.redisClient = NewRedisClient(newCfg.RedisAddr)
 
-		// Alten Client verzögert schließen
+	// 2. Neuen Router erstellen
+	routerDeps := &router.Dependencies{
+		Config:      newCfg,
+		Keys:        keys,
+		RedisClient: redisClient,
+		HealthChecker: s.healthChecker,
+		ConfigState: s.ConfigState,
+		ClientIP:    s.clientIP,
+		GeoIP:       s.geoIP,
+		Routes:      prepared,
+	}
+	newRouter, routes, err := router.SetupRouter(routerDeps)
+	if err != nil {
+		if redisClient != oldRedisClient {
+			redisClient.Close()
+		}
+		return fmt.Errorf("router konnte nicht erstellt werden: %w", err)
+	}
+
+	// 3. Router, Konfiguration (und damit Version) und Apply-Status gemeinsam austauschen
+	s.routerMutex.Lock()
+	s.chiRouter = newRouter
+	s.routes = routes
+	s.deps.Config = newCfg
+	s.deps.Keys = keys
+	s.redisClient = redisClient
+	s.setRouteStatus(newCfg.Version, statuses)
+	s.routerMutex.Unlock()
+
+	if redisClient != oldRedisClient {
+		if newCfg.SharedCircuitState {
+			circuit.EnableSharedState(redisClient, s.deps.InstanceID)
+		}
+		// Alten Client verzögert schließen, laufende Requests nutzen ihn noch
 		go func() {
 			time.Sleep(5 * time.Second)
 			if err := oldRedisClient.Close(); err != nil {
@@ -246,25 +548,212 @@ func (s *Server) ReloadConfig(newCfg *config.GatewayConfig, newPubKey *rsa.Publi
 			}
 		}()
 	}
-	s.routerMutex.Unlock() // Entsperren VOR Router-Setup
 
-	// 2. Neuen Router erstellen
-	routerDeps := &router.Dependencies{
-		Config:      s.deps.Config,
-		PublicKey:   s.deps.PublicKey,
-		RedisClient: s.redisClient,
+	// 4. Cache geänderter Routen leeren. Athena purged schon beim Speichern, aber
+	// erst jetzt liefert diese Replica nur noch Antworten der neuen Definition;
+	// Einträge, die andere Replicas bis zu ihrem Reload noch mit der alten füllen,
+	// löscht deren eigener Reload.
+	s.purgeStaleCache(redisClient, router.StaleCacheRoutes(previousRoutes, newCfg.Routes))
+
+	// 5. Health Checks und Backend-Registry an die neuen Upstreams anpassen
+	s.pruneBackends(prepared)
+	s.healthChecker.Sync(health.TargetsFromRoutes(newCfg.Routes))
+	if s.certStore != nil {
+		s.certStore.SetHosts(listenerHosts(newCfg))
 	}
-	newRouter := router.SetupRouter(routerDeps)
 
-	// 3. Router atomar austauschen
-	s.routerMutex.Lock()
-	s.chiRouter = newRouter
-	s.routerMutex.Unlock()
+	// 6. Als letzten funktionierenden Stand sichern, Apply-Status an Athena melden
+	s.markSynced()
+	s.saveSnapshot(newCfg)
 
 	slog.Info("Hot Reload erfolgreich abgeschlossen.")
 	return nil
 }
 
+// pruneBackends entfernt Backends aus der Registry, die der aktive Router nicht mehr
+// nutzt (z.B. geänderte Upstream-URLs oder Routen, die bei der Prüfung scheiterten)
+func (s *Server) pruneBackends(prepared []router.PreparedRoute) {
+	if removed := balancer.Prune(router.Backends(prepared)); removed > 0 {
+		slog.Debug("Nicht mehr genutzte Backends entfernt", "count", removed)
+	}
+}
+
+// purgeStaleCache löscht die Cache-Einträge der Routen im Hintergrund. Fehler werden
+// nur geloggt: die Einträge laufen spätestens über ihre TTL aus.
+func (s *Server) purgeStaleCache(client *redis.Client, purges []cache.PurgeRequest) {
+	if len(purges) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, req := range purges {
+			purged, err := cache.Purge(ctx, client, req)
+			if err != nil {
+				slog.Warn("Cache-Purge der geänderten Route fehlgeschlagen", "project_id", req.ProjectID, "route_id", req.RouteID, "error", err)
+				continue
+			}
+			slog.Info("Cache der geänderten Route geleert", "project_id", req.ProjectID, "route_id", req.RouteID, "purged", purged)
+		}
+	}()
+}
+
+// ConfigState liefert Herkunft und Sync-Status der aktiven Konfiguration (für /health)
+func (s *Server) ConfigState() config.SyncState {
+	version := s.ConfigVersion()
+
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+
+	state := s.syncState
+	state.Version = version
+	return state
+}
+
+// markSynced wird aufgerufen, sobald Athena den aktiven Stand bestätigt hat.
+// Ein Start aus dem Snapshot ist damit abgeglichen (kein Degraded Mode mehr).
+func (s *Server) markSynced() {
+	version := s.ConfigVersion()
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.syncState.Degraded {
+		slog.Info("Config Sync: Athena wieder erreichbar, Degraded Mode beendet", "version", version)
+	}
+	s.syncState.Source = config.SourceAthena
+	s.syncState.Degraded = false
+	s.syncState.LastSync = time.Now()
+	s.syncState.LastError = ""
+
+	if s.reportPending || time.Since(s.lastReport) >= routeStatusHeartbeat {
+		go s.reportRouteStatus()
+	}
+}
+
+// RouteStatus liefert den Apply-Status aller Routen des letzten Reloads
+func (s *Server) RouteStatus() []router.RouteStatus {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return append([]router.RouteStatus(nil), s.routeStatus...)
+}
+
+// setRouteStatus merkt sich den Apply-Status zusammen mit der Version, zu der er gehört
+func (s *Server) setRouteStatus(version string, statuses []router.RouteStatus) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.routeStatus = statuses
+	s.statusVersion = version
+	s.reportPending = true
+}
+
+// reportRouteStatus meldet den Apply-Status an Athena (nach Änderungen, sonst als
+// Heartbeat). Schlägt die Meldung fehl, wird sie beim nächsten erfolgreichen Sync wiederholt.
+func (s *Server) reportRouteStatus() {
+	if s.deps.AthenaStatusURL == "" {
+		return
+	}
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+
+	s.stateMu.Lock()
+	version := s.statusVersion
+	if !s.reportPending && time.Since(s.lastReport) < routeStatusHeartbeat {
+		s.stateMu.Unlock()
+		return
+	}
+	report := config.RouteStatusReport{
+		InstanceID:    s.deps.InstanceID,
+		ConfigVersion: version,
+		Routes:        make([]config.RouteStatusEntry, 0, len(s.routeStatus)),
+	}
+	for _, rs := range s.routeStatus {
+		if rs.ID == "" {
+			continue // Nur Routen aus Athena haben eine ID
+		}
+		report.Routes = append(report.Routes, config.RouteStatusEntry{RouteID: rs.ID, Status: rs.Status, Reason: truncateUTF8(rs.Reason, maxStatusReasonLen)})
+	}
+	s.reportPending = false
+	s.stateMu.Unlock()
+
+	if err := config.ReportRouteStatus(context.Background(), s.deps.AthenaStatusURL, s.deps.AthenaAPISecret, report); err != nil {
+		slog.Warn("Apply-Status konnte nicht an Athena gemeldet werden (neuer Versuch beim nächsten Sync)", "error", err)
+		s.stateMu.Lock()
+		s.reportPending = true
+		s.stateMu.Unlock()
+		return
+	}
+	s.stateMu.Lock()
+	s.lastReport = time.Now()
+	s.stateMu.Unlock()
+	slog.Debug("Apply-Status an Athena gemeldet", "routes", len(report.Routes), "version", version)
+}
+
+// truncateUTF8 kürzt s auf höchstens n Bytes, ohne ein Zeichen zu teilen
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (s *Server) markSyncError(err error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.syncState.LastError = err.Error()
+}
+
+// saveSnapshot sichert die angewendete Konfiguration für Starts ohne Athena
+func (s *Server) saveSnapshot(cfg *config.GatewayConfig) {
+	if cfg.SnapshotPath == "" {
+		return
+	}
+	var jwks []byte
+	if p, ok := s.currentKeys().(*auth.JWKSProvider); ok {
+		jwks = p.Document()
+	}
+	if err := config.SaveSnapshot(cfg.SnapshotPath, cfg, jwks); err != nil {
+		slog.Warn("Config-Snapshot konnte nicht gespeichert werden", "path", cfg.SnapshotPath, "error", err)
+		return
+	}
+
+	s.stateMu.Lock()
+	s.syncState.SnapshotSavedAt = time.Now()
+	s.stateMu.Unlock()
+	slog.Debug("Config-Snapshot gespeichert", "path", cfg.SnapshotPath, "version", cfg.Version)
+}
+
+// ConfigVersion liefert die Version der aktuell aktiven Konfiguration
+func (s *Server) ConfigVersion() string {
+	s.routerMutex.RLock()
+	defer s.routerMutex.RUnlock()
+	return s.deps.Config.Version
+}
+
+// Config liefert die aktive Konfiguration (nur lesen, wird bei Reloads ersetzt)
+func (s *Server) Config() *config.GatewayConfig {
+	s.routerMutex.RLock()
+	defer s.routerMutex.RUnlock()
+	return s.deps.Config
+}
+
+// currentKeys liefert die aktiven JWT-Schlüssel (werden im PEM-Modus bei Reloads ersetzt)
+func (s *Server) currentKeys() auth.KeyProvider {
+	s.routerMutex.RLock()
+	defer s.routerMutex.RUnlock()
+	return s.deps.Keys
+}
+
+// Routes liefert die registrierten Routen des aktiven Routers
+func (s *Server) Routes() []router.RouteInfo {
+	s.routerMutex.RLock()
+	defer s.routerMutex.RUnlock()
+	return append([]router.RouteInfo(nil), s.routes...)
+}
+
 // GetPublicKeyPath (Unverändert)
 func (s *Server) GetPublicKeyPath() string {
 	s.routerMutex.RLock()
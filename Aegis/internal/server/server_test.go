@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gatekeeper/internal/balancer"
+	"gatekeeper/internal/config"
+	"gatekeeper/internal/health"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func testServer(t *testing.T, cfg *config.GatewayConfig) *Server {
+	t.Helper()
+	t.Setenv("ATHENA_SERVICE_URL", "http://athena.test")
+	cfg.RedisAddr = miniredis.RunT(t).Addr()
+	s := &Server{
+		deps:          &Dependencies{Config: cfg},
+		redisClient:   NewRedisClient(cfg.RedisAddr),
+		healthChecker: health.NewChecker(),
+	}
+	t.Cleanup(func() { s.redisClient.Close() })
+	if err := s.ReloadConfig(cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testRoute(t *testing.T, id string) config.RouteConfig {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	return config.RouteConfig{ID: id, Path: "/" + id, TargetURL: srv.URL}
+}
+
+func TestReloadConfigKeepsStateWhenRouterFails(t *testing.T) {
+	old := testRoute(t, "alt")
+	s := testServer(t, &config.GatewayConfig{Version: "v1", Routes: []config.RouteConfig{old}})
+	oldBackend, _ := balancer.GetBackend(old.TargetURL)
+	router, client := s.chiRouter, s.redisClient
+
+	// Ohne Athena-URL scheitert der Aufbau des Routers
+	t.Setenv("ATHENA_SERVICE_URL", "")
+	newCfg := &config.GatewayConfig{
+		Version:   "v2",
+		RedisAddr: miniredis.RunT(t).Addr(),
+		Routes:    []config.RouteConfig{testRoute(t, "neu")},
+	}
+	if err := s.ReloadConfig(newCfg, nil); err == nil {
+		t.Fatal("Reload ohne Router war erfolgreich")
+	}
+
+	if got := s.ConfigVersion(); got != "v1" {
+		t.Fatalf("Version %s nach fehlgeschlagenem Reload, erwartet v1", got)
+	}
+	if s.chiRouter != router || s.redisClient != client {
+		t.Fatal("Router oder Redis-Client wurden trotz Fehler ausgetauscht")
+	}
+	if statuses := s.RouteStatus(); len(statuses) != 1 || statuses[0].ID != "alt" || s.statusVersion != "v1" {
+		t.Fatalf("Apply-Status %+v (Version %s) gehört nicht zum aktiven Stand", statuses, s.statusVersion)
+	}
+
+	// Der nächste Sync wendet die gleiche Konfiguration erneut an
+	t.Setenv("ATHENA_SERVICE_URL", "http://athena.test")
+	if err := s.ReloadConfig(newCfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s.ConfigVersion() != "v2" || s.statusVersion != "v2" || s.redisClient.Options().Addr != newCfg.RedisAddr {
+		t.Fatalf("Version %s, Status-Version %s nach erfolgreichem Reload", s.ConfigVersion(), s.statusVersion)
+	}
+	if statuses := s.RouteStatus(); len(statuses) != 1 || statuses[0].ID != "neu" {
+		t.Fatalf("Apply-Status %+v, erwartet die neue Route", statuses)
+	}
+	// Der Upstream der entfernten Route verschwindet aus der Registry
+	if b, _ := balancer.GetBackend(old.TargetURL); b == oldBackend {
+		t.Fatal("Backend der entfernten Route ist noch registriert")
+	}
+}
@@ -0,0 +1,45 @@
+package stream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "gatekeeper"
+	subsystem = "stream"
+)
+
+var openStreams = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "open",
+		Help:      "Offene Streams (WebSocket, SSE) pro Route.",
+	},
+	[]string{"route", "kind"},
+)
+
+var rejectedStreams = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rejected_total",
+		Help:      "Abgelehnte Streams, weil das Verbindungslimit der Route erreicht war.",
+	},
+	[]string{"route"},
+)
+
+var streamDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "duration_seconds",
+		Help:      "Lebensdauer beendeter Streams.",
+		Buckets:   []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600},
+	},
+	[]string{"kind", "reason"},
+)
+
+func init() {
+	prometheus.MustRegister(openStreams, rejectedStreams, streamDuration)
+}
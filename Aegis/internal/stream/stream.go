@@ -0,0 +1,267 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gatekeeper/internal/grpc"
+)
+
+// Arten von Streams (Label "kind" der Metriken)
+const (
+	KindWebSocket = "websocket"
+	KindSSE       = "sse"
+	KindGRPC      = "grpc"
+	KindHTTP      = "http" // sonstige lang laufende Antworten (z.B. chunked Downloads)
+)
+
+// Gründe für das Ende eines Streams (Label "reason")
+const (
+	reasonClosed      = "closed"
+	reasonIdle        = "idle"
+	reasonMaxDuration = "max_duration"
+)
+
+// DefaultIdleTimeout schließt Streams, über die so lange nichts übertragen wurde
+const DefaultIdleTimeout = 5 * time.Minute
+
+// TokenSubprotocolPrefix kennzeichnet das JWT im Sec-WebSocket-Protocol-Header
+// ("bearer.<jwt>"). Browser können beim WebSocket-Handshake keine eigenen Header setzen.
+const TokenSubprotocolPrefix = "bearer."
+
+var (
+	errIdle        = errors.New("stream ohne Datenverkehr")
+	errMaxDuration = errors.New("maximale Stream-Dauer erreicht")
+)
+
+// Settings sind die Stream-Einstellungen einer Route
+type Settings struct {
+	IdleTimeout      time.Duration // 0 = kein Idle-Timeout
+	MaxDuration      time.Duration // 0 = unbegrenzt
+	MaxConnections   int           // pro Route und Instanz, 0 = unbegrenzt
+	TokenQueryParam  string        // JWT aus diesem Query-Parameter übernehmen (leer = aus)
+	TokenSubprotocol bool          // JWT aus dem Subprotocol "bearer.<jwt>" übernehmen
+}
+
+// Offene Streams pro Route. Die Zähler überleben ein Neuladen der Routen, damit
+// bestehende Verbindungen weiter gegen das Limit zählen.
+var (
+	countersMu sync.Mutex
+	counters   = make(map[string]*atomic.Int64)
+)
+
+func counterFor(name string) *atomic.Int64 {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	c, ok := counters[name]
+	if !ok {
+		c = new(atomic.Int64)
+		counters[name] = c
+	}
+	return c
+}
+
+// Kind ordnet einen Request einer Stream-Art zu
+func Kind(r *http.Request) string {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return KindWebSocket
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return KindSSE
+	}
+	if grpc.IsGRPCRequest(r) {
+		return KindGRPC
+	}
+	return KindHTTP
+}
+
+// TokenMiddleware übernimmt ein JWT aus Query-Parameter oder Subprotocol in den
+// Authorization-Header. Sie liegt vor Rate Limit und Auth, Middleware dahinter.
+func TokenMiddleware(s Settings) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, extractToken(r, s))
+		})
+	}
+}
+
+// ExtractsToken meldet, ob die Route ein JWT aus Query-Parameter oder Subprotocol übernimmt
+func (s Settings) ExtractsToken() bool {
+	return s.TokenQueryParam != "" || s.TokenSubprotocol
+}
+
+// WithConnController hinterlegt den ResponseController der Verbindung im Request.
+// Der Server ruft sie vor dem Router auf: so hebt Middleware die Timeouts auch dann
+// auf, wenn ein Writer dazwischen kein Unwrap anbietet.
+func WithConnController(w http.ResponseWriter, r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), connControllerKey{}, http.NewResponseController(w)))
+}
+
+type connControllerKey struct{}
+
+func connController(w http.ResponseWriter, r *http.Request) *http.ResponseController {
+	if rc, ok := r.Context().Value(connControllerKey{}).(*http.ResponseController); ok {
+		return rc
+	}
+	return http.NewResponseController(w)
+}
+
+// Middleware macht eine Route streamingfähig: sie hebt die Timeouts des Servers
+// auf, begrenzt die offenen Verbindungen und schließt Streams nach IdleTimeout bzw.
+// MaxDuration. Sie liegt hinter Rate Limit und Auth, damit abgewiesene Requests
+// keinen Platz im Verbindungslimit belegen.
+func Middleware(name string, s Settings) func(http.Handler) http.Handler {
+	open := counterFor(name)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n := open.Add(1); s.MaxConnections > 0 && n > int64(s.MaxConnections) {
+				open.Add(-1)
+				rejectedStreams.WithLabelValues(name).Inc()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Zu viele offene Verbindungen für diese Route.", http.StatusServiceUnavailable)
+				return
+			}
+			defer open.Add(-1)
+
+			kind := Kind(r)
+			start := time.Now()
+			openStreams.WithLabelValues(name, kind).Inc()
+			defer openStreams.WithLabelValues(name, kind).Dec()
+
+			// Read-/WriteTimeout des Servers gelten für normale Requests und würden
+			// Streams nach wenigen Sekunden abbrechen
+			var deadline time.Time
+			if s.MaxDuration > 0 {
+				deadline = start.Add(s.MaxDuration)
+			}
+			rc := connController(w, r)
+			if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				slog.WarnContext(r.Context(), "Read-Deadline für Stream nicht gesetzt", "error", err)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				slog.WarnContext(r.Context(), "Write-Deadline für Stream nicht gesetzt", "error", err)
+			}
+
+			ctx, cancel := context.WithCancelCause(r.Context())
+			defer cancel(nil)
+			if s.MaxDuration > 0 {
+				timer := time.AfterFunc(s.MaxDuration, func() { cancel(errMaxDuration) })
+				defer timer.Stop()
+			}
+
+			sw := &streamWriter{ResponseWriter: w}
+			sw.touch()
+			if s.IdleTimeout > 0 {
+				stop := watchIdle(sw, s.IdleTimeout, func() { cancel(errIdle) })
+				defer stop()
+			}
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			reason := reasonClosed
+			switch context.Cause(ctx) {
+			case errIdle:
+				reason = reasonIdle
+			case errMaxDuration:
+				reason = reasonMaxDuration
+			}
+			if reason != reasonClosed {
+				slog.InfoContext(r.Context(), "Stream beendet", "route", name, "kind", kind, "reason", reason)
+			}
+			streamDuration.WithLabelValues(kind, reason).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// watchIdle ruft onIdle auf, sobald über den Stream timeout lang nichts übertragen
+// wurde. Liefert eine Funktion zum Beenden der Überwachung.
+func watchIdle(sw *streamWriter, timeout time.Duration, onIdle func()) func() {
+	var timer *time.Timer
+	var mu sync.Mutex
+	stopped := false
+	var check func()
+	check = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		if idle := time.Since(sw.lastActivity()); idle < timeout {
+			timer = time.AfterFunc(timeout-idle, check)
+			return
+		}
+		onIdle()
+	}
+	timer = time.AfterFunc(timeout, check)
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+// extractToken übernimmt ein JWT aus Query-Parameter oder Subprotocol in den
+// Authorization-Header, damit Auth und Rate Limit es wie gewohnt prüfen. Das Token
+// wird in jedem Fall entfernt und nicht an den Upstream weitergegeben.
+func extractToken(r *http.Request, s Settings) *http.Request {
+	var token string
+	r2 := r
+
+	if s.TokenQueryParam != "" {
+		if query := r.URL.Query(); query.Has(s.TokenQueryParam) {
+			token = query.Get(s.TokenQueryParam)
+			query.Del(s.TokenQueryParam)
+			r2 = r.Clone(r.Context())
+			r2.URL.RawQuery = query.Encode()
+			// RequestURI enthielte das Token weiterhin (Logs, Cache-Schlüssel)
+			path, _, _ := strings.Cut(r.RequestURI, "?")
+			if r2.URL.RawQuery != "" {
+				path += "?" + r2.URL.RawQuery
+			}
+			r2.RequestURI = path
+		}
+	}
+
+	if s.TokenSubprotocol {
+		if protocols := r.Header.Values("Sec-WebSocket-Protocol"); len(protocols) > 0 {
+			var kept []string
+			for _, value := range protocols {
+				for _, p := range strings.Split(value, ",") {
+					p = strings.TrimSpace(p)
+					if rest, ok := strings.CutPrefix(p, TokenSubprotocolPrefix); ok {
+						if token == "" {
+							token = rest
+						}
+						continue
+					}
+					if p != "" {
+						kept = append(kept, p)
+					}
+				}
+			}
+			if r2 == r {
+				r2 = r.Clone(r.Context())
+			}
+			r2.Header.Del("Sec-WebSocket-Protocol")
+			if len(kept) > 0 {
+				r2.Header.Set("Sec-WebSocket-Protocol", strings.Join(kept, ", "))
+			}
+		}
+	}
+
+	// Ein Authorization-Header hat Vorrang
+	if token != "" && r2.Header.Get("Authorization") == "" {
+		if r2 == r {
+			r2 = r.Clone(r.Context())
+		}
+		r2.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r2
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// opaqueWriter verdeckt den Writer des Servers wie eine Middleware ohne Unwrap
+type opaqueWriter struct {
+	w http.ResponseWriter
+}
+
+func (o opaqueWriter) Header() http.Header         { return o.w.Header() }
+func (o opaqueWriter) Write(b []byte) (int, error) { return o.w.Write(b) }
+func (o opaqueWriter) WriteHeader(status int)      { o.w.WriteHeader(status) }
+func (o opaqueWriter) Flush()                      { o.w.(http.Flusher).Flush() }
+
+func TestStreamOutlivesServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		controller bool // Server hinterlegt den ResponseController der Verbindung
+		wantBody   bool
+	}{
+		{"mit Controller der Verbindung", true, true},
+		{"Writer ohne Unwrap", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := Middleware("test:timeouts:"+tt.name, Settings{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 3; i++ {
+					time.Sleep(150 * time.Millisecond)
+					io.WriteString(w, "data: ping\n\n")
+					w.(http.Flusher).Flush()
+				}
+			}))
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.controller {
+					r = WithConnController(w, r)
+				}
+				events.ServeHTTP(opaqueWriter{w}, r)
+			}))
+			srv.Config.ReadTimeout = 100 * time.Millisecond
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set("Accept", "text/event-stream")
+			resp, err := http.DefaultClient.Do(req)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			complete := err == nil && strings.Count(string(body), "ping") == 3
+			if complete != tt.wantBody {
+				t.Fatalf("Stream vollständig: %v, erwartet %v (body=%q, err=%v)", complete, tt.wantBody, body, err)
+			}
+		})
+	}
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	var got *http.Request
+	h := TokenMiddleware(Settings{TokenQueryParam: "access_token", TokenSubprotocol: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/ws?access_token=abc&x=1", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "chat, bearer.def")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if auth := got.Header.Get("Authorization"); auth != "Bearer abc" {
+		t.Fatalf("Authorization=%q, erwartet das Token aus dem Query-Parameter", auth)
+	}
+	if got.URL.RawQuery != "x=1" || got.RequestURI != "/ws?x=1" {
+		t.Fatalf("Token nicht aus der URL entfernt: %q / %q", got.URL.RawQuery, got.RequestURI)
+	}
+	if p := got.Header.Get("Sec-WebSocket-Protocol"); p != "chat" {
+		t.Fatalf("Sec-WebSocket-Protocol=%q, erwartet chat", p)
+	}
+}
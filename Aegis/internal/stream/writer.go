@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// streamWriter merkt sich den letzten Datenverkehr eines Streams. Nach einem
+// Protokoll-Upgrade (WebSocket) zählt der Verkehr auf der übernommenen Verbindung.
+type streamWriter struct {
+	http.ResponseWriter
+	last atomic.Int64 // UnixNano des letzten Datenverkehrs
+}
+
+func (sw *streamWriter) touch() {
+	sw.last.Store(time.Now().UnixNano())
+}
+
+func (sw *streamWriter) lastActivity() time.Time {
+	return time.Unix(0, sw.last.Load())
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	sw.touch()
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *streamWriter) Flush() {
+	sw.touch()
+	http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+// Hijack übergibt die Verbindung (z.B. an den Reverse Proxy für WebSockets)
+func (sw *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(sw.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sw.touch()
+	return &activityConn{Conn: conn, sw: sw}, brw, nil
+}
+
+func (sw *streamWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// activityConn meldet jeden Lese- und Schreibvorgang als Datenverkehr
+type activityConn struct {
+	net.Conn
+	sw *streamWriter
+}
+
+func (c *activityConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.sw.touch()
+	}
+	return n, err
+}
+
+func (c *activityConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.sw.touch()
+	}
+	return n, err
+}
@@ -0,0 +1,89 @@
+package transform
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// RewritePathMiddleware schreibt den Pfad des Clients um, bevor er an den Upstream
+// geht. Ohne Treffer bleibt der Pfad unverändert.
+func RewritePathMiddleware(rewrite *PathRewrite) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rewrite.Pattern.MatchString(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			path := rewrite.Pattern.ReplaceAllString(r.URL.Path, rewrite.Replacement)
+			if path == "" || path[0] != '/' {
+				path = "/" + path
+			}
+			slog.DebugContext(r.Context(), "Pfad umgeschrieben", "from", r.URL.Path, "to", path)
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = path
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// Middleware wendet Header- und Query-Regeln auf Request und Response an
+func Middleware(rules *Rules) func(http.Handler) http.Handler {
+	if rules.Empty() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r2 := r.Clone(r.Context())
+			rules.RequestHeaders.apply(r2.Header)
+			if len(rules.Query) > 0 {
+				query := r2.URL.Query()
+				for name, value := range rules.Query {
+					query.Set(name, value)
+				}
+				r2.URL.RawQuery = query.Encode()
+			}
+
+			if rules.ResponseHeaders.empty() {
+				next.ServeHTTP(w, r2)
+				return
+			}
+			next.ServeHTTP(&responseWriter{ResponseWriter: w, rules: rules.ResponseHeaders}, r2)
+		})
+	}
+}
+
+// responseWriter ändert die Response-Header, bevor sie geschrieben werden
+type responseWriter struct {
+	http.ResponseWriter
+	rules   HeaderRules
+	written bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.written && status >= 200 {
+		rw.written = true
+		rw.rules.apply(rw.ResponseWriter.Header())
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.written {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *responseWriter) Flush() {
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
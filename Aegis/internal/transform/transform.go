@@ -0,0 +1,106 @@
+package transform
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"slices"
+)
+
+// Header, die nicht per Regel geändert werden dürfen: Host setzt der Proxy aus dem
+// Ziel, Hop-by-Hop-Header gehören zur Verbindung und nicht zum Request
+var protectedHeaders = []string{
+	"Host", "Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Content-Length",
+}
+
+// HeaderRules ändert Header in der Reihenfolge Remove, Set, Add
+type HeaderRules struct {
+	Add    map[string]string
+	Set    map[string]string
+	Remove []string
+}
+
+func (h HeaderRules) empty() bool {
+	return len(h.Add) == 0 && len(h.Set) == 0 && len(h.Remove) == 0
+}
+
+func (h HeaderRules) apply(header http.Header) {
+	for _, name := range h.Remove {
+		header.Del(name)
+	}
+	for name, value := range h.Set {
+		header.Set(name, value)
+	}
+	for name, value := range h.Add {
+		header.Add(name, value)
+	}
+}
+
+func (h HeaderRules) validate(kind string) error {
+	names := slices.Clone(h.Remove)
+	for name := range h.Set {
+		names = append(names, name)
+	}
+	for name := range h.Add {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if name == "" || !validHeaderName(name) {
+			return fmt.Errorf("ungültiger %s-Header '%s'", kind, name)
+		}
+		if slices.Contains(protectedHeaders, canonical) {
+			return fmt.Errorf("%s-Header '%s' kann nicht per Regel geändert werden", kind, name)
+		}
+	}
+	return nil
+}
+
+func validHeaderName(name string) bool {
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// PathRewrite ersetzt den Pfad des Clients per regulärem Ausdruck
+// (Ersetzung mit $1, ${name} wie bei regexp.ReplaceAllString)
+type PathRewrite struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Rules sind die Transformationen einer Route
+type Rules struct {
+	PathRewrite     *PathRewrite
+	RequestHeaders  HeaderRules
+	ResponseHeaders HeaderRules
+	Query           map[string]string // werden gesetzt (überschreiben Werte des Clients)
+}
+
+// NewPathRewrite kompiliert eine Pfad-Regel
+func NewPathRewrite(pattern, replacement string) (*PathRewrite, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiges Pfad-Muster '%s': %w", pattern, err)
+	}
+	return &PathRewrite{Pattern: re, Replacement: replacement}, nil
+}
+
+// Validate prüft die Header-Regeln
+func (r *Rules) Validate() error {
+	if err := r.RequestHeaders.validate("Request"); err != nil {
+		return err
+	}
+	return r.ResponseHeaders.validate("Response")
+}
+
+// Empty meldet, ob keine Header- oder Query-Regel gesetzt ist (der Pfad wird
+// getrennt vor dem Entfernen des Routen-Präfixes umgeschrieben)
+func (r *Rules) Empty() bool {
+	return r == nil || (r.RequestHeaders.empty() && r.ResponseHeaders.empty() && len(r.Query) == 0)
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
+	"gatekeeper/internal/auth"
 	"gatekeeper/internal/config"
 	"gatekeeper/internal/server" // Importiert server
 	"gatekeeper/internal/telementry"
@@ -44,15 +47,63 @@ func main() {
 	athenaAPIURL := os.Getenv("ATHENA_CONFIG_URL")
 	athenaAPISecret := os.Getenv("ATHENA_INTERNAL_SECRET")
 	athenaContextMapURL := os.Getenv("ATHENA_CONTEXT_MAP_URL")
+	athenaWatchURL := os.Getenv("ATHENA_CONFIG_WATCH_URL")
+	if athenaWatchURL == "" {
+		athenaWatchURL = config.WatchURLFromConfigURL(athenaAPIURL)
+	}
+	athenaStatusURL := os.Getenv("ATHENA_ROUTE_STATUS_URL")
+	if athenaStatusURL == "" {
+		athenaStatusURL = config.StatusURLFromConfigURL(athenaAPIURL)
+	}
 
+	initialState := config.SyncState{Source: config.SourceAthena, LastSync: time.Now()}
 	cfg, err := config.LoadConfigFromAPI(athenaAPIURL, athenaContextMapURL, athenaAPISecret)
+	if errors.Is(err, config.ErrContextMapURLMissing) {
+		log.Fatalf("FATAL: %v", err)
+	}
 	if err != nil {
-		log.Fatalf("Fehler beim Laden der initialen Konfiguration von Athena: %v", err)
+		// Athena (oder MySQL) nicht erreichbar: mit dem letzten funktionierenden Stand starten
+		snapshotPath := config.SnapshotPathFromEnv()
+		snapshotCfg, savedAt, snapErr := config.LoadSnapshot(snapshotPath)
+		if snapErr != nil {
+			log.Fatalf("Fehler beim Laden der initialen Konfiguration von Athena: %v (Snapshot nicht nutzbar: %v)", err, snapErr)
+		}
+		slog.Warn("Athena nicht erreichbar. Starte im Degraded Mode aus dem Config-Snapshot.",
+			"error", err, "snapshot", snapshotPath, "saved_at", savedAt, "version", snapshotCfg.Version)
+		cfg = snapshotCfg
+		initialState = config.SyncState{
+			Source:          config.SourceSnapshot,
+			Degraded:        true,
+			LastError:       err.Error(),
+			SnapshotSavedAt: savedAt,
+		}
 	}
 
-	publicKey, err := loadPublicKey(cfg.JwtPublicKeyPath)
-	if err != nil {
-		log.Fatalf("Fehler beim Laden des JWT Public Key: %v", err)
+	// Auch ein Snapshot mit Projekt-Routen braucht die Context Map, sonst schlägt jeder Sync fehl
+	if athenaContextMapURL == "" && cfg.HasProjectRoutes() {
+		log.Fatalf("FATAL: %v", config.ErrContextMapURLMissing)
+	}
+
+	// JWT-Schlüssel: bevorzugt JWKS von Athena (Rotation ohne Downtime), sonst PEM-Datei
+	var keys auth.KeyProvider
+	if cfg.JwksURL != "" {
+		jwksProvider := auth.NewJWKSProvider(cfg.JwksURL, 5*time.Minute)
+		// Im Degraded Mode: Schlüssel aus dem Snapshot, bis Athena wieder erreichbar ist
+		if len(cfg.JWKS) > 0 {
+			if err := jwksProvider.Preload(cfg.JWKS); err != nil {
+				slog.Warn("JWKS aus dem Config-Snapshot ist unbrauchbar", "error", err)
+			}
+		}
+		if err := jwksProvider.Refresh(context.Background()); err != nil {
+			slog.Warn("JWKS konnte initial nicht geladen werden, neuer Versuch bei der ersten Anfrage", "url", cfg.JwksURL, "error", err)
+		}
+		keys = jwksProvider
+	} else {
+		publicKey, err := loadPublicKey(cfg.JwtPublicKeyPath)
+		if err != nil {
+			log.Fatalf("Fehler beim Laden des JWT Public Key: %v", err)
+		}
+		keys = &auth.StaticKeyProvider{Key: publicKey}
 	}
 
 	// 3. Telemetrie
@@ -66,11 +117,15 @@ func main() {
 	// 4. Abhängigkeiten bündeln
 	deps := &server.Dependencies{
 		Config:           cfg,
-		PublicKey:        publicKey,
+		Keys:             keys,
 		TracerShutdown:   tpShutdown,
 		AthenaAPIURL:     athenaAPIURL,
 		AthenaAPISecret:  athenaAPISecret,
 		AthenaContextMapURL: athenaContextMapURL,
+		AthenaWatchURL:   athenaWatchURL,
+		InitialState:     initialState,
+		AthenaStatusURL:  athenaStatusURL,
+		InstanceID:       config.InstanceID(),
 	}
 
 	// 5. Server erstellen
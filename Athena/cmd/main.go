@@ -3,10 +3,12 @@ package main
 import (
 	"athena/internal/auth"
 	"athena/internal/config"
+	"athena/internal/configsync"
 	"athena/internal/database"
 	"athena/internal/handlers"
 	"athena/internal/router"
 	"athena/internal/server"
+	"context"
 	"log/slog"
 	"os"
 
@@ -37,8 +39,6 @@ func main() {
 	}
 	
 	// Verwende die Secrets
-	privateKey := athenaSecrets.PrivateKey
-	publicKey := athenaSecrets.PublicKey
 	databaseURL := athenaSecrets.DatabaseURL
 	cfg.RegistrationSecret = athenaSecrets.RegistrationSecret
 
@@ -56,6 +56,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Signaturschlüssel: Keyring aus Vault (Rotation) oder statisches Schlüsselpaar
+	keyCtx, stopKeys := context.WithCancel(context.Background())
+	defer stopKeys()
+
+	keys, err := auth.NewKeyManager(keyCtx, cfg, athenaSecrets.PrivateKey)
+	if err != nil {
+		slog.Error("Fehler beim Laden der Signaturschlüssel", slog.Any("error", err))
+		os.Exit(1)
+	}
+	keys.Start(keyCtx, cfg.JWTKeyPollInterval)
+
 	// 3. Handler & Repositories
 	userRepo := database.NewUserRepository(db)
 	projectRepo := database.NewProjectRepository(db)
@@ -66,19 +77,27 @@ func main() {
 		userRepo,
 		tokenRepo,
 		cfg.OTPIssuerName,
-		privateKey,
+		keys,
 		cfg.JWTAccessTokenTTL,
 		cfg.JWTRefreshTokenTTL,
 	)
 
+	// Ein Versions-Poller pro Prozess weckt die Aegis-Watcher auch bei Änderungen,
+	// die auf anderen Athena-Instanzen gespeichert wurden
+	notifier := configsync.NewNotifier()
+	pollCtx, stopPoll := context.WithCancel(context.Background())
+	defer stopPoll()
+	go notifier.Poll(pollCtx, configsync.PollInterval, handlers.NewInternalHandlers(routeRepo, projectRepo, notifier).ConfigVersion)
+
 	handlers := router.HandlerDependencies{
 		UserRepo:    userRepo,
 		ProjectRepo: projectRepo,
 		RouteRepo:   routeRepo,
 		TokenRepo:   tokenRepo,
 		DBPinger:    db,
-		PrivateKey:  privateKey,
-		PublicKey:   publicKey,
+		Keys:        keys,
+		Notifier:    notifier,
+		CachePurger: configsync.NewCachePurger(cfg.AegisCachePurgeURL, os.Getenv("ATHENA_INTERNAL_SECRET")),
 		Config:      cfg,
 		AdminHandlers: adminHandlers,
 	}
@@ -8,5 +8,8 @@ type CustomClaims struct {
 	UserID string   `json:"user_id"`
 	Roles  []string `json:"roles"`
 	IsAdmin bool    `json:"is_admin"`
+	Plan    string  `json:"plan,omitempty"` // Plan im Projekt (Rate-Limit-Tiers in Aegis)
+	// Projekt, für das Rollen und Plan gelten (leer = globaler Login)
+	ProjectID string `json:"project_id,omitempty"`
 	jwt.RegisteredClaims
 }
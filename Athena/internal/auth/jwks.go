@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK ist ein öffentlicher RSA-Schlüssel im JSON Web Key Format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS ist das Dokument unter /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyID berechnet den JWK Thumbprint (RFC 7638) eines öffentlichen Schlüssels.
+// Die ID ist damit stabil und ohne zusätzliche Metadaten reproduzierbar.
+func KeyID(publicKey *rsa.PublicKey) string {
+	n := base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+
+	// Kanonische Form: Pflichtfelder in lexikografischer Reihenfolge, ohne Leerzeichen
+	canonical := fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, e, n)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewJWK wandelt einen öffentlichen Schlüssel in einen JWK um
+func NewJWK(publicKey *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: KeyID(publicKey),
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// NewJWKS erstellt ein JWKS-Dokument aus allen aktiven öffentlichen Schlüsseln
+func NewJWKS(publicKeys ...*rsa.PublicKey) JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(publicKeys))}
+	for _, key := range publicKeys {
+		if key == nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, NewJWK(key))
+	}
+	return jwks
+}
@@ -2,7 +2,6 @@ package auth
 
 import (
 	"athena/internal/models"
-	"crypto/rsa"
 	"fmt"
 	"log/slog"
 	"time"
@@ -11,12 +10,19 @@ import (
 	"github.com/google/uuid"
 )
 
-func GenerateToken(user *models.User, roles []string, privateKey *rsa.PrivateKey, ttl time.Duration) (string, error) {
+// GenerateToken erstellt ein Access Token. Rollen und Plan gelten nur im Projekt
+// projectID (leer = globaler Login ohne Projekt).
+func GenerateToken(user *models.User, roles []string, plan string, projectID string, signer Signer, ttl time.Duration) (string, error) {
 	if user == nil {
 		return "", fmt.Errorf("benutzer darf nicht nil sein")
 	}
-	if privateKey == nil {
-		return "", fmt.Errorf("privater Schlüssel darf nicht nil sein")
+	if signer == nil {
+		return "", fmt.Errorf("signer darf nicht nil sein")
+	}
+	// Aktiven Schlüssel pro Token holen, damit eine Rotation sofort greift
+	privateKey, err := signer.SigningKey()
+	if err != nil {
+		return "", fmt.Errorf("kein signaturschlüssel verfügbar: %w", err)
 	}
 
 	now := time.Now().UTC()
@@ -26,6 +32,8 @@ func GenerateToken(user *models.User, roles []string, privateKey *rsa.PrivateKey
 		UserID:  user.ID.String(),
 		Roles:   roles,
 		IsAdmin: user.IsAdmin,
+		Plan:    plan,
+		ProjectID: projectID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   user.ID.String(),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -35,6 +43,8 @@ func GenerateToken(user *models.User, roles []string, privateKey *rsa.PrivateKey
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	// Key-ID setzen, damit Aegis den passenden Schlüssel aus dem JWKS wählen kann
+	token.Header["kid"] = KeyID(&privateKey.PublicKey)
 
 	signedToken, err := token.SignedString(privateKey)
 	if err != nil {
@@ -0,0 +1,619 @@
+package auth
+
+import (
+	"athena/internal/config"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hashicorp/vault/api"
+)
+
+// Lebenszyklus eines Signaturschlüssels:
+// published (nur Verifikation) -> active (signiert) -> retiring (nur Verifikation) -> retired
+const (
+	KeyStatePublished = "published"
+	KeyStateActive    = "active"
+	KeyStateRetiring  = "retiring"
+	KeyStateRetired   = "retired"
+)
+
+var (
+	ErrRotationDisabled   = errors.New("schlüsselrotation ist deaktiviert (VAULT_JWT_KEYRING_PATH nicht gesetzt)")
+	ErrKeyNotFound        = errors.New("schlüssel nicht gefunden")
+	ErrInvalidKeyState    = errors.New("schlüssel ist im falschen zustand für diese aktion")
+	ErrRotationInProgress = errors.New("es ist bereits ein veröffentlichter, noch nicht aktiver schlüssel vorhanden")
+	ErrKeyNotPropagated   = errors.New("schlüssel ist noch nicht lange genug veröffentlicht")
+	ErrKeyStillInUse      = errors.New("mit diesem schlüssel signierte tokens sind noch gültig")
+	ErrConcurrentUpdate   = errors.New("keyring wurde parallel geändert, bitte erneut versuchen")
+	ErrNoActiveKey        = errors.New("kein aktiver signaturschlüssel vorhanden")
+)
+
+// Signer liefert den aktuell aktiven Signaturschlüssel
+type Signer interface {
+	SigningKey() (*rsa.PrivateKey, error)
+}
+
+// KeyResolver liefert den Verifikationsschlüssel zu einer Key-ID
+type KeyResolver interface {
+	PublicKey(kid string) (*rsa.PublicKey, error)
+}
+
+// ManagedKey ist ein Eintrag im Keyring (so auch in Vault gespeichert)
+type ManagedKey struct {
+	Kid           string    `json:"kid"`
+	State         string    `json:"state"`
+	PrivateKeyPEM string    `json:"private_key,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	PublishedAt   time.Time `json:"published_at,omitzero"`
+	ActivatedAt   time.Time `json:"activated_at,omitzero"`
+	DeactivatedAt time.Time `json:"deactivated_at,omitzero"`
+	RetiredAt     time.Time `json:"retired_at,omitzero"`
+
+	privateKey *rsa.PrivateKey
+}
+
+// KeyStatus ist die Sicht auf einen Schlüssel für die Admin-API (ohne Private Key)
+type KeyStatus struct {
+	Kid           string    `json:"kid"`
+	State         string    `json:"state"`
+	CreatedAt     time.Time `json:"created_at"`
+	PublishedAt   time.Time `json:"published_at,omitzero"`
+	ActivatedAt   time.Time `json:"activated_at,omitzero"`
+	DeactivatedAt time.Time `json:"deactivated_at,omitzero"`
+	RetiredAt     time.Time `json:"retired_at,omitzero"`
+	ActivatableAt time.Time `json:"activatable_at,omitzero"` // Frühester Aktivierungszeitpunkt (published)
+	RetirableAt   time.Time `json:"retirable_at,omitzero"`   // Frühester Stilllegungszeitpunkt (retiring)
+}
+
+// RotationStatus ist der aktuelle Rotationszustand (GET /admin/keys)
+type RotationStatus struct {
+	Mode         string      `json:"mode"` // "vault" oder "static"
+	VaultVersion int         `json:"vault_version,omitempty"`
+	ActiveKid    string      `json:"active_kid"`
+	LastSync     time.Time   `json:"last_sync,omitzero"`
+	PublishDelay string      `json:"publish_delay"`
+	MaxTokenTTL  string      `json:"max_token_ttl"`
+	Keys         []KeyStatus `json:"keys"`
+}
+
+// KeyManager verwaltet die versionierten Signaturschlüssel. Im Vault-Modus
+// wird der Keyring regelmäßig neu gelesen, sodass Rotationen ohne Neustart greifen.
+type KeyManager struct {
+	cfg          *config.Config
+	vault        atomic.Pointer[api.Client] // nil = statischer Schlüssel; nach erneutem Login ersetzt
+	path         string
+	publishDelay time.Duration
+	maxTokenTTL  time.Duration
+
+	mu       sync.RWMutex
+	keys     []*ManagedKey
+	version  int
+	lastSync time.Time
+
+	writeMu sync.Mutex // Serialisiert Rotationsschritte dieser Instanz
+}
+
+// NewStaticKeyManager nutzt ein festes Schlüsselpaar ohne Rotation
+func NewStaticKeyManager(privateKey *rsa.PrivateKey) *KeyManager {
+	now := time.Now().UTC()
+	return &KeyManager{
+		keys: []*ManagedKey{{
+			Kid:         KeyID(&privateKey.PublicKey),
+			State:       KeyStateActive,
+			CreatedAt:   now,
+			ActivatedAt: now,
+			privateKey:  privateKey,
+		}},
+	}
+}
+
+// NewKeyManager erstellt den KeyManager. Ohne VAULT_JWT_KEYRING_PATH wird das
+// bisherige Schlüsselpaar statisch verwendet. Ist der Keyring in Vault leer,
+// wird er mit dem bisherigen Paar als aktivem Schlüssel initialisiert.
+func NewKeyManager(ctx context.Context, cfg *config.Config, legacyKey *rsa.PrivateKey) (*KeyManager, error) {
+	if cfg.VaultJWTKeyringPath == "" {
+		slog.Info("VAULT_JWT_KEYRING_PATH nicht gesetzt. Signaturschlüssel wird statisch verwendet (keine Rotation).")
+		return NewStaticKeyManager(legacyKey), nil
+	}
+
+	client, err := createVaultClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTTL := cfg.JWTAccessTokenTTL
+	if cfg.JWTGraceTokenTTL > maxTTL {
+		maxTTL = cfg.JWTGraceTokenTTL
+	}
+
+	m := &KeyManager{
+		cfg:          cfg,
+		path:         cfg.VaultJWTKeyringPath,
+		publishDelay: cfg.JWTKeyPublishDelay,
+		maxTokenTTL:  maxTTL + time.Minute, // Puffer für Clock Skew / Leeway
+	}
+	m.vault.Store(client)
+
+	if err := m.Sync(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	empty := len(m.keys) == 0
+	m.mu.RUnlock()
+
+	if empty {
+		if legacyKey == nil {
+			return nil, fmt.Errorf("keyring unter %s ist leer und es gibt kein bisheriges schlüsselpaar", m.path)
+		}
+		slog.Info("Keyring in Vault ist leer. Initialisiere mit bisherigem Schlüsselpaar.", slog.String("path", m.path))
+		err := m.mutate(ctx, func(keys []*ManagedKey) ([]*ManagedKey, error) {
+			now := time.Now().UTC()
+			return append(keys, &ManagedKey{
+				Kid:           KeyID(&legacyKey.PublicKey),
+				State:         KeyStateActive,
+				PrivateKeyPEM: encodePrivateKeyPEM(legacyKey),
+				CreatedAt:     now,
+				PublishedAt:   now,
+				ActivatedAt:   now,
+				privateKey:    legacyKey,
+			}), nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fehler beim Initialisieren des Keyrings: %w", err)
+		}
+	}
+
+	if _, err := m.SigningKey(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Start liest den Keyring periodisch neu und legt abgelaufene Schlüssel still
+func (m *KeyManager) Start(ctx context.Context, interval time.Duration) {
+	if m.vault.Load() == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		slog.Info("Keyring Poller gestartet", slog.String("path", m.path), slog.Duration("interval", interval))
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := m.Sync(ctx); err != nil {
+				slog.Warn("Keyring Poller: Fehler beim Lesen aus Vault (alter Stand bleibt aktiv)", slog.Any("error", err))
+				continue
+			}
+			if err := m.retireExpired(ctx); err != nil && !errors.Is(err, ErrConcurrentUpdate) {
+				slog.Warn("Keyring Poller: Fehler beim Stilllegen abgelaufener Schlüssel", slog.Any("error", err))
+			}
+		}
+	}()
+}
+
+// SigningKey implementiert Signer
+func (m *KeyManager) SigningKey() (*rsa.PrivateKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if k.State == KeyStateActive && k.privateKey != nil {
+			return k.privateKey, nil
+		}
+	}
+	return nil, ErrNoActiveKey
+}
+
+// PublicKey implementiert KeyResolver. Tokens ohne kid (vor Einführung
+// der Key-IDs ausgestellt) werden mit dem aktiven Schlüssel geprüft.
+func (m *KeyManager) PublicKey(kid string) (*rsa.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if k.privateKey == nil || k.State == KeyStateRetired {
+			continue
+		}
+		if (kid == "" && k.State == KeyStateActive) || k.Kid == kid {
+			return &k.privateKey.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// VerificationKeys liefert alle Schlüssel, die im JWKS veröffentlicht werden
+func (m *KeyManager) VerificationKeys() []*rsa.PublicKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*rsa.PublicKey, 0, len(m.keys))
+	for _, k := range m.keys {
+		if k.State == KeyStateRetired || k.privateKey == nil {
+			continue
+		}
+		keys = append(keys, &k.privateKey.PublicKey)
+	}
+	return keys
+}
+
+// Status liefert den Rotationszustand für die Admin-API
+func (m *KeyManager) Status() RotationStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := RotationStatus{
+		Mode:         "static",
+		VaultVersion: m.version,
+		LastSync:     m.lastSync,
+		PublishDelay: m.publishDelay.String(),
+		MaxTokenTTL:  m.maxTokenTTL.String(),
+		Keys:         make([]KeyStatus, 0, len(m.keys)),
+	}
+	if m.vault.Load() != nil {
+		status.Mode = "vault"
+	}
+
+	for _, k := range m.keys {
+		ks := KeyStatus{
+			Kid:           k.Kid,
+			State:         k.State,
+			CreatedAt:     k.CreatedAt,
+			PublishedAt:   k.PublishedAt,
+			ActivatedAt:   k.ActivatedAt,
+			DeactivatedAt: k.DeactivatedAt,
+			RetiredAt:     k.RetiredAt,
+		}
+		switch k.State {
+		case KeyStateActive:
+			status.ActiveKid = k.Kid
+		case KeyStatePublished:
+			ks.ActivatableAt = k.PublishedAt.Add(m.publishDelay)
+		case KeyStateRetiring:
+			ks.RetirableAt = k.DeactivatedAt.Add(m.maxTokenTTL)
+		}
+		status.Keys = append(status.Keys, ks)
+	}
+	return status
+}
+
+// Publish erzeugt einen neuen Schlüssel, der zunächst nur zur Verifikation
+// veröffentlicht wird (Schritt 1 der Rotation)
+func (m *KeyManager) Publish(ctx context.Context) (*KeyStatus, error) {
+	if m.vault.Load() == nil {
+		return nil, ErrRotationDisabled
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Generieren des RSA-Schlüssels: %w", err)
+	}
+	kid := KeyID(&privateKey.PublicKey)
+
+	err = m.mutate(ctx, func(keys []*ManagedKey) ([]*ManagedKey, error) {
+		for _, k := range keys {
+			if k.State == KeyStatePublished {
+				return nil, ErrRotationInProgress
+			}
+		}
+		now := time.Now().UTC()
+		return append(keys, &ManagedKey{
+			Kid:           kid,
+			State:         KeyStatePublished,
+			PrivateKeyPEM: encodePrivateKeyPEM(privateKey),
+			CreatedAt:     now,
+			PublishedAt:   now,
+			privateKey:    privateKey,
+		}), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.keyStatus(kid)
+}
+
+// Activate macht einen veröffentlichten Schlüssel zum Signaturschlüssel
+// (Schritt 2). Der bisherige aktive Schlüssel wechselt auf "retiring".
+func (m *KeyManager) Activate(ctx context.Context, kid string) (*KeyStatus, error) {
+	if m.vault.Load() == nil {
+		return nil, ErrRotationDisabled
+	}
+
+	err := m.mutate(ctx, func(keys []*ManagedKey) ([]*ManagedKey, error) {
+		target := findKey(keys, kid)
+		if target == nil {
+			return nil, ErrKeyNotFound
+		}
+		if target.State != KeyStatePublished {
+			return nil, ErrInvalidKeyState
+		}
+		now := time.Now().UTC()
+		if now.Before(target.PublishedAt.Add(m.publishDelay)) {
+			return nil, fmt.Errorf("%w (frühestens ab %s)", ErrKeyNotPropagated, target.PublishedAt.Add(m.publishDelay).Format(time.RFC3339))
+		}
+
+		for _, k := range keys {
+			if k.State == KeyStateActive {
+				k.State = KeyStateRetiring
+				k.DeactivatedAt = now
+			}
+		}
+		target.State = KeyStateActive
+		target.ActivatedAt = now
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.keyStatus(kid)
+}
+
+// Retire legt einen Schlüssel still (Schritt 3). Ein "retiring"-Schlüssel erst,
+// wenn alle damit signierten Tokens abgelaufen sind; ein nie aktiver
+// "published"-Schlüssel sofort (Rotation abbrechen).
+func (m *KeyManager) Retire(ctx context.Context, kid string) (*KeyStatus, error) {
+	if m.vault.Load() == nil {
+		return nil, ErrRotationDisabled
+	}
+
+	err := m.mutate(ctx, func(keys []*ManagedKey) ([]*ManagedKey, error) {
+		target := findKey(keys, kid)
+		if target == nil {
+			return nil, ErrKeyNotFound
+		}
+		now := time.Now().UTC()
+		switch target.State {
+		case KeyStatePublished:
+		case KeyStateRetiring:
+			if now.Before(target.DeactivatedAt.Add(m.maxTokenTTL)) {
+				return nil, fmt.Errorf("%w (frühestens ab %s)", ErrKeyStillInUse, target.DeactivatedAt.Add(m.maxTokenTTL).Format(time.RFC3339))
+			}
+		default:
+			return nil, ErrInvalidKeyState
+		}
+		retire(target, now)
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.keyStatus(kid)
+}
+
+// retireExpired legt automatisch alle Schlüssel still, deren Tokens abgelaufen sind
+func (m *KeyManager) retireExpired(ctx context.Context) error {
+	m.mu.RLock()
+	due := false
+	now := time.Now().UTC()
+	for _, k := range m.keys {
+		if k.State == KeyStateRetiring && !now.Before(k.DeactivatedAt.Add(m.maxTokenTTL)) {
+			due = true
+		}
+	}
+	m.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	return m.mutate(ctx, func(keys []*ManagedKey) ([]*ManagedKey, error) {
+		for _, k := range keys {
+			if k.State == KeyStateRetiring && !now.Before(k.DeactivatedAt.Add(m.maxTokenTTL)) {
+				retire(k, now)
+				slog.Info("Signaturschlüssel automatisch stillgelegt", slog.String("kid", k.Kid))
+			}
+		}
+		return keys, nil
+	})
+}
+
+// Sync liest den Keyring aus Vault (KV v2)
+func (m *KeyManager) Sync(ctx context.Context) error {
+	if m.vault.Load() == nil {
+		return nil
+	}
+
+	keys, version, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	changed := version != m.version
+	m.keys = keys
+	m.version = version
+	m.lastSync = time.Now().UTC()
+	m.mu.Unlock()
+
+	if changed {
+		slog.Info("Keyring aus Vault geladen", slog.Int("version", version), slog.Int("keys", len(keys)))
+	}
+	return nil
+}
+
+// mutate führt einen Rotationsschritt aus: frisch lesen, ändern und per
+// Check-and-Set zurückschreiben, damit parallele Athena-Instanzen sich nicht überschreiben
+func (m *KeyManager) mutate(ctx context.Context, fn func(keys []*ManagedKey) ([]*ManagedKey, error)) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	keys, version, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err = fn(keys)
+	if err != nil {
+		return err
+	}
+
+	newVersion, err := m.write(ctx, keys, version)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.version = newVersion
+	m.lastSync = time.Now().UTC()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *KeyManager) read(ctx context.Context) ([]*ManagedKey, int, error) {
+	var secret *api.Secret
+	err := m.withVault(func(client *api.Client) error {
+		var err error
+		secret, err = client.Logical().ReadWithContext(ctx, m.path)
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("fehler beim Lesen des Keyrings von Vault %s: %w", m.path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return []*ManagedKey{}, 0, nil // Noch nicht angelegt
+	}
+
+	version := 0
+	if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		switch v := metadata["version"].(type) {
+		case json.Number:
+			n, _ := v.Int64()
+			version = int(n)
+		case float64:
+			version = int(v)
+		}
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok || data == nil {
+		return []*ManagedKey{}, version, nil // z.B. gelöschte Version
+	}
+
+	raw, err := json.Marshal(data["keys"])
+	if err != nil {
+		return nil, 0, fmt.Errorf("fehler beim Lesen des Keyrings: %w", err)
+	}
+	var keys []*ManagedKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, 0, fmt.Errorf("keyring unter %s hat ein ungültiges format: %w", m.path, err)
+	}
+
+	for _, k := range keys {
+		if k.PrivateKeyPEM == "" {
+			continue // Stillgelegte Schlüssel haben keinen Private Key mehr
+		}
+		k.privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(k.PrivateKeyPEM))
+		if err != nil {
+			return nil, 0, fmt.Errorf("fehler beim Parsen des Schlüssels %s: %w", k.Kid, err)
+		}
+	}
+	return keys, version, nil
+}
+
+func (m *KeyManager) write(ctx context.Context, keys []*ManagedKey, version int) (int, error) {
+	payload := map[string]interface{}{
+		"options": map[string]interface{}{"cas": version},
+		"data":    map[string]interface{}{"keys": keys},
+	}
+
+	var secret *api.Secret
+	err := m.withVault(func(client *api.Client) error {
+		var err error
+		secret, err = client.Logical().WriteWithContext(ctx, m.path, payload)
+		return err
+	})
+	if err != nil {
+		var respErr *api.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == 400 {
+			return 0, ErrConcurrentUpdate // check-and-set Parameter passt nicht
+		}
+		return 0, fmt.Errorf("fehler beim Schreiben des Keyrings nach Vault %s: %w", m.path, err)
+	}
+
+	newVersion := version + 1
+	if secret != nil && secret.Data != nil {
+		switch v := secret.Data["version"].(type) {
+		case json.Number:
+			n, _ := v.Int64()
+			newVersion = int(n)
+		case float64:
+			newVersion = int(v)
+		}
+	}
+	return newVersion, nil
+}
+
+// withVault führt fn aus und meldet sich bei einem Fehler einmal neu an
+// (der AppRole-Token ist kurzlebig)
+func (m *KeyManager) withVault(fn func(client *api.Client) error) error {
+	err := fn(m.vault.Load())
+	if err == nil {
+		return nil
+	}
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != 403 {
+		return err
+	}
+
+	slog.Info("Vault-Token abgelaufen oder ungültig. Führe erneuten AppRole-Login aus.")
+	client, loginErr := createVaultClient(m.cfg)
+	if loginErr != nil {
+		return fmt.Errorf("%w (erneuter Login fehlgeschlagen: %v)", err, loginErr)
+	}
+	m.vault.Store(client)
+	return fn(client)
+}
+
+func (m *KeyManager) keyStatus(kid string) (*KeyStatus, error) {
+	for _, ks := range m.Status().Keys {
+		if ks.Kid == kid {
+			return &ks, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func findKey(keys []*ManagedKey, kid string) *ManagedKey {
+	for _, k := range keys {
+		if k.Kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// retire entfernt das Schlüsselmaterial, der Eintrag bleibt für das Audit erhalten
+func retire(k *ManagedKey, now time.Time) {
+	k.State = KeyStateRetired
+	k.RetiredAt = now
+	k.PrivateKeyPEM = ""
+	k.privateKey = nil
+}
+
+func encodePrivateKeyPEM(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"athena/internal/config"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault bildet AppRole-Login und eine KV-v2-Engine mit Check-and-Set nach
+type fakeVault struct {
+	mu      sync.Mutex
+	token   string
+	logins  int
+	version int
+	data    json.RawMessage
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		v.logins++
+		v.token = fmt.Sprintf("token-%d", v.logins)
+		json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": v.token}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != v.token {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if v.version == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     v.data,
+			"metadata": map[string]any{"version": v.version},
+		}})
+	case http.MethodPut, http.MethodPost:
+		var payload struct {
+			Options struct {
+				CAS int `json:"cas"`
+			} `json:"options"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Options.CAS != v.version {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+			return
+		}
+		v.version++
+		v.data = payload.Data
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"version": v.version}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// expireToken macht den aktuellen Token ungültig (wie ein abgelaufener AppRole-Token)
+func (v *fakeVault) expireToken() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.token = "abgelaufen"
+}
+
+func testKeyManager(t *testing.T, vault *fakeVault, legacyKey *rsa.PrivateKey, publishDelay, tokenTTL time.Duration) *KeyManager {
+	t.Helper()
+	srv := httptest.NewServer(vault)
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_TOKEN", "")
+
+	cfg := &config.Config{
+		VaultAddr:            srv.URL,
+		VaultAppRoleRoleID:   "role",
+		VaultAppRoleSecretID: "secret",
+		VaultJWTKeyringPath:  "secret/data/athena/jwt-keyring",
+		JWTKeyPublishDelay:   publishDelay,
+		JWTAccessTokenTTL:    tokenTTL,
+	}
+	m, err := NewKeyManager(context.Background(), cfg, legacyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Der Puffer für Clock Skew würde sonst jede Stilllegung im Test blockieren
+	m.maxTokenTTL = tokenTTL
+	return m
+}
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signingKid(t *testing.T, m *KeyManager) string {
+	t.Helper()
+	key, err := m.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return KeyID(&key.PublicKey)
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	legacy := testRSAKey(t)
+	m := testKeyManager(t, &fakeVault{}, legacy, 0, 0)
+	legacyKid := KeyID(&legacy.PublicKey)
+
+	if got := signingKid(t, m); got != legacyKid {
+		t.Fatalf("leerer Keyring: signiert mit %s, erwartet das bisherige Schlüsselpaar", got)
+	}
+
+	// 1. Veröffentlichen: neuer Schlüssel prüft, signiert aber noch nicht
+	published, err := m.Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := signingKid(t, m); got != legacyKid {
+		t.Fatalf("nach Publish signiert %s, erwartet weiterhin %s", got, legacyKid)
+	}
+	if n := len(m.VerificationKeys()); n != 2 {
+		t.Fatalf("%d Schlüssel im JWKS, erwartet 2", n)
+	}
+	if _, err := m.Publish(ctx); !errors.Is(err, ErrRotationInProgress) {
+		t.Fatalf("zweites Publish: %v, erwartet ErrRotationInProgress", err)
+	}
+
+	// 2. Aktivieren: alter Schlüssel prüft weiter bereits ausgestellte Tokens
+	if _, err := m.Activate(ctx, published.Kid); err != nil {
+		t.Fatal(err)
+	}
+	if got := signingKid(t, m); got != published.Kid {
+		t.Fatalf("nach Activate signiert %s, erwartet %s", got, published.Kid)
+	}
+	if _, err := m.PublicKey(legacyKid); err != nil {
+		t.Fatalf("alter Schlüssel nach Activate nicht mehr prüfbar: %v", err)
+	}
+	if _, err := m.Activate(ctx, legacyKid); !errors.Is(err, ErrInvalidKeyState) {
+		t.Fatalf("Activate des alten Schlüssels: %v, erwartet ErrInvalidKeyState", err)
+	}
+
+	// 3. Stilllegen: alter Schlüssel verschwindet aus dem JWKS
+	if _, err := m.Retire(ctx, legacyKid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.PublicKey(legacyKid); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("stillgelegter Schlüssel: %v, erwartet ErrKeyNotFound", err)
+	}
+	if n := len(m.VerificationKeys()); n != 1 {
+		t.Fatalf("%d Schlüssel im JWKS, erwartet 1", n)
+	}
+}
+
+func TestKeyRotationGuards(t *testing.T) {
+	ctx := context.Background()
+	legacy := testRSAKey(t)
+	m := testKeyManager(t, &fakeVault{}, legacy, time.Hour, time.Hour)
+
+	published, err := m.Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Activate(ctx, published.Kid); !errors.Is(err, ErrKeyNotPropagated) {
+		t.Fatalf("Activate vor Ablauf des Publish Delay: %v, erwartet ErrKeyNotPropagated", err)
+	}
+
+	m.publishDelay = 0
+	if _, err := m.Activate(ctx, published.Kid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Retire(ctx, KeyID(&legacy.PublicKey)); !errors.Is(err, ErrKeyStillInUse) {
+		t.Fatalf("Retire mit noch gültigen Tokens: %v, erwartet ErrKeyStillInUse", err)
+	}
+	if _, err := m.Retire(ctx, published.Kid); !errors.Is(err, ErrInvalidKeyState) {
+		t.Fatalf("Retire des aktiven Schlüssels: %v, erwartet ErrInvalidKeyState", err)
+	}
+}
+
+func TestKeyRotationAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	vault := &fakeVault{}
+	legacy := testRSAKey(t)
+	first := testKeyManager(t, vault, legacy, 0, 0)
+	second := testKeyManager(t, vault, legacy, 0, 0)
+
+	published, err := first.Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.PublicKey(published.Kid); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("vor dem Sync: %v, erwartet ErrKeyNotFound", err)
+	}
+	if err := second.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.PublicKey(published.Kid); err != nil {
+		t.Fatalf("nach dem Sync: %v", err)
+	}
+
+	// Schreiben mit veraltetem Stand scheitert am Check-and-Set
+	if _, err := second.write(ctx, second.keys, second.version-1); !errors.Is(err, ErrConcurrentUpdate) {
+		t.Fatalf("veraltete Version: %v, erwartet ErrConcurrentUpdate", err)
+	}
+}
+
+func TestKeyManagerRelogin(t *testing.T) {
+	vault := &fakeVault{}
+	m := testKeyManager(t, vault, testRSAKey(t), 0, 0)
+
+	vault.expireToken()
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync nach abgelaufenem Token: %v", err)
+	}
+	if vault.logins != 2 {
+		t.Fatalf("%d Logins, erwartet einen erneuten Login", vault.logins)
+	}
+}
+
+func TestStaticKeyManager(t *testing.T) {
+	key := testRSAKey(t)
+	m := NewStaticKeyManager(key)
+
+	if _, err := m.Publish(context.Background()); !errors.Is(err, ErrRotationDisabled) {
+		t.Fatalf("Publish ohne Vault: %v, erwartet ErrRotationDisabled", err)
+	}
+	// Tokens ohne kid (vor den Key-IDs ausgestellt) prüft der aktive Schlüssel
+	pub, err := m.PublicKey("")
+	if err != nil || !pub.Equal(&key.PublicKey) {
+		t.Fatalf("PublicKey ohne kid: %v", err)
+	}
+}
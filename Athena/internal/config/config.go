@@ -25,7 +25,15 @@ type Config struct {
 	VaultJWTSekretPath  string
 	VaultDBCredsPath    string
 
+	// Schlüsselrotation (optional, ohne Keyring-Pfad wird statisch signiert)
+	VaultJWTKeyringPath string
+	JWTKeyPollInterval  time.Duration
+	JWTKeyPublishDelay  time.Duration
+
 	GatekeeperIPs []string
+
+	// Interne Purge-API von Aegis (leer = kein Cache-Purge bei Routen-Änderungen)
+	AegisCachePurgeURL string
 }
 
 func LoadConfig() (*Config, error) {
@@ -81,6 +89,27 @@ func LoadConfig() (*Config, error) {
 
 	cfg.VaultJWTSekretPath = os.Getenv("VAULT_JWT_SECRET_PATH")
 	cfg.VaultDBCredsPath = os.Getenv("VAULT_DB_CREDS_PATH")
+	cfg.VaultJWTKeyringPath = os.Getenv("VAULT_JWT_KEYRING_PATH")
+
+	keyPollStr := os.Getenv("JWT_KEY_POLL_INTERVAL")
+	if keyPollStr == "" {
+		keyPollStr = "30s"
+	}
+	cfg.JWTKeyPollInterval, err = time.ParseDuration(keyPollStr)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiges JWT_KEY_POLL_INTERVAL: %w", err)
+	}
+
+	// Wartezeit zwischen Veröffentlichung und Aktivierung eines neuen Schlüssels,
+	// damit alle Aegis-Instanzen das JWKS aktualisiert haben
+	keyPublishDelayStr := os.Getenv("JWT_KEY_PUBLISH_DELAY")
+	if keyPublishDelayStr == "" {
+		keyPublishDelayStr = "10m"
+	}
+	cfg.JWTKeyPublishDelay, err = time.ParseDuration(keyPublishDelayStr)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiges JWT_KEY_PUBLISH_DELAY: %w", err)
+	}
 
 	// Vault-Prüfungen
 	if cfg.VaultAddr == "" {
@@ -106,5 +135,7 @@ func LoadConfig() (*Config, error) {
 		cfg.GatekeeperIPs = strings.Split(gatekeeperIPsStr, ",")
 	}
 
+	cfg.AegisCachePurgeURL = os.Getenv("AEGIS_CACHE_PURGE_URL")
+
 	return &cfg, nil
 }
\ No newline at end of file
@@ -0,0 +1,89 @@
+package configsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrPurgeDisabled wird geliefert, wenn keine Purge-URL für Aegis konfiguriert ist
+var ErrPurgeDisabled = errors.New("cache-purge in Aegis ist nicht konfiguriert (AEGIS_CACHE_PURGE_URL)")
+
+// PurgeRequest entspricht der internen Purge-API von Aegis
+type PurgeRequest struct {
+	ProjectID string   `json:"project_id"`
+	RouteID   string   `json:"route_id,omitempty"`
+	Prefix    string   `json:"prefix,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// CachePurger löscht Cache-Einträge in Aegis. Alle Aegis-Instanzen teilen sich den
+// Cache in Redis, daher genügt ein Aufruf an eine beliebige Instanz.
+type CachePurger struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewCachePurger(url, secret string) *CachePurger {
+	return &CachePurger{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Purge führt den Purge aus und liefert die Anzahl der gelöschten URLs
+func (p *CachePurger) Purge(ctx context.Context, req PurgeRequest) (int, error) {
+	if p == nil || p.url == "" {
+		return 0, ErrPurgeDisabled
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("fehler beim Serialisieren des Purge-Requests: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("fehler beim Erstellen des Purge-Requests: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Internal-Secret", p.secret)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("aegis Purge-API (%s) nicht erreichbar: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("aegis Purge-API (%s) hat mit Status %d geantwortet", p.url, resp.StatusCode)
+	}
+
+	var result struct {
+		Purged int `json:"purged"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("ungültige Antwort der Aegis Purge-API: %w", err)
+	}
+	return result.Purged, nil
+}
+
+// PurgeRouteAsync löscht die Einträge einer geänderten oder gelöschten Route im
+// Hintergrund. Fehler werden nur geloggt: die Einträge laufen spätestens über ihre TTL aus.
+func (p *CachePurger) PurgeRouteAsync(ctx context.Context, projectID, routeID string) {
+	if p == nil || p.url == "" {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		purged, err := p.Purge(ctx, PurgeRequest{ProjectID: projectID, RouteID: routeID})
+		if err != nil {
+			slog.WarnContext(ctx, "Cache-Purge der Route fehlgeschlagen", slog.Any("error", err),
+				slog.String("project_id", projectID), slog.String("route_id", routeID))
+			return
+		}
+		slog.InfoContext(ctx, "Cache der Route geleert", slog.String("project_id", projectID),
+			slog.String("route_id", routeID), slog.Int("purged", purged))
+	}()
+}
@@ -0,0 +1,73 @@
+package configsync
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// PollInterval ist das Intervall des Versions-Pollers: so schnell erreichen
+// Änderungen auf anderen Athena-Instanzen die Watcher dieser Instanz
+const PollInterval = 500 * time.Millisecond
+
+// Notifier benachrichtigt wartende Watch-Anfragen (Aegis Long-Poll),
+// sobald sich Routen oder Projekt-Hosts geändert haben
+type Notifier struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{changed: make(chan struct{})}
+}
+
+// Changed liefert einen Kanal, der bei der nächsten Änderung geschlossen wird.
+// Muss VOR dem Lesen des aktuellen Stands geholt werden, sonst kann eine
+// Änderung dazwischen verloren gehen.
+func (n *Notifier) Changed() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changed
+}
+
+// Notify weckt alle aktuell wartenden Watcher auf
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// Poll liest die Config-Version im Intervall und weckt alle Watcher, sobald sie
+// sich ändert (z.B. nach einer Änderung auf einer anderen Athena-Instanz). Ein
+// Poller pro Prozess, unabhängig von der Anzahl wartender Watcher. Blockiert bis
+// ctx beendet ist.
+func (n *Notifier) Poll(ctx context.Context, interval time.Duration, version func(context.Context) (string, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	known := ""
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := version(ctx)
+		if err != nil {
+			if !failing && ctx.Err() == nil {
+				slog.Warn("Config-Version konnte nicht geprüft werden", slog.Any("error", err))
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		if known != "" && current != known {
+			n.Notify()
+		}
+		known = current
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"athena/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+func (r *sqlxRepository) GetProjectIPPolicy(ctx context.Context, projectID string) (models.IPPolicyConfig, error) {
+	var value sql.NullString
+	query := `SELECT ip_policy FROM projects WHERE id = ? LIMIT 1`
+	if err := r.db.GetContext(ctx, &value, query, projectID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.IPPolicyConfig{}, ErrUserNotFound
+		}
+		slog.ErrorContext(ctx, "Fehler beim Abrufen der IP-Richtlinie", slog.Any("error", err), slog.String("project_id", projectID))
+		return models.IPPolicyConfig{}, err
+	}
+	policy, err := models.ParseIPPolicy(value)
+	if err != nil {
+		slog.ErrorContext(ctx, "IP-Richtlinie des Projekts ist beschädigt", slog.Any("error", err), slog.String("project_id", projectID))
+		return models.IPPolicyConfig{}, err
+	}
+	return policy, nil
+}
+
+// UpdateProjectIPPolicy ersetzt die IP-Richtlinie eines Projekts (leer = keine Regeln)
+func (r *sqlxRepository) UpdateProjectIPPolicy(ctx context.Context, projectID string, policy models.IPPolicyConfig) error {
+	query := `UPDATE projects SET ip_policy = ?, updated_at = ? WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, policy.NullString(), time.Now().UTC(), projectID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Speichern der IP-Richtlinie", slog.Any("error", err), slog.String("project_id", projectID))
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		slog.WarnContext(ctx, "Versuch, IP-Richtlinie eines nicht existierenden Projekts zu setzen", slog.String("project_id", projectID))
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetAllProjectIPPolicies liefert die Richtlinien aller Projekte mit Regeln (für die
+// Gateway-Konfiguration). Eine beschädigte Richtlinie ist ein Fehler, keine offene Route.
+func (r *sqlxRepository) GetAllProjectIPPolicies(ctx context.Context) (map[string]models.IPPolicyConfig, error) {
+	var rows []struct {
+		ProjectID string         `db:"id"`
+		IPPolicy  sql.NullString `db:"ip_policy"`
+	}
+	query := `SELECT id, ip_policy FROM projects WHERE ip_policy IS NOT NULL AND ip_policy != ''`
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Abrufen ALLER IP-Richtlinien", slog.Any("error", err))
+		return nil, err
+	}
+
+	result := make(map[string]models.IPPolicyConfig, len(rows))
+	for _, row := range rows {
+		policy, err := models.ParseIPPolicy(row.IPPolicy)
+		if err != nil {
+			slog.ErrorContext(ctx, "IP-Richtlinie des Projekts ist beschädigt", slog.Any("error", err), slog.String("project_id", row.ProjectID))
+			return nil, fmt.Errorf("projekt %s: %w", row.ProjectID, err)
+		}
+		if !policy.Empty() {
+			result[row.ProjectID] = policy
+		}
+	}
+	return result, nil
+}
@@ -38,7 +38,7 @@ func (r *sqlxRepository) GetUserProjectData(ctx context.Context, userID uuid.UUI
 		RolesString  sql.NullString `db:"roles"`
 		UserIDString string         `db:"user_id"`
 	}
-	query := `SELECT user_id, project_id, roles, otp_enabled, otp_secret, otp_auth_url
+	query := `SELECT user_id, project_id, roles, plan, otp_enabled, otp_secret, otp_auth_url
 	           FROM user_projects WHERE user_id = ? AND project_id = ? LIMIT 1`
 
 	err := r.db.GetContext(ctx, &dbData, query, userID.String(), projectID)
@@ -176,7 +176,7 @@ func (r *sqlxRepository) GetUsersByProjectID(ctx context.Context, projectID uuid
 	var dbUsers []*ProjectUserDB
     
     // KORRIGIERTE QUERY: Holt 'up.roles'
-	query := `SELECT u.id, u.email, u.is_admin, u.created_at, u.updated_at, up.roles 
+	query := `SELECT u.id, u.email, u.is_admin, u.created_at, u.updated_at, up.roles, up.plan
               FROM users u
               JOIN user_projects up ON u.id = up.user_id
               WHERE up.project_id = ?`
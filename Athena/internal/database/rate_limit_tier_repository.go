@@ -0,0 +1,122 @@
+package database
+
+import (
+	"athena/internal/models"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type dbRateLimitTier struct {
+	ProjectID string         `db:"project_id"`
+	Name      string         `db:"name"`
+	Position  int            `db:"position"`
+	Limit     int            `db:"rate_limit"`
+	Window    string         `db:"rate_window"`
+	Burst     int            `db:"burst"`
+	Roles     sql.NullString `db:"roles"`
+	Plans     sql.NullString `db:"plans"`
+}
+
+func (t *dbRateLimitTier) ToModel() models.RateLimitTier {
+	return models.RateLimitTier{
+		Name:   t.Name,
+		Limit:  t.Limit,
+		Window: t.Window,
+		Burst:  t.Burst,
+		Roles:  splitList(t.Roles),
+		Plans:  splitList(t.Plans),
+	}
+}
+
+func splitList(value sql.NullString) []string {
+	if !value.Valid || value.String == "" {
+		return []string{}
+	}
+	return strings.Split(value.String, ",")
+}
+
+func joinList(values []string) sql.NullString {
+	if len(values) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.Join(values, ","), Valid: true}
+}
+
+func (r *sqlxRepository) GetRateLimitTiers(ctx context.Context, projectID string) ([]models.RateLimitTier, error) {
+	var rows []dbRateLimitTier
+	query := `SELECT * FROM project_rate_limit_tiers WHERE project_id = ? ORDER BY position`
+	if err := r.db.SelectContext(ctx, &rows, query, projectID); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Abrufen der Rate-Limit-Tiers", slog.Any("error", err), slog.String("project_id", projectID))
+		return nil, err
+	}
+
+	tiers := make([]models.RateLimitTier, 0, len(rows))
+	for _, row := range rows {
+		tiers = append(tiers, row.ToModel())
+	}
+	return tiers, nil
+}
+
+// ReplaceRateLimitTiers ersetzt alle Tiers eines Projekts (Reihenfolge = Priorität)
+func (r *sqlxRepository) ReplaceRateLimitTiers(ctx context.Context, projectID string, tiers []models.RateLimitTier) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM project_rate_limit_tiers WHERE project_id = ?`, projectID); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Löschen der Rate-Limit-Tiers", slog.Any("error", err), slog.String("project_id", projectID))
+		return err
+	}
+
+	query := `INSERT INTO project_rate_limit_tiers (project_id, name, position, rate_limit, rate_window, burst, roles, plans)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for i, t := range tiers {
+		if _, err := tx.ExecContext(ctx, query, projectID, t.Name, i, t.Limit, t.Window, t.Burst, joinList(t.Roles), joinList(t.Plans)); err != nil {
+			slog.ErrorContext(ctx, "Fehler beim Speichern des Rate-Limit-Tiers", slog.Any("error", err), slog.String("project_id", projectID), slog.String("tier", t.Name))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAllRateLimitTiers liefert die Tiers aller Projekte (für die Gateway-Konfiguration)
+func (r *sqlxRepository) GetAllRateLimitTiers(ctx context.Context) (map[string][]models.RateLimitTier, error) {
+	var rows []dbRateLimitTier
+	query := `SELECT * FROM project_rate_limit_tiers ORDER BY project_id, position`
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Abrufen ALLER Rate-Limit-Tiers", slog.Any("error", err))
+		return nil, err
+	}
+
+	result := make(map[string][]models.RateLimitTier)
+	for _, row := range rows {
+		result[row.ProjectID] = append(result[row.ProjectID], row.ToModel())
+	}
+	return result, nil
+}
+
+func (r *sqlxRepository) UpdateUserPlan(ctx context.Context, userID uuid.UUID, projectID string, plan string) error {
+	query := `UPDATE user_projects SET plan = ? WHERE user_id = ? AND project_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, plan, userID.String(), projectID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Aktualisieren des Benutzer-Plans", slog.Any("error", err), slog.String("user_id", userID.String()), slog.String("project_id", projectID))
+		return err
+	}
+
+	// MySQL meldet 0 betroffene Zeilen auch, wenn sich der Wert nicht ändert
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		if _, err := r.GetUserProjectData(ctx, userID, projectID); err != nil {
+			return err
+		}
+	}
+
+	slog.DebugContext(ctx, "Benutzer-Plan erfolgreich aktualisiert", slog.String("user_id", userID.String()), slog.String("project_id", projectID))
+	return nil
+}
@@ -57,6 +57,7 @@ type ProjectUserDB struct {
 	CreatedAt   time.Time `db:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
 	RolesString sql.NullString `db:"roles"`
+	Plan        string         `db:"plan"`
 }
 
 // ProjectRepository kümmert sich um Projekte und deren Benutzer-Beziehungen
@@ -76,6 +77,17 @@ type ProjectRepository interface {
 	GetUserAndProjectDataByEmail(ctx context.Context, email string, projectID string) (*models.User, *models.UserProjectData, error)
 	GetUsersByProjectID(ctx context.Context, projectID uuid.UUID) ([]*ProjectUserDB, error)
 	UpdateUserRoles(ctx context.Context, userID uuid.UUID, projectID string, roles []string) error
+	UpdateUserPlan(ctx context.Context, userID uuid.UUID, projectID string, plan string) error
+
+	// Rate-Limit-Tiers (Reihenfolge = Priorität)
+	GetRateLimitTiers(ctx context.Context, projectID string) ([]models.RateLimitTier, error)
+	ReplaceRateLimitTiers(ctx context.Context, projectID string, tiers []models.RateLimitTier) error
+	GetAllRateLimitTiers(ctx context.Context) (map[string][]models.RateLimitTier, error)
+
+	// IP-Richtlinie (CIDRs und Länder) des Projekts
+	GetProjectIPPolicy(ctx context.Context, projectID string) (models.IPPolicyConfig, error)
+	UpdateProjectIPPolicy(ctx context.Context, projectID string, policy models.IPPolicyConfig) error
+	GetAllProjectIPPolicies(ctx context.Context) (map[string]models.IPPolicyConfig, error)
     RemoveUserFromProject(ctx context.Context, userID uuid.UUID, projectID string) error
 
 	// OTP (ist an die Benutzer-Projekt-Beziehung gebunden)
@@ -94,4 +106,8 @@ type RouteRepository interface {
 	UpdateProjectRoute(ctx context.Context, route *models.ProjectRoute) error
 	DeleteProjectRoute(ctx context.Context, routeID uuid.UUID) error
 	GetAllProjectRoutes(ctx context.Context) ([]*models.ProjectRoute, error)
+
+	// Apply-Status der Aegis-Instanzen
+	ReplaceRouteApplyStatus(ctx context.Context, instanceID string, statuses []models.RouteApplyStatus) error
+	GetRouteApplyStatus(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID][]models.RouteApplyStatus, error)
 }
\ No newline at end of file
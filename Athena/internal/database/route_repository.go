@@ -16,13 +16,36 @@ type dbProjectRoute struct {
 	ID                 string         `db:"id"`
 	ProjectID          string         `db:"project_id"`
 	Path               string         `db:"path"`
+	Methods            string         `db:"methods"`
+	Priority           int            `db:"priority"`
 	TargetURL          string         `db:"target_url"`
+	UpstreamsJSON      sql.NullString `db:"upstreams"`
+	RetryJSON          sql.NullString `db:"retry_policy"`
+	TransformJSON      sql.NullString `db:"transform"`
+	StreamingJSON      sql.NullString `db:"streaming"`
+	UpstreamTLSJSON    sql.NullString `db:"upstream_tls"`
+	IPPolicyJSON       sql.NullString `db:"ip_policy"`
+	LBStrategy         string         `db:"lb_strategy"`
+	Protocol           string         `db:"protocol"`
 	RolesString        sql.NullString `db:"required_roles"`
 	CacheTTL           string         `db:"cache_ttl"`
 	RateLimitLimit     int            `db:"rate_limit_limit"`
 	RateLimitWindow    string         `db:"rate_limit_window"`
+	RateLimitAlgorithm string         `db:"rate_limit_algorithm"`
+	RateLimitBurst     int            `db:"rate_limit_burst"`
 	CbThreshold        int            `db:"cb_threshold"`
 	CbTimeout          string         `db:"cb_timeout"`
+	CbWindow           string         `db:"cb_window"`
+	CbErrorThreshold   int            `db:"cb_error_threshold"`
+	CbMinRequests      int            `db:"cb_min_requests"`
+	CbSlowCall         string         `db:"cb_slow_call"`
+	CbHalfOpenRequests int            `db:"cb_half_open_requests"`
+	CbKey              string         `db:"cb_key"`
+	HcPath             string         `db:"hc_path"`
+	HcInterval         string         `db:"hc_interval"`
+	HcTimeout          string         `db:"hc_timeout"`
+	HcHealthyThreshold int            `db:"hc_healthy_threshold"`
+	HcUnhealthyThreshold int          `db:"hc_unhealthy_threshold"`
 	CreatedAt          time.Time      `db:"created_at"`
 	UpdatedAt          time.Time      `db:"updated_at"`
 }
@@ -32,16 +55,41 @@ func (dbpr *dbProjectRoute) ToModel() *models.ProjectRoute {
 		ID:          uuid.MustParse(dbpr.ID),
 		ProjectID:   uuid.MustParse(dbpr.ProjectID),
 		Path:        dbpr.Path,
+		MethodsString: dbpr.Methods,
+		Priority:    dbpr.Priority,
 		TargetURL:   dbpr.TargetURL,
+		UpstreamsJSON: dbpr.UpstreamsJSON,
+		RetryJSON:     dbpr.RetryJSON,
+		TransformJSON: dbpr.TransformJSON,
+		StreamingJSON: dbpr.StreamingJSON,
+		UpstreamTLSJSON: dbpr.UpstreamTLSJSON,
+		IPPolicyJSON:    dbpr.IPPolicyJSON,
+		LoadBalancing: dbpr.LBStrategy,
+		Protocol:      dbpr.Protocol,
 		RolesString: dbpr.RolesString,
 		CacheTTL:    dbpr.CacheTTL,
 		RateLimit: models.RateLimitConfig{
-			Limit:  dbpr.RateLimitLimit,
-			Window: dbpr.RateLimitWindow,
+			Limit:     dbpr.RateLimitLimit,
+			Window:    dbpr.RateLimitWindow,
+			Algorithm: dbpr.RateLimitAlgorithm,
+			Burst:     dbpr.RateLimitBurst,
 		},
 		CircuitBreaker: models.CircuitBreakerConfig{
 			FailureThreshold: dbpr.CbThreshold,
 			OpenTimeout:      dbpr.CbTimeout,
+			Window:           dbpr.CbWindow,
+			ErrorThreshold:   dbpr.CbErrorThreshold,
+			MinRequests:      dbpr.CbMinRequests,
+			SlowCallDuration: dbpr.CbSlowCall,
+			HalfOpenRequests: dbpr.CbHalfOpenRequests,
+			Key:              dbpr.CbKey,
+		},
+		HealthCheck: models.HealthCheckConfig{
+			Path:               dbpr.HcPath,
+			Interval:           dbpr.HcInterval,
+			Timeout:            dbpr.HcTimeout,
+			HealthyThreshold:   dbpr.HcHealthyThreshold,
+			UnhealthyThreshold: dbpr.HcUnhealthyThreshold,
 		},
 		CreatedAt: dbpr.CreatedAt,
 		UpdatedAt: dbpr.UpdatedAt,
@@ -53,16 +101,32 @@ func (dbpr *dbProjectRoute) ToModel() *models.ProjectRoute {
 func (r *sqlxRepository) CreateProjectRoute(ctx context.Context, route *models.ProjectRoute) error {
 	route.BeforeSave() // Konvertiert RequiredRoles -> RolesString
 	query := `INSERT INTO project_routes (id, project_id, path, target_url, 
+	                      methods, priority,
 	                      required_roles, cache_ttl, 
 	                      rate_limit_limit, rate_limit_window, 
+	                      rate_limit_algorithm, rate_limit_burst,
 	                      cb_threshold, cb_timeout, 
+	                      cb_window, cb_error_threshold, cb_min_requests,
+	                      cb_slow_call, cb_half_open_requests, cb_key,
+	                      upstreams, lb_strategy, protocol, retry_policy, transform, streaming,
+	                      upstream_tls, ip_policy,
+	                      hc_path, hc_interval, hc_timeout,
+	                      hc_healthy_threshold, hc_unhealthy_threshold,
 	                      created_at, updated_at)
-	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := r.db.ExecContext(ctx, query,
 		route.ID.String(), route.ProjectID.String(), route.Path, route.TargetURL,
+		route.MethodsString, route.Priority,
 		route.RolesString, route.CacheTTL,
 		route.RateLimit.Limit, route.RateLimit.Window,
+		route.RateLimit.Algorithm, route.RateLimit.Burst,
 		route.CircuitBreaker.FailureThreshold, route.CircuitBreaker.OpenTimeout,
+		route.CircuitBreaker.Window, route.CircuitBreaker.ErrorThreshold, route.CircuitBreaker.MinRequests,
+		route.CircuitBreaker.SlowCallDuration, route.CircuitBreaker.HalfOpenRequests, route.CircuitBreaker.Key,
+		route.UpstreamsJSON, route.LoadBalancing, route.Protocol, route.RetryJSON, route.TransformJSON, route.StreamingJSON,
+		route.UpstreamTLSJSON, route.IPPolicyJSON,
+		route.HealthCheck.Path, route.HealthCheck.Interval, route.HealthCheck.Timeout,
+		route.HealthCheck.HealthyThreshold, route.HealthCheck.UnhealthyThreshold,
 		route.CreatedAt, route.UpdatedAt,
 	)
 	if err != nil {
@@ -107,14 +171,30 @@ func (r *sqlxRepository) UpdateProjectRoute(ctx context.Context, route *models.P
 	route.UpdatedAt = time.Now().UTC()
 	query := `UPDATE project_routes SET 
 	            path = ?, target_url = ?, required_roles = ?, cache_ttl = ?,
+	            methods = ?, priority = ?,
 	            rate_limit_limit = ?, rate_limit_window = ?,
+	            rate_limit_algorithm = ?, rate_limit_burst = ?,
 	            cb_threshold = ?, cb_timeout = ?,
+	            cb_window = ?, cb_error_threshold = ?, cb_min_requests = ?,
+	            cb_slow_call = ?, cb_half_open_requests = ?, cb_key = ?,
+	            upstreams = ?, lb_strategy = ?, protocol = ?, retry_policy = ?, transform = ?, streaming = ?,
+	            upstream_tls = ?, ip_policy = ?,
+	            hc_path = ?, hc_interval = ?, hc_timeout = ?,
+	            hc_healthy_threshold = ?, hc_unhealthy_threshold = ?,
 	            updated_at = ?
 	          WHERE id = ? AND project_id = ?`
 	_, err := r.db.ExecContext(ctx, query,
 		route.Path, route.TargetURL, route.RolesString, route.CacheTTL,
+		route.MethodsString, route.Priority,
 		route.RateLimit.Limit, route.RateLimit.Window,
+		route.RateLimit.Algorithm, route.RateLimit.Burst,
 		route.CircuitBreaker.FailureThreshold, route.CircuitBreaker.OpenTimeout,
+		route.CircuitBreaker.Window, route.CircuitBreaker.ErrorThreshold, route.CircuitBreaker.MinRequests,
+		route.CircuitBreaker.SlowCallDuration, route.CircuitBreaker.HalfOpenRequests, route.CircuitBreaker.Key,
+		route.UpstreamsJSON, route.LoadBalancing, route.Protocol, route.RetryJSON, route.TransformJSON, route.StreamingJSON,
+		route.UpstreamTLSJSON, route.IPPolicyJSON,
+		route.HealthCheck.Path, route.HealthCheck.Interval, route.HealthCheck.Timeout,
+		route.HealthCheck.HealthyThreshold, route.HealthCheck.UnhealthyThreshold,
 		route.UpdatedAt,
 		route.ID.String(), route.ProjectID.String(),
 	)
@@ -137,7 +217,7 @@ func (r *sqlxRepository) DeleteProjectRoute(ctx context.Context, routeID uuid.UU
 
 func (r *sqlxRepository) GetAllProjectRoutes(ctx context.Context) ([]*models.ProjectRoute, error) {
 	var dbRoutes []dbProjectRoute
-	query := `SELECT * FROM project_routes`
+	query := `SELECT * FROM project_routes ORDER BY id` // Stabile Reihenfolge für die Config-Version (ETag)
 	err := r.db.SelectContext(ctx, &dbRoutes, query)
 	if err != nil {
 		slog.ErrorContext(ctx, "Fehler beim Abrufen ALLER Routen für Aegis", slog.Any("error", err))
@@ -149,4 +229,77 @@ func (r *sqlxRepository) GetAllProjectRoutes(ctx context.Context) ([]*models.Pro
 		routes = append(routes, dbRoute.ToModel())
 	}
 	return routes, nil
-}
\ No newline at end of file
+}
+// routeApplyStatusTTL: Aegis meldet den Status spätestens alle 5 Minuten erneut.
+// Ältere Einträge stammen von beendeten Instanzen und werden ignoriert bzw. gelöscht.
+const routeApplyStatusTTL = 15 * time.Minute
+
+type dbRouteApplyStatus struct {
+	RouteID       string    `db:"route_id"`
+	InstanceID    string    `db:"instance_id"`
+	Status        string    `db:"status"`
+	Reason        string    `db:"reason"`
+	ConfigVersion string    `db:"config_version"`
+	ReportedAt    time.Time `db:"reported_at"`
+}
+
+// ReplaceRouteApplyStatus ersetzt den kompletten Bericht einer Aegis-Instanz.
+// Status für inzwischen gelöschte Routen werden stillschweigend verworfen,
+// abgelaufene Berichte anderer Instanzen gleich mit gelöscht.
+func (r *sqlxRepository) ReplaceRouteApplyStatus(ctx context.Context, instanceID string, statuses []models.RouteApplyStatus) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM route_apply_status WHERE instance_id = ? OR reported_at < ?`,
+		instanceID, now.Add(-routeApplyStatusTTL)); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Löschen des alten Apply-Status", slog.Any("error", err), slog.String("instance_id", instanceID))
+		return err
+	}
+
+	// INSERT ... SELECT: nur Routen, die (noch) existieren
+	query := `INSERT INTO route_apply_status (route_id, instance_id, status, reason, config_version, reported_at)
+	          SELECT id, ?, ?, ?, ?, ? FROM project_routes WHERE id = ?`
+	for _, s := range statuses {
+		if _, err := tx.ExecContext(ctx, query, instanceID, s.Status, s.Reason, s.ConfigVersion, now, s.RouteID.String()); err != nil {
+			slog.ErrorContext(ctx, "Fehler beim Speichern des Apply-Status", slog.Any("error", err), slog.String("route_id", s.RouteID.String()))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetRouteApplyStatus liefert die gemeldeten Status aller Routen eines Projekts, nach Route
+// gruppiert. Berichte älter als routeApplyStatusTTL (beendete Instanzen) fehlen.
+func (r *sqlxRepository) GetRouteApplyStatus(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID][]models.RouteApplyStatus, error) {
+	var rows []dbRouteApplyStatus
+	query := `SELECT s.route_id, s.instance_id, s.status, s.reason, s.config_version, s.reported_at
+	          FROM route_apply_status s
+	          JOIN project_routes pr ON pr.id = s.route_id
+	          WHERE pr.project_id = ? AND s.reported_at >= ?
+	          ORDER BY s.instance_id`
+	if err := r.db.SelectContext(ctx, &rows, query, projectID.String(), time.Now().UTC().Add(-routeApplyStatusTTL)); err != nil {
+		slog.ErrorContext(ctx, "Fehler beim Abrufen des Apply-Status", slog.Any("error", err), slog.String("project_id", projectID.String()))
+		return nil, err
+	}
+
+	result := make(map[uuid.UUID][]models.RouteApplyStatus)
+	for _, row := range rows {
+		routeID, err := uuid.Parse(row.RouteID)
+		if err != nil {
+			continue
+		}
+		result[routeID] = append(result[routeID], models.RouteApplyStatus{
+			RouteID:       routeID,
+			InstanceID:    row.InstanceID,
+			Status:        row.Status,
+			Reason:        row.Reason,
+			ConfigVersion: row.ConfigVersion,
+			ReportedAt:    row.ReportedAt,
+		})
+	}
+	return result, nil
+}
@@ -7,7 +7,6 @@ import (
 	"athena/internal/middleware"
 	"athena/internal/models"
 	"context"
-	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"log/slog"
@@ -26,7 +25,7 @@ type AdminHandlers struct {
 	UserRepo        database.UserRepository
 	TokenRepo       database.TokenRepository // Für LoginOTP
 	IssuerName      string
-	PrivateKey      *rsa.PrivateKey
+	Signer          auth.Signer
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 }
@@ -35,14 +34,14 @@ func NewAdminHandlers(
 	userRepo database.UserRepository,
 	tokenRepo database.TokenRepository,
 	issuerName string,
-	pk *rsa.PrivateKey,
+	signer auth.Signer,
 	accessTTL, refreshTTL time.Duration,
 ) *AdminHandlers {
 	return &AdminHandlers{
 		UserRepo:        userRepo,
 		TokenRepo:       tokenRepo,
 		IssuerName:      issuerName,
-		PrivateKey:      pk,
+		Signer:          signer,
 		AccessTokenTTL:  accessTTL,
 		RefreshTokenTTL: refreshTTL,
 	}
@@ -301,7 +300,7 @@ func (h *AdminHandlers) issueTokensAndRespond(ctx context.Context, w http.Respon
 		roles = []string{}
 	}
 
-	accessToken, err := auth.GenerateToken(user, roles, h.PrivateKey, h.AccessTokenTTL)
+	accessToken, err := auth.GenerateToken(user, roles, "", "", h.Signer, h.AccessTokenTTL) // Globale Admins haben keinen Plan
 	if err != nil {
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
 		return
@@ -8,7 +8,6 @@ import (
 	"athena/internal/middleware"
 	"athena/internal/models"
 	"context"
-	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -22,7 +21,7 @@ type AuthHandlers struct {
 	UserRepo    database.UserRepository
 	ProjectRepo database.ProjectRepository
 	TokenRepo   database.TokenRepository
-	PrivateKey  *rsa.PrivateKey
+	Signer      auth.Signer
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	Config      *config.Config
@@ -32,7 +31,7 @@ func NewAuthHandlers(
 	userRepo database.UserRepository,
 	projectRepo database.ProjectRepository,
 	tokenRepo database.TokenRepository,
-	pk *rsa.PrivateKey,
+	signer auth.Signer,
 	accessTTL, refreshTTL time.Duration,
 	cfg *config.Config,
 ) *AuthHandlers {
@@ -40,7 +39,7 @@ func NewAuthHandlers(
 		UserRepo:    userRepo,
 		ProjectRepo: projectRepo,
 		TokenRepo:   tokenRepo,
-		PrivateKey:  pk,
+		Signer:  signer,
 		AccessTokenTTL:  accessTTL,
 		RefreshTokenTTL: refreshTTL,
 		Config:      cfg,
@@ -213,7 +212,7 @@ func (h *AuthHandlers) handleGlobalLogin(w http.ResponseWriter, r *http.Request)
 
 		// Globaler Admin-Token (ohne Projekt-ID)
 		slog.InfoContext(ctx, "Global-Admin erfolgreich eingeloggt (ohne 2FA)", slog.String("user_id", user.ID.String()))
-		h.issueTokensAndRespond(ctx, w, user, nil, "") // Leere projectID
+		h.issueTokensAndRespond(ctx, w, user, nil, "", "") // Leere projectID
 		logging.LogAuditEvent(ctx, "AUTH_LOGIN_GLOBAL", logging.AuditSuccess,
 			slog.String("result", "tokens_issued_admin"),
 		)
@@ -228,7 +227,7 @@ func (h *AuthHandlers) handleGlobalLogin(w http.ResponseWriter, r *http.Request)
 		
 		// WICHTIG: Token enthält `is_admin: false` und KEINE Rollen.
 		// Die Rollen werden erst bei Projekt-API-Aufrufen über die `X-Project-ID` relevant.
-		h.issueTokensAndRespond(ctx, w, user, nil, "") // Leere projectID
+		h.issueTokensAndRespond(ctx, w, user, nil, "", "") // Leere projectID
 		logging.LogAuditEvent(ctx, "AUTH_LOGIN_GLOBAL", logging.AuditSuccess,
 			slog.String("result", "tokens_issued_user"),
 		)
@@ -295,7 +294,7 @@ func (h *AuthHandlers) handleProjectLogin(w http.ResponseWriter, r *http.Request
 			slog.String("result", "issuing_grace_token"),
 		)
 
-		graceToken, err := auth.GenerateToken(user, projectData.Roles, h.PrivateKey, h.Config.JWTGraceTokenTTL)
+		graceToken, err := auth.GenerateToken(user, projectData.Roles, projectData.Plan, projectID, h.Signer, h.Config.JWTGraceTokenTTL)
 		if err != nil {
 			slog.ErrorContext(ctx, "Fehler beim Erstellen des Grace Tokens", slog.String("user_id", user.ID.String()), slog.Any("error", err))
 			writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
@@ -327,16 +326,16 @@ func (h *AuthHandlers) handleProjectLogin(w http.ResponseWriter, r *http.Request
 	logging.LogAuditEvent(ctx, "AUTH_LOGIN_PROJECT", logging.AuditSuccess,
 		slog.String("result", "tokens_issued"),
 	)
-	h.issueTokensAndRespond(ctx, w, user, projectData.Roles, projectID)
+	h.issueTokensAndRespond(ctx, w, user, projectData.Roles, projectData.Plan, projectID)
 }
 
 // issueTokensAndRespond (WICHTIG: Fügt project_id zur Antwort hinzu)
-func (h *AuthHandlers) issueTokensAndRespond(ctx context.Context, w http.ResponseWriter, user *models.User, roles []string, projectID string) {
+func (h *AuthHandlers) issueTokensAndRespond(ctx context.Context, w http.ResponseWriter, user *models.User, roles []string, plan string, projectID string) {
 	if roles == nil {
 		roles = []string{}
 	}
 
-	accessToken, err := auth.GenerateToken(user, roles, h.PrivateKey, h.AccessTokenTTL)
+	accessToken, err := auth.GenerateToken(user, roles, plan, projectID, h.Signer, h.AccessTokenTTL)
 	if err != nil {
 		slog.ErrorContext(ctx, "Fehler beim Erstellen des Access JWT für Login", slog.String("user_id", user.ID.String()), slog.Any("error", err))
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
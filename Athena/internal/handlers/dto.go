@@ -73,22 +73,109 @@ type ErrorResponse struct {
 }
 
 type RouteRateLimitConfig struct {
-	Limit  int    `json:"limit"`
-	Window string `json:"window" validate:"duration"`
+	Limit     int    `json:"limit"`
+	Window    string `json:"window" validate:"duration"`
+	Algorithm string `json:"algorithm" validate:"omitempty,oneof=token_bucket sliding_window"`
+	Burst     int    `json:"burst" validate:"min=0"`
 }
 
 type RouteCircuitBreakerConfig struct {
-	FailureThreshold int    `json:"failure_threshold"`
+	FailureThreshold int    `json:"failure_threshold" validate:"min=0"`
 	OpenTimeout      string `json:"open_timeout" validate:"duration"`
+	Window           string `json:"window" validate:"duration"`
+	ErrorThreshold   int    `json:"error_threshold" validate:"min=0,max=100"`
+	MinRequests      int    `json:"min_requests" validate:"min=0"`
+	SlowCallDuration string `json:"slow_call_duration" validate:"duration"`
+	HalfOpenRequests int    `json:"half_open_requests" validate:"min=0,max=100"`
+	Key              string `json:"key" validate:"omitempty,oneof=route upstream"`
+}
+
+type RouteHealthCheckConfig struct {
+	Path               string `json:"path" validate:"omitempty,startswith=/"`
+	Interval           string `json:"interval" validate:"duration"`
+	Timeout            string `json:"timeout" validate:"duration"`
+	HealthyThreshold   int    `json:"healthy_threshold" validate:"min=0"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold" validate:"min=0"`
+}
+
+type RouteRetryConfig struct {
+	MaxAttempts         int      `json:"max_attempts" validate:"min=0,max=10"`
+	StatusCodes         []int    `json:"status_codes" validate:"omitempty,max=20,dive,min=400,max=599"`
+	RetryOn             []string `json:"retry_on" validate:"omitempty,dive,oneof=connect_failure reset timeout"`
+	BackoffBase         string   `json:"backoff_base" validate:"duration"`
+	BackoffMax          string   `json:"backoff_max" validate:"duration"`
+	BudgetPercent       int      `json:"budget_percent" validate:"min=0,max=100"`
+	MinRetriesPerSecond int      `json:"min_retries_per_second" validate:"min=0,max=1000"`
+}
+
+type RouteStreamingConfig struct {
+	Enabled          bool   `json:"enabled"`
+	IdleTimeout      string `json:"idle_timeout" validate:"duration"`
+	MaxDuration      string `json:"max_duration" validate:"duration"`
+	MaxConnections   int    `json:"max_connections" validate:"min=0,max=1000000"`
+	TokenQueryParam  string `json:"token_query_param" validate:"omitempty,max=64,printascii,excludesall= &=?#"`
+	TokenSubprotocol bool   `json:"token_subprotocol"`
+}
+
+type RouteUpstreamTLSConfig struct {
+	CAFile     string `json:"ca_file" validate:"omitempty,max=512,startswith=/"`
+	CertFile   string `json:"cert_file" validate:"required_with=KeyFile,omitempty,max=512,startswith=/"`
+	KeyFile    string `json:"key_file" validate:"required_with=CertFile,omitempty,max=512,startswith=/"`
+	VaultPath  string `json:"vault_path" validate:"omitempty,max=512,excludes=..,excludesall= ?#"`
+	ServerName string `json:"server_name" validate:"omitempty,max=253,hostname_rfc1123"`
+	MinVersion string `json:"min_version" validate:"omitempty,oneof=1.2 1.3"`
+}
+
+// IP-Richtlinie einer Route und PUT /projects/{projectID}/ip-policy
+// (CIDRs oder einzelne IPs, Länder als ISO-3166-Code in Großbuchstaben)
+type IPPolicyRequest struct {
+	AllowCIDRs     []string `json:"allow_cidrs" validate:"omitempty,max=500,dive,cidr|ip"`
+	DenyCIDRs      []string `json:"deny_cidrs" validate:"omitempty,max=500,dive,cidr|ip"`
+	AllowCountries []string `json:"allow_countries" validate:"omitempty,max=250,dive,iso3166_1_alpha2"`
+	DenyCountries  []string `json:"deny_countries" validate:"omitempty,max=250,dive,iso3166_1_alpha2"`
+}
+
+type RouteHeaderRules struct {
+	Add    map[string]string `json:"add" validate:"omitempty,max=50,dive,keys,required,max=256,endkeys,max=4096"`
+	Set    map[string]string `json:"set" validate:"omitempty,max=50,dive,keys,required,max=256,endkeys,max=4096"`
+	Remove []string          `json:"remove" validate:"omitempty,max=50,dive,required,max=256"`
+}
+
+type RoutePathRewrite struct {
+	Pattern     string `json:"pattern" validate:"required,max=1024,regexp"`
+	Replacement string `json:"replacement" validate:"max=1024"`
+}
+
+type RouteTransformConfig struct {
+	PathRewrite     *RoutePathRewrite `json:"path_rewrite" validate:"omitempty"`
+	RequestHeaders  RouteHeaderRules  `json:"request_headers"`
+	ResponseHeaders RouteHeaderRules  `json:"response_headers"`
+	Query           map[string]string `json:"query" validate:"omitempty,max=50,dive,keys,required,max=256,endkeys,max=4096"`
+}
+
+type RouteUpstreamConfig struct {
+	URL    string `json:"url" validate:"required,url"`
+	Weight int    `json:"weight" validate:"min=0,max=1000"`
 }
 
 type RouteRequestBase struct {
 	Path           string                    `json:"path" validate:"required,startswith=/"`
-	TargetURL      string                    `json:"target_url" validate:"required,url"`
+	Methods        []string                  `json:"methods" validate:"omitempty,dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	Priority       int                       `json:"priority" validate:"min=-1000,max=1000"`
+	TargetURL      string                    `json:"target_url" validate:"required_without=Upstreams,omitempty,url"`
+	Upstreams      []RouteUpstreamConfig     `json:"upstreams" validate:"omitempty,dive"`
+	LoadBalancing  string                    `json:"load_balancing" validate:"omitempty,oneof=round_robin least_connections random_two_choices"`
+	Protocol       string                    `json:"protocol" validate:"omitempty,oneof=http grpc h2c"`
 	RequiredRoles  []string                  `json:"required_roles"`
 	CacheTTL       string                    `json:"cache_ttl" validate:"duration"`
 	RateLimit      RouteRateLimitConfig      `json:"rate_limit"`
 	CircuitBreaker RouteCircuitBreakerConfig `json:"circuit_breaker"`
+	HealthCheck    RouteHealthCheckConfig    `json:"health_check"`
+	Retry          RouteRetryConfig          `json:"retry"`
+	Transform      RouteTransformConfig      `json:"transform"`
+	Streaming      RouteStreamingConfig      `json:"streaming"`
+	UpstreamTLS    RouteUpstreamTLSConfig    `json:"upstream_tls"`
+	IPPolicy       IPPolicyRequest           `json:"ip_policy"`
 }
 
 // POST /projects/{projectID}/routes
@@ -105,6 +192,44 @@ type UpdateUserRolesRequest struct {
 	Roles []string `json:"roles" validate:"required"`
 }
 
+// PUT /projects/{projectID}/users/{userID}/plan (leer = kein Plan)
+type UpdateUserPlanRequest struct {
+	Plan string `json:"plan" validate:"max=64"`
+}
+
+type RateLimitTierConfig struct {
+	Name   string   `json:"name" validate:"required,max=64,excludesall=:"`
+	Limit  int      `json:"limit" validate:"min=1"`
+	Window string   `json:"window" validate:"required,duration"`
+	Burst  int      `json:"burst" validate:"min=0"`
+	Roles  []string `json:"roles"`
+	Plans  []string `json:"plans"`
+}
+
+// PUT /projects/{projectID}/rate-limit-tiers (Reihenfolge = Priorität)
+type UpdateRateLimitTiersRequest struct {
+	Tiers []RateLimitTierConfig `json:"tiers" validate:"unique=Name,dive"`
+}
+
+// Antwort von GET/PUT /projects/{projectID}/rate-limit-tiers. Tiers wirken nur auf
+// Routen mit eigenem Rate Limit; Routen ohne Limit stehen in routes_without_limit.
+type RateLimitTiersResponse struct {
+	Tiers              []RateLimitTierConfig `json:"tiers"`
+	RoutesWithoutLimit []RouteReference      `json:"routes_without_limit"`
+}
+
+type RouteReference struct {
+	ID   uuid.UUID `json:"id"`
+	Path string    `json:"path"`
+}
+
+// POST /projects/{projectID}/cache/purge (mindestens ein Kriterium, prefix "/" = ganzes Projekt)
+type PurgeProjectCacheRequest struct {
+	RouteID string   `json:"route_id" validate:"omitempty,uuid"`
+	Prefix  string   `json:"prefix" validate:"omitempty,startswith=/,max=255"`
+	Tags    []string `json:"tags" validate:"max=100,dive,required,max=255"`
+}
+
 type ProjectUserResponse struct {
 	ID        uuid.UUID `json:"id"`
 	Email     string    `json:"email"`
@@ -112,4 +237,18 @@ type ProjectUserResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Roles     []string  `json:"roles"`
-}
\ No newline at end of file
+	Plan      string    `json:"plan,omitempty"`
+}
+// RouteStatusEntry ist der Apply-Status einer einzelnen Route
+type RouteStatusEntry struct {
+	RouteID string `json:"route_id" validate:"required,uuid"`
+	Status  string `json:"status" validate:"required,oneof=applied rejected"`
+	Reason  string `json:"reason" validate:"max=1024"`
+}
+
+// POST /internal/v1/routes/status (von Aegis nach jedem Reload)
+type RouteStatusReport struct {
+	InstanceID    string             `json:"instance_id" validate:"required,max=128"`
+	ConfigVersion string             `json:"config_version" validate:"max=64"`
+	Routes        []RouteStatusEntry `json:"routes" validate:"dive"`
+}
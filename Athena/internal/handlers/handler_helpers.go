@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -19,6 +20,7 @@ var validate = validator.New(validator.WithRequiredStructEnabled())
 // init() registriert alle benutzerdefinierten Validatoren
 func init() {
 	validate.RegisterValidation("duration", durationValidator)
+	validate.RegisterValidation("regexp", regexpValidator)
 }
 
 func durationValidator(fl validator.FieldLevel) bool {
@@ -29,6 +31,11 @@ func durationValidator(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
+func regexpValidator(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
 // validateRequest (aus auth_handlers.go verschoben)
 func validateRequest(ctx context.Context, req interface{}) map[string]string {
 	err := validate.StructCtx(ctx, req)
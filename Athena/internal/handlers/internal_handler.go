@@ -1,46 +1,249 @@
 package handlers
 
 import (
+	"athena/internal/configsync"
 	"athena/internal/database"
+	"athena/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 60 * time.Second
 )
 
 type InternalHandlers struct {
 	RouteRepo   database.RouteRepository
 	ProjectRepo database.ProjectRepository
+	Notifier    *configsync.Notifier
 }
 
-func NewInternalHandlers(routeRepo database.RouteRepository, projectRepo database.ProjectRepository) *InternalHandlers {
+func NewInternalHandlers(routeRepo database.RouteRepository, projectRepo database.ProjectRepository, notifier *configsync.Notifier) *InternalHandlers {
 	return &InternalHandlers{
 		RouteRepo:   routeRepo,
 		ProjectRepo: projectRepo,
+		Notifier:    notifier,
 	}
 }
 
+// ConfigVersionResponse ist die Antwort des Watch-Endpunkts
+type ConfigVersionResponse struct {
+	Version string `json:"version"`
+}
+
+// gatewayConfig ist der aktuelle Stand aller für Aegis relevanten Daten
+type gatewayConfig struct {
+	Routes     []*models.ProjectRoute
+	ContextMap map[string]string
+	Version    string
+}
+
+// loadGatewayConfig lädt Routen und Context Map und berechnet daraus eine
+// gemeinsame Version. Die Version ist ein Hash über den Inhalt und damit auf
+// allen Athena-Instanzen identisch.
+func (h *InternalHandlers) loadGatewayConfig(ctx context.Context) (*gatewayConfig, error) {
+	routes, err := h.RouteRepo.GetAllProjectRoutes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Abrufen der Routen: %w", err)
+	}
+	contextMap, err := h.ProjectRepo.GetProjectContextMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Abrufen der Context Map: %w", err)
+	}
+	tiers, err := h.ProjectRepo.GetAllRateLimitTiers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Abrufen der Rate-Limit-Tiers: %w", err)
+	}
+	ipPolicies, err := h.ProjectRepo.GetAllProjectIPPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Abrufen der IP-Richtlinien: %w", err)
+	}
+	// Tiers gelten nur für Routen mit aktivem Rate Limit (die Tier-API listet die
+	// übrigen Routen in routes_without_limit)
+	for _, route := range routes {
+		// Eine unlesbare Richtlinie würde in Aegis alle Clients zulassen:
+		// dann lieber den letzten Stand weiterlaufen lassen
+		if route.IPPolicyErr != nil {
+			slog.ErrorContext(ctx, "IP-Richtlinie der Route ist beschädigt, Gateway-Konfiguration wird nicht veröffentlicht",
+				slog.Any("error", route.IPPolicyErr), slog.String("route_id", route.ID.String()))
+			return nil, fmt.Errorf("route %s: %w", route.ID, route.IPPolicyErr)
+		}
+		if route.RateLimit.Limit > 0 {
+			route.RateLimitTiers = tiers[route.ProjectID.String()]
+		}
+		if policy, ok := ipPolicies[route.ProjectID.String()]; ok {
+			route.ProjectIPPolicy = &policy
+		}
+	}
+
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode(routes); err != nil {
+		return nil, fmt.Errorf("fehler beim Berechnen der Config-Version: %w", err)
+	}
+	if err := json.NewEncoder(hash).Encode(contextMap); err != nil {
+		return nil, fmt.Errorf("fehler beim Berechnen der Config-Version: %w", err)
+	}
+
+	return &gatewayConfig{
+		Routes:     routes,
+		ContextMap: contextMap,
+		Version:    hex.EncodeToString(hash.Sum(nil)[:16]),
+	}, nil
+}
+
+// writeVersioned setzt ETag/X-Config-Version und antwortet mit 304, wenn Aegis den Stand bereits kennt
+func writeVersioned(w http.ResponseWriter, r *http.Request, version string, data interface{}) {
+	etag := `"` + version + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Config-Version", version)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if match := r.Header.Get("If-None-Match"); match != "" && (match == etag || match == version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSONResponse(w, data, http.StatusOK)
+}
+
 func (h *InternalHandlers) GetAllRoutesConfigHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	routes, err := h.RouteRepo.GetAllProjectRoutes(ctx)
+	cfg, err := h.loadGatewayConfig(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "[INTERNAL] Fehler beim Abrufen aller Routen für Aegis", slog.Any("error", err))
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSONResponse(w, routes, http.StatusOK)
+	writeVersioned(w, r, cfg.Version, cfg.Routes)
 }
 
 // GetContextMapHandler
 func (h *InternalHandlers) GetContextMapHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	contextMap, err := h.ProjectRepo.GetProjectContextMap(ctx)
+	cfg, err := h.loadGatewayConfig(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "[INTERNAL] Fehler beim Abrufen der Context Map", slog.Any("error", err))
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSONResponse(w, contextMap, http.StatusOK)
-}
\ No newline at end of file
+	writeVersioned(w, r, cfg.Version, cfg.ContextMap)
+}
+
+// ReportRouteStatusHandler nimmt den Apply-Status einer Aegis-Instanz entgegen
+// Route: POST /internal/v1/routes/status
+func (h *InternalHandlers) ReportRouteStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req RouteStatusReport
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Ungültiger JSON Body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
+		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
+		return
+	}
+
+	statuses := make([]models.RouteApplyStatus, 0, len(req.Routes))
+	rejected := 0
+	for _, entry := range req.Routes {
+		statuses = append(statuses, models.RouteApplyStatus{
+			RouteID:       uuid.MustParse(entry.RouteID), // per Validator geprüft
+			InstanceID:    req.InstanceID,
+			Status:        entry.Status,
+			Reason:        entry.Reason,
+			ConfigVersion: req.ConfigVersion,
+		})
+		if entry.Status == models.RouteStatusRejected {
+			rejected++
+		}
+	}
+
+	if err := h.RouteRepo.ReplaceRouteApplyStatus(ctx, req.InstanceID, statuses); err != nil {
+		slog.ErrorContext(ctx, "[INTERNAL] Fehler beim Speichern des Apply-Status", slog.Any("error", err), slog.String("instance_id", req.InstanceID))
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+
+	if rejected > 0 {
+		slog.WarnContext(ctx, "[INTERNAL] Aegis hat Routen abgelehnt", slog.String("instance_id", req.InstanceID), slog.Int("rejected", rejected), slog.String("config_version", req.ConfigVersion))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfigVersion liefert die aktuelle Config-Version (für den Versions-Poller des Notifiers)
+func (h *InternalHandlers) ConfigVersion(ctx context.Context) (string, error) {
+	cfg, err := h.loadGatewayConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Version, nil
+}
+
+// WatchConfigHandler ist ein Long-Poll-Endpunkt für Aegis. Er antwortet sofort,
+// wenn sich die Version von ?version= unterscheidet, sonst sobald sich Routen oder
+// Projekt-Hosts ändern (lokal oder über den Versions-Poller auf anderen Instanzen).
+// Nach ?timeout= (Default 30s) ohne Änderung: 304.
+// Route: GET /internal/v1/config/watch
+func (h *InternalHandlers) WatchConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	knownVersion := r.URL.Query().Get("version")
+
+	timeout := defaultWatchTimeout
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, "Ungültiger timeout Parameter", http.StatusBadRequest)
+			return
+		}
+		timeout = min(parsed, maxWatchTimeout)
+	}
+
+	// Das globale WriteTimeout des Servers ist kürzer als der Long-Poll
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second)); err != nil {
+		slog.WarnContext(ctx, "[INTERNAL] Write Deadline für Watch konnte nicht gesetzt werden", slog.Any("error", err))
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		changed := h.Notifier.Changed()
+
+		cfg, err := h.loadGatewayConfig(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "[INTERNAL] Fehler beim Prüfen der Config-Version", slog.Any("error", err))
+			writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+			return
+		}
+		if cfg.Version != knownVersion {
+			w.Header().Set("X-Config-Version", cfg.Version)
+			writeJSONResponse(w, ConfigVersionResponse{Version: cfg.Version}, http.StatusOK)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			w.Header().Set("X-Config-Version", knownVersion)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
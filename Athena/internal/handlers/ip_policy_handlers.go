@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"athena/internal/database"
+	"athena/internal/logging"
+	"athena/internal/middleware"
+	"athena/internal/models"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetIPPolicyHandler liefert die IP-Richtlinie eines Projekts
+// Route: GET /projects/{projectID}/ip-policy
+func (h *ProjectHandlers) GetIPPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isAdmin, err := h.checkProjectAdmin(ctx, adminUserID, projectID)
+	if err != nil || !isAdmin {
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	policy, err := h.ProjectRepo.GetProjectIPPolicy(ctx, projectID)
+	if err != nil {
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, policy, http.StatusOK)
+}
+
+// UpdateIPPolicyHandler ersetzt die IP-Richtlinie eines Projekts. Aegis prüft sie
+// für jede Route des Projekts zusätzlich zur Richtlinie der Route.
+// Route: PUT /projects/{projectID}/ip-policy
+func (h *ProjectHandlers) UpdateIPPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isAdmin, err := h.checkProjectAdmin(ctx, adminUserID, projectID)
+	if err != nil || !isAdmin {
+		logging.LogAuditEvent(ctx, "PROJECT_IP_POLICY_UPDATE", logging.AuditFailure, slog.String("reason", "permission_denied"))
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	var req IPPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Ungültiger JSON Body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
+		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
+		return
+	}
+
+	policy := models.IPPolicyConfig(req)
+	if err := h.ProjectRepo.UpdateProjectIPPolicy(ctx, projectID, policy); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			writeJSONError(w, "Projekt nicht gefunden", http.StatusNotFound)
+		} else {
+			writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "PROJECT_IP_POLICY_UPDATE", logging.AuditSuccess,
+		slog.String("project_id", projectID),
+		slog.Int("allow_cidrs", len(policy.AllowCIDRs)),
+		slog.Int("deny_cidrs", len(policy.DenyCIDRs)),
+		slog.Int("allow_countries", len(policy.AllowCountries)),
+		slog.Int("deny_countries", len(policy.DenyCountries)),
+	)
+	h.Notifier.Notify()
+
+	writeJSONResponse(w, policy, http.StatusOK)
+}
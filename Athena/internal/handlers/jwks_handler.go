@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"athena/internal/auth"
+	"net/http"
+)
+
+// JWKSHandler veröffentlicht alle Verifikationsschlüssel (veröffentlicht, aktiv
+// und auslaufend), damit Tokens während einer Rotation gültig bleiben.
+// Route: GET /.well-known/jwks.json
+func JWKSHandler(keys *auth.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwks := auth.NewJWKS(keys.VerificationKeys()...)
+
+		// Kurzes Caching erlaubt, Aegis lädt bei unbekannter Key-ID ohnehin neu
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSONResponse(w, jwks, http.StatusOK)
+	}
+}
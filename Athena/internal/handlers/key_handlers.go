@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"athena/internal/auth"
+	"athena/internal/logging"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// KeyHandlers steuert die Rotation der JWT-Signaturschlüssel (nur Admins)
+type KeyHandlers struct {
+	Keys *auth.KeyManager
+}
+
+func NewKeyHandlers(keys *auth.KeyManager) *KeyHandlers {
+	return &KeyHandlers{Keys: keys}
+}
+
+// GetKeyStatusHandler
+// Route: GET /admin/keys
+func (h *KeyHandlers) GetKeyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, h.Keys.Status(), http.StatusOK)
+}
+
+// PublishKeyHandler erzeugt einen neuen Schlüssel und veröffentlicht ihn im JWKS
+// Route: POST /admin/keys
+func (h *KeyHandlers) PublishKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	key, err := h.Keys.Publish(ctx)
+	if err != nil {
+		h.writeRotationError(ctx, w, "JWT_KEY_PUBLISH", "", err)
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "JWT_KEY_PUBLISH", logging.AuditSuccess, slog.String("kid", key.Kid))
+	writeJSONResponse(w, key, http.StatusCreated)
+}
+
+// ActivateKeyHandler macht einen veröffentlichten Schlüssel zum Signaturschlüssel
+// Route: POST /admin/keys/{kid}/activate
+func (h *KeyHandlers) ActivateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	kid := chi.URLParam(r, "kid")
+
+	key, err := h.Keys.Activate(ctx, kid)
+	if err != nil {
+		h.writeRotationError(ctx, w, "JWT_KEY_ACTIVATE", kid, err)
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "JWT_KEY_ACTIVATE", logging.AuditSuccess, slog.String("kid", kid))
+	writeJSONResponse(w, key, http.StatusOK)
+}
+
+// RetireKeyHandler entfernt einen Schlüssel aus dem JWKS
+// Route: POST /admin/keys/{kid}/retire
+func (h *KeyHandlers) RetireKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	kid := chi.URLParam(r, "kid")
+
+	key, err := h.Keys.Retire(ctx, kid)
+	if err != nil {
+		h.writeRotationError(ctx, w, "JWT_KEY_RETIRE", kid, err)
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "JWT_KEY_RETIRE", logging.AuditSuccess, slog.String("kid", kid))
+	writeJSONResponse(w, key, http.StatusOK)
+}
+
+func (h *KeyHandlers) writeRotationError(ctx context.Context, w http.ResponseWriter, event, kid string, err error) {
+	logging.LogAuditEvent(ctx, event, logging.AuditFailure, slog.String("kid", kid), slog.String("reason", err.Error()))
+
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		writeJSONError(w, "Schlüssel nicht gefunden", http.StatusNotFound)
+	case errors.Is(err, auth.ErrRotationDisabled):
+		writeJSONError(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, auth.ErrInvalidKeyState),
+		errors.Is(err, auth.ErrRotationInProgress),
+		errors.Is(err, auth.ErrKeyNotPropagated),
+		errors.Is(err, auth.ErrKeyStillInUse),
+		errors.Is(err, auth.ErrConcurrentUpdate):
+		writeJSONError(w, err.Error(), http.StatusConflict)
+	default:
+		slog.ErrorContext(ctx, "Fehler bei der Schlüsselrotation", slog.String("event", event), slog.Any("error", err))
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"athena/internal/middleware"
 	"athena/internal/models"
 	"context"
-	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"log/slog"
@@ -23,19 +22,19 @@ type OTPHandlers struct {
 	ProjectRepo database.ProjectRepository
 	TokenRepo   database.TokenRepository
 	IssuerName string
-	PrivateKey      *rsa.PrivateKey
+	Signer      auth.Signer
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 }
 
 func NewOTPHandlers(repo database.UserRepository, projectRepo database.ProjectRepository,
-	tokenRepo database.TokenRepository, issuerName string, pk *rsa.PrivateKey, accessTTL, refreshTTL time.Duration) *OTPHandlers {
+	tokenRepo database.TokenRepository, issuerName string, signer auth.Signer, accessTTL, refreshTTL time.Duration) *OTPHandlers {
 	return &OTPHandlers{
 		UserRepo:        repo,
 		ProjectRepo: projectRepo,
 		TokenRepo:   tokenRepo,
 		IssuerName:  issuerName,
-		PrivateKey:      pk,
+		Signer:      signer,
 		AccessTokenTTL:  accessTTL,
 		RefreshTokenTTL: refreshTTL,
 	}
@@ -234,7 +233,7 @@ func (h *OTPHandlers) VerifyOTPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.issueTokensAndRespond(ctx, w, user, projectData.Roles)
+	h.issueTokensAndRespond(ctx, w, user, projectData.Roles, projectData.Plan, projectID)
 	
 	logging.LogAuditEvent(ctx, "OTP_VERIFY", logging.AuditSuccess)
 	
@@ -345,8 +344,8 @@ func (h *OTPHandlers) DisableOTPHandler(w http.ResponseWriter, r *http.Request)
 }
 
 
-func (h *OTPHandlers) issueTokensAndRespond(ctx context.Context, w http.ResponseWriter, user *models.User, roles []string) {
-	accessToken, err := auth.GenerateToken(user, roles, h.PrivateKey, h.AccessTokenTTL)
+func (h *OTPHandlers) issueTokensAndRespond(ctx context.Context, w http.ResponseWriter, user *models.User, roles []string, plan string, projectID string) {
+	accessToken, err := auth.GenerateToken(user, roles, plan, projectID, h.Signer, h.AccessTokenTTL)
 	if err != nil {
 		slog.ErrorContext(ctx, "Fehler beim Erstellen des Access JWT für Login", slog.String("user_id", user.ID.String()), slog.Any("error", err))
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
@@ -474,7 +473,7 @@ func (h *OTPHandlers) LoginOTPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.issueTokensAndRespond(ctx, w, user, projectData.Roles)
+	h.issueTokensAndRespond(ctx, w, user, projectData.Roles, projectData.Plan, projectID)
 	logging.LogAuditEvent(ctx, "AUTH_LOGIN_OTP", logging.AuditSuccess,
 		slog.String("email", req.Email),
 		slog.String("user_id", user.ID.String()),
@@ -568,7 +567,7 @@ func (h *OTPHandlers) LoginOTPStandaloneHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	h.issueTokensAndRespond(ctx, w, user, projectData.Roles)
+	h.issueTokensAndRespond(ctx, w, user, projectData.Roles, projectData.Plan, projectID)
 	logging.LogAuditEvent(ctx, "AUTH_LOGIN_OTP_STANDALONE", logging.AuditSuccess,
 		slog.String("email", req.Email),
 		slog.String("user_id", user.ID.String()),
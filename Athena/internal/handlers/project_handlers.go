@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"athena/internal/configsync"
 	"athena/internal/database"
 	"athena/internal/logging"
 	"athena/internal/middleware"
@@ -9,6 +10,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -21,6 +23,8 @@ type ProjectHandlers struct {
 	ProjectRepo database.ProjectRepository
 	RouteRepo   database.RouteRepository
 	UserRepo    database.UserRepository
+	Notifier    *configsync.Notifier // Weckt Aegis-Watcher bei Routen-/Host-Änderungen
+	CachePurger *configsync.CachePurger
 }
 
 // Konstruktor aktualisieren:
@@ -28,11 +32,15 @@ func NewProjectHandlers(
 	projectRepo database.ProjectRepository,
 	routeRepo database.RouteRepository,
 	userRepo database.UserRepository,
+	notifier *configsync.Notifier,
+	cachePurger *configsync.CachePurger,
 ) *ProjectHandlers {
 	return &ProjectHandlers{
 		ProjectRepo: projectRepo,
 		RouteRepo:   routeRepo,
 		UserRepo:    userRepo,
+		Notifier:    notifier,
+		CachePurger: cachePurger,
 	}
 }
 
@@ -220,6 +228,7 @@ func (h *ProjectHandlers) UpdateProjectSettingsHandler(w http.ResponseWriter, r
 		slog.String("new_name", projectToUpdate.Name),
 		slog.Bool("force_2fa", projectToUpdate.Force2FA),
 	)
+	h.Notifier.Notify() // Host kann sich geändert haben (Context Map)
 
 	writeJSONResponse(w, projectToUpdate, http.StatusOK)
 }
@@ -383,6 +392,7 @@ func (h *ProjectHandlers) CreateProjectRouteHandler(w http.ResponseWriter, r *ht
 		return
 	}
 	defer r.Body.Close()
+	req.Methods = models.NormalizeMethods(req.Methods)
 
 	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
 		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
@@ -391,16 +401,36 @@ func (h *ProjectHandlers) CreateProjectRouteHandler(w http.ResponseWriter, r *ht
 
 	// DTO zu Modell konvertieren
 	newRoute := models.NewProjectRoute(projectIDUUID, req.Path, req.TargetURL)
+	newRoute.Methods = req.Methods
+	newRoute.Priority = req.Priority
 	newRoute.RequiredRoles = req.RequiredRoles
+	newRoute.Upstreams = toUpstreamTargets(req.Upstreams)
+	if req.LoadBalancing != "" {
+		newRoute.LoadBalancing = req.LoadBalancing
+	}
+	if req.Protocol != "" {
+		newRoute.Protocol = req.Protocol
+	}
 	newRoute.CacheTTL = req.CacheTTL
 	newRoute.RateLimit = models.RateLimitConfig(req.RateLimit)
 	newRoute.CircuitBreaker = models.CircuitBreakerConfig(req.CircuitBreaker)
+	newRoute.HealthCheck = models.HealthCheckConfig(req.HealthCheck)
+	newRoute.Retry = models.RetryConfig(req.Retry)
+	newRoute.Transform = toTransformConfig(req.Transform)
+	newRoute.Streaming = models.StreamingConfig(req.Streaming)
+	newRoute.UpstreamTLS = models.UpstreamTLSConfig(req.UpstreamTLS)
+	newRoute.IPPolicy = models.IPPolicyConfig(req.IPPolicy)
+
+	if !h.checkRouteConflict(ctx, w, newRoute) {
+		return
+	}
 
 	if err := h.RouteRepo.CreateProjectRoute(ctx, newRoute); err != nil {
 		slog.ErrorContext(ctx, "Fehler beim Speichern der neuen Route", slog.Any("error", err))
 		writeJSONError(w, "Fehler beim Speichern (Pfad existiert vielleicht schon)", http.StatusInternalServerError)
 		return
 	}
+	h.Notifier.Notify()
 
 	writeJSONResponse(w, newRoute, http.StatusCreated)
 }
@@ -442,6 +472,16 @@ func (h *ProjectHandlers) GetProjectRoutesHandler(w http.ResponseWriter, r *http
 		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
 		return
 	}
+
+	// Apply-Status der Aegis-Instanzen anhängen (welche Routen sind tatsächlich live)
+	applyStatus, err := h.RouteRepo.GetRouteApplyStatus(ctx, projectIDUUID)
+	if err != nil {
+		slog.WarnContext(ctx, "Apply-Status der Routen konnte nicht geladen werden", slog.Any("error", err), slog.String("project_id", projectID))
+	} else {
+		for _, route := range routes {
+			route.ApplyStatus = applyStatus[route.ID]
+		}
+	}
 	
 	writeJSONResponse(w, routes, http.StatusOK)
 }
@@ -536,6 +576,7 @@ func (h *ProjectHandlers) GetProjectUsersHandler(w http.ResponseWriter, r *http.
 			CreatedAt: dbUser.CreatedAt,
 			UpdatedAt: dbUser.UpdatedAt,
 			Roles:     roles,
+			Plan:      dbUser.Plan,
 		})
 	}
 
@@ -701,6 +742,8 @@ func (h *ProjectHandlers) DeleteProjectRouteHandler(w http.ResponseWriter, r *ht
 		slog.String("project_id", projectID),
 		slog.String("route_id", routeIDStr),
 	)
+	h.Notifier.Notify()
+	h.CachePurger.PurgeRouteAsync(ctx, projectID, routeIDStr)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -742,6 +785,7 @@ func (h *ProjectHandlers) UpdateProjectRouteHandler(w http.ResponseWriter, r *ht
 		return
 	}
 	defer r.Body.Close()
+	req.Methods = models.NormalizeMethods(req.Methods)
 
 	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
 		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
@@ -757,11 +801,30 @@ func (h *ProjectHandlers) UpdateProjectRouteHandler(w http.ResponseWriter, r *ht
 
 	// 5. Felder aktualisieren
 	routeToUpdate.Path = req.Path
+	routeToUpdate.Methods = req.Methods
+	routeToUpdate.Priority = req.Priority
 	routeToUpdate.TargetURL = req.TargetURL
+	routeToUpdate.Upstreams = toUpstreamTargets(req.Upstreams)
+	routeToUpdate.LoadBalancing = req.LoadBalancing
+	routeToUpdate.Protocol = req.Protocol
 	routeToUpdate.RequiredRoles = req.RequiredRoles
 	routeToUpdate.CacheTTL = req.CacheTTL
 	routeToUpdate.RateLimit = models.RateLimitConfig(req.RateLimit)
 	routeToUpdate.CircuitBreaker = models.CircuitBreakerConfig(req.CircuitBreaker)
+	routeToUpdate.HealthCheck = models.HealthCheckConfig(req.HealthCheck)
+	routeToUpdate.Retry = models.RetryConfig(req.Retry)
+	routeToUpdate.Transform = toTransformConfig(req.Transform)
+	routeToUpdate.Streaming = models.StreamingConfig(req.Streaming)
+	routeToUpdate.UpstreamTLS = models.UpstreamTLSConfig(req.UpstreamTLS)
+	routeToUpdate.IPPolicy = models.IPPolicyConfig(req.IPPolicy)
+
+	if !h.checkRouteConflict(ctx, w, routeToUpdate) {
+		logging.LogAuditEvent(ctx, "PROJECT_ROUTE_UPDATE", logging.AuditFailure,
+			slog.String("reason", "route_conflict"),
+			slog.String("route_id", routeIDStr),
+		)
+		return
+	}
 
 	// 6. In DB speichern
 	if err := h.RouteRepo.UpdateProjectRoute(ctx, routeToUpdate); err != nil {
@@ -774,6 +837,123 @@ func (h *ProjectHandlers) UpdateProjectRouteHandler(w http.ResponseWriter, r *ht
 		slog.String("project_id", projectID),
 		slog.String("route_id", routeIDStr),
 	)
+	h.Notifier.Notify()
+	h.CachePurger.PurgeRouteAsync(ctx, projectID, routeIDStr)
 
 	writeJSONResponse(w, routeToUpdate, http.StatusOK)
-}
\ No newline at end of file
+}
+
+// checkRouteConflict lehnt Routen ab, die in Aegis mehrdeutig wären (gleicher Pfad,
+// gleiche Priority, überlappende Methoden). Aegis isoliert Projekt-Routen per Host,
+// daher wird nur innerhalb des Projekts geprüft.
+// Liefert false, wenn bereits eine Fehlerantwort geschrieben wurde.
+func (h *ProjectHandlers) checkRouteConflict(ctx context.Context, w http.ResponseWriter, route *models.ProjectRoute) bool {
+	existing, err := h.RouteRepo.GetProjectRoutes(ctx, route.ProjectID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Fehler bei der Konfliktprüfung der Route", slog.Any("error", err))
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return false
+	}
+
+	for _, other := range existing {
+		if !route.ConflictsWith(other) {
+			continue
+		}
+		slog.WarnContext(ctx, "Route kollidiert mit bestehender Route",
+			slog.String("path", route.Path),
+			slog.String("conflicting_route_id", other.ID.String()),
+		)
+		writeJSONError(w, fmt.Sprintf("Route kollidiert mit Route %s (gleicher Pfad, gleiche Priorität, überlappende Methoden)", other.ID), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// toUpstreamTargets konvertiert die Upstream-DTOs in das Modell (Gewicht 0 -> 1)
+func toUpstreamTargets(upstreams []RouteUpstreamConfig) []models.UpstreamTarget {
+	targets := make([]models.UpstreamTarget, 0, len(upstreams))
+	for _, u := range upstreams {
+		weight := u.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		targets = append(targets, models.UpstreamTarget{URL: u.URL, Weight: weight})
+	}
+	return targets
+}
+
+// toTransformConfig konvertiert die Transform-DTOs in das Modell
+func toTransformConfig(t RouteTransformConfig) models.TransformConfig {
+	cfg := models.TransformConfig{
+		RequestHeaders:  models.HeaderRulesConfig(t.RequestHeaders),
+		ResponseHeaders: models.HeaderRulesConfig(t.ResponseHeaders),
+		Query:           t.Query,
+	}
+	if t.PathRewrite != nil {
+		cfg.PathRewrite = &models.PathRewriteConfig{Pattern: t.PathRewrite.Pattern, Replacement: t.PathRewrite.Replacement}
+	}
+	return cfg
+}
+
+// PurgeProjectCacheHandler leert den Aegis-Cache des Projekts nach Route, Pfad-Präfix
+// oder Surrogate Keys (z.B. nach einem Deploy)
+// Route: POST /projects/{projectID}/cache/purge
+func (h *ProjectHandlers) PurgeProjectCacheHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isAdmin, err := h.checkProjectAdmin(ctx, adminUserID, projectID)
+	if err != nil || !isAdmin {
+		logging.LogAuditEvent(ctx, "PROJECT_CACHE_PURGE", logging.AuditFailure, slog.String("reason", "permission_denied"))
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	var req PurgeProjectCacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Ungültiger JSON Body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
+		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
+		return
+	}
+	if req.RouteID == "" && req.Prefix == "" && len(req.Tags) == 0 {
+		writeJSONError(w, "route_id, prefix oder tags erforderlich", http.StatusBadRequest)
+		return
+	}
+
+	purged, err := h.CachePurger.Purge(ctx, configsync.PurgeRequest{
+		ProjectID: projectID,
+		RouteID:   req.RouteID,
+		Prefix:    req.Prefix,
+		Tags:      req.Tags,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Cache-Purge fehlgeschlagen", slog.Any("error", err), slog.String("project_id", projectID))
+		logging.LogAuditEvent(ctx, "PROJECT_CACHE_PURGE", logging.AuditFailure, slog.String("reason", "gateway_error"))
+		if errors.Is(err, configsync.ErrPurgeDisabled) {
+			writeJSONError(w, "Cache-Purge ist nicht konfiguriert", http.StatusServiceUnavailable)
+		} else {
+			writeJSONError(w, "Cache-Purge in Aegis fehlgeschlagen", http.StatusBadGateway)
+		}
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "PROJECT_CACHE_PURGE", logging.AuditSuccess,
+		slog.String("project_id", projectID),
+		slog.String("route_id", req.RouteID),
+		slog.String("prefix", req.Prefix),
+		slog.Int("purged", purged),
+	)
+
+	writeJSONResponse(w, map[string]int{"purged": purged}, http.StatusOK)
+}
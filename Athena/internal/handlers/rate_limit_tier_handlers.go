@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"athena/internal/database"
+	"athena/internal/logging"
+	"athena/internal/middleware"
+	"athena/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetRateLimitTiersHandler listet die Rate-Limit-Tiers eines Projekts
+// Route: GET /projects/{projectID}/rate-limit-tiers
+func (h *ProjectHandlers) GetRateLimitTiersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isAdmin, err := h.checkProjectAdmin(ctx, adminUserID, projectID)
+	if err != nil || !isAdmin {
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	tiers, err := h.ProjectRepo.GetRateLimitTiers(ctx, projectID)
+	if err != nil {
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := h.rateLimitTiersResponse(ctx, projectID, tiers)
+	if err != nil {
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+	writeJSONResponse(w, resp, http.StatusOK)
+}
+
+// UpdateRateLimitTiersHandler ersetzt alle Tiers eines Projekts. Die Reihenfolge
+// bestimmt die Priorität; das Tier "anonymous" gilt für Aufrufer ohne Token.
+// Route: PUT /projects/{projectID}/rate-limit-tiers
+func (h *ProjectHandlers) UpdateRateLimitTiersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isAdmin, err := h.checkProjectAdmin(ctx, adminUserID, projectID)
+	if err != nil || !isAdmin {
+		logging.LogAuditEvent(ctx, "PROJECT_RATE_LIMIT_TIERS_UPDATE", logging.AuditFailure, slog.String("reason", "permission_denied"))
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	var req UpdateRateLimitTiersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Ungültiger JSON Body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
+		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
+		return
+	}
+
+	tiers := make([]models.RateLimitTier, 0, len(req.Tiers))
+	for _, t := range req.Tiers {
+		tiers = append(tiers, models.RateLimitTier(t))
+	}
+
+	if err := h.ProjectRepo.ReplaceRateLimitTiers(ctx, projectID, tiers); err != nil {
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "PROJECT_RATE_LIMIT_TIERS_UPDATE", logging.AuditSuccess,
+		slog.String("project_id", projectID),
+		slog.Int("tiers", len(tiers)),
+	)
+	h.Notifier.Notify()
+
+	resp, err := h.rateLimitTiersResponse(ctx, projectID, tiers)
+	if err != nil {
+		writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		return
+	}
+	if len(resp.RoutesWithoutLimit) > 0 {
+		slog.WarnContext(ctx, "Rate-Limit-Tiers greifen nicht auf Routen ohne eigenes Rate Limit",
+			slog.String("project_id", projectID), slog.Int("routes_without_limit", len(resp.RoutesWithoutLimit)))
+	}
+	writeJSONResponse(w, resp, http.StatusOK)
+}
+
+// rateLimitTiersResponse ergänzt die Tiers um die Routen des Projekts, auf die sie
+// nicht wirken (Aegis wendet Tiers nur auf Routen mit Rate Limit an)
+func (h *ProjectHandlers) rateLimitTiersResponse(ctx context.Context, projectID string, tiers []models.RateLimitTier) (*RateLimitTiersResponse, error) {
+	resp := &RateLimitTiersResponse{
+		Tiers:              make([]RateLimitTierConfig, 0, len(tiers)),
+		RoutesWithoutLimit: []RouteReference{},
+	}
+	for _, t := range tiers {
+		resp.Tiers = append(resp.Tiers, RateLimitTierConfig(t))
+	}
+	if len(tiers) == 0 {
+		return resp, nil
+	}
+
+	projectUUID, err := uuid.Parse(projectID)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := h.RouteRepo.GetProjectRoutes(ctx, projectUUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if route.RateLimit.Limit <= 0 {
+			resp.RoutesWithoutLimit = append(resp.RoutesWithoutLimit, RouteReference{ID: route.ID, Path: route.Path})
+		}
+	}
+	return resp, nil
+}
+
+// UpdateUserPlanHandler setzt den Plan eines Benutzers im Projekt.
+// Der Plan landet beim nächsten Login/Refresh im Access Token.
+// Route: PUT /projects/{projectID}/users/{userID}/plan
+func (h *ProjectHandlers) UpdateUserPlanHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminUserIDStr, ok := middleware.GetUserIDFromContext(ctx)
+	if !ok {
+		writeJSONError(w, "Benutzeridentifikation im Kontext fehlt", http.StatusInternalServerError)
+		return
+	}
+	adminUserID, _ := uuid.Parse(adminUserIDStr)
+	projectID := chi.URLParam(r, "projectID")
+
+	isOwner, err := h.checkProjectOwner(ctx, adminUserID, projectID)
+	if err != nil || !isOwner {
+		logging.LogAuditEvent(ctx, "PROJECT_USER_UPDATE_PLAN", logging.AuditFailure, slog.String("reason", "permission_denied"))
+		writeJSONError(w, "Zugriff verweigert", http.StatusForbidden)
+		return
+	}
+
+	targetUserIDStr := chi.URLParam(r, "userID")
+	targetUserID, err := uuid.Parse(targetUserIDStr)
+	if err != nil {
+		writeJSONError(w, "Ungültige Ziel-UserID in URL", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateUserPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Ungültiger JSON Body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if validationErrs := validateRequest(ctx, req); validationErrs != nil {
+		writeJSONResponse(w, validationErrs, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ProjectRepo.UpdateUserPlan(ctx, targetUserID, projectID, req.Plan); err != nil {
+		if errors.Is(err, database.ErrUserNotInProject) {
+			writeJSONError(w, "Benutzer nicht in diesem Projekt gefunden", http.StatusNotFound)
+		} else {
+			writeJSONError(w, "Interner Serverfehler", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logging.LogAuditEvent(ctx, "PROJECT_USER_UPDATE_PLAN", logging.AuditSuccess,
+		slog.String("project_id", projectID),
+		slog.String("target_user_id", targetUserIDStr),
+		slog.String("new_plan", req.Plan),
+	)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -6,7 +6,6 @@ import (
 	"athena/internal/logging"
 	"athena/internal/middleware"
 	"context"
-	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -19,19 +18,19 @@ type TokenHandlers struct {
 	UserRepo        database.UserRepository
 	ProjectRepo 	database.ProjectRepository
 	TokenRepo   	database.TokenRepository
-	PrivateKey      *rsa.PrivateKey
+	Signer          auth.Signer
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 }
 
 // Konstruktor für TokenHandlers
 func NewTokenHandlers(repo database.UserRepository, projectRepo database.ProjectRepository,
-	tokenRepo database.TokenRepository, pk *rsa.PrivateKey, accessTTL, refreshTTL time.Duration) *TokenHandlers {
+	tokenRepo database.TokenRepository, signer auth.Signer, accessTTL, refreshTTL time.Duration) *TokenHandlers {
 	return &TokenHandlers{
 		UserRepo:        repo,
 		ProjectRepo: projectRepo,
 		TokenRepo:   tokenRepo,
-		PrivateKey:      pk,
+		Signer:      signer,
 		AccessTokenTTL:  accessTTL,
 		RefreshTokenTTL: refreshTTL,
 	}
@@ -155,7 +154,7 @@ func (h *TokenHandlers) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newAccessToken, err := auth.GenerateToken(user, projectData.Roles, h.PrivateKey, h.AccessTokenTTL)
+	newAccessToken, err := auth.GenerateToken(user, projectData.Roles, projectData.Plan, req.ProjectID, h.Signer, h.AccessTokenTTL)
 	if err != nil {
 		slog.ErrorContext(ctx, "Fehler beim Erstellen des neuen Access Tokens bei Refresh", slog.String("user_id", user.ID.String()), slog.Any("error", err))
 		logging.LogAuditEvent(ctx, "AUTH_REFRESH", logging.AuditFailure,
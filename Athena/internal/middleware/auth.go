@@ -3,7 +3,6 @@ package middleware
 import (
 	"athena/internal/auth"
 	"context"
-	"crypto/rsa"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -17,7 +16,7 @@ type contextKey string
 const UserIDContextKey contextKey = "userID"
 const ProjectIDContextKey contextKey = "projectID"
 
-func Authenticator(publicKey *rsa.PublicKey) func(next http.Handler) http.Handler {
+func Authenticator(keys auth.KeyResolver) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -32,7 +31,10 @@ func Authenticator(publicKey *rsa.PublicKey) func(next http.Handler) http.Handle
 				if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 					return nil, fmt.Errorf("unerwarteter Signaturalgorithmus: %v", token.Header["alg"])
 				}
-				return publicKey, nil
+				// Schlüssel anhand der Key-ID wählen, damit Tokens aus
+				// der Rotationsphase (alter und neuer Schlüssel) gültig bleiben
+				kid, _ := token.Header["kid"].(string)
+				return keys.PublicKey(kid)
 			})
 
 			if err != nil {
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// IPPolicyConfig: Zugriff auf Netzwerkebene, den Aegis vor Rate Limit und Auth
+// prüft. Deny-Einträge haben Vorrang; sind Allow-Einträge gesetzt, muss die
+// Client-IP in einem der Netze oder Länder liegen. Länder sind ISO-3166-Codes
+// (z.B. "DE") und werden in Aegis über eine MaxMind-Datenbank aufgelöst.
+// Wird als JSON gespeichert, am Projekt und an der Route (beide müssen passen).
+type IPPolicyConfig struct {
+	AllowCIDRs     []string `json:"allow_cidrs,omitempty"`
+	DenyCIDRs      []string `json:"deny_cidrs,omitempty"`
+	AllowCountries []string `json:"allow_countries,omitempty"`
+	DenyCountries  []string `json:"deny_countries,omitempty"`
+}
+
+// Empty meldet, ob keine Regel gesetzt ist
+func (p IPPolicyConfig) Empty() bool {
+	return len(p.AllowCIDRs) == 0 && len(p.DenyCIDRs) == 0 && len(p.AllowCountries) == 0 && len(p.DenyCountries) == 0
+}
+
+// ParseIPPolicy liest die JSON-Spalte (leer = keine Regeln). Ungültiges JSON ist
+// ein Fehler: als leere Richtlinie würde es alle Clients zulassen.
+func ParseIPPolicy(value sql.NullString) (IPPolicyConfig, error) {
+	var policy IPPolicyConfig
+	if value.Valid && value.String != "" {
+		if err := json.Unmarshal([]byte(value.String), &policy); err != nil {
+			return IPPolicyConfig{}, fmt.Errorf("ungültige IP-Richtlinie in der Datenbank: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// NullString liefert den Wert für die JSON-Spalte (ohne Regeln NULL)
+func (p IPPolicyConfig) NullString() sql.NullString {
+	if p.Empty() {
+		return sql.NullString{String: "", Valid: false}
+	}
+	data, _ := json.Marshal(p)
+	return sql.NullString{String: string(data), Valid: true}
+}
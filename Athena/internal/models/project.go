@@ -13,6 +13,7 @@ type Project struct {
 	OwnerUserID uuid.UUID `db:"owner_user_id" json:"-"`
 	Force2FA   bool      `db:"force_2fa" json:"force_2fa"`
 	Host       sql.NullString `db:"host" json:"host,omitempty"`
+	IPPolicyJSON sql.NullString `db:"ip_policy" json:"-"` // IPPolicyConfig als JSON (siehe GetProjectIPPolicy)
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
 }
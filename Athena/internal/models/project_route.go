@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -10,25 +11,178 @@ import (
 
 
 type RateLimitConfig struct {
-	Limit  int    `json:"limit" db:"rate_limit_limit"`
-	Window string `json:"window" db:"rate_limit_window"`
+	Limit     int    `json:"limit" db:"rate_limit_limit"`
+	Window    string `json:"window" db:"rate_limit_window"`
+	Algorithm string `json:"algorithm" db:"rate_limit_algorithm"`
+	Burst     int    `json:"burst" db:"rate_limit_burst"` // Nur token_bucket, 0 = Limit
 }
 
+// Rate-Limit-Algorithmen (werden in Aegis per Lua-Skript in Redis umgesetzt)
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// CircuitBreakerConfig: der Breaker in Aegis öffnet bei FailureThreshold Fehlern im
+// Fenster oder ab MinRequests Requests bei ErrorThreshold Prozent Fehlern
 type CircuitBreakerConfig struct {
 	FailureThreshold int    `json:"failure_threshold" db:"cb_threshold"`
 	OpenTimeout      string `json:"open_timeout" db:"cb_timeout"`
+	Window           string `json:"window" db:"cb_window"`
+	ErrorThreshold   int    `json:"error_threshold" db:"cb_error_threshold"`
+	MinRequests      int    `json:"min_requests" db:"cb_min_requests"`
+	SlowCallDuration string `json:"slow_call_duration" db:"cb_slow_call"`
+	HalfOpenRequests int    `json:"half_open_requests" db:"cb_half_open_requests"`
+	Key              string `json:"key" db:"cb_key"` // "route" oder "upstream"
+}
+
+// RetryConfig: Aegis wiederholt fehlgeschlagene Upstream-Requests (nur idempotente
+// Methoden oder Requests mit Idempotency-Key). Wird als JSON gespeichert.
+type RetryConfig struct {
+	MaxAttempts         int      `json:"max_attempts"`
+	StatusCodes         []int    `json:"status_codes,omitempty"`
+	RetryOn             []string `json:"retry_on,omitempty"`
+	BackoffBase         string   `json:"backoff_base,omitempty"`
+	BackoffMax          string   `json:"backoff_max,omitempty"`
+	BudgetPercent       int      `json:"budget_percent,omitempty"`
+	MinRetriesPerSecond int      `json:"min_retries_per_second,omitempty"`
+}
+
+// StreamingConfig: WebSocket- und SSE-Routen. Aegis hebt für sie die Server-Timeouts
+// auf und schaltet den Cache ab. Wird als JSON gespeichert.
+type StreamingConfig struct {
+	Enabled          bool   `json:"enabled"`
+	IdleTimeout      string `json:"idle_timeout,omitempty"`
+	MaxDuration      string `json:"max_duration,omitempty"`
+	MaxConnections   int    `json:"max_connections,omitempty"`
+	TokenQueryParam  string `json:"token_query_param,omitempty"`
+	TokenSubprotocol bool   `json:"token_subprotocol,omitempty"`
+}
+
+// UpstreamTLSConfig: TLS zu den Upstreams (eigene CAs, Client-Zertifikat für mTLS).
+// Das Material liegt als Datei auf den Aegis-Hosts oder in Vault (KV v2, Felder
+// ca, cert, key). Wird als JSON gespeichert.
+type UpstreamTLSConfig struct {
+	CAFile     string `json:"ca_file,omitempty"`
+	CertFile   string `json:"cert_file,omitempty"`
+	KeyFile    string `json:"key_file,omitempty"`
+	VaultPath  string `json:"vault_path,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	MinVersion string `json:"min_version,omitempty"`
+}
+
+// HeaderRulesConfig ändert Header in der Reihenfolge Remove, Set, Add
+type HeaderRulesConfig struct {
+	Add    map[string]string `json:"add,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// PathRewriteConfig schreibt den vollständigen Pfad per Regex um (ersetzt das
+// automatische Entfernen des Routen-Präfixes in Aegis)
+type PathRewriteConfig struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// TransformConfig: Umschreibungen von Pfad, Headern und Query-Parametern zwischen
+// Client und Upstream. Wird als JSON gespeichert.
+type TransformConfig struct {
+	PathRewrite     *PathRewriteConfig `json:"path_rewrite,omitempty"`
+	RequestHeaders  HeaderRulesConfig  `json:"request_headers,omitempty"`
+	ResponseHeaders HeaderRulesConfig  `json:"response_headers,omitempty"`
+	Query           map[string]string  `json:"query,omitempty"`
+}
+
+// Empty meldet, ob keine Regel gesetzt ist
+func (t TransformConfig) Empty() bool {
+	return t.PathRewrite == nil && len(t.Query) == 0 &&
+		len(t.RequestHeaders.Add) == 0 && len(t.RequestHeaders.Set) == 0 && len(t.RequestHeaders.Remove) == 0 &&
+		len(t.ResponseHeaders.Add) == 0 && len(t.ResponseHeaders.Set) == 0 && len(t.ResponseHeaders.Remove) == 0
+}
+
+// HealthCheckConfig steuert das aktive Health Checking der Upstreams
+type HealthCheckConfig struct {
+	Path               string `json:"path" db:"hc_path"`
+	Interval           string `json:"interval" db:"hc_interval"`
+	Timeout            string `json:"timeout" db:"hc_timeout"`
+	HealthyThreshold   int    `json:"healthy_threshold" db:"hc_healthy_threshold"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold" db:"hc_unhealthy_threshold"`
+}
+
+// Load-Balancing-Strategien für Routen mit mehreren Upstreams
+const (
+	LBRoundRobin       = "round_robin"
+	LBLeastConnections = "least_connections"
+	LBRandomTwoChoices = "random_two_choices"
+)
+
+// Upstream-Protokolle einer Route
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc" // HTTP/2 mit Trailern, Pfad /paket.Service/Methode bleibt unverändert
+	ProtocolH2C  = "h2c"  // HTTP/2 ohne TLS zum Upstream
+)
+
+// UpstreamTarget ist eine Backend-Instanz einer Route mit Gewichtung
+type UpstreamTarget struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Apply-Status, den die Aegis-Instanzen pro Route zurückmelden
+const (
+	RouteStatusApplied  = "applied"
+	RouteStatusRejected = "rejected"
+)
+
+// RouteApplyStatus ist der von einer Aegis-Instanz gemeldete Zustand einer Route
+type RouteApplyStatus struct {
+	RouteID       uuid.UUID `json:"-" db:"route_id"`
+	InstanceID    string    `json:"instance_id" db:"instance_id"`
+	Status        string    `json:"status" db:"status"`
+	Reason        string    `json:"reason,omitempty" db:"reason"`
+	ConfigVersion string    `json:"config_version,omitempty" db:"config_version"`
+	ReportedAt    time.Time `json:"reported_at" db:"reported_at"`
 }
 
 type ProjectRoute struct {
 	ID            uuid.UUID            `json:"id" db:"id"`
 	ProjectID     uuid.UUID            `json:"project_id" db:"project_id"`
 	Path          string               `json:"path" db:"path"`
+	Methods       []string             `json:"methods"` // Leer = alle Methoden
+	MethodsString string               `json:"-" db:"methods"`
+	Priority      int                  `json:"priority" db:"priority"`
 	TargetURL     string               `json:"target_url" db:"target_url"`
+	Upstreams     []UpstreamTarget     `json:"upstreams"`
+	UpstreamsJSON sql.NullString       `json:"-" db:"upstreams"`
+	LoadBalancing string               `json:"load_balancing" db:"lb_strategy"`
+	Protocol      string               `json:"protocol" db:"protocol"`
 	RequiredRoles []string             `json:"required_roles"`
 	RolesString   sql.NullString       `json:"-" db:"required_roles"`
 	CacheTTL      string               `json:"cache_ttl" db:"cache_ttl"`
 	RateLimit     RateLimitConfig      `json:"rate_limit"`
 	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	HealthCheck    HealthCheckConfig    `json:"health_check"`
+	Retry          RetryConfig          `json:"retry"`
+	RetryJSON      sql.NullString       `json:"-" db:"retry_policy"`
+	Transform      TransformConfig      `json:"transform"`
+	TransformJSON  sql.NullString       `json:"-" db:"transform"`
+	Streaming      StreamingConfig      `json:"streaming"`
+	StreamingJSON  sql.NullString       `json:"-" db:"streaming"`
+	UpstreamTLS     UpstreamTLSConfig   `json:"upstream_tls"`
+	UpstreamTLSJSON sql.NullString      `json:"-" db:"upstream_tls"`
+	IPPolicy        IPPolicyConfig      `json:"ip_policy"`
+	IPPolicyJSON    sql.NullString      `json:"-" db:"ip_policy"`
+	IPPolicyErr     error               `json:"-" db:"-"` // Spalte ip_policy unlesbar: Gateway-Konfiguration wird nicht veröffentlicht
+
+	// Nur in der Gateway-Konfiguration befüllt (Tiers des Projekts, falls die Route limitiert ist)
+	RateLimitTiers []RateLimitTier `json:"rate_limit_tiers,omitempty" db:"-"`
+	// Nur in der Gateway-Konfiguration befüllt (IP-Richtlinie des Projekts, gilt zusätzlich)
+	ProjectIPPolicy *IPPolicyConfig `json:"project_ip_policy,omitempty" db:"-"`
+
+	// Nur in der Projekt-API befüllt, nicht Teil der Gateway-Konfiguration
+	ApplyStatus []RouteApplyStatus `json:"apply_status,omitempty" db:"-"`
 
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
@@ -40,6 +194,52 @@ func (pr *ProjectRoute) AfterGet() {
 	} else {
 		pr.RequiredRoles = []string{}
 	}
+
+	pr.Methods = []string{}
+	if pr.MethodsString != "" {
+		pr.Methods = strings.Split(pr.MethodsString, ",")
+	}
+
+	pr.Upstreams = []UpstreamTarget{}
+	if pr.UpstreamsJSON.Valid && pr.UpstreamsJSON.String != "" {
+		if err := json.Unmarshal([]byte(pr.UpstreamsJSON.String), &pr.Upstreams); err != nil {
+			pr.Upstreams = []UpstreamTarget{}
+		}
+	}
+	pr.Retry = RetryConfig{}
+	if pr.RetryJSON.Valid && pr.RetryJSON.String != "" {
+		if err := json.Unmarshal([]byte(pr.RetryJSON.String), &pr.Retry); err != nil {
+			pr.Retry = RetryConfig{}
+		}
+	}
+	pr.Transform = TransformConfig{}
+	if pr.TransformJSON.Valid && pr.TransformJSON.String != "" {
+		if err := json.Unmarshal([]byte(pr.TransformJSON.String), &pr.Transform); err != nil {
+			pr.Transform = TransformConfig{}
+		}
+	}
+	pr.Streaming = StreamingConfig{}
+	if pr.StreamingJSON.Valid && pr.StreamingJSON.String != "" {
+		if err := json.Unmarshal([]byte(pr.StreamingJSON.String), &pr.Streaming); err != nil {
+			pr.Streaming = StreamingConfig{}
+		}
+	}
+	pr.UpstreamTLS = UpstreamTLSConfig{}
+	if pr.UpstreamTLSJSON.Valid && pr.UpstreamTLSJSON.String != "" {
+		if err := json.Unmarshal([]byte(pr.UpstreamTLSJSON.String), &pr.UpstreamTLS); err != nil {
+			pr.UpstreamTLS = UpstreamTLSConfig{}
+		}
+	}
+	pr.IPPolicy, pr.IPPolicyErr = ParseIPPolicy(pr.IPPolicyJSON)
+	if pr.LoadBalancing == "" {
+		pr.LoadBalancing = LBRoundRobin
+	}
+	if pr.Protocol == "" {
+		pr.Protocol = ProtocolHTTP
+	}
+	if pr.RateLimit.Algorithm == "" {
+		pr.RateLimit.Algorithm = RateLimitTokenBucket
+	}
 }
 
 func (pr *ProjectRoute) BeforeSave() {
@@ -51,6 +251,56 @@ func (pr *ProjectRoute) BeforeSave() {
 	} else {
 		pr.RolesString = sql.NullString{String: "", Valid: false}
 	}
+
+	pr.Methods = NormalizeMethods(pr.Methods)
+	pr.MethodsString = strings.Join(pr.Methods, ",")
+
+	// Ohne explizite Upstreams bleibt TargetURL das einzige Ziel.
+	// Mit Upstreams spiegelt TargetURL das erste Ziel (Abwärtskompatibilität).
+	if len(pr.Upstreams) > 0 {
+		data, _ := json.Marshal(pr.Upstreams)
+		pr.UpstreamsJSON = sql.NullString{String: string(data), Valid: true}
+		if pr.TargetURL == "" {
+			pr.TargetURL = pr.Upstreams[0].URL
+		}
+	} else {
+		pr.UpstreamsJSON = sql.NullString{String: "", Valid: false}
+	}
+	// Ohne Wiederholungen (max_attempts <= 1) bleibt die Spalte leer
+	if pr.Retry.MaxAttempts > 1 {
+		data, _ := json.Marshal(pr.Retry)
+		pr.RetryJSON = sql.NullString{String: string(data), Valid: true}
+	} else {
+		pr.RetryJSON = sql.NullString{String: "", Valid: false}
+	}
+	if !pr.Transform.Empty() {
+		data, _ := json.Marshal(pr.Transform)
+		pr.TransformJSON = sql.NullString{String: string(data), Valid: true}
+	} else {
+		pr.TransformJSON = sql.NullString{String: "", Valid: false}
+	}
+	if pr.Streaming.Enabled {
+		data, _ := json.Marshal(pr.Streaming)
+		pr.StreamingJSON = sql.NullString{String: string(data), Valid: true}
+	} else {
+		pr.StreamingJSON = sql.NullString{String: "", Valid: false}
+	}
+	if pr.UpstreamTLS != (UpstreamTLSConfig{}) {
+		data, _ := json.Marshal(pr.UpstreamTLS)
+		pr.UpstreamTLSJSON = sql.NullString{String: string(data), Valid: true}
+	} else {
+		pr.UpstreamTLSJSON = sql.NullString{String: "", Valid: false}
+	}
+	pr.IPPolicyJSON = pr.IPPolicy.NullString()
+	if pr.LoadBalancing == "" {
+		pr.LoadBalancing = LBRoundRobin
+	}
+	if pr.Protocol == "" {
+		pr.Protocol = ProtocolHTTP
+	}
+	if pr.RateLimit.Algorithm == "" {
+		pr.RateLimit.Algorithm = RateLimitTokenBucket
+	}
 }
 
 func NewProjectRoute(projectID uuid.UUID, path, targetURL string) *ProjectRoute {
@@ -61,8 +311,13 @@ func NewProjectRoute(projectID uuid.UUID, path, targetURL string) *ProjectRoute
 		Path:          path,
 		TargetURL:     targetURL,
 		RequiredRoles: []string{},
-		RateLimit:     RateLimitConfig{Limit: 0, Window: "0s"},
+		Methods:       []string{},
+		Upstreams:     []UpstreamTarget{},
+		LoadBalancing: LBRoundRobin,
+		Protocol:      ProtocolHTTP,
+		RateLimit:     RateLimitConfig{Limit: 0, Window: "0s", Algorithm: RateLimitTokenBucket},
 		CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 0, OpenTimeout: "0s"},
+		HealthCheck:   HealthCheckConfig{Interval: "0s", Timeout: "0s"},
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
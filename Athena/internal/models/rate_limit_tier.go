@@ -0,0 +1,16 @@
+package models
+
+// AnonymousRateLimitTier gilt für nicht authentifizierte Aufrufer (Zähler pro IP)
+const AnonymousRateLimitTier = "anonymous"
+
+// RateLimitTier ist ein benanntes Limit eines Projekts. Aegis wählt pro Request
+// das erste Tier (in Reihenfolge), dessen Rollen oder Pläne zum Aufrufer passen;
+// ohne Treffer gilt das Limit der Route.
+type RateLimitTier struct {
+	Name   string   `json:"name"`
+	Limit  int      `json:"limit"`
+	Window string   `json:"window"`
+	Burst  int      `json:"burst"`
+	Roles  []string `json:"roles"`
+	Plans  []string `json:"plans"`
+}
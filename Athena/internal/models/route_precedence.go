@@ -0,0 +1,42 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Präzedenz in Aegis: exakter Pfad vor Präfix ("/*"), längster Präfix gewinnt.
+// Bei gleichem Pfad entscheidet Priority (höher gewinnt), dann explizite Methoden
+// vor "alle Methoden". Gleicher Pfad, gleiche Priority und überlappende Methoden
+// sind innerhalb eines Projekts mehrdeutig und werden beim Speichern abgelehnt.
+// Zwischen Projekten gibt es keine Konflikte: Aegis routet pro Host/Projekt.
+
+// NormalizeMethods liefert die Methoden in Großbuchstaben ohne Duplikate
+func NormalizeMethods(methods []string) []string {
+	normalized := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != "" && !slices.Contains(normalized, m) {
+			normalized = append(normalized, m)
+		}
+	}
+	return normalized
+}
+
+// ConflictsWith prüft, ob beide Routen denselben Request gleichrangig beanspruchen
+func (pr *ProjectRoute) ConflictsWith(other *ProjectRoute) bool {
+	if pr.ID == other.ID || pr.ProjectID != other.ProjectID || pr.Path != other.Path || pr.Priority != other.Priority {
+		return false
+	}
+	a, b := NormalizeMethods(pr.Methods), NormalizeMethods(other.Methods)
+	// "Alle Methoden" ist gegenüber expliziten Methoden nur der Fallback
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == 0 && len(b) == 0
+	}
+	for _, m := range a {
+		if slices.Contains(b, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,7 @@ type UserProjectData struct {
 	UserID     uuid.UUID      `db:"user_id"`
 	ProjectID  string         `db:"project_id"`
 	Roles      []string       `db:"roles"`
+	Plan       string         `db:"plan"` // Für Rate-Limit-Tiers, landet im JWT
 	OTPEnabled bool           `db:"otp_enabled"`
 	OTPSecret  sql.NullString `db:"otp_secret"`
 	OTPAuthURL sql.NullString `db:"otp_auth_url"`
@@ -1,11 +1,12 @@
 package router
 
 import (
+	"athena/internal/auth"
 	"athena/internal/config"
+	"athena/internal/configsync"
 	"athena/internal/database"
 	"athena/internal/handlers"
 	"athena/internal/middleware"
-	"crypto/rsa"
 	"net/http"
 	"time"
 
@@ -20,20 +21,22 @@ type HandlerDependencies struct {
 	RouteRepo   database.RouteRepository
 	TokenRepo   database.TokenRepository
 	DBPinger    database.DBPinger
-	PrivateKey  *rsa.PrivateKey
-	PublicKey   *rsa.PublicKey
+	Keys        *auth.KeyManager
+	Notifier    *configsync.Notifier
+	CachePurger *configsync.CachePurger
 	Config      *config.Config
 	AdminHandlers *handlers.AdminHandlers
 }
 
 func SetupRouter(deps HandlerDependencies) http.Handler {
 	// Handler initialisieren
-	authHandlers := handlers.NewAuthHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.PrivateKey, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL, deps.Config,)
-	otpHandlers := handlers.NewOTPHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.Config.OTPIssuerName, deps.PrivateKey, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL)
-	tokenHandlers := handlers.NewTokenHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.PrivateKey, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL)
+	authHandlers := handlers.NewAuthHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.Keys, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL, deps.Config,)
+	otpHandlers := handlers.NewOTPHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.Config.OTPIssuerName, deps.Keys, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL)
+	tokenHandlers := handlers.NewTokenHandlers(deps.UserRepo, deps.ProjectRepo, deps.TokenRepo, deps.Keys, deps.Config.JWTAccessTokenTTL, deps.Config.JWTRefreshTokenTTL)
 	userHandler := handlers.NewUserHandlers(deps.UserRepo, deps.ProjectRepo)
-	projectHandlers := handlers.NewProjectHandlers(deps.ProjectRepo, deps.RouteRepo, deps.UserRepo) // (Mit der Korrektur aus Schritt 3)
-	internalHandlers := handlers.NewInternalHandlers(deps.RouteRepo, deps.ProjectRepo)
+	projectHandlers := handlers.NewProjectHandlers(deps.ProjectRepo, deps.RouteRepo, deps.UserRepo, deps.Notifier, deps.CachePurger) // (Mit der Korrektur aus Schritt 3)
+	internalHandlers := handlers.NewInternalHandlers(deps.RouteRepo, deps.ProjectRepo, deps.Notifier)
+	keyHandlers := handlers.NewKeyHandlers(deps.Keys)
 
 	r := chi.NewRouter()
 
@@ -51,6 +54,7 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
 		w.Write([]byte("Auth Service ist online!"))
 	})
 	r.Get("/health", handlers.HealthCheckHandler(deps.DBPinger))
+	r.Get("/.well-known/jwks.json", handlers.JWKSHandler(deps.Keys))
 
 	// --- Routen-Definitionen ---
 	r.Route("/auth", func(r chi.Router) {
@@ -71,14 +75,14 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
 	})
 
 	r.Route("/users", func(r chi.Router) {
-		r.Use(middleware.Authenticator(deps.PublicKey))
+		r.Use(middleware.Authenticator(deps.Keys))
 		r.Use(middleware.ProjectIDValidator)
 		r.Get("/me", userHandler.GetCurrentUserHandler)
 		r.Put("/me", userHandler.UpdateCurrentUserHandler)
 	})
 
 	r.Route("/auth/otp", func(r chi.Router) {
-		r.Use(middleware.Authenticator(deps.PublicKey))
+		r.Use(middleware.Authenticator(deps.Keys))
 		r.Use(middleware.ProjectIDValidator)
 
 		r.Post("/setup", otpHandlers.SetupOTPHandler)
@@ -87,7 +91,7 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
 	})
 
 	r.Route("/admin/otp", func(r chi.Router) {
-        r.Use(middleware.Authenticator(deps.PublicKey))
+        r.Use(middleware.Authenticator(deps.Keys))
         r.Use(deps.AdminHandlers.OnlyAdmin) // Sichert alle Routen in dieser Gruppe
 
 		r.Get("/profile", deps.AdminHandlers.GetGlobalProfileHandler)
@@ -97,8 +101,19 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
         r.Post("/disable", deps.AdminHandlers.DisableGlobalOTPHandler)
     })
 
+	// Schlüsselrotation: publish -> (Propagation abwarten) -> activate -> retire
+	r.Route("/admin/keys", func(r chi.Router) {
+		r.Use(middleware.Authenticator(deps.Keys))
+		r.Use(deps.AdminHandlers.OnlyAdmin)
+
+		r.Get("/", keyHandlers.GetKeyStatusHandler)
+		r.Post("/", keyHandlers.PublishKeyHandler)
+		r.Post("/{kid}/activate", keyHandlers.ActivateKeyHandler)
+		r.Post("/{kid}/retire", keyHandlers.RetireKeyHandler)
+	})
+
 	r.Route("/projects", func(r chi.Router) {
-		r.Use(middleware.Authenticator(deps.PublicKey))
+		r.Use(middleware.Authenticator(deps.Keys))
 		r.Post("/", projectHandlers.CreateProjectHandler)
 		r.Get("/", projectHandlers.GetMyProjectsHandler)
 
@@ -111,6 +126,15 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
 			r.Get("/users", projectHandlers.GetProjectUsersHandler)
 			r.Put("/users/{userID}", projectHandlers.UpdateUserRolesHandler)
 			r.Delete("/users/{userID}", projectHandlers.RemoveUserFromProjectHandler)
+			r.Put("/users/{userID}/plan", projectHandlers.UpdateUserPlanHandler)
+
+			// Rate-Limit-Tiers
+			r.Get("/rate-limit-tiers", projectHandlers.GetRateLimitTiersHandler)
+			r.Put("/rate-limit-tiers", projectHandlers.UpdateRateLimitTiersHandler)
+
+			// IP-Richtlinie (gilt für alle Routen des Projekts)
+			r.Get("/ip-policy", projectHandlers.GetIPPolicyHandler)
+			r.Put("/ip-policy", projectHandlers.UpdateIPPolicyHandler)
 
 			// Routen-Management
 			r.Post("/routes", projectHandlers.CreateProjectRouteHandler)
@@ -118,13 +142,18 @@ func SetupRouter(deps HandlerDependencies) http.Handler {
 			
 			r.Delete("/routes/{routeID}", projectHandlers.DeleteProjectRouteHandler)
 			r.Put("/routes/{routeID}", projectHandlers.UpdateProjectRouteHandler)
+
+			// Cache in Aegis
+			r.Post("/cache/purge", projectHandlers.PurgeProjectCacheHandler)
 		})
 	})
 
 	r.Route("/internal/v1", func(r chi.Router) {
 		r.Use(middleware.InternalAuth)
 		r.Get("/routes/config", internalHandlers.GetAllRoutesConfigHandler)
+		r.Post("/routes/status", internalHandlers.ReportRouteStatusHandler)
 		r.Get("/context-map", internalHandlers.GetContextMapHandler)
+		r.Get("/config/watch", internalHandlers.WatchConfigHandler) // Long-Poll für Aegis
 	})
 
 	return r
@@ -0,0 +1,3 @@
+alter table project_routes
+    drop column `upstreams`,
+    drop column `lb_strategy`;
\ No newline at end of file
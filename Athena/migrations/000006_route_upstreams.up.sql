@@ -0,0 +1,3 @@
+alter table project_routes
+    add column `upstreams` text null default null,
+    add column `lb_strategy` varchar(50) not null default 'round_robin';
\ No newline at end of file
@@ -0,0 +1,6 @@
+alter table project_routes
+    drop column `hc_path`,
+    drop column `hc_interval`,
+    drop column `hc_timeout`,
+    drop column `hc_healthy_threshold`,
+    drop column `hc_unhealthy_threshold`;
\ No newline at end of file
@@ -0,0 +1,6 @@
+alter table project_routes
+    add column `hc_path` varchar(256) not null default '',
+    add column `hc_interval` varchar(50) not null default '0s',
+    add column `hc_timeout` varchar(50) not null default '0s',
+    add column `hc_healthy_threshold` int not null default 0,
+    add column `hc_unhealthy_threshold` int not null default 0;
\ No newline at end of file
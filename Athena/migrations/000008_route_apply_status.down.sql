@@ -0,0 +1 @@
+drop table if exists route_apply_status;
@@ -0,0 +1,16 @@
+create table route_apply_status (
+    `route_id` varchar(36) not null,
+    `instance_id` varchar(128) not null,
+    `status` varchar(20) not null,
+    `reason` text not null,
+    `config_version` varchar(64) not null default '',
+    `reported_at` timestamp not null default current_timestamp,
+
+    primary key (`route_id`, `instance_id`),
+
+    constraint `fk_route_apply_status_route_id`
+        foreign key (`route_id`)
+        references `project_routes` (`id`)
+        on delete cascade
+        on update cascade
+);
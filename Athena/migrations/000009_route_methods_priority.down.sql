@@ -0,0 +1,3 @@
+alter table project_routes
+    drop column `methods`,
+    drop column `priority`;
@@ -0,0 +1,3 @@
+alter table project_routes
+    add column `methods` varchar(128) not null default '',
+    add column `priority` int not null default 0;
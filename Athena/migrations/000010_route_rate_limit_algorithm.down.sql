@@ -0,0 +1,3 @@
+alter table project_routes
+    drop column `rate_limit_algorithm`,
+    drop column `rate_limit_burst`;
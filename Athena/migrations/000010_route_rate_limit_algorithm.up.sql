@@ -0,0 +1,3 @@
+alter table project_routes
+    add column `rate_limit_algorithm` varchar(50) not null default 'token_bucket',
+    add column `rate_limit_burst` int not null default 0;
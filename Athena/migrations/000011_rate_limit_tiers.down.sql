@@ -0,0 +1,4 @@
+alter table user_projects
+    drop column `plan`;
+
+drop table if exists project_rate_limit_tiers;
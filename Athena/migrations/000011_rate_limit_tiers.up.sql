@@ -0,0 +1,21 @@
+create table project_rate_limit_tiers (
+    `project_id` varchar(36) not null,
+    `name` varchar(64) not null,
+    `position` int not null default 0,
+    `rate_limit` int not null,
+    `rate_window` varchar(50) not null,
+    `burst` int not null default 0,
+    `roles` text null default null,
+    `plans` text null default null,
+
+    primary key (`project_id`, `name`),
+
+    constraint `fk_rate_limit_tiers_project`
+        foreign key (`project_id`)
+        references `projects` (`id`)
+        on delete cascade
+        on update cascade
+);
+
+alter table user_projects
+    add column `plan` varchar(64) not null default '';
@@ -0,0 +1,7 @@
+alter table project_routes
+    drop column `cb_window`,
+    drop column `cb_error_threshold`,
+    drop column `cb_min_requests`,
+    drop column `cb_slow_call`,
+    drop column `cb_half_open_requests`,
+    drop column `cb_key`;
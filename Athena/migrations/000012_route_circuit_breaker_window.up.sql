@@ -0,0 +1,7 @@
+alter table project_routes
+    add column `cb_window` varchar(32) not null default '',
+    add column `cb_error_threshold` int not null default 0,
+    add column `cb_min_requests` int not null default 0,
+    add column `cb_slow_call` varchar(32) not null default '',
+    add column `cb_half_open_requests` int not null default 0,
+    add column `cb_key` varchar(16) not null default 'route';
@@ -0,0 +1,2 @@
+alter table project_routes
+    add column `streaming` text null;
@@ -0,0 +1,2 @@
+alter table project_routes
+    add column `protocol` varchar(16) not null default 'http';
@@ -0,0 +1,2 @@
+alter table project_routes
+    drop column `upstream_tls`;
@@ -0,0 +1,2 @@
+alter table project_routes
+    add column `upstream_tls` text null;
@@ -0,0 +1,4 @@
+alter table project_routes
+    drop column `ip_policy`;
+alter table projects
+    drop column `ip_policy`;
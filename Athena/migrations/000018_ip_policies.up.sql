@@ -0,0 +1,4 @@
+alter table projects
+    add column `ip_policy` text null;
+alter table project_routes
+    add column `ip_policy` text null;